@@ -0,0 +1,84 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ProviderConfigLabel is set on catalog objects mirrored from OpenStack to the
+// name of the ProviderConfig they were listed with.
+const ProviderConfigLabel = "infrastructure.cloudprovider.io/provider-config"
+
+// FlavorSpec mirrors a Nova flavor. It is written by the operator and any
+// manual change is overwritten on the next catalog refresh.
+type FlavorSpec struct {
+	ProviderConfigName string `json:"providerConfigName"`
+	// ID is the OpenStack flavor ID.
+	ID string `json:"id"`
+	// Name is the OpenStack flavor name, as used in InstanceStack.spec.flavorName.
+	Name string `json:"name"`
+
+	VCPUs   int32 `json:"vcpus"`
+	RAMMiB  int32 `json:"ramMiB"`
+	DiskGiB int32 `json:"diskGiB"`
+	// +optional
+	IsPublic bool `json:"isPublic,omitempty"`
+	// Properties are the flavor extra specs.
+	// +optional
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+// FlavorStatus defines the observed state of Flavor
+type FlavorStatus struct {
+	// LastSyncTime is when the flavor was last seen in the OpenStack catalog.
+	// It is rewritten at most hourly while the flavor does not change.
+	// +optional
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Flavor",type=string,JSONPath=`.spec.name`
+// +kubebuilder:printcolumn:name="Provider",type=string,JSONPath=`.spec.providerConfigName`
+// +kubebuilder:printcolumn:name="vCPUs",type=integer,JSONPath=`.spec.vcpus`
+// +kubebuilder:printcolumn:name="RAM(MiB)",type=integer,JSONPath=`.spec.ramMiB`
+// +kubebuilder:printcolumn:name="Disk(GiB)",type=integer,JSONPath=`.spec.diskGiB`
+// +kubebuilder:printcolumn:name="Public",type=boolean,JSONPath=`.spec.isPublic`
+
+// Flavor is a read-only mirror of an OpenStack flavor
+type Flavor struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   FlavorSpec   `json:"spec,omitempty"`
+	Status FlavorStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// FlavorList contains a list of Flavor
+type FlavorList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Flavor `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Flavor{}, &FlavorList{})
+}
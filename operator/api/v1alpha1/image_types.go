@@ -0,0 +1,84 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ImageSpec mirrors a Glance image. It is written by the operator and any
+// manual change is overwritten on the next catalog refresh.
+type ImageSpec struct {
+	ProviderConfigName string `json:"providerConfigName"`
+	// ID is the OpenStack image ID.
+	ID string `json:"id"`
+	// Name is the OpenStack image name, as used in InstanceStack.spec.imageName.
+	Name string `json:"name"`
+
+	// +optional
+	Visibility string `json:"visibility,omitempty"`
+	// +optional
+	MinDiskGiB int32 `json:"minDiskGiB,omitempty"`
+	// +optional
+	MinRAMMiB int32 `json:"minRAMMiB,omitempty"`
+	// +optional
+	SizeBytes int64 `json:"sizeBytes,omitempty"`
+	// Properties are the custom image properties such as os_distro.
+	// +optional
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+// ImageStatus defines the observed state of Image
+type ImageStatus struct {
+	// Status is the Glance image status, e.g. "active".
+	// +optional
+	Status string `json:"status,omitempty"`
+	// LastSyncTime is when the image was last seen in the OpenStack catalog.
+	// It is rewritten at most hourly while the image does not change.
+	// +optional
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Image",type=string,JSONPath=`.spec.name`
+// +kubebuilder:printcolumn:name="Provider",type=string,JSONPath=`.spec.providerConfigName`
+// +kubebuilder:printcolumn:name="Visibility",type=string,JSONPath=`.spec.visibility`
+// +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.status`
+
+// Image is a read-only mirror of an OpenStack image
+type Image struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ImageSpec   `json:"spec,omitempty"`
+	Status ImageStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ImageList contains a list of Image
+type ImageList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Image `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Image{}, &ImageList{})
+}
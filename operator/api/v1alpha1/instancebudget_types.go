@@ -0,0 +1,113 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// InstanceBudgetSpec limits what the InstanceStacks of a namespace may
+// consume. Unset limits are unlimited.
+type InstanceBudgetSpec struct {
+	// Instances is the maximum number of servers.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Instances *int64 `json:"instances,omitempty"`
+
+	// VCPUs is the maximum total vCPUs of the servers' flavors.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	VCPUs *int64 `json:"vcpus,omitempty"`
+
+	// RAMMiB is the maximum total RAM of the servers' flavors.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	RAMMiB *int64 `json:"ramMiB,omitempty"`
+
+	// VolumeGiB is the maximum total size of volumes.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	VolumeGiB *int64 `json:"volumeGiB,omitempty"`
+}
+
+// BudgetUsage is what InstanceStacks consume, computed from their flavors and
+// volume sizes.
+type BudgetUsage struct {
+	Instances int64 `json:"instances"`
+	VCPUs     int64 `json:"vcpus"`
+	RAMMiB    int64 `json:"ramMiB"`
+	VolumeGiB int64 `json:"volumeGiB"`
+}
+
+// InstanceBudgetStatus defines the observed state of InstanceBudget
+type InstanceBudgetStatus struct {
+	// Used is the current consumption of the namespace.
+	// +optional
+	Used BudgetUsage `json:"used,omitempty"`
+
+	// UnknownFlavors lists flavors missing from the catalog and EC2 instance
+	// types, whose size is not known; servers using them count as instances
+	// only.
+	// +optional
+	UnknownFlavors []string `json:"unknownFlavors,omitempty"`
+
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// Condition types and reasons reported on InstanceBudget and InstanceStack.
+const (
+	// ConditionBudgetExceeded is True on an InstanceBudget whose usage is
+	// over a limit, and on an InstanceStack blocked by a budget.
+	ConditionBudgetExceeded = "BudgetExceeded"
+	ReasonOverBudget        = "OverBudget"
+	ReasonWithinBudget      = "WithinBudget"
+)
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Instances",type=integer,JSONPath=`.status.used.instances`
+// +kubebuilder:printcolumn:name="vCPUs",type=integer,JSONPath=`.status.used.vcpus`
+// +kubebuilder:printcolumn:name="RAM MiB",type=integer,JSONPath=`.status.used.ramMiB`
+// +kubebuilder:printcolumn:name="Volume GiB",type=integer,JSONPath=`.status.used.volumeGiB`
+// +kubebuilder:printcolumn:name="Exceeded",type=string,JSONPath=`.status.conditions[?(@.type=="BudgetExceeded")].status`
+
+// InstanceBudget is the Schema for the instancebudgets API. It caps the
+// instances, vCPUs, RAM and volume size of the InstanceStacks in its
+// namespace.
+type InstanceBudget struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   InstanceBudgetSpec   `json:"spec,omitempty"`
+	Status InstanceBudgetStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// InstanceBudgetList contains a list of InstanceBudget
+type InstanceBudgetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []InstanceBudget `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&InstanceBudget{}, &InstanceBudgetList{})
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// InstanceSetLabel is set on the InstanceStacks of an InstanceSet to its
+// name.
+const InstanceSetLabel = "infrastructure.cloudprovider.io/instance-set"
+
+// PlacementAnnotation records the placement target of an InstanceStack of an
+// InstanceSet as "<providerConfigName>/<region>".
+const PlacementAnnotation = "infrastructure.cloudprovider.io/placement"
+
+// InstanceSetSpec defines the desired state of InstanceSet.
+type InstanceSetSpec struct {
+	// Replicas is the number of InstanceStacks to keep.
+	// +kubebuilder:validation:Minimum=0
+	Replicas int32 `json:"replicas"`
+
+	// Template is the InstanceStack every replica is created from. The
+	// placement policy overrides its providerConfigName and region.
+	Template InstanceStackTemplate `json:"template"`
+
+	// Placement spreads the replicas across regions and clouds. Without it
+	// every replica uses the providerConfigName and region of the template.
+	// +optional
+	Placement *PlacementPolicy `json:"placement,omitempty"`
+}
+
+// InstanceStackTemplate describes the InstanceStacks of an InstanceSet.
+type InstanceStackTemplate struct {
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	Spec InstanceStackSpec `json:"spec"`
+}
+
+// PlacementStrategy decides which target a new replica is placed in.
+// +kubebuilder:validation:Enum=Spread;Fill
+type PlacementStrategy string
+
+const (
+	// PlacementSpread places each new replica in the target with the fewest
+	// replicas, the first one on ties.
+	PlacementSpread PlacementStrategy = "Spread"
+	// PlacementFill fills the targets in order up to their maxReplicas.
+	PlacementFill PlacementStrategy = "Fill"
+)
+
+// PlacementPolicy lists where the replicas of an InstanceSet may run.
+type PlacementPolicy struct {
+	// +kubebuilder:default=Spread
+	// +optional
+	Strategy PlacementStrategy `json:"strategy,omitempty"`
+
+	// +kubebuilder:validation:MinItems=1
+	Targets []PlacementTarget `json:"targets"`
+}
+
+// PlacementTarget is a region of a cloud.
+type PlacementTarget struct {
+	// ProviderConfigName selects the cloud. Defaults to the one of the
+	// template.
+	// +optional
+	ProviderConfigName string `json:"providerConfigName,omitempty"`
+
+	// Region is a region of the ProviderConfig. Defaults to its primary
+	// region.
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// MaxReplicas caps the replicas placed in the target. Zero means no cap.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxReplicas int32 `json:"maxReplicas,omitempty"`
+}
+
+// InstanceSetStatus defines the observed state of InstanceSet.
+type InstanceSetStatus struct {
+	// Replicas is the number of InstanceStacks of the set.
+	// +optional
+	Replicas int32 `json:"replicas"`
+	// ReadyReplicas is the number of them that are Ready.
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas"`
+
+	// Placements show where each replica landed.
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Placements []ReplicaPlacement `json:"placements,omitempty"`
+
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// ReplicaPlacement is the placement of one InstanceStack of a set.
+type ReplicaPlacement struct {
+	// Name is the name of the InstanceStack.
+	Name               string `json:"name"`
+	ProviderConfigName string `json:"providerConfigName,omitempty"`
+	// Region is the region the InstanceStack was applied in, or the one it
+	// selects until then.
+	// +optional
+	Region string `json:"region,omitempty"`
+	Ready  bool   `json:"ready"`
+}
+
+// Condition reasons reported on InstanceSet.
+const (
+	ReasonReplicasReady    = "ReplicasReady"
+	ReasonReplicasNotReady = "ReplicasNotReady"
+	// ReasonNoPlacement is set on Ready while replicas cannot be placed
+	// because every target is at its maxReplicas.
+	ReasonNoPlacement = "NoPlacement"
+	// ReasonScaleDownBlocked is set on Ready while replicas to be removed are
+	// kept by the admission webhook, e.g. because of deletion protection.
+	ReasonScaleDownBlocked = "ScaleDownBlocked"
+)
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:subresource:scale:specpath=.spec.replicas,statuspath=.status.replicas
+// +kubebuilder:printcolumn:name="Desired",type=integer,JSONPath=`.spec.replicas`
+// +kubebuilder:printcolumn:name="Current",type=integer,JSONPath=`.status.replicas`
+// +kubebuilder:printcolumn:name="Ready",type=integer,JSONPath=`.status.readyReplicas`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// InstanceSet keeps a number of InstanceStacks from a template, placed across
+// regions and clouds by a placement policy.
+type InstanceSet struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   InstanceSetSpec   `json:"spec,omitempty"`
+	Status InstanceSetStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// InstanceSetList contains a list of InstanceSet
+type InstanceSetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []InstanceSet `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&InstanceSet{}, &InstanceSetList{})
+}
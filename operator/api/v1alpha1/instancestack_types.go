@@ -1,22 +1,656 @@
 package v1alpha1
 
 import (
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+const (
+	// AllowReplacementAnnotation permits changes to fields that force the
+	// OpenStack server to be replaced when set to "true".
+	AllowReplacementAnnotation = "infrastructure.cloudprovider.io/allow-replacement"
+
+	// Namespace annotations holding InstanceStack defaults. They take
+	// precedence over the defaults of the referenced ProviderConfig.
+	DefaultFlavorNameAnnotation  = "infrastructure.cloudprovider.io/default-flavor-name"
+	DefaultImageNameAnnotation   = "infrastructure.cloudprovider.io/default-image-name"
+	DefaultNetworkUUIDAnnotation = "infrastructure.cloudprovider.io/default-network-uuid"
+
+	// ImportIDAnnotation is an alternative to spec.importID for adopting an
+	// existing server. The spec field wins when both are set.
+	ImportIDAnnotation = "infrastructure.cloudprovider.io/import-id"
+
+	// ConfirmDeletionAnnotation must be set to the InstanceStack's name before
+	// an InstanceStack with deletion protection can be deleted. The finalizer
+	// then destroys the server despite the protection.
+	ConfirmDeletionAnnotation = "infrastructure.cloudprovider.io/confirm-deletion"
+
+	// RebootAnnotation requests a one-shot reboot of the server, "soft" or
+	// "hard". The controller removes it once the reboot has been issued.
+	RebootAnnotation = "infrastructure.cloudprovider.io/reboot"
+
+	// ExtendExpiryAnnotation postpones the expiry of an InstanceStack by a
+	// duration such as "24h". The controller adds it to
+	// status.expiryExtension and removes the annotation.
+	ExtendExpiryAnnotation = "infrastructure.cloudprovider.io/extend-expiry"
+)
+
 // InstanceStackSpec defines the desired state of InstanceStack
+// +kubebuilder:validation:XValidation:rule="!(has(self.ttl) && has(self.expiresAt))",message="ttl and expiresAt are mutually exclusive"
 type InstanceStackSpec struct {
+	// Program is the registered Pulumi program to run. Defaults to "server",
+	// which creates one compute instance from the fields below.
+	// +optional
+	Program string `json:"program,omitempty"`
+
+	// Parameters are passed to the program and validated against its
+	// parameter schema.
+	// +optional
+	Parameters map[string]apiextensionsv1.JSON `json:"parameters,omitempty"`
+
+	// ProviderConfigName is the ProviderConfig this stack is provisioned
+	// with. Defaults to "default".
+	// +optional
+	ProviderConfigName string `json:"providerConfigName,omitempty"`
+
+	// Region is the region of the ProviderConfig cloud to provision in. It
+	// must be the ProviderConfig region or one of its spec.regions. Defaults
+	// to the ProviderConfig region.
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// MachineClassName is a cluster-scoped MachineClass whose mapping for the
+	// ProviderConfig fills the flavor, image, network and spec.aws fields
+	// left empty here. The values used are recorded in status.machine.
+	// +optional
+	MachineClassName string `json:"machineClassName,omitempty"`
+
+	// FlavorName, ImageName, NetworkUUID and AvailabilityZone select the
+	// server of OpenStack programs. Programs for other providers take their
+	// settings from the provider block, e.g. spec.aws.
 	FlavorName  string `json:"flavorName,omitempty"`
 	ImageName   string `json:"imageName,omitempty"`
 	NetworkUUID string `json:"networkUUID,omitempty"`
+
+	// AvailabilityZone is the Nova availability zone to boot the server in.
+	// +optional
+	AvailabilityZone string `json:"availabilityZone,omitempty"`
+
+	// AWS holds the EC2 settings of programs for AWS ProviderConfigs.
+	// +optional
+	AWS *AWSInstanceSpec `json:"aws,omitempty"`
+
+	// Libvirt holds the domain settings of programs for Libvirt
+	// ProviderConfigs.
+	// +optional
+	Libvirt *LibvirtInstanceSpec `json:"libvirt,omitempty"`
+
+	// ServerName is the name of the OpenStack server. When empty Pulumi
+	// generates one from the InstanceStack name. Set it to the existing name
+	// when importing a server.
+	// +optional
+	ServerName string `json:"serverName,omitempty"`
+
+	// ImportID is the ID of an existing OpenStack server to adopt instead of
+	// creating a new one. The server is only imported if the spec matches it
+	// exactly; differences are reported in status.importMismatches.
+	// +optional
+	ImportID string `json:"importID,omitempty"`
+
+	// DeletionPolicy decides what happens to the OpenStack resources when
+	// the InstanceStack is deleted. Defaults to Delete.
+	// +kubebuilder:default=Delete
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// StateExport is where the Retain policy writes the Pulumi checkpoint.
+	// Defaults to a Secret named "<name>-pulumi-state".
+	// +optional
+	StateExport *StateExport `json:"stateExport,omitempty"`
+
+	// DeletionProtection keeps the server from being destroyed. Deleting the
+	// object requires the confirm-deletion annotation, and the finalizer does
+	// not destroy the server until protection is turned off.
+	// +optional
+	DeletionProtection bool `json:"deletionProtection,omitempty"`
+
+	// WriteOutputsToSecret is the name of a Secret in the same namespace that
+	// receives every stack output, including secret ones, after each
+	// successful update. The Secret is owned by the InstanceStack; an
+	// existing Secret it does not control is not overwritten.
+	// +optional
+	WriteOutputsToSecret string `json:"writeOutputsToSecret,omitempty"`
+
+	// WriteOutputsToConfigMap is the name of a ConfigMap in the same
+	// namespace that receives the stack outputs that are not secret. Like
+	// the Secret, it is owned by the InstanceStack.
+	// +optional
+	WriteOutputsToConfigMap string `json:"writeOutputsToConfigMap,omitempty"`
+
+	// OutputRefs pass outputs of other InstanceStacks or Stacks in the same
+	// namespace to the program. The stack waits until every referenced output
+	// exists, and referenced stacks cannot be deleted while it exists.
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	OutputRefs []OutputReference `json:"outputRefs,omitempty"`
+
+	// DependsOn lists objects in the same namespace that must be ready
+	// before this stack is provisioned.
+	// +optional
+	DependsOn []Dependency `json:"dependsOn,omitempty"`
+
+	// ReadinessGates are extra conditions, set on this InstanceStack by other
+	// controllers, that must be True before it is reported Ready.
+	// +optional
+	// +listType=map
+	// +listMapKey=conditionType
+	ReadinessGates []ReadinessGate `json:"readinessGates,omitempty"`
+
+	// PowerState is the desired power state of the server. When empty the
+	// power state is left as it is.
+	// +optional
+	PowerState PowerState `json:"powerState,omitempty"`
+
+	// TTL deletes the InstanceStack this long after it was created.
+	// +optional
+	TTL *metav1.Duration `json:"ttl,omitempty"`
+
+	// ExpiresAt deletes the InstanceStack at a fixed time. It cannot be
+	// combined with ttl.
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// HealthCheck probes the instance after it has been provisioned and
+	// reports the result in the HealthCheckPassed condition.
+	// +optional
+	HealthCheck *HealthCheck `json:"healthCheck,omitempty"`
+}
+
+// AWSInstanceSpec selects the EC2 instance of an InstanceStack.
+type AWSInstanceSpec struct {
+	// InstanceType is the EC2 instance type, e.g. t3.micro.
+	InstanceType string `json:"instanceType"`
+
+	// AMI is the ID of the image to launch the instance from.
+	AMI string `json:"ami"`
+
+	// SubnetID is the VPC subnet to launch the instance in. Defaults to the
+	// default subnet of the availability zone EC2 picks.
+	// +optional
+	SubnetID string `json:"subnetID,omitempty"`
+
+	// SecurityGroupIDs are the VPC security groups of the instance. Defaults
+	// to the default security group of the VPC.
+	// +optional
+	// +listType=set
+	SecurityGroupIDs []string `json:"securityGroupIDs,omitempty"`
+
+	// KeyName is an existing EC2 key pair to install on the instance.
+	// +optional
+	KeyName string `json:"keyName,omitempty"`
+}
+
+// LibvirtInstanceSpec selects the libvirt domain of an InstanceStack.
+type LibvirtInstanceSpec struct {
+	// VCPUs is the number of virtual CPUs of the domain.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=1
+	// +optional
+	VCPUs int32 `json:"vcpus,omitempty"`
+
+	// MemoryMiB is the memory of the domain in MiB.
+	// +kubebuilder:validation:Minimum=128
+	// +kubebuilder:default=1024
+	// +optional
+	MemoryMiB int32 `json:"memoryMiB,omitempty"`
+
+	// Image is the URL or host path of the qcow2 cloud image the root disk
+	// is cloned from.
+	Image string `json:"image"`
+
+	// Pool is the storage pool of the volumes.
+	// +kubebuilder:default=default
+	// +optional
+	Pool string `json:"pool,omitempty"`
+
+	// NetworkName is the libvirt network the domain is attached to. The
+	// network must hand out DHCP leases for the address to be reported.
+	// +kubebuilder:default=default
+	// +optional
+	NetworkName string `json:"networkName,omitempty"`
+}
+
+// PowerState is the power state of a server.
+// +kubebuilder:validation:Enum=Running;Stopped;Suspended;Shelved
+type PowerState string
+
+const (
+	PowerStateRunning   PowerState = "Running"
+	PowerStateStopped   PowerState = "Stopped"
+	PowerStateSuspended PowerState = "Suspended"
+	PowerStateShelved   PowerState = "Shelved"
+)
+
+// HealthCheck configures the probes run against a provisioned instance.
+type HealthCheck struct {
+	// +kubebuilder:validation:MinItems=1
+	// +listType=map
+	// +listMapKey=name
+	Probes []Probe `json:"probes"`
+
+	// AddressOutput is the stack output holding the address to probe.
+	// Defaults to floatingIP, falling back to instanceIP.
+	// +optional
+	AddressOutput string `json:"addressOutput,omitempty"`
+
+	// +kubebuilder:default=60
+	// +kubebuilder:validation:Minimum=5
+	// +optional
+	PeriodSeconds int32 `json:"periodSeconds,omitempty"`
+
+	// +kubebuilder:default=5
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	TimeoutSeconds int32 `json:"timeoutSeconds,omitempty"`
+
+	// FailureThreshold is the number of consecutive failed rounds after
+	// which HealthCheckPassed turns False.
+	// +kubebuilder:default=3
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	FailureThreshold int32 `json:"failureThreshold,omitempty"`
+}
+
+// Probe is a single check. Exactly one of tcpSocket, httpGet or cloudInit
+// must be set.
+// +kubebuilder:validation:XValidation:rule="(has(self.tcpSocket) ? 1 : 0) + (has(self.httpGet) ? 1 : 0) + (has(self.cloudInit) ? 1 : 0) == 1",message="exactly one of tcpSocket, httpGet or cloudInit must be set"
+type Probe struct {
+	Name string `json:"name"`
+
+	// +optional
+	TCPSocket *TCPSocketProbe `json:"tcpSocket,omitempty"`
+	// +optional
+	HTTPGet *HTTPGetProbe `json:"httpGet,omitempty"`
+	// +optional
+	CloudInit *CloudInitProbe `json:"cloudInit,omitempty"`
+}
+
+// TCPSocketProbe succeeds when a TCP connection can be opened.
+type TCPSocketProbe struct {
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port"`
+}
+
+// HTTPGetProbe succeeds on a 2xx or 3xx response.
+type HTTPGetProbe struct {
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port"`
+
+	// +kubebuilder:default="/"
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// +kubebuilder:validation:Enum=HTTP;HTTPS
+	// +kubebuilder:default=HTTP
+	// +optional
+	Scheme string `json:"scheme,omitempty"`
+}
+
+// CloudInitProbe succeeds once the console log of the server matches Marker.
+type CloudInitProbe struct {
+	// Marker is a regular expression matched against the console log.
+	// Defaults to the line cloud-init prints when it has finished.
+	// +optional
+	Marker string `json:"marker,omitempty"`
+}
+
+// DefaultCloudInitMarker matches "Cloud-init v. 23.4 finished at ...".
+const DefaultCloudInitMarker = `Cloud-init v\. \S+ finished at`
+
+// Dependency is an object that must be ready first.
+type Dependency struct {
+	// APIVersion of the object. Defaults to this API group's version.
+	// +optional
+	APIVersion string `json:"apiVersion,omitempty"`
+
+	// Kind of the object. Defaults to InstanceStack.
+	// +optional
+	Kind string `json:"kind,omitempty"`
+
+	Name string `json:"name"`
+
+	// ConditionType is the status condition that must be True. Defaults to
+	// "Ready".
+	// +optional
+	ConditionType string `json:"conditionType,omitempty"`
+}
+
+// ReadinessGate is a condition that must be True for the InstanceStack to
+// be Ready.
+type ReadinessGate struct {
+	ConditionType string `json:"conditionType"`
+}
+
+// OutputReference is an output of another stack in the same namespace.
+type OutputReference struct {
+	// Name of the value. It is passed as the program parameter of the same
+	// name, and "$(name)" is replaced by it in string parameters.
+	Name string `json:"name"`
+
+	// Kind of the referenced object, InstanceStack or Stack.
+	// +kubebuilder:validation:Enum=InstanceStack;Stack
+	// +kubebuilder:default=InstanceStack
+	// +optional
+	Kind string `json:"kind,omitempty"`
+
+	// StackName is the name of the referenced object.
+	StackName string `json:"stackName"`
+
+	// Output is the name of the stack output.
+	Output string `json:"output"`
+}
+
+// MaskedOutputValue replaces secret outputs in status.outputs. Secret
+// outputs cannot be referenced.
+const MaskedOutputValue = "[secret]"
+
+// DeletionPolicy is the action taken on the cloud resources of a deleted
+// InstanceStack.
+// +kubebuilder:validation:Enum=Delete;Orphan;Retain
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDelete destroys the cloud resources and the Pulumi stack.
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyOrphan removes the Pulumi stack but keeps the cloud resources.
+	DeletionPolicyOrphan DeletionPolicy = "Orphan"
+	// DeletionPolicyRetain keeps the cloud resources and exports the Pulumi
+	// checkpoint before removing the stack, so it can be imported elsewhere.
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+)
+
+// StateExport names the object a Pulumi checkpoint is written to.
+type StateExport struct {
+	// Kind is the kind of object to write, Secret or ConfigMap.
+	// +kubebuilder:validation:Enum=Secret;ConfigMap
+	// +kubebuilder:default=Secret
+	// +optional
+	Kind string `json:"kind,omitempty"`
+
+	// Name of the object in the InstanceStack's namespace. Defaults to
+	// "<name>-pulumi-state".
+	// +optional
+	Name string `json:"name,omitempty"`
+}
+
+// StateExportKey is the data key holding the exported checkpoint.
+const StateExportKey = "checkpoint.json"
+
+// PropertyMismatch is a server property whose value differs from the spec.
+type PropertyMismatch struct {
+	// Property is the Pulumi property path, e.g. "flavorName".
+	Property string `json:"property"`
+	// Kind is the Pulumi diff kind such as "update" or "update-replace".
+	Kind string `json:"kind"`
 }
 
 // InstanceStackStatus defines the observed state of InstanceStack
 type InstanceStackStatus struct {
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// ImportedID is the server ID adopted through spec.importID once the
+	// import succeeded.
+	// +optional
+	ImportedID string `json:"importedID,omitempty"`
+
+	// ImportMismatches lists the properties that kept the server from being
+	// imported. It is cleared once the import succeeds.
+	// +optional
+	ImportMismatches []PropertyMismatch `json:"importMismatches,omitempty"`
+
+	// Machine is what spec.machineClassName resolved to on the
+	// ProviderConfig.
+	// +optional
+	Machine *MachineStatus `json:"machine,omitempty"`
+
+	// Region is the region the stack was last applied in.
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// Outputs are the stack outputs of the last successful update. Secret
+	// outputs are masked.
+	// +optional
+	Outputs map[string]apiextensionsv1.JSON `json:"outputs,omitempty"`
+
+	// PowerState is the power state last observed on the server, such as
+	// Running or Stopped. Transitional Nova states are reported as they are.
+	// +optional
+	PowerState string `json:"powerState,omitempty"`
+
+	// ExpiresAt is when the InstanceStack will be deleted, including any
+	// extensions.
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// ExpiryExtension is the total time added through the extend-expiry
+	// annotation.
+	// +optional
+	ExpiryExtension *metav1.Duration `json:"expiryExtension,omitempty"`
+
+	// LastExpiryWarning is when the last ExpiringSoon event was emitted.
+	// +optional
+	LastExpiryWarning *metav1.Time `json:"lastExpiryWarning,omitempty"`
+
+	// HealthCheckFailures is the number of consecutive failed probe rounds.
+	// +optional
+	HealthCheckFailures int32 `json:"healthCheckFailures,omitempty"`
+
+	// Cost is the estimated cost of the spec, computed from the PriceList of
+	// the ProviderConfig.
+	// +optional
+	Cost *CostEstimate `json:"cost,omitempty"`
+}
+
+// MachineStatus records the concrete settings a MachineClass resolved to.
+type MachineStatus struct {
+	// ClassName is the MachineClass the settings come from.
+	ClassName string `json:"className"`
+
+	// +optional
+	FlavorName string `json:"flavorName,omitempty"`
+	// +optional
+	ImageName string `json:"imageName,omitempty"`
+	// +optional
+	NetworkUUID string `json:"networkUUID,omitempty"`
+	// +optional
+	AWS *AWSInstanceSpec `json:"aws,omitempty"`
+	// +optional
+	Libvirt *LibvirtInstanceSpec `json:"libvirt,omitempty"`
+}
+
+// CostEstimate is the estimated cost of an InstanceStack. Amounts are
+// decimal strings in Currency.
+type CostEstimate struct {
+	// PriceList is the name of the PriceList the estimate is based on.
+	PriceList string `json:"priceList"`
+	Currency  string `json:"currency,omitempty"`
+
+	// Hourly is the estimated hourly cost of the current spec.
+	Hourly string `json:"hourly"`
+	// Monthly is Hourly over 730 hours.
+	Monthly string `json:"monthly"`
+
+	// AppliedHourly is the hourly cost of the spec last applied to the
+	// cloud.
+	// +optional
+	AppliedHourly string `json:"appliedHourly,omitempty"`
+	// HourlyDelta is Hourly minus AppliedHourly, e.g. "+0.1200", while a
+	// spec change has not been applied yet.
+	// +optional
+	HourlyDelta string `json:"hourlyDelta,omitempty"`
+
+	// Unpriced lists the resources missing from the PriceList; they are
+	// not part of the estimate.
+	// +optional
+	Unpriced []string `json:"unpriced,omitempty"`
+}
+
+// Condition types and reasons reported on InstanceStack.
+const (
+	// ConditionCatalogValid reports whether the flavor, image and network
+	// exist in the OpenStack catalog of the ProviderConfig.
+	ConditionCatalogValid = "CatalogValid"
+
+	ReasonCatalogMatched   = "Matched"
+	ReasonUnknownReference = "UnknownReference"
+
+	// ConditionImported reports whether the server named by spec.importID has
+	// been adopted.
+	ConditionImported = "Imported"
+
+	ReasonImportSucceeded = "ImportSucceeded"
+	ReasonImportMismatch  = "ImportMismatch"
+
+	// ConditionDeletionBlocked is set while a deleted InstanceStack keeps its
+	// server because of deletion protection.
+	ConditionDeletionBlocked = "DeletionBlocked"
+
+	ReasonDeletionProtectionEnabled = "DeletionProtectionEnabled"
+
+	// ConditionProgramValid reports whether spec.program is registered and
+	// spec.parameters match its schema.
+	ConditionProgramValid = "ProgramValid"
+
+	ReasonProgramResolved   = "Resolved"
+	ReasonInvalidParameters = "InvalidParameters"
+	// ReasonProviderMismatch means spec.program was written for another
+	// provider than the one of the ProviderConfig.
+	ReasonProviderMismatch = "ProviderMismatch"
+
+	// ConditionReferencesResolved reports whether every output in
+	// spec.outputRefs could be read.
+	ConditionReferencesResolved = "ReferencesResolved"
+
+	ReasonReferencesResolved = "Resolved"
+	ReasonWaitingForOutputs  = "WaitingForOutputs"
+	ReasonInvalidReference   = "InvalidReference"
+
+	// ConditionMachineClassResolved reports whether spec.machineClassName
+	// could be resolved for the ProviderConfig.
+	ConditionMachineClassResolved = "MachineClassResolved"
+
+	ReasonMachineClassResolved = "Resolved"
+	ReasonMachineClassNotFound = "MachineClassNotFound"
+	ReasonNoMachineMapping     = "NoMapping"
+	// ReasonReplacementBlocked means the MachineClass changed a setting that
+	// replaces the server of a provisioned InstanceStack.
+	ReasonReplacementBlocked = "ReplacementBlocked"
+
+	// ConditionReady (shared with Stack) is True once the stack has been
+	// provisioned and all readiness gates are True.
+	ReasonProvisioned = "Provisioned"
+
+	// ConditionQuotaExceeded is True when the project has no room for the
+	// server that would be created.
+	ConditionQuotaExceeded  = "QuotaExceeded"
+	ReasonInsufficientQuota = "InsufficientQuota"
+	ReasonWithinQuota       = "WithinQuota"
+
+	// ConditionHealthCheckPassed reports the result of spec.healthCheck.
+	ConditionHealthCheckPassed = "HealthCheckPassed"
+	ReasonProbesSucceeded      = "ProbesSucceeded"
+	ReasonProbesFailed         = "ProbesFailed"
+	ReasonProbing              = "Probing"
+
+	// ConditionDependenciesReady is True once every object in spec.dependsOn
+	// is ready.
+	ConditionDependenciesReady   = "DependenciesReady"
+	ReasonDependenciesReady      = "DependenciesReady"
+	ReasonWaitingForDependencies = "WaitingForDependencies"
+	ReasonReadinessGatesPending  = "ReadinessGatesPending"
+
+	// ReasonHasDependents is set on DeletionBlocked while other stacks
+	// reference the outputs of this one.
+	ReasonHasDependents = "HasDependents"
+
+	// ReasonRegionNotAllowed is set on Ready when spec.region is not a
+	// region of the ProviderConfig.
+	ReasonRegionNotAllowed = "RegionNotAllowed"
+
+	// ReasonOutputTargetConflict is set on Ready when
+	// spec.writeOutputsToSecret or spec.writeOutputsToConfigMap names an
+	// object that this InstanceStack does not control.
+	ReasonOutputTargetConflict = "OutputTargetConflict"
+)
+
+// EffectiveFlavorName returns spec.flavorName, or the flavor the
+// MachineClass of the InstanceStack resolved to.
+func (s *InstanceStack) EffectiveFlavorName() string {
+	if s.Spec.FlavorName == "" && s.Status.Machine != nil {
+		return s.Status.Machine.FlavorName
+	}
+	return s.Spec.FlavorName
+}
+
+// EffectiveAWS returns spec.aws, or the settings its MachineClass resolved
+// to.
+func (s *InstanceStack) EffectiveAWS() *AWSInstanceSpec {
+	if s.Spec.AWS == nil && s.Status.Machine != nil {
+		return s.Status.Machine.AWS
+	}
+	return s.Spec.AWS
+}
+
+// EffectiveLibvirt returns spec.libvirt, or the settings its MachineClass
+// resolved to.
+func (s *InstanceStack) EffectiveLibvirt() *LibvirtInstanceSpec {
+	if s.Spec.Libvirt == nil && s.Status.Machine != nil {
+		return s.Status.Machine.Libvirt
+	}
+	return s.Spec.Libvirt
+}
+
+// EffectiveImportID returns spec.importID or, when unset, the import-id
+// annotation.
+func (s *InstanceStack) EffectiveImportID() string {
+	if s.Spec.ImportID != "" {
+		return s.Spec.ImportID
+	}
+	return s.Annotations[ImportIDAnnotation]
+}
+
+// DeletionConfirmed reports whether the confirm-deletion annotation names
+// the InstanceStack.
+func (s *InstanceStack) DeletionConfirmed() bool {
+	return s.Annotations[ConfirmDeletionAnnotation] == s.Name
+}
+
+// PendingImportID returns the ID still to be imported, or "" when the stack
+// is not adopting a resource or already did.
+func (s *InstanceStack) PendingImportID() string {
+	importID := s.EffectiveImportID()
+	if importID == s.Status.ImportedID {
+		return ""
+	}
+	return importID
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Reason",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].reason`
+// +kubebuilder:printcolumn:name="Power",type=string,JSONPath=`.status.powerState`
+// +kubebuilder:printcolumn:name="Region",type=string,JSONPath=`.status.region`,priority=1
+// +kubebuilder:printcolumn:name="Expires",type=string,JSONPath=`.status.expiresAt`,priority=1
+// +kubebuilder:printcolumn:name="Cost/h",type=string,JSONPath=`.status.cost.hourly`,priority=1
+// +kubebuilder:printcolumn:name="Delta/h",type=string,JSONPath=`.status.cost.hourlyDelta`,priority=1
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // InstanceStack is the Schema for the instancestacks API
 type InstanceStack struct {
@@ -0,0 +1,93 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MachineClassSpec maps an abstract machine, such as "a medium Ubuntu VM",
+// to the concrete settings of each ProviderConfig it can run on.
+type MachineClassSpec struct {
+	// Size is the abstract size alias of the class, e.g. "medium". It is
+	// only descriptive.
+	// +optional
+	Size string `json:"size,omitempty"`
+
+	// Image is the abstract image alias of the class, e.g. "ubuntu-22.04".
+	// It is only descriptive.
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// Mappings give the concrete settings of the class per ProviderConfig.
+	// +kubebuilder:validation:MinItems=1
+	// +listType=map
+	// +listMapKey=providerConfigName
+	Mappings []MachineMapping `json:"mappings"`
+}
+
+// MachineMapping is what a MachineClass stands for on one ProviderConfig.
+// Fields the InstanceStack sets itself take precedence.
+type MachineMapping struct {
+	ProviderConfigName string `json:"providerConfigName"`
+
+	// FlavorName, ImageName and NetworkUUID are used on OpenStack
+	// ProviderConfigs.
+	// +optional
+	FlavorName string `json:"flavorName,omitempty"`
+	// +optional
+	ImageName string `json:"imageName,omitempty"`
+	// +optional
+	NetworkUUID string `json:"networkUUID,omitempty"`
+
+	// AWS is used on AWS ProviderConfigs.
+	// +optional
+	AWS *AWSInstanceSpec `json:"aws,omitempty"`
+
+	// Libvirt is used on Libvirt ProviderConfigs.
+	// +optional
+	Libvirt *LibvirtInstanceSpec `json:"libvirt,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Size",type=string,JSONPath=`.spec.size`
+// +kubebuilder:printcolumn:name="Image",type=string,JSONPath=`.spec.image`
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// MachineClass is the Schema for the machineclasses API. InstanceStacks
+// select one with spec.machineClassName instead of naming flavors and images
+// of a particular cloud.
+type MachineClass struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec MachineClassSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// MachineClassList contains a list of MachineClass
+type MachineClassList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MachineClass `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MachineClass{}, &MachineClassList{})
+}
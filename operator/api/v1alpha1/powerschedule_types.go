@@ -0,0 +1,136 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SkipPowerScheduleAnnotation set to "true" on an InstanceStack keeps
+// PowerSchedules from changing its power state.
+const SkipPowerScheduleAnnotation = "infrastructure.cloudprovider.io/skip-power-schedule"
+
+// PowerScheduleSpec defines the desired state of PowerSchedule
+type PowerScheduleSpec struct {
+	// Selector selects the InstanceStacks in the PowerSchedule's namespace.
+	Selector metav1.LabelSelector `json:"selector"`
+
+	// Start is a cron expression (minute hour day-of-month month
+	// day-of-week) at which the selected servers are powered on.
+	Start string `json:"start"`
+
+	// Stop is a cron expression at which the selected servers are powered
+	// off.
+	Stop string `json:"stop"`
+
+	// TimeZone is the IANA time zone the cron expressions are evaluated in.
+	// Defaults to UTC.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+
+	// OffState is the power state applied at Stop.
+	// +kubebuilder:validation:Enum=Stopped;Shelved;Suspended
+	// +kubebuilder:default=Stopped
+	// +optional
+	OffState PowerState `json:"offState,omitempty"`
+
+	// Suspend pauses the schedule without deleting it.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+// PowerScheduleAction is a scheduled transition.
+type PowerScheduleAction string
+
+const (
+	PowerScheduleActionStart PowerScheduleAction = "Start"
+	PowerScheduleActionStop  PowerScheduleAction = "Stop"
+)
+
+// PowerScheduleStatus defines the observed state of PowerSchedule
+type PowerScheduleStatus struct {
+	// LastAction is the last transition that was applied.
+	// +optional
+	LastAction PowerScheduleAction `json:"lastAction,omitempty"`
+
+	// LastScheduleTime is when LastAction was scheduled.
+	// +optional
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+
+	// NextStartTime is the next time the selected servers are powered on.
+	// +optional
+	NextStartTime *metav1.Time `json:"nextStartTime,omitempty"`
+
+	// NextStopTime is the next time the selected servers are powered off.
+	// +optional
+	NextStopTime *metav1.Time `json:"nextStopTime,omitempty"`
+
+	// InstanceStacks lists the InstanceStacks the last transition was
+	// applied to.
+	// +optional
+	InstanceStacks []string `json:"instanceStacks,omitempty"`
+
+	// FailedInstanceStacks lists the selected InstanceStacks whose power state
+	// could not be set by the last transition. They are not retried until
+	// the next transition.
+	// +optional
+	FailedInstanceStacks []string `json:"failedInstanceStacks,omitempty"`
+
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// Reasons reported on the Ready condition of a PowerSchedule.
+const (
+	ReasonScheduled        = "Scheduled"
+	ReasonInvalidSchedule  = "InvalidSchedule"
+	ReasonSuspended        = "Suspended"
+	ReasonTransitionFailed = "TransitionFailed"
+)
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Start",type=string,JSONPath=`.spec.start`
+// +kubebuilder:printcolumn:name="Stop",type=string,JSONPath=`.spec.stop`
+// +kubebuilder:printcolumn:name="Last",type=string,JSONPath=`.status.lastAction`
+// +kubebuilder:printcolumn:name="Next Start",type=string,JSONPath=`.status.nextStartTime`
+// +kubebuilder:printcolumn:name="Next Stop",type=string,JSONPath=`.status.nextStopTime`
+
+// PowerSchedule is the Schema for the powerschedules API. It powers the
+// selected InstanceStacks on and off on a schedule.
+type PowerSchedule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PowerScheduleSpec   `json:"spec,omitempty"`
+	Status PowerScheduleStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// PowerScheduleList contains a list of PowerSchedule
+type PowerScheduleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PowerSchedule `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PowerSchedule{}, &PowerScheduleList{})
+}
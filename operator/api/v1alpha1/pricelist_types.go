@@ -0,0 +1,87 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// HoursPerMonth is the number of hours used to turn hourly rates into a
+// monthly estimate (365 * 24 / 12).
+const HoursPerMonth = 730
+
+// PriceListSpec maps the resources of one cloud to hourly rates. Rates are
+// decimal quantities in Currency, e.g. "0.045".
+type PriceListSpec struct {
+	// ProviderConfigName is the cloud the prices apply to. Defaults to
+	// "default".
+	// +optional
+	ProviderConfigName string `json:"providerConfigName,omitempty"`
+
+	// Currency is an ISO 4217 code, only used for display.
+	// +kubebuilder:default=USD
+	// +optional
+	Currency string `json:"currency,omitempty"`
+
+	// Flavors is the hourly rate of a server by flavor name, or by instance
+	// type for AWS ProviderConfigs. Libvirt domains are not priced.
+	// +optional
+	Flavors map[string]resource.Quantity `json:"flavors,omitempty"`
+
+	// VolumeGiB is the hourly rate per GiB of volumes whose type is not
+	// listed in VolumeTypes.
+	// +optional
+	VolumeGiB *resource.Quantity `json:"volumeGiB,omitempty"`
+
+	// VolumeTypes is the hourly rate per GiB by Cinder volume type.
+	// +optional
+	VolumeTypes map[string]resource.Quantity `json:"volumeTypes,omitempty"`
+
+	// FloatingIP is the hourly rate of a floating IP.
+	// +optional
+	FloatingIP *resource.Quantity `json:"floatingIP,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Provider",type=string,JSONPath=`.spec.providerConfigName`
+// +kubebuilder:printcolumn:name="Currency",type=string,JSONPath=`.spec.currency`
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// PriceList is the Schema for the pricelists API. The cost of an
+// InstanceStack is estimated from the PriceList of its ProviderConfig; when
+// several match, the first by name is used.
+type PriceList struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PriceListSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// PriceListList contains a list of PriceList
+type PriceListList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PriceList `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PriceList{}, &PriceListList{})
+}
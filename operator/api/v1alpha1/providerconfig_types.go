@@ -0,0 +1,323 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultProviderConfigName is the ProviderConfig used by an InstanceStack
+// that does not set spec.providerConfigName.
+const DefaultProviderConfigName = "default"
+
+// InstanceDefaults holds values applied to an InstanceStack when the
+// corresponding spec field is left empty.
+type InstanceDefaults struct {
+	FlavorName  string `json:"flavorName,omitempty"`
+	ImageName   string `json:"imageName,omitempty"`
+	NetworkUUID string `json:"networkUUID,omitempty"`
+}
+
+// SecretReference points at a Secret in a specific namespace.
+type SecretReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+// ConfigMapReference points at a ConfigMap in a specific namespace.
+type ConfigMapReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+// TLSConfig holds the certificates of a ProviderConfig.
+type TLSConfig struct {
+	// CABundle holds PEM encoded certificate authorities trusted in addition
+	// to the system ones.
+	// +optional
+	CABundle *CABundleSource `json:"caBundle,omitempty"`
+
+	// ClientCertificateSecretRef names a Secret with PEM encoded "tls.crt"
+	// and "tls.key" keys, presented to the OpenStack APIs.
+	// +optional
+	ClientCertificateSecretRef *SecretReference `json:"clientCertificateSecretRef,omitempty"`
+}
+
+// CABundleSource selects a key of a Secret or a ConfigMap.
+// +kubebuilder:validation:XValidation:rule="has(self.secretRef) != has(self.configMapRef)",message="exactly one of secretRef or configMapRef must be set"
+type CABundleSource struct {
+	// +optional
+	SecretRef *SecretReference `json:"secretRef,omitempty"`
+	// +optional
+	ConfigMapRef *ConfigMapReference `json:"configMapRef,omitempty"`
+
+	// Key is the key holding the bundle.
+	// +kubebuilder:default="ca.crt"
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// ProviderType is the kind of cloud a ProviderConfig connects to.
+// +kubebuilder:validation:Enum=OpenStack;AWS;Libvirt
+type ProviderType string
+
+const (
+	ProviderOpenStack ProviderType = "OpenStack"
+	ProviderAWS       ProviderType = "AWS"
+	// ProviderLibvirt runs InstanceStacks as libvirt domains, e.g. on a
+	// laptop or a CI runner without a cloud account.
+	ProviderLibvirt ProviderType = "Libvirt"
+)
+
+// AWSProviderConfig holds the AWS account of a ProviderConfig.
+type AWSProviderConfig struct {
+	// CredentialsSecretRef names a Secret holding "accessKeyID" and
+	// "secretAccessKey" keys, and optionally "sessionToken". When empty the
+	// default credential chain of the AWS SDK is used, e.g. IRSA.
+	// +optional
+	CredentialsSecretRef *SecretReference `json:"credentialsSecretRef,omitempty"`
+
+	// Endpoint overrides the EC2 endpoint, e.g. with a local EC2-compatible
+	// emulator such as LocalStack or moto. The account and credential checks
+	// emulators do not implement are skipped.
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// LibvirtProviderConfig holds the libvirt daemon of a ProviderConfig.
+type LibvirtProviderConfig struct {
+	// URI is the libvirt connection URI, e.g. qemu:///system or
+	// qemu+ssh://root@host/system.
+	// +kubebuilder:default="qemu:///system"
+	// +optional
+	URI string `json:"uri,omitempty"`
+}
+
+// ProviderConfigSpec defines the desired state of ProviderConfig.
+// Connection fields left empty fall back to the OPENSTACK_* environment
+// variables of the operator.
+// +kubebuilder:validation:XValidation:rule="!has(self.aws) || self.provider == 'AWS'",message="aws requires provider AWS"
+// +kubebuilder:validation:XValidation:rule="!has(self.libvirt) || self.provider == 'Libvirt'",message="libvirt requires provider Libvirt"
+type ProviderConfigSpec struct {
+	// Provider is the kind of cloud. The remaining fields configure
+	// OpenStack, except region and regions, which apply to every provider,
+	// and the block named after the provider.
+	// +kubebuilder:default=OpenStack
+	// +optional
+	Provider ProviderType `json:"provider,omitempty"`
+
+	// AWS configures the account of an AWS ProviderConfig.
+	// +optional
+	AWS *AWSProviderConfig `json:"aws,omitempty"`
+
+	// Libvirt configures the daemon of a Libvirt ProviderConfig.
+	// +optional
+	Libvirt *LibvirtProviderConfig `json:"libvirt,omitempty"`
+
+	// AuthURL is the Keystone v3 endpoint.
+	// +optional
+	AuthURL string `json:"authURL,omitempty"`
+	// Region is the primary region, used by InstanceStacks that do not set
+	// spec.region.
+	// +optional
+	Region string `json:"region,omitempty"`
+	// Regions are the other regions of the cloud InstanceStacks may select
+	// with spec.region. Other clouds need their own ProviderConfig.
+	// +optional
+	// +listType=set
+	Regions []string `json:"regions,omitempty"`
+	// +optional
+	TenantName string `json:"tenantName,omitempty"`
+	// Insecure disables certificate verification of the OpenStack APIs. It
+	// must be opted into explicitly and is reported by the InsecureTLS
+	// condition; prefer tls.caBundle for private certificate authorities.
+	// +optional
+	Insecure *bool `json:"insecure,omitempty"`
+
+	// TLS configures the certificates used to connect to the OpenStack APIs,
+	// both by the operator and by the Pulumi OpenStack provider.
+	// +optional
+	TLS *TLSConfig `json:"tls,omitempty"`
+
+	// CredentialsSecretRef names a Secret holding "username" and "password" keys.
+	// With ApplicationCredential set, they are only used to create and rotate
+	// the application credential and the Secret may be deleted afterwards.
+	// +optional
+	CredentialsSecretRef *SecretReference `json:"credentialsSecretRef,omitempty"`
+
+	// ApplicationCredential makes the operator create a Keystone application
+	// credential for this ProviderConfig and use it instead of the password
+	// for everything else, rotating it periodically.
+	// +optional
+	ApplicationCredential *ApplicationCredentialPolicy `json:"applicationCredential,omitempty"`
+
+	// Defaults are applied by the defaulting webhook to InstanceStacks that
+	// reference this ProviderConfig.
+	// +optional
+	Defaults InstanceDefaults `json:"defaults,omitempty"`
+}
+
+// ApplicationCredentialPolicy configures the application credential managed
+// for a ProviderConfig.
+type ApplicationCredentialPolicy struct {
+	// SecretRef names the Secret the operator writes the credential to, under
+	// the "applicationCredentialID" and "applicationCredentialSecret" keys.
+	SecretRef SecretReference `json:"secretRef"`
+
+	// Roles restricts the credential to these roles of the user on the
+	// project. Empty means all of them.
+	// +optional
+	// +listType=set
+	Roles []string `json:"roles,omitempty"`
+
+	// RotationPeriod is how long a credential is used before it is replaced.
+	// +kubebuilder:default="720h"
+	// +optional
+	RotationPeriod metav1.Duration `json:"rotationPeriod,omitempty"`
+
+	// Overlap is how long the replaced credential stays valid after a
+	// rotation, so that operations started with it can finish.
+	// +kubebuilder:default="1h"
+	// +optional
+	Overlap metav1.Duration `json:"overlap,omitempty"`
+
+	// Unrestricted lets the credential create application credentials, so
+	// that it can rotate itself once the password Secret is gone. Otherwise
+	// rotation needs CredentialsSecretRef or the environment password.
+	// +optional
+	Unrestricted bool `json:"unrestricted,omitempty"`
+}
+
+// ApplicationCredentialStatus describes the managed application credential.
+type ApplicationCredentialStatus struct {
+	// ID is the credential currently written to the Secret.
+	ID        string      `json:"id"`
+	CreatedAt metav1.Time `json:"createdAt"`
+	// ExpiresAt is when Keystone stops accepting the credential, even if it
+	// is not rotated.
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// PreviousID is the credential replaced by the last rotation. It is
+	// deleted at PreviousRetireAt.
+	// +optional
+	PreviousID string `json:"previousID,omitempty"`
+	// +optional
+	PreviousRetireAt *metav1.Time `json:"previousRetireAt,omitempty"`
+}
+
+// ProviderConfigStatus defines the observed state of ProviderConfig
+type ProviderConfigStatus struct {
+	// CatalogRefreshTime is when the flavor, image, network and availability
+	// zone catalog was last listed successfully.
+	// +optional
+	CatalogRefreshTime *metav1.Time `json:"catalogRefreshTime,omitempty"`
+
+	// Quota is the compute and network quota usage of the project, refreshed
+	// together with the catalog.
+	// +optional
+	Quota *ProjectQuota `json:"quota,omitempty"`
+
+	// ApplicationCredential is set once the credential of
+	// spec.applicationCredential has been created.
+	// +optional
+	ApplicationCredential *ApplicationCredentialStatus `json:"applicationCredential,omitempty"`
+
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// ProjectQuota is the quota usage of the OpenStack project of a
+// ProviderConfig.
+type ProjectQuota struct {
+	Instances   QuotaUsage `json:"instances"`
+	Cores       QuotaUsage `json:"cores"`
+	RAMMiB      QuotaUsage `json:"ramMiB"`
+	FloatingIPs QuotaUsage `json:"floatingIPs"`
+	Ports       QuotaUsage `json:"ports"`
+}
+
+// QuotaUsage is the limit and usage of one quota. A limit of -1 means
+// unlimited.
+type QuotaUsage struct {
+	Limit int64 `json:"limit"`
+	InUse int64 `json:"inUse"`
+	// +optional
+	Reserved int64 `json:"reserved,omitempty"`
+}
+
+// Condition types and reasons reported on ProviderConfig.
+const (
+	ConditionCatalogReady = "CatalogReady"
+
+	ReasonCatalogRefreshed     = "Refreshed"
+	ReasonCatalogRefreshFailed = "RefreshFailed"
+
+	ConditionApplicationCredentialReady = "ApplicationCredentialReady"
+
+	ReasonApplicationCredentialCurrent = "Current"
+	ReasonBootstrapFailed              = "BootstrapFailed"
+	ReasonRotationFailed               = "RotationFailed"
+	// ReasonRotationBlocked means the credential is due for rotation but
+	// there is no password and the credential cannot rotate itself.
+	ReasonRotationBlocked = "RotationBlocked"
+
+	// ConditionInsecureTLS is True while certificate verification of the
+	// OpenStack APIs is disabled.
+	ConditionInsecureTLS = "InsecureTLS"
+
+	ReasonInsecureSkipVerify   = "InsecureSkipVerify"
+	ReasonCertificatesVerified = "CertificatesVerified"
+	ReasonInvalidTLSConfig     = "InvalidTLSConfig"
+)
+
+// ProviderType returns spec.provider, treating ProviderConfigs created
+// before it existed as OpenStack.
+func (p *ProviderConfig) ProviderType() ProviderType {
+	if p.Spec.Provider == "" {
+		return ProviderOpenStack
+	}
+	return p.Spec.Provider
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+
+// ProviderConfig is the Schema for the providerconfigs API
+type ProviderConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ProviderConfigSpec   `json:"spec,omitempty"`
+	Status ProviderConfigStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ProviderConfigList contains a list of ProviderConfig
+type ProviderConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ProviderConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ProviderConfig{}, &ProviderConfigList{})
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// StackSpec defines the desired state of Stack
+type StackSpec struct {
+	// ProviderConfigName is the ProviderConfig whose credentials are passed
+	// to the program as openstack:* config. Defaults to "default".
+	// +optional
+	ProviderConfigName string `json:"providerConfigName,omitempty"`
+
+	// Source is where the Pulumi YAML program comes from.
+	Source StackSource `json:"source"`
+
+	// Config holds plain stack config values keyed by Pulumi config key,
+	// e.g. "flavor". The openstack:* keys are set from the ProviderConfig and
+	// are refused here and in secretConfig.
+	// +optional
+	Config map[string]string `json:"config,omitempty"`
+
+	// SecretConfig holds stack config values read from Secrets in the
+	// Stack's namespace. They are stored encrypted in the stack.
+	// +optional
+	// +listType=map
+	// +listMapKey=key
+	SecretConfig []SecretConfigValue `json:"secretConfig,omitempty"`
+}
+
+// StackSource selects exactly one source of a Pulumi YAML program.
+// +kubebuilder:validation:XValidation:rule="(has(self.inline) ? 1 : 0) + (has(self.configMapRef) ? 1 : 0) + (has(self.git) ? 1 : 0) == 1",message="exactly one of inline, configMapRef or git must be set"
+type StackSource struct {
+	// Inline is the content of a Pulumi.yaml file. The project name and
+	// runtime are filled in by the operator.
+	// +optional
+	Inline string `json:"inline,omitempty"`
+
+	// ConfigMapRef points at a ConfigMap key holding the Pulumi.yaml content.
+	// +optional
+	ConfigMapRef *ConfigMapProgramSource `json:"configMapRef,omitempty"`
+
+	// Git points at a Git checkout on the operator's filesystem, for example
+	// one kept up to date by a git-sync sidecar. The checkout must be inside
+	// the Git root of the operator, and the project is copied out of it.
+	// +optional
+	Git *GitProgramSource `json:"git,omitempty"`
+}
+
+// ConfigMapProgramSource is a Pulumi.yaml stored in a ConfigMap.
+type ConfigMapProgramSource struct {
+	Name string `json:"name"`
+
+	// Key of the program in the ConfigMap. Defaults to "Pulumi.yaml".
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// GitProgramSource is a Pulumi YAML project inside a local Git checkout.
+type GitProgramSource struct {
+	// Path is the absolute path of the checkout, inside the --stack-git-root
+	// directory of the operator.
+	Path string `json:"path"`
+
+	// Dir is the project directory relative to Path.
+	// +optional
+	Dir string `json:"dir,omitempty"`
+}
+
+// SecretConfigValue is a stack config value read from a Secret.
+type SecretConfigValue struct {
+	// Key is the Pulumi config key.
+	Key string `json:"key"`
+
+	SecretKeyRef corev1.SecretKeySelector `json:"secretKeyRef"`
+}
+
+// StackStatus defines the observed state of Stack
+type StackStatus struct {
+	// Outputs are the stack outputs of the last successful update. Secret
+	// outputs are masked.
+	// +optional
+	Outputs map[string]apiextensionsv1.JSON `json:"outputs,omitempty"`
+
+	// SourceRevision is the Git commit the last update ran from.
+	// +optional
+	SourceRevision string `json:"sourceRevision,omitempty"`
+
+	// LastUpdateTime is when the last successful update finished.
+	// +optional
+	LastUpdateTime *metav1.Time `json:"lastUpdateTime,omitempty"`
+
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// Condition types and reasons reported on Stack.
+const (
+	// ConditionReady reports whether the last update of the stack succeeded.
+	ConditionReady = "Ready"
+
+	ReasonUpdateSucceeded = "UpdateSucceeded"
+	ReasonUpdateFailed    = "UpdateFailed"
+	ReasonSourceNotFound  = "SourceNotFound"
+	// ReasonSourceNotAllowed is reported for Git sources outside of the Git
+	// root of the operator.
+	ReasonSourceNotAllowed = "SourceNotAllowed"
+	// ReasonReservedConfig is reported for openstack:* config keys.
+	ReasonReservedConfig = "ReservedConfig"
+)
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Revision",type=string,JSONPath=`.status.sourceRevision`,priority=1
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// Stack is the Schema for the stacks API. It runs an arbitrary Pulumi YAML
+// program.
+type Stack struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   StackSpec   `json:"spec,omitempty"`
+	Status StackStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// StackList contains a list of Stack
+type StackList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Stack `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Stack{}, &StackList{})
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Keys of the Secret referenced by TenantBinding.spec.applicationCredentialSecretRef.
+const (
+	ApplicationCredentialIDKey     = "applicationCredentialID"
+	ApplicationCredentialSecretKey = "applicationCredentialSecret"
+)
+
+// TenantBindingSpec maps namespaces to an OpenStack project. InstanceStacks
+// and Stacks in those namespaces are provisioned with an application
+// credential of the project instead of the ProviderConfig credentials.
+type TenantBindingSpec struct {
+	// Namespaces bound to the project. A namespace may only be bound once.
+	// +kubebuilder:validation:MinItems=1
+	// +listType=set
+	Namespaces []string `json:"namespaces"`
+
+	// ProviderConfigName is the cloud the project lives in. InstanceStacks
+	// in the bound namespaces may not use another ProviderConfig. Defaults
+	// to "default".
+	// +optional
+	ProviderConfigName string `json:"providerConfigName,omitempty"`
+
+	// ProjectName is the OpenStack project the application credential must
+	// be scoped to.
+	// +kubebuilder:validation:MinLength=1
+	ProjectName string `json:"projectName"`
+
+	// ProjectDomainName is the Keystone domain of the project.
+	// +kubebuilder:default=Default
+	// +optional
+	ProjectDomainName string `json:"projectDomainName,omitempty"`
+
+	// ApplicationCredentialSecretRef names a Secret holding the
+	// "applicationCredentialID" and "applicationCredentialSecret" keys.
+	ApplicationCredentialSecretRef SecretReference `json:"applicationCredentialSecretRef"`
+}
+
+// TenantBindingStatus defines the observed state of TenantBinding
+type TenantBindingStatus struct {
+	// ProjectID is the ID of the project the application credential was
+	// verified against.
+	// +optional
+	ProjectID string `json:"projectID,omitempty"`
+
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// Reasons reported on the Ready condition of TenantBinding.
+const (
+	ReasonCredentialVerified = "CredentialVerified"
+	ReasonInvalidCredential  = "InvalidCredential"
+	ReasonProjectMismatch    = "ProjectMismatch"
+	ReasonNamespaceConflict  = "NamespaceConflict"
+
+	// ReasonCrossTenantReference is reported by InstanceStacks and Stacks
+	// that reach outside the project their namespace is bound to.
+	ReasonCrossTenantReference = "CrossTenantReference"
+)
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Project",type=string,JSONPath=`.spec.projectName`
+// +kubebuilder:printcolumn:name="Provider",type=string,JSONPath=`.spec.providerConfigName`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Reason",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].reason`
+
+// TenantBinding is the Schema for the tenantbindings API. It is cluster
+// scoped so that only cluster administrators decide which project a
+// namespace provisions into.
+type TenantBinding struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   TenantBindingSpec   `json:"spec,omitempty"`
+	Status TenantBindingStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// TenantBindingList contains a list of TenantBinding
+type TenantBindingList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []TenantBinding `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&TenantBinding{}, &TenantBindingList{})
+}
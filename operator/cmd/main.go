@@ -20,6 +20,11 @@ import (
 	"crypto/tls"
 	"flag"
 	"os"
+	"path/filepath"
+	"time"
+	// Embed the time zone database for PowerSchedule time zones; the runtime
+	// image does not ship one.
+	_ "time/tzdata"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -36,7 +41,10 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+	"github.com/gunniLee/cloud-provider-operator/internal/catalog"
 	"github.com/gunniLee/cloud-provider-operator/internal/controller"
+	"github.com/gunniLee/cloud-provider-operator/internal/openstack"
+	webhookinfrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/internal/webhook/v1alpha1"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -58,6 +66,9 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var catalogRefreshInterval time.Duration
+	var stackWorkDir string
+	var stackGitRoot string
 	var tlsOpts []func(*tls.Config)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
@@ -69,6 +80,12 @@ func main() {
 		"If set, the metrics endpoint is served securely via HTTPS. Use --metrics-secure=false to use HTTP instead.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.DurationVar(&catalogRefreshInterval, "catalog-refresh-interval", catalog.DefaultInterval,
+		"How often flavors, images, networks and availability zones are listed for each ProviderConfig.")
+	flag.StringVar(&stackWorkDir, "stack-workdir", filepath.Join(os.TempDir(), "cloud-provider-operator", "stacks"),
+		"Directory where the Pulumi projects of Stack programs are written or copied to.")
+	flag.StringVar(&stackGitRoot, "stack-git-root", "",
+		"Directory Git sources of Stacks must be inside, e.g. the volume of a git-sync sidecar. Git sources are refused when empty.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -142,13 +159,114 @@ func main() {
 		os.Exit(1)
 	}
 
+	if openstack.CredentialsFromEnv().Insecure {
+		setupLog.Info("WARNING: OPENSTACK_INSECURE is set, certificates of the OpenStack APIs are not verified")
+	}
+
+	catalogCache := catalog.NewCache(mgr.GetClient(), catalogRefreshInterval)
+	catalogCache.Elected = mgr.Elected()
+	if err := mgr.Add(catalogCache); err != nil {
+		setupLog.Error(err, "unable to set up catalog cache")
+		os.Exit(1)
+	}
+	if err := mgr.Add(&catalog.Mirror{Client: mgr.GetClient(), Cache: catalogCache}); err != nil {
+		setupLog.Error(err, "unable to set up catalog mirror")
+		os.Exit(1)
+	}
+
 	if err = (&controller.InstanceStackReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Catalog:  catalogCache,
+		Recorder: mgr.GetEventRecorderFor("instancestack"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "InstanceStack")
+		os.Exit(1)
+	}
+	if err = (&controller.InstanceStackHealthReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
 	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "InstanceStack")
+		setupLog.Error(err, "unable to create controller", "controller", "InstanceStackHealth")
+		os.Exit(1)
+	}
+	if err = (&controller.InstanceStackExpiryReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("instancestack-expiry"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "InstanceStackExpiry")
+		os.Exit(1)
+	}
+	if err = (&controller.InstanceStackCostReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "InstanceStackCost")
+		os.Exit(1)
+	}
+	if err = (&controller.StackReconciler{
+		Client:  mgr.GetClient(),
+		Scheme:  mgr.GetScheme(),
+		WorkDir: stackWorkDir,
+		GitRoot: stackGitRoot,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Stack")
 		os.Exit(1)
 	}
+	if err = (&controller.PowerScheduleReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("powerschedule"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "PowerSchedule")
+		os.Exit(1)
+	}
+	if err = (&controller.InstanceBudgetReconciler{
+		Client:  mgr.GetClient(),
+		Scheme:  mgr.GetScheme(),
+		Catalog: catalogCache,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "InstanceBudget")
+		os.Exit(1)
+	}
+	if err = (&controller.TenantBindingReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "TenantBinding")
+		os.Exit(1)
+	}
+	if err = (&controller.ApplicationCredentialReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ApplicationCredential")
+		os.Exit(1)
+	}
+	if err = (&controller.ProviderConfigTLSReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("providerconfig-tls"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ProviderConfigTLS")
+		os.Exit(1)
+	}
+	if err = (&controller.InstanceSetReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("instanceset"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "InstanceSet")
+		os.Exit(1)
+	}
+	// nolint:goconst
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = webhookinfrastructurev1alpha1.SetupInstanceStackWebhookWithManager(mgr, catalogCache); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "InstanceStack")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
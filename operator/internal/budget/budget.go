@@ -0,0 +1,151 @@
+// Package budget computes what InstanceStacks consume and checks it against
+// the InstanceBudgets of their namespace. It is shared by the admission
+// webhook, which rejects InstanceStacks that would not fit, and the
+// controllers.
+package budget
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+	"github.com/gunniLee/cloud-provider-operator/internal/catalog"
+	"github.com/gunniLee/cloud-provider-operator/internal/program"
+)
+
+// Usage returns what an InstanceStack consumes. Servers count one instance
+// plus the vCPUs and RAM of their flavor, or of spec.libvirt for libvirt
+// domains; known is false when the flavor is not in the catalog, in which
+// case only the instance is counted. EC2 instance types are never known.
+func Usage(ctx context.Context, cache *catalog.Cache, instanceStack *infrastructurev1alpha1.InstanceStack) (usage infrastructurev1alpha1.BudgetUsage, known bool) {
+	prog, params, errs := program.Resolve(instanceStack)
+	if prog == nil || len(errs) > 0 {
+		return usage, true
+	}
+	if prog.VolumeSizeParameter != "" {
+		usage.VolumeGiB = int64(params.Int(prog.VolumeSizeParameter))
+	}
+	if !prog.Instance {
+		return usage, true
+	}
+	usage.Instances = 1
+	switch prog.Provider {
+	case infrastructurev1alpha1.ProviderLibvirt:
+		vcpus, memoryMiB := program.LibvirtSize(instanceStack.EffectiveLibvirt())
+		usage.VCPUs, usage.RAMMiB = int64(vcpus), int64(memoryMiB)
+		return usage, true
+	case infrastructurev1alpha1.ProviderAWS:
+		return usage, false
+	}
+	snapshot := cache.Get(ctx, instanceStack.Spec.ProviderConfigName)
+	if snapshot == nil {
+		return usage, false
+	}
+	for _, flavor := range snapshot.Flavors {
+		if flavor.Name == instanceStack.EffectiveFlavorName() {
+			usage.VCPUs = int64(flavor.VCPUs)
+			usage.RAMMiB = int64(flavor.RAM)
+			return usage, true
+		}
+	}
+	return usage, false
+}
+
+// Add returns the sum of two usages.
+func Add(a, b infrastructurev1alpha1.BudgetUsage) infrastructurev1alpha1.BudgetUsage {
+	return infrastructurev1alpha1.BudgetUsage{
+		Instances: a.Instances + b.Instances,
+		VCPUs:     a.VCPUs + b.VCPUs,
+		RAMMiB:    a.RAMMiB + b.RAMMiB,
+		VolumeGiB: a.VolumeGiB + b.VolumeGiB,
+	}
+}
+
+// Increases reports whether next consumes more than previous of anything.
+func Increases(previous, next infrastructurev1alpha1.BudgetUsage) bool {
+	return next.Instances > previous.Instances || next.VCPUs > previous.VCPUs ||
+		next.RAMMiB > previous.RAMMiB || next.VolumeGiB > previous.VolumeGiB
+}
+
+// Exceeded describes every limit of spec that used is over. Only the
+// resources requested is non-zero for are reported, so a stack is not blamed
+// for a limit it does not consume.
+func Exceeded(spec infrastructurev1alpha1.InstanceBudgetSpec, used, requested infrastructurev1alpha1.BudgetUsage) []string {
+	var exceeded []string
+	check := func(name string, limit *int64, used, requested int64) {
+		if limit != nil && used > *limit && requested > 0 {
+			exceeded = append(exceeded, fmt.Sprintf("%s: %d of %d", name, used, *limit))
+		}
+	}
+	check("instances", spec.Instances, used.Instances, requested.Instances)
+	check("vcpus", spec.VCPUs, used.VCPUs, requested.VCPUs)
+	check("ramMiB", spec.RAMMiB, used.RAMMiB, requested.RAMMiB)
+	check("volumeGiB", spec.VolumeGiB, used.VolumeGiB, requested.VolumeGiB)
+	return exceeded
+}
+
+// NamespaceUsage sums the usage of the InstanceStacks in a namespace that
+// are not being deleted and for which count returns true. It also returns
+// the flavors and instance types whose size is unknown.
+func NamespaceUsage(ctx context.Context, c client.Reader, cache *catalog.Cache, namespace string,
+	count func(*infrastructurev1alpha1.InstanceStack) bool) (infrastructurev1alpha1.BudgetUsage, []string, error) {
+	instanceStacks := &infrastructurev1alpha1.InstanceStackList{}
+	if err := c.List(ctx, instanceStacks, client.InNamespace(namespace)); err != nil {
+		return infrastructurev1alpha1.BudgetUsage{}, nil, err
+	}
+	var total infrastructurev1alpha1.BudgetUsage
+	unknown := map[string]bool{}
+	for i := range instanceStacks.Items {
+		instanceStack := &instanceStacks.Items[i]
+		if !instanceStack.DeletionTimestamp.IsZero() || (count != nil && !count(instanceStack)) {
+			continue
+		}
+		usage, known := Usage(ctx, cache, instanceStack)
+		if !known {
+			if prog, _ := program.Lookup(instanceStack.Spec.Program); prog != nil {
+				unknown[prog.MachineType(instanceStack)] = true
+			}
+		}
+		total = Add(total, usage)
+	}
+	flavors := make([]string, 0, len(unknown))
+	for flavor := range unknown {
+		flavors = append(flavors, flavor)
+	}
+	sort.Strings(flavors)
+	return total, flavors, nil
+}
+
+// Check returns the limits of the namespace's InstanceBudgets that
+// instanceStack would exceed, prefixed with the budget name. The usage of
+// the other InstanceStacks for which count returns true is added to that of
+// instanceStack.
+func Check(ctx context.Context, c client.Reader, cache *catalog.Cache, instanceStack *infrastructurev1alpha1.InstanceStack,
+	count func(*infrastructurev1alpha1.InstanceStack) bool) ([]string, error) {
+	budgets := &infrastructurev1alpha1.InstanceBudgetList{}
+	if err := c.List(ctx, budgets, client.InNamespace(instanceStack.Namespace)); err != nil {
+		return nil, err
+	}
+	if len(budgets.Items) == 0 {
+		return nil, nil
+	}
+	others, _, err := NamespaceUsage(ctx, c, cache, instanceStack.Namespace, func(other *infrastructurev1alpha1.InstanceStack) bool {
+		return other.Name != instanceStack.Name && (count == nil || count(other))
+	})
+	if err != nil {
+		return nil, err
+	}
+	requested, _ := Usage(ctx, cache, instanceStack)
+	used := Add(others, requested)
+
+	var exceeded []string
+	for _, b := range budgets.Items {
+		for _, limit := range Exceeded(b.Spec, used, requested) {
+			exceeded = append(exceeded, fmt.Sprintf("InstanceBudget %s: %s", b.Name, limit))
+		}
+	}
+	return exceeded, nil
+}
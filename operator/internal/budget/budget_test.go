@@ -0,0 +1,158 @@
+package budget
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+	"github.com/gunniLee/cloud-provider-operator/internal/catalog"
+	"github.com/gunniLee/cloud-provider-operator/internal/openstack"
+)
+
+var _ = Describe("InstanceBudget", func() {
+	ctx := context.Background()
+
+	var cache *catalog.Cache
+
+	BeforeEach(func() {
+		cache = catalog.NewCache(nil, 0)
+		cache.Set(infrastructurev1alpha1.DefaultProviderConfigName, &catalog.Snapshot{
+			Flavors: []openstack.Flavor{{Name: "m1.small", VCPUs: 2, RAM: 2048}, {Name: "m1.large", VCPUs: 8, RAM: 16384}},
+		})
+	})
+
+	server := func(name, flavor string) *infrastructurev1alpha1.InstanceStack {
+		return &infrastructurev1alpha1.InstanceStack{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+			Spec:       infrastructurev1alpha1.InstanceStackSpec{FlavorName: flavor},
+		}
+	}
+
+	volume := func(name string, sizeGiB string) *infrastructurev1alpha1.InstanceStack {
+		return &infrastructurev1alpha1.InstanceStack{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+			Spec: infrastructurev1alpha1.InstanceStackSpec{
+				Program:    "volume",
+				Parameters: map[string]apiextensionsv1.JSON{"sizeGiB": {Raw: []byte(sizeGiB)}},
+			},
+		}
+	}
+
+	newClient := func(objs ...client.Object) client.Client {
+		scheme := runtime.NewScheme()
+		Expect(infrastructurev1alpha1.AddToScheme(scheme)).To(Succeed())
+		return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+	}
+
+	It("Should count servers by flavor and volumes by size", func() {
+		usage, known := Usage(ctx, cache, server("web", "m1.large"))
+		Expect(known).To(BeTrue())
+		Expect(usage).To(Equal(infrastructurev1alpha1.BudgetUsage{Instances: 1, VCPUs: 8, RAMMiB: 16384}))
+
+		usage, known = Usage(ctx, cache, volume("data", "100"))
+		Expect(known).To(BeTrue())
+		Expect(usage).To(Equal(infrastructurev1alpha1.BudgetUsage{VolumeGiB: 100}))
+	})
+
+	It("Should count libvirt domains by spec.libvirt and EC2 instances as instances only", func() {
+		domain := &infrastructurev1alpha1.InstanceStack{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "dev"},
+			Spec: infrastructurev1alpha1.InstanceStackSpec{
+				Program: "libvirt-domain",
+				Libvirt: &infrastructurev1alpha1.LibvirtInstanceSpec{Image: "https://example.com/jammy.qcow2", VCPUs: 4},
+			},
+		}
+		usage, known := Usage(ctx, cache, domain)
+		Expect(known).To(BeTrue())
+		Expect(usage).To(Equal(infrastructurev1alpha1.BudgetUsage{Instances: 1, VCPUs: 4, RAMMiB: 1024}))
+
+		ec2 := &infrastructurev1alpha1.InstanceStack{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+			Spec: infrastructurev1alpha1.InstanceStackSpec{
+				Program: "ec2-instance",
+				AWS:     &infrastructurev1alpha1.AWSInstanceSpec{InstanceType: "t3.micro", AMI: "ami-0123456789abcdef0"},
+			},
+		}
+		usage, known = Usage(ctx, cache, ec2)
+		Expect(known).To(BeFalse())
+		Expect(usage).To(Equal(infrastructurev1alpha1.BudgetUsage{Instances: 1}))
+
+		c := newClient(domain, ec2)
+		used, unknown, err := NamespaceUsage(ctx, c, cache, "default", nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(used).To(Equal(infrastructurev1alpha1.BudgetUsage{Instances: 2, VCPUs: 4, RAMMiB: 1024}))
+		Expect(unknown).To(ConsistOf("t3.micro"))
+	})
+
+	It("Should count servers with unknown flavors as instances only", func() {
+		usage, known := Usage(ctx, cache, server("web", "m1.missing"))
+		Expect(known).To(BeFalse())
+		Expect(usage).To(Equal(infrastructurev1alpha1.BudgetUsage{Instances: 1}))
+
+		c := newClient(server("web", "m1.missing"), server("db", "m1.small"))
+		used, unknown, err := NamespaceUsage(ctx, c, cache, "default", nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(used).To(Equal(infrastructurev1alpha1.BudgetUsage{Instances: 2, VCPUs: 2, RAMMiB: 2048}))
+		Expect(unknown).To(ConsistOf("m1.missing"))
+	})
+
+	It("Should report only the limits the InstanceStack consumes", func() {
+		spec := infrastructurev1alpha1.InstanceBudgetSpec{VCPUs: ptr.To[int64](10), VolumeGiB: ptr.To[int64](50)}
+		used := infrastructurev1alpha1.BudgetUsage{Instances: 2, VCPUs: 12, VolumeGiB: 80}
+		Expect(Exceeded(spec, used, infrastructurev1alpha1.BudgetUsage{Instances: 1, VCPUs: 8})).To(ConsistOf("vcpus: 12 of 10"))
+		Expect(Exceeded(spec, used, used)).To(ConsistOf("vcpus: 12 of 10", "volumeGiB: 80 of 50"))
+	})
+
+	It("Should reject an InstanceStack that does not fit in a budget", func() {
+		budget := &infrastructurev1alpha1.InstanceBudget{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "team"},
+			Spec:       infrastructurev1alpha1.InstanceBudgetSpec{Instances: ptr.To[int64](2), VCPUs: ptr.To[int64](8)},
+		}
+		c := newClient(budget, server("web", "m1.small"), volume("data", "10"))
+
+		exceeded, err := Check(ctx, c, cache, server("db", "m1.small"), nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exceeded).To(BeEmpty())
+
+		exceeded, err = Check(ctx, c, cache, server("db", "m1.large"), nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exceeded).To(ConsistOf("InstanceBudget team: vcpus: 10 of 8"))
+	})
+
+	It("Should not count the InstanceStack being checked twice", func() {
+		budget := &infrastructurev1alpha1.InstanceBudget{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "team"},
+			Spec:       infrastructurev1alpha1.InstanceBudgetSpec{Instances: ptr.To[int64](1)},
+		}
+		web := server("web", "m1.small")
+		c := newClient(budget, web)
+
+		exceeded, err := Check(ctx, c, cache, web, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exceeded).To(BeEmpty())
+
+		exceeded, err = Check(ctx, c, cache, server("db", "m1.small"), nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exceeded).To(ConsistOf("InstanceBudget team: instances: 2 of 1"))
+	})
+
+	It("Should skip InstanceStacks that count rejects", func() {
+		budget := &infrastructurev1alpha1.InstanceBudget{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "team"},
+			Spec:       infrastructurev1alpha1.InstanceBudgetSpec{Instances: ptr.To[int64](1)},
+		}
+		c := newClient(budget, server("web", "m1.small"))
+
+		exceeded, err := Check(ctx, c, cache, server("db", "m1.small"), func(*infrastructurev1alpha1.InstanceStack) bool { return false })
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exceeded).To(BeEmpty())
+	})
+})
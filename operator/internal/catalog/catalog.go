@@ -0,0 +1,422 @@
+// Package catalog keeps an in-memory copy of the flavors, images, networks and
+// availability zones of every ProviderConfig so that InstanceStacks can be
+// checked against what actually exists before Pulumi runs.
+package catalog
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/agext/levenshtein"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+	"github.com/gunniLee/cloud-provider-operator/internal/openstack"
+)
+
+var catalogLog = logf.Log.WithName("catalog")
+
+// DefaultInterval is how often the catalog is listed when no interval is given.
+const DefaultInterval = 10 * time.Minute
+
+// Snapshot is the catalog of one ProviderConfig at a point in time. A nil
+// list means that part of the catalog is unknown and is not checked.
+type Snapshot struct {
+	Flavors           []openstack.Flavor
+	Images            []openstack.Image
+	Networks          []openstack.Network
+	AvailabilityZones []openstack.AvailabilityZone
+	RefreshedAt       time.Time
+
+	// Region is the region the catalog was listed in, empty for mirrored
+	// snapshots.
+	Region string
+
+	// Quotas is nil when the quota APIs could not be read.
+	Quotas *openstack.Quotas
+
+	// mirrored is set on snapshots rebuilt from Flavor and Image objects.
+	mirrored bool
+}
+
+// FetchFunc lists the catalog of a cloud.
+type FetchFunc func(ctx context.Context, creds openstack.Credentials) (*Snapshot, error)
+
+// Fetch lists the catalog using the OpenStack REST API.
+func Fetch(ctx context.Context, creds openstack.Credentials) (*Snapshot, error) {
+	osClient, err := openstack.NewClient(ctx, creds)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &Snapshot{Region: creds.Region, RefreshedAt: time.Now()}
+	if snapshot.Flavors, err = osClient.ListFlavors(ctx); err != nil {
+		return nil, err
+	}
+	if snapshot.Images, err = osClient.ListImages(ctx); err != nil {
+		return nil, err
+	}
+	if snapshot.Networks, err = osClient.ListNetworks(ctx); err != nil {
+		return nil, err
+	}
+	if snapshot.AvailabilityZones, err = osClient.ListAvailabilityZones(ctx); err != nil {
+		return nil, err
+	}
+	// Quotas are informational here; some clouds restrict the quota APIs.
+	if snapshot.Quotas, err = osClient.GetQuotas(ctx); err != nil {
+		catalogLog.V(1).Info("skipping project quotas", "reason", err.Error())
+	}
+	// An empty cloud is still a known catalog.
+	if snapshot.Flavors == nil {
+		snapshot.Flavors = []openstack.Flavor{}
+	}
+	if snapshot.Images == nil {
+		snapshot.Images = []openstack.Image{}
+	}
+	if snapshot.Networks == nil {
+		snapshot.Networks = []openstack.Network{}
+	}
+	if snapshot.AvailabilityZones == nil {
+		snapshot.AvailabilityZones = []openstack.AvailabilityZone{}
+	}
+	return snapshot, nil
+}
+
+// Cache holds the latest Snapshot per ProviderConfig name and refreshes them
+// periodically once started by the manager.
+type Cache struct {
+	Client   client.Client
+	Interval time.Duration
+	Fetch    FetchFunc
+
+	// Elected, when set, is closed once this replica is the leader. Every
+	// replica refreshes its own catalog, but only the leader records it in
+	// the ProviderConfig status.
+	Elected <-chan struct{}
+
+	mu        sync.RWMutex
+	snapshots map[string]*Snapshot
+}
+
+// NewCache returns a Cache that lists the catalog over the OpenStack API.
+func NewCache(c client.Client, interval time.Duration) *Cache {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Cache{Client: c, Interval: interval, Fetch: Fetch}
+}
+
+// Get returns the last snapshot of a ProviderConfig. Before the first
+// successful refresh it falls back to the mirrored Flavor and Image objects,
+// and returns nil if there are none.
+func (c *Cache) Get(ctx context.Context, providerConfigName string) *Snapshot {
+	if c == nil {
+		return nil
+	}
+	if providerConfigName == "" {
+		providerConfigName = infrastructurev1alpha1.DefaultProviderConfigName
+	}
+	c.mu.RLock()
+	snapshot := c.snapshots[providerConfigName]
+	c.mu.RUnlock()
+	if snapshot == nil && c.Client != nil {
+		snapshot = c.snapshotFromObjects(ctx, providerConfigName)
+	}
+	return snapshot
+}
+
+// GetRegion is Get for an InstanceStack in region. The catalog is only
+// listed in the primary region of the ProviderConfig, so there is no
+// snapshot for the others.
+func (c *Cache) GetRegion(ctx context.Context, providerConfigName, region string) *Snapshot {
+	snapshot := c.Get(ctx, providerConfigName)
+	if region == "" || snapshot == nil || snapshot.Region == region {
+		return snapshot
+	}
+	return nil
+}
+
+// Names returns the ProviderConfigs with a snapshot in memory.
+func (c *Cache) Names() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	names := make([]string, 0, len(c.snapshots))
+	for name, snapshot := range c.snapshots {
+		if snapshot != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Set stores a snapshot, replacing any previous one.
+func (c *Cache) Set(providerConfigName string, snapshot *Snapshot) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.snapshots == nil {
+		c.snapshots = map[string]*Snapshot{}
+	}
+	c.snapshots[providerConfigName] = snapshot
+}
+
+// Start implements manager.Runnable.
+func (c *Cache) Start(ctx context.Context) error {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	for {
+		c.RefreshAll(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. Every replica
+// serves webhooks, so every replica keeps its own catalog.
+func (c *Cache) NeedLeaderElection() bool {
+	return false
+}
+
+// RefreshAll refreshes every ProviderConfig plus the implicit "default" one
+// backed by the environment. Failures are logged and recorded on the
+// ProviderConfig, keeping the previous snapshot.
+func (c *Cache) RefreshAll(ctx context.Context) {
+	providerConfigs := &infrastructurev1alpha1.ProviderConfigList{}
+	if err := c.Client.List(ctx, providerConfigs); err != nil {
+		catalogLog.Error(err, "failed to list ProviderConfigs")
+		return
+	}
+
+	refreshedDefault := false
+	for i := range providerConfigs.Items {
+		providerConfig := &providerConfigs.Items[i]
+		refreshedDefault = refreshedDefault || providerConfig.Name == infrastructurev1alpha1.DefaultProviderConfigName
+		if providerConfig.ProviderType() != infrastructurev1alpha1.ProviderOpenStack {
+			// 카탈로그와 쿼터는 OpenStack에만 있음
+			continue
+		}
+		err := c.Refresh(ctx, providerConfig.Name)
+		c.recordStatus(ctx, providerConfig, err)
+	}
+	if !refreshedDefault {
+		if err := c.Refresh(ctx, infrastructurev1alpha1.DefaultProviderConfigName); err != nil {
+			catalogLog.V(1).Info("skipping catalog of the environment credentials", "reason", err.Error())
+		}
+	}
+}
+
+// Refresh lists the catalog of one ProviderConfig and stores it.
+func (c *Cache) Refresh(ctx context.Context, providerConfigName string) error {
+	creds, err := openstack.ResolveCredentials(ctx, c.Client, providerConfigName)
+	if err != nil {
+		return err
+	}
+	snapshot, err := c.Fetch(ctx, creds)
+	if err != nil {
+		catalogLog.Error(err, "failed to refresh catalog", "providerConfig", providerConfigName)
+		return err
+	}
+	c.Set(providerConfigName, snapshot)
+	catalogLog.V(1).Info("refreshed catalog", "providerConfig", providerConfigName,
+		"flavors", len(snapshot.Flavors), "images", len(snapshot.Images), "networks", len(snapshot.Networks))
+	return nil
+}
+
+func (c *Cache) recordStatus(ctx context.Context, providerConfig *infrastructurev1alpha1.ProviderConfig, refreshErr error) {
+	if !c.leading() {
+		return
+	}
+	condition := metav1.Condition{
+		Type:               infrastructurev1alpha1.ConditionCatalogReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             infrastructurev1alpha1.ReasonCatalogRefreshed,
+		Message:            "catalog listed successfully",
+		ObservedGeneration: providerConfig.Generation,
+	}
+	if refreshErr != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = infrastructurev1alpha1.ReasonCatalogRefreshFailed
+		condition.Message = refreshErr.Error()
+	} else {
+		snapshot := c.Get(ctx, providerConfig.Name)
+		now := metav1.NewTime(snapshot.RefreshedAt)
+		providerConfig.Status.CatalogRefreshTime = &now
+		providerConfig.Status.Quota = projectQuota(snapshot.Quotas)
+	}
+	meta.SetStatusCondition(&providerConfig.Status.Conditions, condition)
+	if err := c.Client.Status().Update(ctx, providerConfig); err != nil {
+		catalogLog.Error(err, "failed to update ProviderConfig status", "providerConfig", providerConfig.Name)
+	}
+}
+
+// leading reports whether this replica writes the ProviderConfig status.
+func (c *Cache) leading() bool {
+	if c.Elected == nil {
+		return true
+	}
+	select {
+	case <-c.Elected:
+		return true
+	default:
+		return false
+	}
+}
+
+// projectQuota converts quotas for ProviderConfig status.
+func projectQuota(quotas *openstack.Quotas) *infrastructurev1alpha1.ProjectQuota {
+	if quotas == nil {
+		return nil
+	}
+	usage := func(q openstack.QuotaUsage) infrastructurev1alpha1.QuotaUsage {
+		return infrastructurev1alpha1.QuotaUsage{Limit: q.Limit, InUse: q.InUse, Reserved: q.Reserved}
+	}
+	return &infrastructurev1alpha1.ProjectQuota{
+		Instances:   usage(quotas.Instances),
+		Cores:       usage(quotas.Cores),
+		RAMMiB:      usage(quotas.RAM),
+		FloatingIPs: usage(quotas.FloatingIPs),
+		Ports:       usage(quotas.Ports),
+	}
+}
+
+// Problem describes a reference that does not exist in the catalog.
+type Problem struct {
+	Field       string
+	Value       string
+	Suggestions []string
+}
+
+func (p Problem) String() string {
+	msg := fmt.Sprintf("%s %q not found in the OpenStack catalog", p.Field, p.Value)
+	if len(p.Suggestions) > 0 {
+		msg += fmt.Sprintf("; did you mean %s?", strings.Join(quoteAll(p.Suggestions), " or "))
+	}
+	return msg
+}
+
+// Reference is the set of catalog names an InstanceStack points at. Empty
+// fields are not checked.
+type Reference struct {
+	FlavorName       string
+	ImageName        string
+	NetworkUUID      string
+	AvailabilityZone string
+}
+
+// Check returns a Problem for every reference missing from the snapshot.
+func (s *Snapshot) Check(ref Reference) []Problem {
+	var problems []Problem
+
+	if ref.FlavorName != "" && s.Flavors != nil {
+		names := make([]string, 0, len(s.Flavors))
+		for _, f := range s.Flavors {
+			names = append(names, f.Name)
+		}
+		if !contains(names, ref.FlavorName) {
+			problems = append(problems, Problem{Field: "flavorName", Value: ref.FlavorName, Suggestions: Suggest(ref.FlavorName, names)})
+		}
+	}
+
+	if ref.ImageName != "" && s.Images != nil {
+		names := make([]string, 0, len(s.Images))
+		for _, i := range s.Images {
+			if i.Status == "" || i.Status == "active" {
+				names = append(names, i.Name)
+			}
+		}
+		if !contains(names, ref.ImageName) {
+			problems = append(problems, Problem{Field: "imageName", Value: ref.ImageName, Suggestions: Suggest(ref.ImageName, names)})
+		}
+	}
+
+	if ref.NetworkUUID != "" && s.Networks != nil {
+		found := false
+		var byName []string
+		for _, n := range s.Networks {
+			found = found || n.ID == ref.NetworkUUID
+			if strings.EqualFold(n.Name, ref.NetworkUUID) {
+				byName = append(byName, n.ID)
+			}
+		}
+		if !found {
+			problems = append(problems, Problem{Field: "networkUUID", Value: ref.NetworkUUID, Suggestions: byName})
+		}
+	}
+
+	if ref.AvailabilityZone != "" && s.AvailabilityZones != nil {
+		names := make([]string, 0, len(s.AvailabilityZones))
+		for _, z := range s.AvailabilityZones {
+			if z.Available {
+				names = append(names, z.Name)
+			}
+		}
+		if !contains(names, ref.AvailabilityZone) {
+			problems = append(problems, Problem{Field: "availabilityZone", Value: ref.AvailabilityZone, Suggestions: Suggest(ref.AvailabilityZone, names)})
+		}
+	}
+	return problems
+}
+
+// maxSuggestions caps how many alternatives a Problem lists.
+const maxSuggestions = 3
+
+// Suggest returns up to three candidates close to value, nearest first.
+// Candidates differing only in case always qualify; others must be within an
+// edit distance of a third of the value's length (at least two).
+func Suggest(value string, candidates []string) []string {
+	type scored struct {
+		name     string
+		distance int
+	}
+	limit := len(value) / 3
+	if limit < 2 {
+		limit = 2
+	}
+	lower := strings.ToLower(value)
+
+	var matches []scored
+	for _, candidate := range candidates {
+		d := levenshtein.Distance(lower, strings.ToLower(candidate), nil)
+		if d <= limit || strings.Contains(strings.ToLower(candidate), lower) {
+			matches = append(matches, scored{candidate, d})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].distance < matches[j].distance })
+
+	var out []string
+	for _, m := range matches {
+		if len(out) == maxSuggestions {
+			break
+		}
+		if !contains(out, m.name) {
+			out = append(out, m.name)
+		}
+	}
+	return out
+}
+
+func contains(slice []string, s string) bool {
+	for _, item := range slice {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func quoteAll(values []string) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = fmt.Sprintf("%q", v)
+	}
+	return out
+}
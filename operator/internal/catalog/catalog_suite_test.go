@@ -0,0 +1,14 @@
+package catalog
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestCatalog(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Catalog Suite")
+}
@@ -0,0 +1,238 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+	"github.com/gunniLee/cloud-provider-operator/internal/openstack"
+	"github.com/gunniLee/cloud-provider-operator/internal/openstack/openstacktest"
+)
+
+const networkUUID = "0a7e0885-9deb-45c6-bfeb-d28821d8d3d3"
+
+var _ = Describe("Catalog", func() {
+	var cloud *openstacktest.Server
+
+	BeforeEach(func() {
+		cloud = openstacktest.NewServer()
+		cloud.Flavors = []openstack.Flavor{
+			{ID: "1", Name: "m1.small", VCPUs: 1, RAM: 2048, Disk: 20},
+			{ID: "2", Name: "m1.large", VCPUs: 4, RAM: 8192, Disk: 80},
+		}
+		cloud.AddImage("img-1", "ubuntu-22.04")
+		cloud.Networks = []openstack.Network{{ID: networkUUID, Name: "private"}}
+		cloud.AvailabilityZones = []openstack.AvailabilityZone{{Name: "nova", Available: true}}
+		cloud.Quotas.Cores = openstack.QuotaUsage{Limit: 20, InUse: 4}
+		DeferCleanup(cloud.Close)
+	})
+
+	It("Should list the catalog from the OpenStack API", func() {
+		snapshot, err := Fetch(context.Background(), cloud.Credentials())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(snapshot.Flavors).To(HaveLen(2))
+		Expect(snapshot.Images).To(HaveLen(1))
+		Expect(snapshot.Images[0].Name).To(Equal("ubuntu-22.04"))
+		Expect(snapshot.Networks).To(HaveLen(1))
+		Expect(snapshot.AvailabilityZones).To(ConsistOf(openstack.AvailabilityZone{Name: "nova", Available: true}))
+	})
+
+	It("Should report unknown references with suggestions", func() {
+		snapshot, err := Fetch(context.Background(), cloud.Credentials())
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(snapshot.Check(Reference{
+			FlavorName:  "m1.small",
+			ImageName:   "ubuntu-22.04",
+			NetworkUUID: networkUUID,
+		})).To(BeEmpty())
+
+		problems := snapshot.Check(Reference{
+			FlavorName:       "m1.smal",
+			ImageName:        "Ubuntu-22.04",
+			NetworkUUID:      "private",
+			AvailabilityZone: "zone-b",
+		})
+		Expect(problems).To(HaveLen(4))
+		Expect(problems[0].Suggestions).To(Equal([]string{"m1.small"}))
+		Expect(problems[0].String()).To(Equal(`flavorName "m1.smal" not found in the OpenStack catalog; did you mean "m1.small"?`))
+		Expect(problems[1].Suggestions).To(Equal([]string{"ubuntu-22.04"}))
+		Expect(problems[2].Suggestions).To(Equal([]string{networkUUID}))
+		Expect(problems[3].Suggestions).To(BeEmpty())
+	})
+
+	It("Should refresh every ProviderConfig and record the result in its status", func() {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(infrastructurev1alpha1.AddToScheme(scheme)).To(Succeed())
+
+		creds := cloud.Credentials()
+		providerConfig := &infrastructurev1alpha1.ProviderConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: "lab"},
+			Spec: infrastructurev1alpha1.ProviderConfigSpec{
+				AuthURL:    creds.AuthURL,
+				Region:     creds.Region,
+				TenantName: creds.TenantName,
+				CredentialsSecretRef: &infrastructurev1alpha1.SecretReference{
+					Name: "lab-credentials", Namespace: "operator-system",
+				},
+			},
+		}
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "lab-credentials", Namespace: "operator-system"},
+			Data:       map[string][]byte{"username": []byte(creds.Username), "password": []byte(creds.Password)},
+		}
+		k8sClient := fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(providerConfig, secret).
+			WithStatusSubresource(providerConfig).
+			Build()
+
+		cache := NewCache(k8sClient, 0)
+		cache.RefreshAll(context.Background())
+
+		Expect(cache.Get(context.Background(), "lab")).NotTo(BeNil())
+		Expect(cache.Get(context.Background(), "lab").Flavors).To(HaveLen(2))
+
+		updated := &infrastructurev1alpha1.ProviderConfig{}
+		Expect(k8sClient.Get(context.Background(), types.NamespacedName{Name: "lab"}, updated)).To(Succeed())
+		Expect(updated.Status.CatalogRefreshTime).NotTo(BeNil())
+		Expect(updated.Status.Quota).NotTo(BeNil())
+		Expect(updated.Status.Quota.Cores).To(Equal(infrastructurev1alpha1.QuotaUsage{Limit: 20, InUse: 4}))
+		Expect(apimeta.IsStatusConditionTrue(updated.Status.Conditions, infrastructurev1alpha1.ConditionCatalogReady)).To(BeTrue())
+	})
+
+	It("Should leave the ProviderConfig status to the leader", func() {
+		scheme := runtime.NewScheme()
+		Expect(infrastructurev1alpha1.AddToScheme(scheme)).To(Succeed())
+		providerConfig := &infrastructurev1alpha1.ProviderConfig{ObjectMeta: metav1.ObjectMeta{Name: "lab"}}
+		k8sClient := fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(providerConfig).
+			WithStatusSubresource(providerConfig).
+			Build()
+		elected := make(chan struct{})
+		cache := NewCache(k8sClient, 0)
+		cache.Elected = elected
+
+		cache.recordStatus(context.Background(), providerConfig.DeepCopy(), errors.New("unreachable"))
+		updated := &infrastructurev1alpha1.ProviderConfig{}
+		Expect(k8sClient.Get(context.Background(), types.NamespacedName{Name: "lab"}, updated)).To(Succeed())
+		Expect(updated.Status.Conditions).To(BeEmpty())
+
+		By("recording it once elected")
+		close(elected)
+		cache.recordStatus(context.Background(), updated.DeepCopy(), errors.New("unreachable"))
+		Expect(k8sClient.Get(context.Background(), types.NamespacedName{Name: "lab"}, updated)).To(Succeed())
+		Expect(apimeta.IsStatusConditionFalse(updated.Status.Conditions, infrastructurev1alpha1.ConditionCatalogReady)).To(BeTrue())
+	})
+
+	It("Should mirror flavors and images into cluster-scoped objects", func() {
+		ctx := context.Background()
+		scheme := runtime.NewScheme()
+		Expect(infrastructurev1alpha1.AddToScheme(scheme)).To(Succeed())
+		stale := &infrastructurev1alpha1.Flavor{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   ObjectName("default", "gone"),
+				Labels: map[string]string{infrastructurev1alpha1.ProviderConfigLabel: "default"},
+			},
+		}
+		k8sClient := fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(stale).
+			WithStatusSubresource(&infrastructurev1alpha1.Flavor{}, &infrastructurev1alpha1.Image{}).
+			Build()
+
+		snapshot, err := Fetch(ctx, cloud.Credentials())
+		Expect(err).NotTo(HaveOccurred())
+		cache := NewCache(k8sClient, 0)
+		cache.Set("default", snapshot)
+
+		mirror := &Mirror{Client: k8sClient, Cache: cache}
+		mirror.SyncAll(ctx)
+
+		flavors := &infrastructurev1alpha1.FlavorList{}
+		Expect(k8sClient.List(ctx, flavors)).To(Succeed())
+		Expect(flavors.Items).To(HaveLen(2))
+		small := &infrastructurev1alpha1.Flavor{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "default-1"}, small)).To(Succeed())
+		Expect(small.Spec.Name).To(Equal("m1.small"))
+		Expect(small.Spec.VCPUs).To(BeEquivalentTo(1))
+		Expect(small.Spec.RAMMiB).To(BeEquivalentTo(2048))
+		Expect(small.Status.LastSyncTime).NotTo(BeNil())
+
+		image := &infrastructurev1alpha1.Image{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "default-img-1"}, image)).To(Succeed())
+		Expect(image.Spec.Name).To(Equal("ubuntu-22.04"))
+		Expect(image.Status.Status).To(Equal("active"))
+
+		By("validating from the mirrored objects when the cache is empty")
+		fresh := NewCache(k8sClient, 0)
+		fallback := fresh.Get(ctx, "default")
+		Expect(fallback).NotTo(BeNil())
+		Expect(fallback.Check(Reference{FlavorName: "m1.large", ImageName: "ubuntu-22.04", NetworkUUID: networkUUID})).To(BeEmpty())
+		Expect(fallback.Check(Reference{FlavorName: "m1.huge"})).To(HaveLen(1))
+	})
+
+	It("Should delete a stale image whose name matches a current flavor", func() {
+		ctx := context.Background()
+		scheme := runtime.NewScheme()
+		Expect(infrastructurev1alpha1.AddToScheme(scheme)).To(Succeed())
+		// An image gone from the catalog whose object name matches flavor "1".
+		stale := &infrastructurev1alpha1.Image{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   ObjectName("default", "1"),
+				Labels: map[string]string{infrastructurev1alpha1.ProviderConfigLabel: "default"},
+			},
+		}
+		k8sClient := fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(stale).
+			WithStatusSubresource(&infrastructurev1alpha1.Flavor{}, &infrastructurev1alpha1.Image{}).
+			Build()
+		snapshot, err := Fetch(ctx, cloud.Credentials())
+		Expect(err).NotTo(HaveOccurred())
+		Expect((&Mirror{Client: k8sClient}).Sync(ctx, "default", snapshot)).To(Succeed())
+
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "default-1"}, &infrastructurev1alpha1.Flavor{})).To(Succeed())
+		images := &infrastructurev1alpha1.ImageList{}
+		Expect(k8sClient.List(ctx, images)).To(Succeed())
+		Expect(images.Items).To(HaveLen(1))
+		Expect(images.Items[0].Name).To(Equal("default-img-1"))
+	})
+
+	It("Should not rewrite the status of unchanged mirrored objects on every refresh", func() {
+		ctx := context.Background()
+		scheme := runtime.NewScheme()
+		Expect(infrastructurev1alpha1.AddToScheme(scheme)).To(Succeed())
+		k8sClient := fake.NewClientBuilder().WithScheme(scheme).
+			WithStatusSubresource(&infrastructurev1alpha1.Flavor{}, &infrastructurev1alpha1.Image{}).
+			Build()
+		snapshot, err := Fetch(ctx, cloud.Credentials())
+		Expect(err).NotTo(HaveOccurred())
+		mirror := &Mirror{Client: k8sClient}
+		Expect(mirror.Sync(ctx, "default", snapshot)).To(Succeed())
+		small := &infrastructurev1alpha1.Flavor{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "default-1"}, small)).To(Succeed())
+		resourceVersion := small.ResourceVersion
+
+		next := *snapshot
+		next.RefreshedAt = snapshot.RefreshedAt.Add(DefaultInterval)
+		Expect(mirror.Sync(ctx, "default", &next)).To(Succeed())
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "default-1"}, small)).To(Succeed())
+		Expect(small.ResourceVersion).To(Equal(resourceVersion))
+
+		By("rewriting it once the last sync time is an hour old")
+		next.RefreshedAt = snapshot.RefreshedAt.Add(lastSyncResolution)
+		Expect(mirror.Sync(ctx, "default", &next)).To(Succeed())
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "default-1"}, small)).To(Succeed())
+		Expect(small.Status.LastSyncTime.Time).To(BeTemporally("~", next.RefreshedAt, time.Second))
+	})
+})
@@ -0,0 +1,265 @@
+package catalog
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+	"github.com/gunniLee/cloud-provider-operator/internal/openstack"
+)
+
+// mirrorPollInterval is how often the Mirror looks for newer snapshots. It is
+// only an in-memory comparison, so it is much shorter than the refresh interval.
+const mirrorPollInterval = 30 * time.Second
+
+// lastSyncResolution is how old status.lastSyncTime of an unchanged mirrored
+// object may get before it is rewritten, so that a refresh does not write the
+// status of every Flavor and Image.
+const lastSyncResolution = time.Hour
+
+// Mirror copies the cached catalog into cluster-scoped Flavor and Image
+// objects so users can inspect it with kubectl. Objects that disappear from
+// the catalog are deleted.
+type Mirror struct {
+	Client client.Client
+	Cache  *Cache
+
+	// synced is the RefreshedAt of the last snapshot mirrored per ProviderConfig.
+	synced map[string]time.Time
+}
+
+// +kubebuilder:rbac:groups=infrastructure.cloudprovider.io,resources=flavors;images,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=infrastructure.cloudprovider.io,resources=flavors/status;images/status,verbs=get;update;patch
+
+// Start implements manager.Runnable.
+func (m *Mirror) Start(ctx context.Context) error {
+	ticker := time.NewTicker(mirrorPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			m.SyncAll(ctx)
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable so only one
+// replica writes the mirrored objects.
+func (m *Mirror) NeedLeaderElection() bool {
+	return true
+}
+
+// SyncAll mirrors every snapshot of the cache that is newer than the one
+// mirrored last.
+func (m *Mirror) SyncAll(ctx context.Context) {
+	if m.synced == nil {
+		m.synced = map[string]time.Time{}
+	}
+	for _, name := range m.Cache.Names() {
+		snapshot := m.Cache.Get(ctx, name)
+		if snapshot == nil || snapshot.mirrored || !snapshot.RefreshedAt.After(m.synced[name]) {
+			continue
+		}
+		if err := m.Sync(ctx, name, snapshot); err != nil {
+			catalogLog.Error(err, "failed to mirror catalog", "providerConfig", name)
+			continue
+		}
+		m.synced[name] = snapshot.RefreshedAt
+	}
+}
+
+// Sync makes the Flavor and Image objects of a ProviderConfig match snapshot.
+func (m *Mirror) Sync(ctx context.Context, providerConfigName string, snapshot *Snapshot) error {
+	owner := &infrastructurev1alpha1.ProviderConfig{}
+	if err := m.Client.Get(ctx, types.NamespacedName{Name: providerConfigName}, owner); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		owner = nil
+	}
+	syncTime := metav1.NewTime(snapshot.RefreshedAt)
+
+	keepFlavors := map[string]bool{}
+	for _, f := range snapshot.Flavors {
+		flavor := &infrastructurev1alpha1.Flavor{ObjectMeta: metav1.ObjectMeta{Name: ObjectName(providerConfigName, f.ID)}}
+		keepFlavors[flavor.Name] = true
+		if _, err := controllerutil.CreateOrUpdate(ctx, m.Client, flavor, func() error {
+			setMirrorMeta(flavor, providerConfigName)
+			flavor.Spec = flavorSpec(providerConfigName, f)
+			return setOwner(owner, flavor, m.Client)
+		}); err != nil {
+			return fmt.Errorf("failed to mirror flavor %q: %w", f.Name, err)
+		}
+		if !lastSyncStale(flavor.Status.LastSyncTime, syncTime) {
+			continue
+		}
+		flavor.Status.LastSyncTime = &syncTime
+		if err := m.Client.Status().Update(ctx, flavor); err != nil {
+			return fmt.Errorf("failed to update status of flavor %q: %w", f.Name, err)
+		}
+	}
+	flavors := &infrastructurev1alpha1.FlavorList{}
+	if err := m.Client.List(ctx, flavors, client.MatchingLabels{infrastructurev1alpha1.ProviderConfigLabel: providerConfigName}); err != nil {
+		return err
+	}
+	for i := range flavors.Items {
+		if !keepFlavors[flavors.Items[i].Name] {
+			if err := client.IgnoreNotFound(m.Client.Delete(ctx, &flavors.Items[i])); err != nil {
+				return err
+			}
+		}
+	}
+
+	keepImages := map[string]bool{}
+	for _, img := range snapshot.Images {
+		image := &infrastructurev1alpha1.Image{ObjectMeta: metav1.ObjectMeta{Name: ObjectName(providerConfigName, img.ID)}}
+		keepImages[image.Name] = true
+		if _, err := controllerutil.CreateOrUpdate(ctx, m.Client, image, func() error {
+			setMirrorMeta(image, providerConfigName)
+			image.Spec = imageSpec(providerConfigName, img)
+			return setOwner(owner, image, m.Client)
+		}); err != nil {
+			return fmt.Errorf("failed to mirror image %q: %w", img.Name, err)
+		}
+		if image.Status.Status == img.Status && !lastSyncStale(image.Status.LastSyncTime, syncTime) {
+			continue
+		}
+		image.Status.Status = img.Status
+		image.Status.LastSyncTime = &syncTime
+		if err := m.Client.Status().Update(ctx, image); err != nil {
+			return fmt.Errorf("failed to update status of image %q: %w", img.Name, err)
+		}
+	}
+	images := &infrastructurev1alpha1.ImageList{}
+	if err := m.Client.List(ctx, images, client.MatchingLabels{infrastructurev1alpha1.ProviderConfigLabel: providerConfigName}); err != nil {
+		return err
+	}
+	for i := range images.Items {
+		if !keepImages[images.Items[i].Name] {
+			if err := client.IgnoreNotFound(m.Client.Delete(ctx, &images.Items[i])); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// lastSyncStale reports whether lastSyncTime is missing or more than
+// lastSyncResolution older than syncTime.
+func lastSyncStale(lastSyncTime *metav1.Time, syncTime metav1.Time) bool {
+	return lastSyncTime == nil || syncTime.Sub(lastSyncTime.Time) >= lastSyncResolution
+}
+
+func flavorSpec(providerConfigName string, f openstack.Flavor) infrastructurev1alpha1.FlavorSpec {
+	return infrastructurev1alpha1.FlavorSpec{
+		ProviderConfigName: providerConfigName,
+		ID:                 f.ID,
+		Name:               f.Name,
+		VCPUs:              int32(f.VCPUs),
+		RAMMiB:             int32(f.RAM),
+		DiskGiB:            int32(f.Disk),
+		IsPublic:           f.IsPublic,
+		Properties:         f.Extra,
+	}
+}
+
+func imageSpec(providerConfigName string, img openstack.Image) infrastructurev1alpha1.ImageSpec {
+	properties := img.Properties
+	if len(properties) == 0 {
+		properties = nil
+	}
+	return infrastructurev1alpha1.ImageSpec{
+		ProviderConfigName: providerConfigName,
+		ID:                 img.ID,
+		Name:               img.Name,
+		Visibility:         img.Visibility,
+		MinDiskGiB:         int32(img.MinDisk),
+		MinRAMMiB:          int32(img.MinRAM),
+		SizeBytes:          img.Size,
+		Properties:         properties,
+	}
+}
+
+func setMirrorMeta(obj client.Object, providerConfigName string) {
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[infrastructurev1alpha1.ProviderConfigLabel] = providerConfigName
+	obj.SetLabels(labels)
+}
+
+// setOwner makes the ProviderConfig own the object so that deleting it
+// removes the mirrored catalog. owner is nil for the environment-backed
+// default ProviderConfig.
+func setOwner(owner *infrastructurev1alpha1.ProviderConfig, obj client.Object, c client.Client) error {
+	if owner == nil {
+		return nil
+	}
+	return controllerutil.SetOwnerReference(owner, obj, c.Scheme())
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9.-]+`)
+
+// ObjectName returns the Kubernetes name of a mirrored catalog entry. OpenStack
+// IDs are usually UUIDs but may be arbitrary strings, so they are sanitized.
+func ObjectName(providerConfigName, id string) string {
+	name := providerConfigName + "-" + invalidNameChars.ReplaceAllString(strings.ToLower(id), "-")
+	name = strings.Trim(name, "-.")
+	if len(name) > 253 {
+		name = strings.Trim(name[:253], "-.")
+	}
+	return name
+}
+
+// snapshotFromObjects rebuilds a partial snapshot from the mirrored objects,
+// which lets a freshly started replica validate flavors and images before
+// its own first refresh. Networks and availability zones are not mirrored and
+// are left nil so they are not checked.
+func (c *Cache) snapshotFromObjects(ctx context.Context, providerConfigName string) *Snapshot {
+	selector := client.MatchingLabels{infrastructurev1alpha1.ProviderConfigLabel: providerConfigName}
+
+	flavors := &infrastructurev1alpha1.FlavorList{}
+	if err := c.Client.List(ctx, flavors, selector); err != nil {
+		catalogLog.V(1).Info("unable to list mirrored flavors", "reason", err.Error())
+		return nil
+	}
+	images := &infrastructurev1alpha1.ImageList{}
+	if err := c.Client.List(ctx, images, selector); err != nil {
+		catalogLog.V(1).Info("unable to list mirrored images", "reason", err.Error())
+		return nil
+	}
+	if len(flavors.Items) == 0 && len(images.Items) == 0 {
+		return nil
+	}
+
+	snapshot := &Snapshot{mirrored: true}
+	for _, f := range flavors.Items {
+		snapshot.Flavors = append(snapshot.Flavors, openstack.Flavor{
+			ID: f.Spec.ID, Name: f.Spec.Name, VCPUs: int(f.Spec.VCPUs), RAM: int(f.Spec.RAMMiB),
+			Disk: int(f.Spec.DiskGiB), IsPublic: f.Spec.IsPublic, Extra: f.Spec.Properties,
+		})
+		if f.Status.LastSyncTime != nil && f.Status.LastSyncTime.After(snapshot.RefreshedAt) {
+			snapshot.RefreshedAt = f.Status.LastSyncTime.Time
+		}
+	}
+	for _, img := range images.Items {
+		snapshot.Images = append(snapshot.Images, openstack.Image{
+			ID: img.Spec.ID, Name: img.Spec.Name, Status: img.Status.Status, Visibility: img.Spec.Visibility,
+			MinDisk: int(img.Spec.MinDiskGiB), MinRAM: int(img.Spec.MinRAMMiB), Size: img.Spec.SizeBytes,
+			Properties: img.Spec.Properties,
+		})
+	}
+	return snapshot
+}
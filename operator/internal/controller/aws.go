@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+	"github.com/gunniLee/cloud-provider-operator/internal/openstack"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+)
+
+// Keys of the Secret named by spec.aws.credentialsSecretRef.
+const (
+	awsAccessKeyIDKey     = "accessKeyID"
+	awsSecretAccessKeyKey = "secretAccessKey"
+	awsSessionTokenKey    = "sessionToken"
+)
+
+// awsProvider runs programs against the AWS account of the ProviderConfig.
+type awsProvider struct{}
+
+func (awsProvider) Target(ctx context.Context, c client.Reader, instanceStack *infrastructurev1alpha1.InstanceStack) (*Target, error) {
+	name := providerConfigOrDefault(instanceStack.Spec.ProviderConfigName)
+	providerConfig := &infrastructurev1alpha1.ProviderConfig{}
+	if err := c.Get(ctx, types.NamespacedName{Name: name}, providerConfig); err != nil {
+		return nil, fmt.Errorf("failed to get ProviderConfig %q: %w", name, err)
+	}
+
+	// 프로젝트에 묶인 네임스페이스는 바인딩의 ProviderConfig만 사용할 수 있음
+	binding, err := openstack.FindTenantBinding(ctx, c, instanceStack.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	if err := openstack.CheckTenantProviderConfig(binding, name); err != nil {
+		return nil, err
+	}
+
+	region := instanceStack.Spec.Region
+	if region == "" {
+		region = providerConfig.Spec.Region
+	} else if err := openstack.CheckRegion(ctx, c, name, region); err != nil {
+		return nil, err
+	}
+	if region == "" {
+		return nil, fmt.Errorf("ProviderConfig %q has no region", name)
+	}
+
+	config, stale, err := awsConfig(ctx, c, providerConfig, region)
+	if err != nil {
+		return nil, err
+	}
+	return &Target{Region: region, Config: config, Stale: stale}, nil
+}
+
+// awsConfig is the Pulumi AWS provider config of a ProviderConfig in region.
+// Like openStackConfig, it also returns the keys of settings it leaves out.
+func awsConfig(ctx context.Context, c client.Reader, providerConfig *infrastructurev1alpha1.ProviderConfig, region string) (auto.ConfigMap, []string, error) {
+	settings := providerConfig.Spec.AWS
+	if settings == nil {
+		settings = &infrastructurev1alpha1.AWSProviderConfig{}
+	}
+	config := auto.ConfigMap{"aws:region": {Value: region}}
+	optional := map[string]auto.ConfigValue{}
+
+	// 비어 있으면 AWS SDK의 기본 인증 체인(IRSA 등)을 사용
+	if ref := settings.CredentialsSecretRef; ref != nil {
+		secret := &corev1.Secret{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, secret); err != nil {
+			return nil, nil, fmt.Errorf("failed to get credentials Secret %s/%s: %w", ref.Namespace, ref.Name, err)
+		}
+		if len(secret.Data[awsAccessKeyIDKey]) == 0 || len(secret.Data[awsSecretAccessKeyKey]) == 0 {
+			return nil, nil, fmt.Errorf("credentials Secret %s/%s needs %s and %s", ref.Namespace, ref.Name, awsAccessKeyIDKey, awsSecretAccessKeyKey)
+		}
+		optional["aws:accessKey"] = auto.ConfigValue{Value: string(secret.Data[awsAccessKeyIDKey])}
+		optional["aws:secretKey"] = auto.ConfigValue{Value: string(secret.Data[awsSecretAccessKeyKey]), Secret: true}
+		optional["aws:token"] = auto.ConfigValue{Value: string(secret.Data[awsSessionTokenKey]), Secret: true}
+	}
+
+	// 로컬 EC2 호환 에뮬레이터는 계정 조회와 자격 증명 검증을 지원하지 않음
+	if settings.Endpoint != "" {
+		endpoints, err := json.Marshal([]map[string]string{{"ec2": settings.Endpoint}})
+		if err != nil {
+			return nil, nil, err
+		}
+		optional["aws:endpoints"] = auto.ConfigValue{Value: string(endpoints)}
+		optional["aws:skipCredentialsValidation"] = auto.ConfigValue{Value: "true"}
+		optional["aws:skipRequestingAccountId"] = auto.ConfigValue{Value: "true"}
+		optional["aws:skipMetadataApiCheck"] = auto.ConfigValue{Value: "true"}
+	}
+
+	var stale []string
+	for _, key := range []string{
+		"aws:accessKey", "aws:secretKey", "aws:token",
+		"aws:endpoints", "aws:skipCredentialsValidation", "aws:skipRequestingAccountId", "aws:skipMetadataApiCheck",
+	} {
+		if value, ok := optional[key]; ok && value.Value != "" {
+			config[key] = value
+			continue
+		}
+		stale = append(stale, key)
+	}
+	sort.Strings(stale)
+	return config, stale, nil
+}
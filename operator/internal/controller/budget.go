@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+)
+
+// budgetRetryInterval is how long an InstanceStack that does not fit in an
+// InstanceBudget waits before it is checked again.
+const budgetRetryInterval = 5 * time.Minute
+
+// provisioned reports whether an InstanceStack already has resources in the
+// cloud. The controller only counts those against a budget, so that two new
+// stacks racing for the last slot do not block each other forever.
+func provisioned(instanceStack *infrastructurev1alpha1.InstanceStack) bool {
+	return len(instanceStack.Status.Outputs) > 0
+}
+
+// setBudgetCondition records whether the InstanceStack fits in the
+// InstanceBudgets of its namespace. Nothing is recorded for stacks that were
+// never over budget, so namespaces without budgets do not get the condition.
+func (r *InstanceStackReconciler) setBudgetCondition(ctx context.Context, instanceStack *infrastructurev1alpha1.InstanceStack, exceeded []string) error {
+	if len(exceeded) == 0 && meta.FindStatusCondition(instanceStack.Status.Conditions, infrastructurev1alpha1.ConditionBudgetExceeded) == nil {
+		return nil
+	}
+	condition := metav1.Condition{
+		Type:               infrastructurev1alpha1.ConditionBudgetExceeded,
+		Status:             metav1.ConditionFalse,
+		Reason:             infrastructurev1alpha1.ReasonWithinBudget,
+		Message:            "the InstanceStack fits in the InstanceBudgets of its namespace",
+		ObservedGeneration: instanceStack.Generation,
+	}
+	if len(exceeded) > 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = infrastructurev1alpha1.ReasonOverBudget
+		condition.Message = strings.Join(exceeded, "; ")
+	}
+	if !meta.SetStatusCondition(&instanceStack.Status.Conditions, condition) {
+		return nil
+	}
+	return r.Status().Update(ctx, instanceStack)
+}
@@ -0,0 +1,202 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+)
+
+// dependsOnIndex indexes InstanceStacks by the objects in spec.dependsOn, as
+// "<group>/<Kind>/<name>".
+const dependsOnIndex = "spec.dependsOn"
+
+// dependencyPollInterval is how often dependencies of kinds the controller
+// does not watch are checked again.
+const dependencyPollInterval = 30 * time.Second
+
+// dependencyGVK returns the GroupVersionKind of a dependency, defaulting to
+// an InstanceStack of this API group.
+func dependencyGVK(dep infrastructurev1alpha1.Dependency) (schema.GroupVersionKind, error) {
+	gv := infrastructurev1alpha1.GroupVersion
+	if dep.APIVersion != "" {
+		var err error
+		if gv, err = schema.ParseGroupVersion(dep.APIVersion); err != nil {
+			return schema.GroupVersionKind{}, err
+		}
+	}
+	kind := dep.Kind
+	if kind == "" {
+		kind = "InstanceStack"
+	}
+	return gv.WithKind(kind), nil
+}
+
+func dependencyKey(group, kind, name string) string {
+	return group + "/" + kind + "/" + name
+}
+
+// dependsOnKeys lists the objects an InstanceStack depends on.
+func dependsOnKeys(obj client.Object) []string {
+	instanceStack := obj.(*infrastructurev1alpha1.InstanceStack)
+	keys := make([]string, 0, len(instanceStack.Spec.DependsOn))
+	for _, dep := range instanceStack.Spec.DependsOn {
+		if gvk, err := dependencyGVK(dep); err == nil {
+			keys = append(keys, dependencyKey(gvk.Group, gvk.Kind, dep.Name))
+		}
+	}
+	return keys
+}
+
+// watchedDependency reports whether changes to a dependency of this kind
+// requeue its dependents through a watch.
+func watchedDependency(gvk schema.GroupVersionKind) bool {
+	return gvk.Group == infrastructurev1alpha1.GroupVersion.Group && (gvk.Kind == "InstanceStack" || gvk.Kind == "Stack")
+}
+
+// pendingDependencies returns a description of every dependency that is not
+// ready yet, and whether any of them has to be polled because it is not
+// watched. Dependencies the manager may not read or whose kind is not
+// served are reported as pending too, since they can be fixed by granting
+// RBAC or installing the CRD without changing the InstanceStack.
+func pendingDependencies(ctx context.Context, c client.Reader, instanceStack *infrastructurev1alpha1.InstanceStack) ([]string, bool, error) {
+	var pending []string
+	poll := false
+	for _, dep := range instanceStack.Spec.DependsOn {
+		gvk, err := dependencyGVK(dep)
+		if err != nil {
+			return nil, false, fmt.Errorf("dependency %q: %w", dep.Name, err)
+		}
+		conditionType := dep.ConditionType
+		if conditionType == "" {
+			conditionType = infrastructurev1alpha1.ConditionReady
+		}
+
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(gvk)
+		err = c.Get(ctx, types.NamespacedName{Namespace: instanceStack.Namespace, Name: dep.Name}, obj)
+		switch {
+		case apierrors.IsNotFound(err):
+			pending = append(pending, fmt.Sprintf("%s/%s not found", gvk.Kind, dep.Name))
+		case apierrors.IsForbidden(err):
+			pending = append(pending, fmt.Sprintf("%s/%s cannot be read, the manager needs get on %s",
+				gvk.Kind, dep.Name, gvk.GroupKind()))
+		case meta.IsNoMatchError(err):
+			pending = append(pending, fmt.Sprintf("%s/%s has a kind that is not served by the cluster", gvk.Kind, dep.Name))
+		case err != nil:
+			return nil, false, err
+		case !conditionTrue(obj, conditionType):
+			pending = append(pending, fmt.Sprintf("%s/%s not %s", gvk.Kind, dep.Name, conditionType))
+		default:
+			continue
+		}
+		poll = poll || !watchedDependency(gvk)
+	}
+	return pending, poll, nil
+}
+
+// conditionTrue reports whether obj has a status condition of the given type
+// with status True.
+func conditionTrue(obj *unstructured.Unstructured, conditionType string) bool {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if ok && condition["type"] == conditionType {
+			return condition["status"] == string(metav1.ConditionTrue)
+		}
+	}
+	return false
+}
+
+// pendingReadinessGates returns the readiness gates whose condition is not
+// True yet.
+func pendingReadinessGates(instanceStack *infrastructurev1alpha1.InstanceStack) []string {
+	var pending []string
+	for _, gate := range instanceStack.Spec.ReadinessGates {
+		if !meta.IsStatusConditionTrue(instanceStack.Status.Conditions, gate.ConditionType) {
+			pending = append(pending, gate.ConditionType)
+		}
+	}
+	return pending
+}
+
+// setDependenciesCondition records whether spec.dependsOn is satisfied. While
+// waiting, Ready is False as well.
+func (r *InstanceStackReconciler) setDependenciesCondition(ctx context.Context, instanceStack *infrastructurev1alpha1.InstanceStack, pending []string) error {
+	if len(instanceStack.Spec.DependsOn) == 0 {
+		return nil
+	}
+	condition := metav1.Condition{
+		Type:               infrastructurev1alpha1.ConditionDependenciesReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             infrastructurev1alpha1.ReasonDependenciesReady,
+		Message:            "all dependencies are ready",
+		ObservedGeneration: instanceStack.Generation,
+	}
+	changed := false
+	if len(pending) > 0 {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = infrastructurev1alpha1.ReasonWaitingForDependencies
+		condition.Message = "waiting for " + strings.Join(pending, ", ")
+		ready := condition
+		ready.Type = infrastructurev1alpha1.ConditionReady
+		changed = meta.SetStatusCondition(&instanceStack.Status.Conditions, ready)
+	}
+	if !meta.SetStatusCondition(&instanceStack.Status.Conditions, condition) && !changed {
+		return nil
+	}
+	return r.Status().Update(ctx, instanceStack)
+}
+
+// setReadyCondition marks a provisioned InstanceStack Ready once all of its
+// readiness gates are True.
+func (r *InstanceStackReconciler) setReadyCondition(ctx context.Context, instanceStack *infrastructurev1alpha1.InstanceStack) error {
+	condition := metav1.Condition{
+		Type:               infrastructurev1alpha1.ConditionReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             infrastructurev1alpha1.ReasonProvisioned,
+		Message:            "stack is up to date",
+		ObservedGeneration: instanceStack.Generation,
+	}
+	if pending := pendingReadinessGates(instanceStack); len(pending) > 0 {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = infrastructurev1alpha1.ReasonReadinessGatesPending
+		condition.Message = "waiting for readiness gates " + strings.Join(pending, ", ")
+	}
+	if !meta.SetStatusCondition(&instanceStack.Status.Conditions, condition) {
+		return nil
+	}
+	return r.Status().Update(ctx, instanceStack)
+}
+
+// requestsForDependsOn maps a changed InstanceStack or Stack to the
+// InstanceStacks that depend on it.
+func requestsForDependsOn(c client.Reader, kind string) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		instanceStacks := &infrastructurev1alpha1.InstanceStackList{}
+		key := dependencyKey(infrastructurev1alpha1.GroupVersion.Group, kind, obj.GetName())
+		if err := c.List(ctx, instanceStacks, client.InNamespace(obj.GetNamespace()),
+			client.MatchingFields{dependsOnIndex: key}); err != nil {
+			log.FromContext(ctx).Error(err, "failed to list dependent InstanceStacks", "kind", kind, "name", obj.GetName())
+			return nil
+		}
+		requests := make([]reconcile.Request, 0, len(instanceStacks.Items))
+		for _, item := range instanceStacks.Items {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: item.Namespace, Name: item.Name}})
+		}
+		return requests
+	}
+}
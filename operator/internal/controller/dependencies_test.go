@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+)
+
+var _ = Describe("Dependencies", func() {
+	newClient := func(objs ...client.Object) client.Client {
+		scheme := runtime.NewScheme()
+		Expect(infrastructurev1alpha1.AddToScheme(scheme)).To(Succeed())
+		return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+	}
+
+	app := &infrastructurev1alpha1.InstanceStack{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"},
+		Spec: infrastructurev1alpha1.InstanceStackSpec{
+			DependsOn: []infrastructurev1alpha1.Dependency{
+				{Name: "db"},
+				{Kind: "Stack", Name: "network"},
+			},
+		},
+	}
+
+	ready := func(conditions *[]metav1.Condition, conditionType string) {
+		*conditions = append(*conditions, metav1.Condition{Type: conditionType, Status: metav1.ConditionTrue, Reason: "Test"})
+	}
+
+	It("Should wait for missing and unready dependencies", func() {
+		network := &infrastructurev1alpha1.Stack{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "network"}}
+		pending, poll, err := pendingDependencies(context.Background(), newClient(network), app)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pending).To(Equal([]string{"InstanceStack/db not found", "Stack/network not Ready"}))
+		Expect(poll).To(BeFalse())
+	})
+
+	It("Should wait for dependencies the manager cannot read", func() {
+		c := fake.NewClientBuilder().WithScheme(runtime.NewScheme()).WithInterceptorFuncs(interceptor.Funcs{
+			Get: func(_ context.Context, _ client.WithWatch, key client.ObjectKey, obj client.Object, _ ...client.GetOption) error {
+				gvk := obj.GetObjectKind().GroupVersionKind()
+				if gvk.Kind == "SecurityGroup" {
+					return apierrors.NewForbidden(schema.GroupResource{Group: gvk.Group, Resource: "securitygroups"}, key.Name, errors.New("no RBAC"))
+				}
+				return &meta.NoKindMatchError{GroupKind: gvk.GroupKind()}
+			},
+		}).Build()
+		external := &infrastructurev1alpha1.InstanceStack{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"},
+			Spec: infrastructurev1alpha1.InstanceStackSpec{
+				DependsOn: []infrastructurev1alpha1.Dependency{
+					{APIVersion: "networking.example.com/v1", Kind: "SecurityGroup", Name: "web"},
+					{APIVersion: "dns.example.com/v1", Kind: "Record", Name: "web"},
+				},
+			},
+		}
+		pending, poll, err := pendingDependencies(context.Background(), c, external)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pending).To(Equal([]string{
+			"SecurityGroup/web cannot be read, the manager needs get on SecurityGroup.networking.example.com",
+			"Record/web has a kind that is not served by the cluster",
+		}))
+		Expect(poll).To(BeTrue())
+	})
+
+	It("Should proceed once every dependency is Ready", func() {
+		db := &infrastructurev1alpha1.InstanceStack{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "db"}}
+		ready(&db.Status.Conditions, infrastructurev1alpha1.ConditionReady)
+		network := &infrastructurev1alpha1.Stack{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "network"}}
+		ready(&network.Status.Conditions, infrastructurev1alpha1.ConditionReady)
+
+		pending, _, err := pendingDependencies(context.Background(), newClient(db, network), app)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pending).To(BeEmpty())
+	})
+
+	It("Should index dependencies by group, kind and name", func() {
+		Expect(dependsOnKeys(app)).To(Equal([]string{
+			"infrastructure.cloudprovider.io/InstanceStack/db",
+			"infrastructure.cloudprovider.io/Stack/network",
+		}))
+	})
+
+	It("Should hold Ready until the readiness gates are True", func() {
+		instanceStack := &infrastructurev1alpha1.InstanceStack{
+			Spec: infrastructurev1alpha1.InstanceStackSpec{
+				ReadinessGates: []infrastructurev1alpha1.ReadinessGate{{ConditionType: "example.com/Registered"}},
+			},
+		}
+		Expect(pendingReadinessGates(instanceStack)).To(Equal([]string{"example.com/Registered"}))
+		ready(&instanceStack.Status.Conditions, "example.com/Registered")
+		Expect(pendingReadinessGates(instanceStack)).To(BeEmpty())
+	})
+})
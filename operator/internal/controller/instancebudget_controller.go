@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"context"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+	"github.com/gunniLee/cloud-provider-operator/internal/budget"
+	"github.com/gunniLee/cloud-provider-operator/internal/catalog"
+)
+
+// InstanceBudgetReconciler reports how much of an InstanceBudget the
+// InstanceStacks of its namespace consume. Enforcement happens in the
+// InstanceStack webhook and controller.
+type InstanceBudgetReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// Catalog provides the flavors used to compute vCPUs and RAM.
+	Catalog *catalog.Cache
+}
+
+// +kubebuilder:rbac:groups=infrastructure.cloudprovider.io,resources=instancebudgets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=infrastructure.cloudprovider.io,resources=instancebudgets/status,verbs=get;update;patch
+
+func (r *InstanceBudgetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	instanceBudget := &infrastructurev1alpha1.InstanceBudget{}
+	if err := r.Get(ctx, req.NamespacedName, instanceBudget); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	used, unknownFlavors, err := budget.NamespaceUsage(ctx, r.Client, r.Catalog, instanceBudget.Namespace, nil)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	original := instanceBudget.Status.DeepCopy()
+	instanceBudget.Status.Used = used
+	instanceBudget.Status.UnknownFlavors = unknownFlavors
+	condition := metav1.Condition{
+		Type:               infrastructurev1alpha1.ConditionBudgetExceeded,
+		Status:             metav1.ConditionFalse,
+		Reason:             infrastructurev1alpha1.ReasonWithinBudget,
+		Message:            "usage is within all limits",
+		ObservedGeneration: instanceBudget.Generation,
+	}
+	// 예산을 줄이면 이미 있는 스택 때문에 한도를 넘을 수 있음
+	if exceeded := budget.Exceeded(instanceBudget.Spec, used, used); len(exceeded) > 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = infrastructurev1alpha1.ReasonOverBudget
+		condition.Message = strings.Join(exceeded, "; ")
+	}
+	meta.SetStatusCondition(&instanceBudget.Status.Conditions, condition)
+
+	if !equality.Semantic.DeepEqual(original, &instanceBudget.Status) {
+		if err := r.Status().Update(ctx, instanceBudget); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	// flavor 정보는 카탈로그가 갱신될 때 바뀔 수 있음
+	var result ctrl.Result
+	if r.Catalog != nil {
+		result.RequeueAfter = r.Catalog.Interval
+	}
+	return result, nil
+}
+
+// requestsForNamespaceBudgets maps an InstanceStack to the InstanceBudgets of
+// its namespace.
+func (r *InstanceBudgetReconciler) requestsForNamespaceBudgets(ctx context.Context, obj client.Object) []reconcile.Request {
+	budgets := &infrastructurev1alpha1.InstanceBudgetList{}
+	if err := r.List(ctx, budgets, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(budgets.Items))
+	for _, b := range budgets.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: b.Namespace, Name: b.Name}})
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *InstanceBudgetReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&infrastructurev1alpha1.InstanceBudget{}).
+		Watches(&infrastructurev1alpha1.InstanceStack{}, handler.EnqueueRequestsFromMapFunc(r.requestsForNamespaceBudgets)).
+		Named("instancebudget").
+		Complete(r)
+}
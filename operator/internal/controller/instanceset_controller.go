@@ -0,0 +1,334 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+)
+
+// InstanceSetReconciler keeps spec.replicas InstanceStacks named
+// <set>-<ordinal> from the template of an InstanceSet. A replica keeps the
+// target it was placed in for its lifetime; only new replicas are placed by
+// the policy, so scaling never moves existing servers.
+type InstanceSetReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+// placement is where a replica runs. An empty region is the primary region
+// of the ProviderConfig.
+type placement struct {
+	providerConfigName string
+	region             string
+}
+
+// +kubebuilder:rbac:groups=infrastructure.cloudprovider.io,resources=instancesets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=infrastructure.cloudprovider.io,resources=instancesets/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=infrastructure.cloudprovider.io,resources=instancesets/finalizers,verbs=update
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+func (r *InstanceSetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	instanceSet := &infrastructurev1alpha1.InstanceSet{}
+	if err := r.Get(ctx, req.NamespacedName, instanceSet); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !instanceSet.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	children, err := r.listReplicas(ctx, instanceSet)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	targets := placementTargets(instanceSet)
+
+	// 축소되었거나 대상에서 빠진 위치의 복제본은 삭제
+	desired := map[string]bool{}
+	for i := int32(0); i < instanceSet.Spec.Replicas; i++ {
+		desired[replicaName(instanceSet, i)] = true
+	}
+	counts := map[placement]int32{}
+	kept := map[string]placement{}
+	terminating := map[string]bool{}
+	var blocked []string
+	for i := range children {
+		child := &children[i]
+		at := placementOf(child)
+		if !child.DeletionTimestamp.IsZero() {
+			terminating[child.Name] = true
+			continue
+		}
+		if !desired[child.Name] || !containsPlacement(targets, at) {
+			log.Info("deleting InstanceStack of InstanceSet", "instanceStack", child.Name)
+			err := r.Delete(ctx, child)
+			if apierrors.IsForbidden(err) {
+				// 삭제 보호나 참조 중인 스택은 webhook이 삭제를 거부하므로 그대로 두고 다시 만들지 않음
+				r.Recorder.Eventf(instanceSet, corev1.EventTypeWarning, "ScaleDownBlocked", "cannot delete InstanceStack %s: %v", child.Name, err)
+				blocked = append(blocked, child.Name)
+				terminating[child.Name] = true
+				continue
+			}
+			if err := client.IgnoreNotFound(err); err != nil {
+				return ctrl.Result{}, err
+			}
+			terminating[child.Name] = true
+			continue
+		}
+		kept[child.Name] = at
+		counts[at]++
+	}
+
+	unplaced := 0
+	for i := int32(0); i < instanceSet.Spec.Replicas; i++ {
+		name := replicaName(instanceSet, i)
+		if terminating[name] {
+			// 삭제가 끝나면 InstanceStack 이벤트로 다시 생성
+			continue
+		}
+		at, ok := kept[name]
+		if !ok {
+			if at, ok = choosePlacement(instanceSet, targets, counts); !ok {
+				unplaced++
+				continue
+			}
+			counts[at]++
+		}
+		if err := r.applyReplica(ctx, instanceSet, name, at); err != nil {
+			log.Error(err, "failed to apply InstanceStack of InstanceSet", "instanceStack", name)
+			return ctrl.Result{}, err
+		}
+	}
+
+	children, err = r.listReplicas(ctx, instanceSet)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.updateStatus(ctx, instanceSet, children, unplaced, blocked); err != nil {
+		log.Error(err, "failed to update InstanceSet status")
+		return ctrl.Result{}, err
+	}
+	if len(blocked) > 0 {
+		// 보호가 해제되면 InstanceStack 이벤트로 다시 reconcile되지만, 참조가 사라지는 경우를 위해 주기적으로 재시도
+		return ctrl.Result{RequeueAfter: time.Hour}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// listReplicas returns the InstanceStacks controlled by the set.
+func (r *InstanceSetReconciler) listReplicas(ctx context.Context, instanceSet *infrastructurev1alpha1.InstanceSet) ([]infrastructurev1alpha1.InstanceStack, error) {
+	list := &infrastructurev1alpha1.InstanceStackList{}
+	if err := r.List(ctx, list, client.InNamespace(instanceSet.Namespace),
+		client.MatchingLabels{infrastructurev1alpha1.InstanceSetLabel: instanceSet.Name}); err != nil {
+		return nil, fmt.Errorf("failed to list InstanceStacks: %w", err)
+	}
+	var children []infrastructurev1alpha1.InstanceStack
+	for _, child := range list.Items {
+		if metav1.IsControlledBy(&child, instanceSet) {
+			children = append(children, child)
+		}
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i].Name < children[j].Name })
+	return children, nil
+}
+
+// applyReplica creates or updates one InstanceStack from the template.
+func (r *InstanceSetReconciler) applyReplica(ctx context.Context, instanceSet *infrastructurev1alpha1.InstanceSet, name string, at placement) error {
+	template := instanceSet.Spec.Template
+	child := &infrastructurev1alpha1.InstanceStack{ObjectMeta: metav1.ObjectMeta{Namespace: instanceSet.Namespace, Name: name}}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, child, func() error {
+		if child.Labels == nil {
+			child.Labels = map[string]string{}
+		}
+		for k, v := range template.Labels {
+			child.Labels[k] = v
+		}
+		child.Labels[infrastructurev1alpha1.InstanceSetLabel] = instanceSet.Name
+		if child.Annotations == nil {
+			child.Annotations = map[string]string{}
+		}
+		for k, v := range template.Annotations {
+			child.Annotations[k] = v
+		}
+		child.Annotations[infrastructurev1alpha1.PlacementAnnotation] = at.String()
+
+		spec := *template.Spec.DeepCopy()
+		spec.ProviderConfigName = at.providerConfigName
+		spec.Region = at.region
+		// 웹훅이 채운 기본값은 템플릿이 비워 둔 경우 유지
+		if spec.FlavorName == "" {
+			spec.FlavorName = child.Spec.FlavorName
+		}
+		if spec.ImageName == "" {
+			spec.ImageName = child.Spec.ImageName
+		}
+		if spec.NetworkUUID == "" {
+			spec.NetworkUUID = child.Spec.NetworkUUID
+		}
+		if spec.Program == "" {
+			spec.Program = child.Spec.Program
+		}
+		// 템플릿이 정하지 않은 전원 상태는 PowerSchedule 등이 바꾼 값을 유지
+		if spec.PowerState == "" {
+			spec.PowerState = child.Spec.PowerState
+		}
+		child.Spec = spec
+		return controllerutil.SetControllerReference(instanceSet, child, r.Scheme)
+	})
+	return err
+}
+
+func (r *InstanceSetReconciler) updateStatus(ctx context.Context, instanceSet *infrastructurev1alpha1.InstanceSet, children []infrastructurev1alpha1.InstanceStack, unplaced int, blocked []string) error {
+	original := instanceSet.Status.DeepCopy()
+	status := &instanceSet.Status
+	status.Replicas = 0
+	status.ReadyReplicas = 0
+	status.Placements = nil
+	for _, child := range children {
+		if !child.DeletionTimestamp.IsZero() {
+			continue
+		}
+		ready := meta.IsStatusConditionTrue(child.Status.Conditions, infrastructurev1alpha1.ConditionReady)
+		region := child.Status.Region
+		if region == "" {
+			region = child.Spec.Region
+		}
+		status.Placements = append(status.Placements, infrastructurev1alpha1.ReplicaPlacement{
+			Name:               child.Name,
+			ProviderConfigName: providerConfigOrDefault(child.Spec.ProviderConfigName),
+			Region:             region,
+			Ready:              ready,
+		})
+		status.Replicas++
+		if ready {
+			status.ReadyReplicas++
+		}
+	}
+	status.ObservedGeneration = instanceSet.Generation
+
+	condition := metav1.Condition{
+		Type:               infrastructurev1alpha1.ConditionReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             infrastructurev1alpha1.ReasonReplicasReady,
+		Message:            fmt.Sprintf("%d of %d replicas are ready", status.ReadyReplicas, instanceSet.Spec.Replicas),
+		ObservedGeneration: instanceSet.Generation,
+	}
+	switch {
+	case unplaced > 0:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = infrastructurev1alpha1.ReasonNoPlacement
+		condition.Message = fmt.Sprintf("%d replicas cannot be placed, every placement target is at its maxReplicas", unplaced)
+	case len(blocked) > 0:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = infrastructurev1alpha1.ReasonScaleDownBlocked
+		condition.Message = fmt.Sprintf("cannot delete %s, remove deletionProtection or its dependents", strings.Join(blocked, ", "))
+	case status.ReadyReplicas < instanceSet.Spec.Replicas:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = infrastructurev1alpha1.ReasonReplicasNotReady
+	}
+	meta.SetStatusCondition(&status.Conditions, condition)
+
+	if equality.Semantic.DeepEqual(original, status) {
+		return nil
+	}
+	return r.Status().Update(ctx, instanceSet)
+}
+
+func (p placement) String() string {
+	return p.providerConfigName + "/" + p.region
+}
+
+// placementOf returns the target an InstanceStack was placed in. The
+// annotation is used because the defaulting webhook may fill in
+// spec.providerConfigName.
+func placementOf(instanceStack *infrastructurev1alpha1.InstanceStack) placement {
+	if value, ok := instanceStack.Annotations[infrastructurev1alpha1.PlacementAnnotation]; ok {
+		if providerConfigName, region, ok := strings.Cut(value, "/"); ok {
+			return placement{providerConfigName: providerConfigName, region: region}
+		}
+	}
+	return placement{providerConfigName: instanceStack.Spec.ProviderConfigName, region: instanceStack.Spec.Region}
+}
+
+func replicaName(instanceSet *infrastructurev1alpha1.InstanceSet, ordinal int32) string {
+	return fmt.Sprintf("%s-%d", instanceSet.Name, ordinal)
+}
+
+// placementTargets returns the targets of the policy with the template's
+// ProviderConfig filled in. Without a policy the template is the only target.
+func placementTargets(instanceSet *infrastructurev1alpha1.InstanceSet) []infrastructurev1alpha1.PlacementTarget {
+	templateSpec := instanceSet.Spec.Template.Spec
+	if instanceSet.Spec.Placement == nil {
+		return []infrastructurev1alpha1.PlacementTarget{{ProviderConfigName: templateSpec.ProviderConfigName, Region: templateSpec.Region}}
+	}
+	targets := make([]infrastructurev1alpha1.PlacementTarget, 0, len(instanceSet.Spec.Placement.Targets))
+	for _, target := range instanceSet.Spec.Placement.Targets {
+		if target.ProviderConfigName == "" {
+			target.ProviderConfigName = templateSpec.ProviderConfigName
+		}
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+func containsPlacement(targets []infrastructurev1alpha1.PlacementTarget, at placement) bool {
+	for _, target := range targets {
+		if sameProviderConfig(target.ProviderConfigName, at.providerConfigName) && target.Region == at.region {
+			return true
+		}
+	}
+	return false
+}
+
+// choosePlacement picks the target of a new replica given the replicas
+// already placed, or reports that every target is full.
+func choosePlacement(instanceSet *infrastructurev1alpha1.InstanceSet, targets []infrastructurev1alpha1.PlacementTarget, counts map[placement]int32) (placement, bool) {
+	strategy := infrastructurev1alpha1.PlacementSpread
+	if instanceSet.Spec.Placement != nil && instanceSet.Spec.Placement.Strategy != "" {
+		strategy = instanceSet.Spec.Placement.Strategy
+	}
+	var (
+		chosen placement
+		found  bool
+	)
+	for _, target := range targets {
+		at := placement{providerConfigName: target.ProviderConfigName, region: target.Region}
+		if target.MaxReplicas > 0 && counts[at] >= target.MaxReplicas {
+			continue
+		}
+		if strategy == infrastructurev1alpha1.PlacementFill {
+			return at, true
+		}
+		if !found || counts[at] < counts[chosen] {
+			chosen, found = at, true
+		}
+	}
+	return chosen, found
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *InstanceSetReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&infrastructurev1alpha1.InstanceSet{}).
+		Owns(&infrastructurev1alpha1.InstanceStack{}).
+		Named("instanceset").
+		Complete(r)
+}
@@ -0,0 +1,225 @@
+package controller
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+	"github.com/gunniLee/cloud-provider-operator/internal/openstack"
+)
+
+var _ = Describe("InstanceSet", func() {
+	ctx := context.Background()
+
+	var (
+		c        client.Client
+		recorder *record.FakeRecorder
+	)
+
+	newInstanceSet := func(replicas int32, placement *infrastructurev1alpha1.PlacementPolicy) *infrastructurev1alpha1.InstanceSet {
+		return &infrastructurev1alpha1.InstanceSet{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+			Spec: infrastructurev1alpha1.InstanceSetSpec{
+				Replicas: replicas,
+				Template: infrastructurev1alpha1.InstanceStackTemplate{
+					Labels: map[string]string{"app": "web"},
+					Spec:   infrastructurev1alpha1.InstanceStackSpec{FlavorName: "m1.small", ImageName: "ubuntu-22.04"},
+				},
+				Placement: placement,
+			},
+		}
+	}
+
+	reconcile := func() *infrastructurev1alpha1.InstanceSet {
+		r := &InstanceSetReconciler{Client: c, Scheme: c.Scheme(), Recorder: recorder}
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "web"}})
+		Expect(err).NotTo(HaveOccurred())
+		instanceSet := &infrastructurev1alpha1.InstanceSet{}
+		Expect(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "web"}, instanceSet)).To(Succeed())
+		return instanceSet
+	}
+
+	regions := func(instanceSet *infrastructurev1alpha1.InstanceSet) map[string]string {
+		placed := map[string]string{}
+		for _, p := range instanceSet.Status.Placements {
+			placed[p.Name] = p.Region
+		}
+		return placed
+	}
+
+	setup := func(objs ...client.Object) {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(infrastructurev1alpha1.AddToScheme(scheme)).To(Succeed())
+		recorder = record.NewFakeRecorder(10)
+		c = fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(objs...).
+			WithStatusSubresource(&infrastructurev1alpha1.InstanceSet{}, &infrastructurev1alpha1.InstanceStack{}).
+			// 삭제 보호는 webhook이 거부하므로 같은 응답을 흉내 냄
+			WithInterceptorFuncs(interceptor.Funcs{
+				Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+					if instanceStack, ok := obj.(*infrastructurev1alpha1.InstanceStack); ok && instanceStack.Spec.DeletionProtection {
+						return apierrors.NewForbidden(infrastructurev1alpha1.GroupVersion.WithResource("instancestacks").GroupResource(),
+							obj.GetName(), errors.New("spec.deletionProtection is enabled"))
+					}
+					return c.Delete(ctx, obj, opts...)
+				},
+			}).
+			Build()
+	}
+
+	twoRegions := &infrastructurev1alpha1.PlacementPolicy{Targets: []infrastructurev1alpha1.PlacementTarget{
+		{Region: "RegionOne"},
+		{Region: "RegionTwo"},
+	}}
+
+	It("Should spread replicas across regions and keep them in place when scaling", func() {
+		setup(newInstanceSet(3, twoRegions))
+		instanceSet := reconcile()
+		Expect(regions(instanceSet)).To(Equal(map[string]string{"web-0": "RegionOne", "web-1": "RegionTwo", "web-2": "RegionOne"}))
+		Expect(instanceSet.Status.Replicas).To(Equal(int32(3)))
+
+		child := &infrastructurev1alpha1.InstanceStack{}
+		Expect(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "web-1"}, child)).To(Succeed())
+		Expect(child.Spec.Region).To(Equal("RegionTwo"))
+		Expect(child.Spec.FlavorName).To(Equal("m1.small"))
+		Expect(child.Labels).To(HaveKeyWithValue("app", "web"))
+		Expect(metav1.IsControlledBy(child, instanceSet)).To(BeTrue())
+
+		By("reporting readiness from the InstanceStacks")
+		meta.SetStatusCondition(&child.Status.Conditions, metav1.Condition{
+			Type: infrastructurev1alpha1.ConditionReady, Status: metav1.ConditionTrue, Reason: infrastructurev1alpha1.ReasonProvisioned,
+		})
+		child.Status.Region = "RegionTwo"
+		Expect(c.Status().Update(ctx, child)).To(Succeed())
+		instanceSet = reconcile()
+		Expect(instanceSet.Status.ReadyReplicas).To(Equal(int32(1)))
+		condition := meta.FindStatusCondition(instanceSet.Status.Conditions, infrastructurev1alpha1.ConditionReady)
+		Expect(condition.Reason).To(Equal(infrastructurev1alpha1.ReasonReplicasNotReady))
+
+		By("scaling down from the highest ordinal and placing new replicas where there are fewest")
+		instanceSet.Spec.Replicas = 1
+		Expect(c.Update(ctx, instanceSet)).To(Succeed())
+		instanceSet = reconcile()
+		Expect(regions(instanceSet)).To(Equal(map[string]string{"web-0": "RegionOne"}))
+
+		instanceSet.Spec.Replicas = 2
+		Expect(c.Update(ctx, instanceSet)).To(Succeed())
+		instanceSet = reconcile()
+		Expect(regions(instanceSet)).To(Equal(map[string]string{"web-0": "RegionOne", "web-1": "RegionTwo"}))
+	})
+
+	It("Should keep the power state set on a replica when the template leaves it empty", func() {
+		setup(newInstanceSet(1, nil))
+		reconcile()
+
+		By("stopping the replica as a PowerSchedule would")
+		child := &infrastructurev1alpha1.InstanceStack{}
+		Expect(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "web-0"}, child)).To(Succeed())
+		child.Spec.PowerState = infrastructurev1alpha1.PowerStateStopped
+		Expect(c.Update(ctx, child)).To(Succeed())
+		reconcile()
+		Expect(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "web-0"}, child)).To(Succeed())
+		Expect(child.Spec.PowerState).To(Equal(infrastructurev1alpha1.PowerStateStopped))
+
+		By("applying the power state of the template once it sets one")
+		instanceSet := &infrastructurev1alpha1.InstanceSet{}
+		Expect(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "web"}, instanceSet)).To(Succeed())
+		instanceSet.Spec.Template.Spec.PowerState = infrastructurev1alpha1.PowerStateRunning
+		Expect(c.Update(ctx, instanceSet)).To(Succeed())
+		reconcile()
+		Expect(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "web-0"}, child)).To(Succeed())
+		Expect(child.Spec.PowerState).To(Equal(infrastructurev1alpha1.PowerStateRunning))
+	})
+
+	It("Should keep protected replicas on scale-down and report them", func() {
+		instanceSet := newInstanceSet(2, nil)
+		instanceSet.Spec.Template.Spec.DeletionProtection = true
+		setup(instanceSet)
+		instanceSet = reconcile()
+		Expect(instanceSet.Status.Replicas).To(Equal(int32(2)))
+
+		instanceSet.Spec.Replicas = 1
+		Expect(c.Update(ctx, instanceSet)).To(Succeed())
+		r := &InstanceSetReconciler{Client: c, Scheme: c.Scheme(), Recorder: recorder}
+		result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(instanceSet)})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).NotTo(BeZero())
+		Expect(recorder.Events).To(Receive(ContainSubstring("cannot delete InstanceStack web-1")))
+
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(instanceSet), instanceSet)).To(Succeed())
+		condition := meta.FindStatusCondition(instanceSet.Status.Conditions, infrastructurev1alpha1.ConditionReady)
+		Expect(condition.Reason).To(Equal(infrastructurev1alpha1.ReasonScaleDownBlocked))
+		Expect(condition.Message).To(ContainSubstring("web-1"))
+
+		By("deleting the replica once protection is turned off")
+		child := &infrastructurev1alpha1.InstanceStack{}
+		Expect(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "web-1"}, child)).To(Succeed())
+		child.Spec.DeletionProtection = false
+		Expect(c.Update(ctx, child)).To(Succeed())
+		instanceSet = reconcile()
+		Expect(regions(instanceSet)).To(HaveLen(1))
+		condition = meta.FindStatusCondition(instanceSet.Status.Conditions, infrastructurev1alpha1.ConditionReady)
+		Expect(condition.Reason).NotTo(Equal(infrastructurev1alpha1.ReasonScaleDownBlocked))
+	})
+
+	It("Should fill targets in order and report replicas that do not fit", func() {
+		setup(newInstanceSet(3, &infrastructurev1alpha1.PlacementPolicy{
+			Strategy: infrastructurev1alpha1.PlacementFill,
+			Targets: []infrastructurev1alpha1.PlacementTarget{
+				{Region: "RegionOne", MaxReplicas: 1},
+				{ProviderConfigName: "other-cloud", MaxReplicas: 1},
+			},
+		}))
+		instanceSet := reconcile()
+		Expect(instanceSet.Status.Placements).To(ConsistOf(
+			infrastructurev1alpha1.ReplicaPlacement{Name: "web-0", ProviderConfigName: "default", Region: "RegionOne"},
+			infrastructurev1alpha1.ReplicaPlacement{Name: "web-1", ProviderConfigName: "other-cloud"},
+		))
+		condition := meta.FindStatusCondition(instanceSet.Status.Conditions, infrastructurev1alpha1.ConditionReady)
+		Expect(condition.Reason).To(Equal(infrastructurev1alpha1.ReasonNoPlacement))
+	})
+
+	It("Should resolve credentials in the region of the InstanceStack", func() {
+		setup(
+			&infrastructurev1alpha1.ProviderConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: infrastructurev1alpha1.DefaultProviderConfigName},
+				Spec: infrastructurev1alpha1.ProviderConfigSpec{
+					AuthURL: "https://keystone.example.com/v3", Region: "RegionOne", Regions: []string{"RegionTwo"},
+					TenantName:           "admin",
+					CredentialsSecretRef: &infrastructurev1alpha1.SecretReference{Namespace: "system", Name: "openstack"},
+				},
+			},
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "system", Name: "openstack"},
+				Data:       map[string][]byte{"username": []byte("admin"), "password": []byte("secret")},
+			},
+		)
+		instanceStack := &infrastructurev1alpha1.InstanceStack{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "regional"},
+			Spec:       infrastructurev1alpha1.InstanceStackSpec{Region: "RegionTwo"},
+		}
+		creds, err := instanceStackCredentials(ctx, c, instanceStack)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(creds.Region).To(Equal("RegionTwo"))
+
+		instanceStack.Spec.Region = "RegionThree"
+		_, err = instanceStackCredentials(ctx, c, instanceStack)
+		Expect(err).To(MatchError(openstack.ErrRegionNotAllowed))
+	})
+})
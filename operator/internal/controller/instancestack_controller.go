@@ -2,30 +2,79 @@ package controller
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
+	"sort"
+	"strconv"
+	"strings"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+	"github.com/gunniLee/cloud-provider-operator/internal/budget"
+	"github.com/gunniLee/cloud-provider-operator/internal/catalog"
+	"github.com/gunniLee/cloud-provider-operator/internal/engine"
+	"github.com/gunniLee/cloud-provider-operator/internal/openstack"
+	"github.com/gunniLee/cloud-provider-operator/internal/program"
 
-	"github.com/pulumi/pulumi-openstack/sdk/v4/go/openstack/compute"
 	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 )
 
+// stateExportLabel marks Secrets and ConfigMaps holding an exported checkpoint
+// with the name of the InstanceStack it came from.
+const stateExportLabel = "infrastructure.cloudprovider.io/exported-from"
+
+// instanceStackChanged keeps the status writes of the health, cost and expiry
+// controllers from running Pulumi again. Annotations request reboots and
+// confirm deletions, so changing them still reconciles.
+var instanceStackChanged = predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{})
+
 // InstanceStackReconciler reconciles an InstanceStack object
 type InstanceStackReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// Catalog, when set, is consulted before running Pulumi so that unknown
+	// flavors, images and networks are reported instead of failing mid-update.
+	Catalog *catalog.Cache
+
+	// Engine runs the Pulumi stacks. Defaults to the Pulumi Automation API.
+	Engine engine.Engine
+
+	// Provider, when set, targets every InstanceStack instead of the Provider
+	// of its ProviderConfig type, e.g. in tests running without a cloud.
+	Provider Provider
+
+	// Recorder reports reboot requests that cannot be honored.
+	Recorder record.EventRecorder
 }
 
 // +kubebuilder:rbac:groups=infrastructure.cloudprovider.io,resources=instancestacks,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=infrastructure.cloudprovider.io,resources=instancestacks/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=infrastructure.cloudprovider.io,resources=instancestacks/finalizers,verbs=update
+// +kubebuilder:rbac:groups=infrastructure.cloudprovider.io,resources=instancebudgets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=infrastructure.cloudprovider.io,resources=tenantbindings,verbs=get;list;watch
+// +kubebuilder:rbac:groups=infrastructure.cloudprovider.io,resources=machineclasses,verbs=get;list;watch
+// +kubebuilder:rbac:groups=infrastructure.cloudprovider.io,resources=providerconfigs,verbs=get;list;watch
+// +kubebuilder:rbac:groups=infrastructure.cloudprovider.io,resources=providerconfigs/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=secrets;configmaps,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 func (r *InstanceStackReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
@@ -40,8 +89,36 @@ func (r *InstanceStackReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 	// Check if the instanceStack is marked for deletion
 	if !instanceStack.ObjectMeta.DeletionTimestamp.IsZero() {
 		if containsString(instanceStack.ObjectMeta.Finalizers, "instancestack.finalizers.cloudprovider.io") {
-			if err := r.deleteOpenStackResource(ctx, instanceStack); err != nil {
-				log.Error(err, "failed to delete OpenStack resource")
+			// 다른 스택이 출력을 참조하는 동안에는 삭제하지 않음
+			names, err := dependents(ctx, r.Client, "InstanceStack", instanceStack.Namespace, instanceStack.Name)
+			if err != nil {
+				log.Error(err, "failed to list dependent InstanceStacks")
+				return ctrl.Result{}, err
+			}
+			if len(names) > 0 {
+				log.Info("InstanceStack is referenced by other stacks, not deleting", "dependents", names)
+				if err := r.setDeletionBlockedCondition(ctx, instanceStack, infrastructurev1alpha1.ReasonHasDependents,
+					fmt.Sprintf("outputs are referenced by %s", strings.Join(names, ", "))); err != nil {
+					log.Error(err, "failed to update InstanceStack status")
+					return ctrl.Result{}, err
+				}
+				return ctrl.Result{}, nil
+			}
+
+			// 삭제 보호가 켜져 있으면 삭제 확인 어노테이션이 없는 한 destroy하지 않고 대기
+			if destroysResources(instanceStack) && instanceStack.Spec.DeletionProtection && !instanceStack.DeletionConfirmed() {
+				log.Info("deletion protection is enabled, not destroying cloud resources")
+				if err := r.setDeletionBlockedCondition(ctx, instanceStack, infrastructurev1alpha1.ReasonDeletionProtectionEnabled,
+					fmt.Sprintf("spec.deletionProtection is true, so the server is not destroyed; set it to false, "+
+						"set the %s=%s annotation to confirm, or set spec.deletionPolicy to Orphan or Retain to keep the server",
+						infrastructurev1alpha1.ConfirmDeletionAnnotation, instanceStack.Name)); err != nil {
+					log.Error(err, "failed to update InstanceStack status")
+					return ctrl.Result{}, err
+				}
+				return ctrl.Result{}, nil
+			}
+			if err := r.deleteStackResources(ctx, instanceStack); err != nil {
+				log.Error(err, "failed to delete stack resources")
 				return ctrl.Result{}, err
 			}
 
@@ -63,38 +140,203 @@ func (r *InstanceStackReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 		}
 	}
 
+	// dependsOn에 나열된 객체가 모두 Ready가 될 때까지 대기
+	pending, poll, err := pendingDependencies(ctx, r.Client, instanceStack)
+	if err != nil {
+		log.Error(err, "failed to check dependencies")
+		return ctrl.Result{}, err
+	}
+	if err := r.setDependenciesCondition(ctx, instanceStack, pending); err != nil {
+		log.Error(err, "failed to update InstanceStack status")
+		return ctrl.Result{}, err
+	}
+	if len(pending) > 0 {
+		// InstanceStack/Stack은 watch로, 그 외 kind는 주기적으로 다시 확인
+		log.Info("waiting for dependencies", "pending", pending)
+		if poll {
+			return ctrl.Result{RequeueAfter: dependencyPollInterval}, nil
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// 다른 스택의 출력을 파라미터로 전달
+	resolved, err := resolveOutputRefs(ctx, r.Client, instanceStack)
+	if err := r.setReferencesCondition(ctx, instanceStack, err); err != nil {
+		log.Error(err, "failed to update InstanceStack status")
+		return ctrl.Result{}, err
+	}
+	var waiting *errWaitingForOutputs
+	if errors.As(err, &waiting) {
+		// 참조한 스택의 출력이 생기면 watch로 다시 reconcile됨
+		log.Info("waiting for referenced outputs", "reason", err.Error())
+		return ctrl.Result{}, nil
+	}
+	if errors.Is(err, openstack.ErrCrossTenant) {
+		// spec이나 참조한 스택이 바뀌면 watch로 다시 reconcile됨
+		log.Info("refusing cross-tenant output reference", "reason", err.Error())
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		log.Error(err, "failed to resolve output references")
+		return ctrl.Result{}, err
+	}
+
+	// MachineClass의 크기/이미지 별칭을 ProviderConfig의 실제 값으로 변환
+	resolved, ok, err := r.resolveMachineClass(ctx, instanceStack, resolved)
+	if err != nil {
+		log.Error(err, "failed to resolve MachineClass")
+		return ctrl.Result{}, err
+	}
+	if !ok {
+		// MachineClass가 바뀌면 watch로 다시 reconcile됨
+		log.Info("MachineClass cannot be used", "machineClass", instanceStack.Spec.MachineClassName)
+		return ctrl.Result{}, nil
+	}
+
+	// ProviderConfig 종류에 맞는 기본 프로그램 선택
+	providerType, err := providerTypeOf(ctx, r.Client, instanceStack)
+	if err != nil {
+		log.Error(err, "failed to get ProviderConfig")
+		return ctrl.Result{}, err
+	}
+	if resolved.Spec.Program == "" {
+		resolved = resolved.DeepCopy()
+		resolved.Spec.Program = program.DefaultFor(providerType)
+	}
+
+	// 프로그램과 파라미터를 Pulumi 실행 전에 검증
+	prog, params, errs := program.Resolve(resolved)
+	reason := infrastructurev1alpha1.ReasonInvalidParameters
+	if len(errs) == 0 {
+		if err := prog.CheckProvider(providerType); err != nil {
+			errs, reason = field.ErrorList{err}, infrastructurev1alpha1.ReasonProviderMismatch
+		}
+	}
+	if err := r.setProgramCondition(ctx, instanceStack, errs, reason); err != nil {
+		log.Error(err, "failed to update InstanceStack status")
+		return ctrl.Result{}, err
+	}
+	if len(errs) > 0 {
+		log.Info("InstanceStack has an invalid program or parameters", "program", instanceStack.Spec.Program, "errors", errs.ToAggregate().Error())
+		return ctrl.Result{}, nil
+	}
+
+	// 카탈로그에 없는 flavor/image/network는 Pulumi 실행 전에 걸러냄
+	if snapshot := r.Catalog.GetRegion(ctx, instanceStack.Spec.ProviderConfigName, instanceStack.Spec.Region); prog.ServerSpec && snapshot != nil {
+		problems := snapshot.Check(catalogReference(resolved))
+		if err := r.setCatalogCondition(ctx, instanceStack, problems); err != nil {
+			log.Error(err, "failed to update InstanceStack status")
+			return ctrl.Result{}, err
+		}
+		if len(problems) > 0 {
+			log.Info("InstanceStack references unknown catalog entries", "problems", len(problems))
+			return ctrl.Result{RequeueAfter: r.Catalog.Interval}, nil
+		}
+	}
+
+	// 네임스페이스 예산(InstanceBudget)을 넘는 새 스택은 만들지 않음
+	if !provisioned(instanceStack) {
+		exceeded, err := budget.Check(ctx, r.Client, r.Catalog, resolved, provisioned)
+		if err != nil {
+			log.Error(err, "failed to check InstanceBudgets")
+			return ctrl.Result{}, err
+		}
+		if err := r.setBudgetCondition(ctx, instanceStack, exceeded); err != nil {
+			log.Error(err, "failed to update InstanceStack status")
+			return ctrl.Result{}, err
+		}
+		if len(exceeded) > 0 {
+			log.Info("InstanceBudget exceeded", "exceeded", exceeded)
+			return ctrl.Result{RequeueAfter: budgetRetryInterval}, nil
+		}
+	}
+
+	// ProviderConfig의 계정과 리전 설정
+	provider, err := r.providerFor(providerType)
+	if err != nil {
+		log.Error(err, "failed to select provider")
+		return ctrl.Result{}, err
+	}
+	target, err := provider.Target(ctx, r.Client, instanceStack)
+	if errors.Is(err, openstack.ErrCrossTenant) {
+		log.Info("refusing ProviderConfig outside the namespace's project", "reason", err.Error())
+		return ctrl.Result{}, r.setCrossTenantCondition(ctx, instanceStack, err)
+	}
+	if errors.Is(err, openstack.ErrRegionNotAllowed) {
+		log.Info("refusing region of InstanceStack", "reason", err.Error())
+		return ctrl.Result{}, r.setRegionCondition(ctx, instanceStack, err)
+	}
+	if err != nil {
+		log.Error(err, "failed to resolve provider credentials")
+		return ctrl.Result{}, err
+	}
+	// OpenStack 서버 프로그램은 Pulumi 외에 Nova API도 사용
+	creds := target.OpenStack
+	openStackServer := prog.ServerSpec && creds != nil
+
+	// 새 서버가 프로젝트 쿼터를 넘으면 Pulumi를 실행하지 않음
+	if request, ok := serverQuotaRequest(r.Catalog.GetRegion(ctx, instanceStack.Spec.ProviderConfigName, instanceStack.Spec.Region), resolved, params); openStackServer && ok {
+		shortfalls, err := checkQuota(ctx, *creds, request)
+		if err != nil {
+			log.Error(err, "failed to check project quota")
+			return ctrl.Result{}, err
+		}
+		if err := r.setQuotaCondition(ctx, instanceStack, shortfalls); err != nil {
+			log.Error(err, "failed to update InstanceStack status")
+			return ctrl.Result{}, err
+		}
+		if len(shortfalls) > 0 {
+			log.Info("project quota exceeded", "shortfalls", shortfalls)
+			return ctrl.Result{RequeueAfter: quotaRetryInterval}, nil
+		}
+	}
+
 	// Pulumi 스택 이름 설정
 	stackName := fmt.Sprintf("%s-%s", instanceStack.Namespace, instanceStack.Name)
-	projectName := "cloud-provider-operator"
-
-	os.Setenv("PULUMI_CONFIG_PASSPHRASE", "cloud1234")
 
-	stack, err := auto.UpsertStackInlineSource(ctx, stackName, projectName, pulumiProgram(instanceStack),
-		auto.SecretsProvider("passphrase"))
+	stack, err := r.engine().UpsertStack(ctx, stackName, pulumiProgram(resolved))
 	if err != nil {
 		log.Error(err, "failed to create or select Pulumi stack")
 		return ctrl.Result{}, err
 	}
 
-	// OpenStack 인증 정보 설정
-	authURL := os.Getenv("OPENSTACK_AUTH_URL")
-	userName := os.Getenv("OPENSTACK_USERNAME")
-	password := os.Getenv("OPENSTACK_PASSWORD")
-	tenantName := os.Getenv("OPENSTACK_TENANT_NAME")
-	region := os.Getenv("OPENSTACK_REGION")
-	insecure := os.Getenv("OPENSTACK_INSECURE")
+	if err := stack.SetAllConfig(ctx, target.Config); err != nil {
+		log.Error(err, "failed to set Pulumi stack config")
+		return ctrl.Result{}, err
+	}
+	if err := stack.RemoveAllConfig(ctx, target.Stale); err != nil {
+		log.Error(err, "failed to remove stale Pulumi stack config")
+		return ctrl.Result{}, err
+	}
+	if err := ensureGeneratedSecrets(ctx, stack, prog.GeneratedSecrets); err != nil {
+		log.Error(err, "failed to generate Pulumi stack secrets")
+		return ctrl.Result{}, err
+	}
 
-	if authURL == "" || userName == "" || password == "" || tenantName == "" || region == "" || insecure == "" {
-		log.Error(nil, "Required environment variables are not set")
-		return ctrl.Result{}, fmt.Errorf("missing required environment variables")
+	// 기존 서버를 가져올 때는 diff가 없을 때만 up을 실행
+	importID := instanceStack.PendingImportID()
+	if importID != "" {
+		mismatches, err := previewImport(ctx, stack)
+		if err != nil {
+			log.Error(err, "failed to preview import", "importID", importID)
+			return ctrl.Result{}, err
+		}
+		if len(mismatches) > 0 {
+			log.Info("existing server does not match the spec, not importing", "importID", importID, "mismatches", len(mismatches))
+			if err := r.setImportStatus(ctx, instanceStack, importID, mismatches); err != nil {
+				log.Error(err, "failed to update InstanceStack status")
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{}, nil
+		}
 	}
 
-	stack.SetConfig(ctx, "openstack:authUrl", auto.ConfigValue{Value: authURL})
-	stack.SetConfig(ctx, "openstack:userName", auto.ConfigValue{Value: userName})
-	stack.SetConfig(ctx, "openstack:password", auto.ConfigValue{Value: password, Secret: true})
-	stack.SetConfig(ctx, "openstack:tenantName", auto.ConfigValue{Value: tenantName})
-	stack.SetConfig(ctx, "openstack:region", auto.ConfigValue{Value: region})
-	stack.SetConfig(ctx, "openstack:insecure", auto.ConfigValue{Value: insecure})
+	if openStackServer {
+		if err := resumeBeforeUpdate(ctx, *creds, instanceStack); err != nil {
+			log.Error(err, "failed to resume server")
+			return ctrl.Result{}, err
+		}
+	}
 
 	upRes, err := stack.Up(ctx)
 	if err != nil {
@@ -102,26 +344,236 @@ func (r *InstanceStackReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 		return ctrl.Result{}, err
 	}
 
-	ipAddress := upRes.Outputs["instanceIP"].Value.(string)
-	log.Info("Successfully created OpenStack instance", "IP", ipAddress)
+	// 다른 스택이 참조할 수 있도록 출력을 status에 기록
+	outputs, err := statusOutputs(upRes.Outputs)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	outputsChanged := !equality.Semantic.DeepEqual(instanceStack.Status.Outputs, outputs) || instanceStack.Status.Region != target.Region
+	instanceStack.Status.Outputs = outputs
+	instanceStack.Status.Region = target.Region
+	if importID != "" {
+		err = r.setImportStatus(ctx, instanceStack, importID, nil)
+	} else if outputsChanged {
+		err = r.Status().Update(ctx, instanceStack)
+	}
+	if err != nil {
+		log.Error(err, "failed to update InstanceStack status")
+		return ctrl.Result{}, err
+	}
+
+	// 스택 출력을 Secret/ConfigMap에 기록
+	if err := r.publishOutputs(ctx, instanceStack, instanceStack.Spec.WriteOutputsToSecret,
+		instanceStack.Spec.WriteOutputsToConfigMap, upRes.Outputs); err != nil {
+		if errors.Is(err, errOutputTargetConflict) {
+			log.Info("refusing to write outputs", "reason", err.Error())
+			return ctrl.Result{}, r.setOutputTargetCondition(ctx, instanceStack, err)
+		}
+		log.Error(err, "failed to publish stack outputs")
+		return ctrl.Result{}, err
+	}
+
+	// Pulumi가 지원하지 않는 suspend와 reboot는 Nova API로 처리
+	rebootPending := false
+	if openStackServer {
+		if rebootPending, err = r.reconcilePowerState(ctx, instanceStack, *creds); err != nil {
+			log.Error(err, "failed to reconcile power state")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if err := r.setReadyCondition(ctx, instanceStack); err != nil {
+		log.Error(err, "failed to update InstanceStack status")
+		return ctrl.Result{}, err
+	}
+
+	if ipAddress, ok := upRes.Outputs["instanceIP"].Value.(string); ok {
+		log.Info("Successfully created instance", "provider", providerType, "IP", ipAddress)
+	} else {
+		log.Info("Successfully applied Pulumi program", "program", prog.Name)
+	}
+	// 서버가 아직 실행 중이 아니면 재부팅 요청을 유지하고 다시 확인
+	if rebootPending {
+		return ctrl.Result{RequeueAfter: rebootRetryInterval}, nil
+	}
 	return ctrl.Result{}, nil
 }
 
-func (r *InstanceStackReconciler) deleteOpenStackResource(ctx context.Context, instanceStack *infrastructurev1alpha1.InstanceStack) error {
+// openStackConfig is the provider config of a stack using creds. It also
+// returns the keys of settings creds leaves out, such as the other
+// authentication method, which must be removed from stacks that switched,
+// e.g. when a namespace got bound to a project.
+func openStackConfig(creds openstack.Credentials) (auto.ConfigMap, []string) {
+	config := auto.ConfigMap{
+		"openstack:authUrl":  {Value: creds.AuthURL},
+		"openstack:region":   {Value: creds.Region},
+		"openstack:insecure": {Value: strconv.FormatBool(creds.Insecure)},
+	}
+	var stale []string
+	// cacertFile, cert, key는 파일 경로 대신 PEM 내용도 받음
+	for key, value := range map[string]auto.ConfigValue{
+		"openstack:cacertFile": {Value: creds.CACert},
+		"openstack:cert":       {Value: creds.ClientCert},
+		"openstack:key":        {Value: creds.ClientKey, Secret: true},
+	} {
+		if value.Value == "" {
+			stale = append(stale, key)
+			continue
+		}
+		config[key] = value
+	}
+	sort.Strings(stale)
+
+	if creds.UsesApplicationCredential() {
+		config["openstack:applicationCredentialId"] = auto.ConfigValue{Value: creds.ApplicationCredentialID}
+		config["openstack:applicationCredentialSecret"] = auto.ConfigValue{Value: creds.ApplicationCredentialSecret, Secret: true}
+		return config, append(stale, "openstack:userName", "openstack:password", "openstack:tenantName", "openstack:projectDomainName")
+	}
+	config["openstack:userName"] = auto.ConfigValue{Value: creds.Username}
+	config["openstack:password"] = auto.ConfigValue{Value: creds.Password, Secret: true}
+	config["openstack:tenantName"] = auto.ConfigValue{Value: creds.TenantName}
+	stale = append(stale, "openstack:applicationCredentialId", "openstack:applicationCredentialSecret")
+	if creds.ProjectDomainName != "" {
+		config["openstack:projectDomainName"] = auto.ConfigValue{Value: creds.ProjectDomainName}
+	} else {
+		stale = append(stale, "openstack:projectDomainName")
+	}
+	return config, stale
+}
+
+// catalogReference returns the catalog names used by a server program. A
+// network passed through the networkID parameter usually comes from another
+// stack and may not be in the catalog yet, so it is not checked.
+func catalogReference(instanceStack *infrastructurev1alpha1.InstanceStack) catalog.Reference {
+	ref := catalog.Reference{
+		FlavorName:       instanceStack.Spec.FlavorName,
+		ImageName:        instanceStack.Spec.ImageName,
+		NetworkUUID:      instanceStack.Spec.NetworkUUID,
+		AvailabilityZone: instanceStack.Spec.AvailabilityZone,
+	}
+	if _, ok := instanceStack.Spec.Parameters["networkID"]; ok {
+		ref.NetworkUUID = ""
+	}
+	return ref
+}
+
+// setCatalogCondition records the result of the catalog check on the status.
+func (r *InstanceStackReconciler) setCatalogCondition(ctx context.Context, instanceStack *infrastructurev1alpha1.InstanceStack, problems []catalog.Problem) error {
+	condition := metav1.Condition{
+		Type:               infrastructurev1alpha1.ConditionCatalogValid,
+		Status:             metav1.ConditionTrue,
+		Reason:             infrastructurev1alpha1.ReasonCatalogMatched,
+		Message:            "all references exist in the OpenStack catalog",
+		ObservedGeneration: instanceStack.Generation,
+	}
+	if len(problems) > 0 {
+		messages := make([]string, 0, len(problems))
+		for _, p := range problems {
+			messages = append(messages, p.String())
+		}
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = infrastructurev1alpha1.ReasonUnknownReference
+		condition.Message = strings.Join(messages, "; ")
+	}
+	if !meta.SetStatusCondition(&instanceStack.Status.Conditions, condition) {
+		return nil
+	}
+	return r.Status().Update(ctx, instanceStack)
+}
+
+// setReferencesCondition records the result of resolving spec.outputRefs.
+func (r *InstanceStackReconciler) setReferencesCondition(ctx context.Context, instanceStack *infrastructurev1alpha1.InstanceStack, resolveErr error) error {
+	if len(instanceStack.Spec.OutputRefs) == 0 {
+		return nil
+	}
+	condition := metav1.Condition{
+		Type:               infrastructurev1alpha1.ConditionReferencesResolved,
+		Status:             metav1.ConditionTrue,
+		Reason:             infrastructurev1alpha1.ReasonReferencesResolved,
+		Message:            "all referenced outputs are available",
+		ObservedGeneration: instanceStack.Generation,
+	}
+	var waiting *errWaitingForOutputs
+	switch {
+	case errors.As(resolveErr, &waiting):
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = infrastructurev1alpha1.ReasonWaitingForOutputs
+		condition.Message = resolveErr.Error()
+	case errors.Is(resolveErr, openstack.ErrCrossTenant):
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = infrastructurev1alpha1.ReasonCrossTenantReference
+		condition.Message = resolveErr.Error()
+	case resolveErr != nil:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = infrastructurev1alpha1.ReasonInvalidReference
+		condition.Message = resolveErr.Error()
+	}
+	if !meta.SetStatusCondition(&instanceStack.Status.Conditions, condition) {
+		return nil
+	}
+	return r.Status().Update(ctx, instanceStack)
+}
+
+// setProgramCondition records the result of resolving spec.program, with
+// reason explaining errs.
+func (r *InstanceStackReconciler) setProgramCondition(ctx context.Context, instanceStack *infrastructurev1alpha1.InstanceStack, errs field.ErrorList, reason string) error {
+	condition := metav1.Condition{
+		Type:               infrastructurev1alpha1.ConditionProgramValid,
+		Status:             metav1.ConditionTrue,
+		Reason:             infrastructurev1alpha1.ReasonProgramResolved,
+		Message:            "program and parameters are valid",
+		ObservedGeneration: instanceStack.Generation,
+	}
+	if len(errs) > 0 {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = reason
+		condition.Message = errs.ToAggregate().Error()
+	}
+	if !meta.SetStatusCondition(&instanceStack.Status.Conditions, condition) {
+		return nil
+	}
+	return r.Status().Update(ctx, instanceStack)
+}
+
+func (r *InstanceStackReconciler) deleteStackResources(ctx context.Context, instanceStack *infrastructurev1alpha1.InstanceStack) error {
+	log := log.FromContext(ctx)
 	stackName := fmt.Sprintf("%s-%s", instanceStack.Namespace, instanceStack.Name)
-	projectName := "cloud-provider-operator"
 
-	stack, err := auto.SelectStackInlineSource(ctx, stackName, projectName, pulumiProgram(instanceStack))
+	stack, err := r.engine().SelectStack(ctx, stackName, pulumiProgram(instanceStack))
+	if errors.Is(err, engine.ErrStackNotFound) {
+		// 스택을 만들기 전에 멈춘 InstanceStack은 삭제할 리소스가 없음
+		log.Info("Pulumi stack does not exist, nothing to delete", "stack", stackName)
+		return nil
+	}
 	if err != nil {
 		return fmt.Errorf("failed to select Pulumi stack: %w", err)
 	}
 
-	_, err = stack.Destroy(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to destroy Pulumi stack: %w", err)
+	// deletionPolicy에 따라 리소스 삭제 여부 결정
+	switch instanceStack.Spec.DeletionPolicy {
+	case infrastructurev1alpha1.DeletionPolicyOrphan:
+		log.Info("Orphaning cloud resources", "stack", stackName)
+	case infrastructurev1alpha1.DeletionPolicyRetain:
+		if err := r.exportState(ctx, instanceStack, stack); err != nil {
+			return err
+		}
+		log.Info("Retaining cloud resources and exported Pulumi state", "stack", stackName)
+	default:
+		// 보호 해제 후 바로 삭제된 경우 state에 protect가 남아 있을 수 있음
+		if err := unprotectState(ctx, stack); err != nil {
+			return err
+		}
+		if err := r.refreshTargetConfig(ctx, instanceStack, stack); err != nil {
+			return err
+		}
+		_, err = stack.Destroy(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to destroy Pulumi stack: %w", err)
+		}
 	}
 
-	err = stack.Workspace().RemoveStack(ctx, stackName)
+	// Orphan/Retain은 리소스가 남아 있는 스택을 지워야 하므로 강제 삭제
+	err = stack.Remove(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to remove Pulumi stack: %w", err)
 	}
@@ -129,32 +581,195 @@ func (r *InstanceStackReconciler) deleteOpenStackResource(ctx context.Context, i
 	return nil
 }
 
+// refreshTargetConfig sets the current provider config on the stack before a
+// destroy, since the credentials stored by the last update may have been
+// rotated and deleted since. The stored config is kept when the target can
+// no longer be resolved, e.g. because the ProviderConfig is gone.
+func (r *InstanceStackReconciler) refreshTargetConfig(ctx context.Context, instanceStack *infrastructurev1alpha1.InstanceStack, stack engine.Stack) error {
+	target, err := r.target(ctx, instanceStack)
+	if err != nil {
+		log.FromContext(ctx).Info("keeping the stored provider config for destroy", "reason", err.Error())
+		return nil
+	}
+	if err := stack.SetAllConfig(ctx, target.Config); err != nil {
+		return fmt.Errorf("failed to set Pulumi stack config: %w", err)
+	}
+	if err := stack.RemoveAllConfig(ctx, target.Stale); err != nil {
+		return fmt.Errorf("failed to remove stale Pulumi stack config: %w", err)
+	}
+	return nil
+}
+
+// target resolves the Target of an InstanceStack.
+func (r *InstanceStackReconciler) target(ctx context.Context, instanceStack *infrastructurev1alpha1.InstanceStack) (*Target, error) {
+	providerType, err := providerTypeOf(ctx, r.Client, instanceStack)
+	if err != nil {
+		return nil, err
+	}
+	provider, err := r.providerFor(providerType)
+	if err != nil {
+		return nil, err
+	}
+	return provider.Target(ctx, r.Client, instanceStack)
+}
+
+// destroysResources reports whether deleting the InstanceStack destroys its
+// cloud resources.
+func destroysResources(instanceStack *infrastructurev1alpha1.InstanceStack) bool {
+	policy := instanceStack.Spec.DeletionPolicy
+	return policy != infrastructurev1alpha1.DeletionPolicyOrphan && policy != infrastructurev1alpha1.DeletionPolicyRetain
+}
+
+// setDeletionBlockedCondition explains why a deleted InstanceStack is kept.
+func (r *InstanceStackReconciler) setDeletionBlockedCondition(ctx context.Context, instanceStack *infrastructurev1alpha1.InstanceStack, reason, message string) error {
+	if !meta.SetStatusCondition(&instanceStack.Status.Conditions, metav1.Condition{
+		Type:               infrastructurev1alpha1.ConditionDeletionBlocked,
+		Status:             metav1.ConditionTrue,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: instanceStack.Generation,
+	}) {
+		return nil
+	}
+	return r.Status().Update(ctx, instanceStack)
+}
+
+// unprotectState clears the Pulumi protect flag from every resource in the
+// stack's checkpoint, which the program sets while deletion protection is on.
+func unprotectState(ctx context.Context, stack engine.Stack) error {
+	untyped, err := stack.Export(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to export Pulumi stack: %w", err)
+	}
+	if untyped.Version != 3 {
+		return nil
+	}
+	var deployment apitype.DeploymentV3
+	if err := json.Unmarshal(untyped.Deployment, &deployment); err != nil {
+		return fmt.Errorf("failed to decode Pulumi checkpoint: %w", err)
+	}
+	changed := false
+	for i := range deployment.Resources {
+		changed = changed || deployment.Resources[i].Protect
+		deployment.Resources[i].Protect = false
+	}
+	if !changed {
+		return nil
+	}
+	if untyped.Deployment, err = json.Marshal(deployment); err != nil {
+		return fmt.Errorf("failed to encode Pulumi checkpoint: %w", err)
+	}
+	if err := stack.Import(ctx, untyped); err != nil {
+		return fmt.Errorf("failed to unprotect Pulumi stack: %w", err)
+	}
+	return nil
+}
+
+// exportState writes the Pulumi checkpoint of the stack to the Secret or
+// ConfigMap named by spec.stateExport. The object is not owned by the
+// InstanceStack so that it outlives it.
+func (r *InstanceStackReconciler) exportState(ctx context.Context, instanceStack *infrastructurev1alpha1.InstanceStack, stack engine.Stack) error {
+	deployment, err := stack.Export(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to export Pulumi stack: %w", err)
+	}
+	checkpoint, err := json.Marshal(deployment)
+	if err != nil {
+		return fmt.Errorf("failed to encode Pulumi checkpoint: %w", err)
+	}
+
+	kind, name := "Secret", instanceStack.Name+"-pulumi-state"
+	if export := instanceStack.Spec.StateExport; export != nil {
+		if export.Kind != "" {
+			kind = export.Kind
+		}
+		if export.Name != "" {
+			name = export.Name
+		}
+	}
+	objectMeta := metav1.ObjectMeta{Name: name, Namespace: instanceStack.Namespace}
+
+	var obj client.Object
+	var mutate controllerutil.MutateFn
+	if kind == "ConfigMap" {
+		configMap := &corev1.ConfigMap{ObjectMeta: objectMeta}
+		obj, mutate = configMap, func() error {
+			configMap.Data = map[string]string{infrastructurev1alpha1.StateExportKey: string(checkpoint)}
+			return nil
+		}
+	} else {
+		secret := &corev1.Secret{ObjectMeta: objectMeta}
+		obj, mutate = secret, func() error {
+			secret.Data = map[string][]byte{infrastructurev1alpha1.StateExportKey: checkpoint}
+			return nil
+		}
+	}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, obj, func() error {
+		labels := obj.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[stateExportLabel] = instanceStack.Name
+		obj.SetLabels(labels)
+		return mutate()
+	}); err != nil {
+		return fmt.Errorf("failed to write Pulumi checkpoint to %s %q: %w", kind, name, err)
+	}
+	return nil
+}
+
+// pulumiProgram runs the registered program selected by spec.program.
 func pulumiProgram(instanceStack *infrastructurev1alpha1.InstanceStack) pulumi.RunFunc {
 	return func(ctx *pulumi.Context) error {
-		// OpenStack 인스턴스 생성
-		newInstance, err := compute.NewInstance(ctx, instanceStack.Name, &compute.InstanceArgs{
-			FlavorName: pulumi.String(instanceStack.Spec.FlavorName),
-			ImageName:  pulumi.String(instanceStack.Spec.ImageName),
-			Networks: compute.InstanceNetworkArray{
-				&compute.InstanceNetworkArgs{
-					Uuid: pulumi.String(instanceStack.Spec.NetworkUUID),
-				},
-			},
-		})
-		if err != nil {
-			return err
+		prog, params, errs := program.Resolve(instanceStack)
+		if len(errs) > 0 {
+			return errs.ToAggregate()
 		}
+		return prog.Run(ctx, instanceStack, params)
+	}
+}
 
-		// 생성된 인스턴스의 IP를 Export
-		ctx.Export("instanceIP", newInstance.AccessIpV4)
-		return nil
+// engine returns the Engine running the stacks.
+func (r *InstanceStackReconciler) engine() engine.Engine {
+	if r.Engine == nil {
+		return engine.Pulumi{}
 	}
+	return r.Engine
+}
+
+// providerFor returns r.Provider or the Provider of a ProviderType.
+func (r *InstanceStackReconciler) providerFor(providerType infrastructurev1alpha1.ProviderType) (Provider, error) {
+	if r.Provider != nil {
+		return r.Provider, nil
+	}
+	return providerFor(providerType)
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *InstanceStackReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &infrastructurev1alpha1.InstanceStack{},
+		outputRefIndex, outputRefKeys); err != nil {
+		return err
+	}
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &infrastructurev1alpha1.InstanceStack{},
+		dependsOnIndex, dependsOnKeys); err != nil {
+		return err
+	}
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &infrastructurev1alpha1.InstanceStack{},
+		machineClassIndex, machineClassKeys); err != nil {
+		return err
+	}
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&infrastructurev1alpha1.InstanceStack{}).
+		For(&infrastructurev1alpha1.InstanceStack{}, builder.WithPredicates(instanceStackChanged)).
+		Watches(&infrastructurev1alpha1.InstanceStack{}, handler.EnqueueRequestsFromMapFunc(requestsForOutputRefs(mgr.GetClient(), "InstanceStack"))).
+		Watches(&infrastructurev1alpha1.Stack{}, handler.EnqueueRequestsFromMapFunc(requestsForOutputRefs(mgr.GetClient(), "Stack"))).
+		Watches(&infrastructurev1alpha1.InstanceStack{}, handler.EnqueueRequestsFromMapFunc(requestsForDependsOn(mgr.GetClient(), "InstanceStack"))).
+		Watches(&infrastructurev1alpha1.Stack{}, handler.EnqueueRequestsFromMapFunc(requestsForDependsOn(mgr.GetClient(), "Stack"))).
+		Watches(&infrastructurev1alpha1.TenantBinding{}, handler.EnqueueRequestsFromMapFunc(requestsForTenantBinding(mgr.GetClient(),
+			func() client.ObjectList { return &infrastructurev1alpha1.InstanceStackList{} }))).
+		Watches(&infrastructurev1alpha1.MachineClass{}, handler.EnqueueRequestsFromMapFunc(requestsForMachineClass(mgr.GetClient()))).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(requestsForCredentialSecret(mgr.GetClient(),
+			func() client.ObjectList { return &infrastructurev1alpha1.InstanceStackList{} }))).
 		Named("instancestack").
 		Complete(r)
 }
@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+	"github.com/gunniLee/cloud-provider-operator/internal/cost"
+)
+
+// InstanceStackCostReconciler estimates the cost of InstanceStacks from the
+// PriceList of their ProviderConfig and exports the per-namespace totals.
+type InstanceStackCostReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=infrastructure.cloudprovider.io,resources=pricelists,verbs=get;list;watch
+
+func (r *InstanceStackCostReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	instanceStack := &infrastructurev1alpha1.InstanceStack{}
+	if err := r.Get(ctx, req.NamespacedName, instanceStack); client.IgnoreNotFound(err) != nil {
+		return ctrl.Result{}, err
+	} else if err != nil || !instanceStack.DeletionTimestamp.IsZero() {
+		// 삭제된 스택은 네임스페이스 합계에서만 빠지면 됨
+		return ctrl.Result{}, r.recordNamespace(ctx, req.Namespace, nil)
+	}
+
+	priceList, err := cost.FindPriceList(ctx, r.Client, instanceStack.Spec.ProviderConfigName)
+	if err != nil {
+		log.Error(err, "failed to find the PriceList")
+		return ctrl.Result{}, err
+	}
+	estimate := costEstimate(priceList, instanceStack)
+	if !equality.Semantic.DeepEqual(estimate, instanceStack.Status.Cost) {
+		instanceStack.Status.Cost = estimate
+		if err := r.Status().Update(ctx, instanceStack); err != nil {
+			log.Error(err, "failed to update InstanceStack status")
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, r.recordNamespace(ctx, instanceStack.Namespace, instanceStack)
+}
+
+// costEstimate prices the spec of an InstanceStack. While a spec change has
+// not been applied, the cost of the last applied spec is kept so that the
+// change shows up as a delta, like a plan would.
+func costEstimate(priceList *infrastructurev1alpha1.PriceList, instanceStack *infrastructurev1alpha1.InstanceStack) *infrastructurev1alpha1.CostEstimate {
+	if priceList == nil {
+		return nil
+	}
+	hourly, unpriced := cost.Hourly(priceList.Spec, instanceStack)
+	estimate := &infrastructurev1alpha1.CostEstimate{
+		PriceList: priceList.Name,
+		Currency:  priceList.Spec.Currency,
+		Hourly:    cost.Format(hourly),
+		Monthly:   cost.Format(hourly * infrastructurev1alpha1.HoursPerMonth),
+		Unpriced:  unpriced,
+	}
+	switch previous := instanceStack.Status.Cost; {
+	case specApplied(instanceStack):
+		estimate.AppliedHourly = estimate.Hourly
+	case previous != nil:
+		estimate.AppliedHourly = previous.AppliedHourly
+	}
+	if estimate.AppliedHourly != estimate.Hourly {
+		estimate.HourlyDelta = cost.FormatDelta(hourly - cost.Parse(estimate.AppliedHourly))
+	}
+	return estimate
+}
+
+// specApplied reports whether the current generation has been applied to the
+// cloud.
+func specApplied(instanceStack *infrastructurev1alpha1.InstanceStack) bool {
+	ready := meta.FindStatusCondition(instanceStack.Status.Conditions, infrastructurev1alpha1.ConditionReady)
+	if ready == nil || ready.ObservedGeneration != instanceStack.Generation {
+		return false
+	}
+	return ready.Reason == infrastructurev1alpha1.ReasonProvisioned ||
+		ready.Reason == infrastructurev1alpha1.ReasonReadinessGatesPending
+}
+
+// recordNamespace updates the cost metrics of a namespace. updated replaces
+// its cached copy, which may not have seen the latest status update yet.
+func (r *InstanceStackCostReconciler) recordNamespace(ctx context.Context, namespace string, updated *infrastructurev1alpha1.InstanceStack) error {
+	instanceStacks := &infrastructurev1alpha1.InstanceStackList{}
+	if err := r.List(ctx, instanceStacks, client.InNamespace(namespace)); err != nil {
+		return err
+	}
+	for i := range instanceStacks.Items {
+		if updated != nil && instanceStacks.Items[i].Name == updated.Name {
+			instanceStacks.Items[i] = *updated
+		}
+	}
+	cost.RecordNamespace(namespace, instanceStacks.Items)
+	return nil
+}
+
+// requestsForPriceList maps a changed PriceList to every InstanceStack, since
+// its ProviderConfig may have changed too.
+func (r *InstanceStackCostReconciler) requestsForPriceList(ctx context.Context, _ client.Object) []reconcile.Request {
+	instanceStacks := &infrastructurev1alpha1.InstanceStackList{}
+	if err := r.List(ctx, instanceStacks); err != nil {
+		log.FromContext(ctx).Error(err, "failed to list InstanceStacks")
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(instanceStacks.Items))
+	for _, instanceStack := range instanceStacks.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+			Namespace: instanceStack.Namespace, Name: instanceStack.Name,
+		}})
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *InstanceStackCostReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&infrastructurev1alpha1.InstanceStack{}).
+		Watches(&infrastructurev1alpha1.PriceList{}, handler.EnqueueRequestsFromMapFunc(r.requestsForPriceList)).
+		Named("instancestack-cost").
+		Complete(r)
+}
@@ -0,0 +1,71 @@
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+)
+
+var _ = Describe("InstanceStack cost", func() {
+	priceList := &infrastructurev1alpha1.PriceList{
+		ObjectMeta: metav1.ObjectMeta{Name: "default"},
+		Spec: infrastructurev1alpha1.PriceListSpec{
+			Currency: "USD",
+			Flavors: map[string]resource.Quantity{
+				"m1.small": resource.MustParse("0.02"),
+				"m1.large": resource.MustParse("0.08"),
+			},
+		},
+	}
+
+	newInstanceStack := func(flavor string, generation, appliedGeneration int64) *infrastructurev1alpha1.InstanceStack {
+		instanceStack := &infrastructurev1alpha1.InstanceStack{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", Generation: generation},
+			Spec:       infrastructurev1alpha1.InstanceStackSpec{FlavorName: flavor},
+		}
+		if appliedGeneration > 0 {
+			instanceStack.Status.Conditions = []metav1.Condition{{
+				Type:               infrastructurev1alpha1.ConditionReady,
+				Status:             metav1.ConditionTrue,
+				Reason:             infrastructurev1alpha1.ReasonProvisioned,
+				ObservedGeneration: appliedGeneration,
+			}}
+		}
+		return instanceStack
+	}
+
+	It("Should not estimate without a PriceList", func() {
+		Expect(costEstimate(nil, newInstanceStack("m1.small", 1, 1))).To(BeNil())
+	})
+
+	It("Should estimate hourly and monthly cost of an applied spec", func() {
+		estimate := costEstimate(priceList, newInstanceStack("m1.small", 1, 1))
+		Expect(estimate.Hourly).To(Equal("0.0200"))
+		Expect(estimate.Monthly).To(Equal("14.6000"))
+		Expect(estimate.AppliedHourly).To(Equal("0.0200"))
+		Expect(estimate.HourlyDelta).To(BeEmpty())
+	})
+
+	It("Should show the delta of a change that has not been applied", func() {
+		instanceStack := newInstanceStack("m1.large", 2, 1)
+		instanceStack.Status.Cost = &infrastructurev1alpha1.CostEstimate{PriceList: "default", Hourly: "0.0200", AppliedHourly: "0.0200"}
+
+		estimate := costEstimate(priceList, instanceStack)
+		Expect(estimate.Hourly).To(Equal("0.0800"))
+		Expect(estimate.AppliedHourly).To(Equal("0.0200"))
+		Expect(estimate.HourlyDelta).To(Equal("+0.0600"))
+
+		instanceStack.Status.Conditions[0].ObservedGeneration = 2
+		estimate = costEstimate(priceList, instanceStack)
+		Expect(estimate.HourlyDelta).To(BeEmpty())
+	})
+
+	It("Should count the whole cost of a new InstanceStack as a delta", func() {
+		estimate := costEstimate(priceList, newInstanceStack("m1.small", 1, 0))
+		Expect(estimate.AppliedHourly).To(BeEmpty())
+		Expect(estimate.HourlyDelta).To(Equal("+0.0200"))
+	})
+})
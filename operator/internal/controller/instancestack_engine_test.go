@@ -0,0 +1,315 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+	"github.com/gunniLee/cloud-provider-operator/internal/engine/enginetest"
+)
+
+// inMemoryProvider targets no cloud account, so that InstanceStacks only
+// reach the fake engine. The password stands in for the credentials of a
+// ProviderConfig.
+type inMemoryProvider struct {
+	password string
+}
+
+func (p inMemoryProvider) Target(context.Context, client.Reader, *infrastructurev1alpha1.InstanceStack) (*Target, error) {
+	return &Target{Region: "memory", Config: auto.ConfigMap{
+		"openstack:region":   {Value: "memory"},
+		"openstack:password": {Value: p.password, Secret: true},
+	}}, nil
+}
+
+var _ = Describe("InstanceStack Controller with an in-memory engine", func() {
+	const serverType = "openstack:compute/instance:Instance"
+
+	ctx := context.Background()
+
+	var (
+		c          client.Client
+		fakeEngine *enginetest.Engine
+		r          *InstanceStackReconciler
+	)
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(infrastructurev1alpha1.AddToScheme(scheme)).To(Succeed())
+		c = fake.NewClientBuilder().WithScheme(scheme).
+			WithStatusSubresource(&infrastructurev1alpha1.InstanceStack{}).
+			WithIndex(&infrastructurev1alpha1.InstanceStack{}, outputRefIndex, outputRefKeys).
+			WithIndex(&infrastructurev1alpha1.InstanceStack{}, dependsOnIndex, dependsOnKeys).
+			Build()
+		fakeEngine = enginetest.NewEngine()
+		r = &InstanceStackReconciler{Client: c, Scheme: scheme, Engine: fakeEngine, Provider: inMemoryProvider{}}
+	})
+
+	create := func(name string, deletionProtection bool) types.NamespacedName {
+		instanceStack := &infrastructurev1alpha1.InstanceStack{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+			Spec: infrastructurev1alpha1.InstanceStackSpec{
+				FlavorName:         "m1.small",
+				ImageName:          "ubuntu-22.04",
+				NetworkUUID:        "0a7e0885-9deb-45c6-bfeb-d28821d8d3d3",
+				DeletionProtection: deletionProtection,
+			},
+		}
+		Expect(c.Create(ctx, instanceStack)).To(Succeed())
+		return client.ObjectKeyFromObject(instanceStack)
+	}
+
+	reconcile := func(key types.NamespacedName) {
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	get := func(key types.NamespacedName) *infrastructurev1alpha1.InstanceStack {
+		instanceStack := &infrastructurev1alpha1.InstanceStack{}
+		Expect(c.Get(ctx, key, instanceStack)).To(Succeed())
+		return instanceStack
+	}
+
+	It("Should create, update and delete the server", func() {
+		key := create("in-memory", false)
+
+		By("creating the server")
+		reconcile(key)
+		stack := fakeEngine.Stack("default-in-memory")
+		Expect(stack).NotTo(BeNil())
+		Expect(stack.Config()).To(HaveKey("openstack:region"))
+		server := stack.Resource(serverType)
+		Expect(server).NotTo(BeNil())
+		Expect(server.Inputs["flavorName"].StringValue()).To(Equal("m1.small"))
+
+		instanceStack := get(key)
+		Expect(instanceStack.Finalizers).To(ContainElement("instancestack.finalizers.cloudprovider.io"))
+		Expect(instanceStack.Status.Region).To(Equal("memory"))
+		Expect(statusOutputString(instanceStack, "serverID")).To(Equal(server.ID))
+		Expect(statusOutputString(instanceStack, "instanceIP")).NotTo(BeEmpty())
+		Expect(meta.IsStatusConditionTrue(instanceStack.Status.Conditions, infrastructurev1alpha1.ConditionReady)).To(BeTrue())
+
+		By("resizing the server in place")
+		instanceStack.Spec.FlavorName = "m1.large"
+		Expect(c.Update(ctx, instanceStack)).To(Succeed())
+		reconcile(key)
+		Expect(stack.Updates()).To(Equal(2))
+		resized := stack.Resource(serverType)
+		Expect(resized.ID).To(Equal(server.ID))
+		Expect(resized.Inputs["flavorName"].StringValue()).To(Equal("m1.large"))
+
+		By("destroying the server")
+		Expect(c.Delete(ctx, get(key))).To(Succeed())
+		reconcile(key)
+		Expect(stack.Resources()).To(BeEmpty())
+		Expect(fakeEngine.Stack("default-in-memory")).To(BeNil())
+		Expect(apierrors.IsNotFound(c.Get(ctx, key, &infrastructurev1alpha1.InstanceStack{}))).To(BeTrue())
+	})
+
+	It("Should not run Pulumi again for status-only updates", func() {
+		key := create("probed", false)
+		reconcile(key)
+		stack := fakeEngine.Stack("default-probed")
+		Expect(stack.Updates()).To(Equal(1))
+
+		// 컨트롤러의 For() 이벤트를 흉내 내어 predicate를 통과한 경우에만 reconcile
+		update := func(mutate func(*infrastructurev1alpha1.InstanceStack)) bool {
+			before := get(key)
+			after := before.DeepCopy()
+			mutate(after)
+			if !equality.Semantic.DeepEqual(before.Status, after.Status) {
+				Expect(c.Status().Update(ctx, after)).To(Succeed())
+			} else {
+				Expect(c.Update(ctx, after)).To(Succeed())
+			}
+			after = get(key)
+			changed := instanceStackChanged.Update(event.UpdateEvent{ObjectOld: before, ObjectNew: after})
+			if changed {
+				reconcile(key)
+			}
+			return changed
+		}
+
+		By("recording a passed health check")
+		Expect(update(func(instanceStack *infrastructurev1alpha1.InstanceStack) {
+			meta.SetStatusCondition(&instanceStack.Status.Conditions, metav1.Condition{
+				Type:   infrastructurev1alpha1.ConditionHealthCheckPassed,
+				Status: metav1.ConditionTrue,
+				Reason: infrastructurev1alpha1.ReasonProbesSucceeded,
+			})
+		})).To(BeFalse())
+		Expect(stack.Updates()).To(Equal(1))
+
+		By("still reconciling annotation changes")
+		Expect(update(func(instanceStack *infrastructurev1alpha1.InstanceStack) {
+			instanceStack.Annotations = map[string]string{infrastructurev1alpha1.RebootAnnotation: "soft"}
+		})).To(BeTrue())
+		Expect(stack.Updates()).To(Equal(2))
+	})
+
+	It("Should remove the finalizer when the stack was never created", func() {
+		instanceStack := &infrastructurev1alpha1.InstanceStack{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "waiting"},
+			Spec: infrastructurev1alpha1.InstanceStackSpec{
+				FlavorName:  "m1.small",
+				ImageName:   "ubuntu-22.04",
+				NetworkUUID: "0a7e0885-9deb-45c6-bfeb-d28821d8d3d3",
+				DependsOn:   []infrastructurev1alpha1.Dependency{{Name: "missing"}},
+			},
+		}
+		Expect(c.Create(ctx, instanceStack)).To(Succeed())
+		key := client.ObjectKeyFromObject(instanceStack)
+		reconcile(key)
+		Expect(get(key).Finalizers).To(ContainElement("instancestack.finalizers.cloudprovider.io"))
+		Expect(fakeEngine.Stack("default-waiting")).To(BeNil())
+
+		Expect(c.Delete(ctx, get(key))).To(Succeed())
+		reconcile(key)
+		Expect(apierrors.IsNotFound(c.Get(ctx, key, &infrastructurev1alpha1.InstanceStack{}))).To(BeTrue())
+	})
+
+	It("Should destroy the server with the current credentials", func() {
+		r.Provider = inMemoryProvider{password: "old"}
+		key := create("in-memory-rotated", false)
+		reconcile(key)
+		stack := fakeEngine.Stack("default-in-memory-rotated")
+		Expect(stack.Config()["openstack:password"].Value).To(Equal("old"))
+
+		By("rotating the credentials before the server is deleted")
+		r.Provider = inMemoryProvider{password: "rotated"}
+		Expect(c.Delete(ctx, get(key))).To(Succeed())
+		reconcile(key)
+		Expect(stack.Resources()).To(BeEmpty())
+		Expect(stack.Config()["openstack:password"].Value).To(Equal("rotated"))
+	})
+
+	It("Should keep the server and remove the stack with the Orphan policy", func() {
+		key := create("in-memory-orphan", false)
+		reconcile(key)
+		stack := fakeEngine.Stack("default-in-memory-orphan")
+		server := stack.Resource(serverType)
+
+		instanceStack := get(key)
+		instanceStack.Spec.DeletionPolicy = infrastructurev1alpha1.DeletionPolicyOrphan
+		Expect(c.Update(ctx, instanceStack)).To(Succeed())
+		Expect(c.Delete(ctx, get(key))).To(Succeed())
+		reconcile(key)
+
+		Expect(fakeEngine.Stack("default-in-memory-orphan")).To(BeNil())
+		Expect(stack.Resource(serverType)).To(Equal(server))
+		Expect(apierrors.IsNotFound(c.Get(ctx, key, &infrastructurev1alpha1.InstanceStack{}))).To(BeTrue())
+		Expect(apierrors.IsNotFound(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "in-memory-orphan-pulumi-state"},
+			&corev1.Secret{}))).To(BeTrue())
+	})
+
+	It("Should keep the server and export the checkpoint with the Retain policy", func() {
+		// checkpointServer returns the server of an exported checkpoint.
+		checkpointServer := func(checkpoint []byte) apitype.ResourceV3 {
+			var state apitype.UntypedDeployment
+			Expect(json.Unmarshal(checkpoint, &state)).To(Succeed())
+			var deployment apitype.DeploymentV3
+			Expect(json.Unmarshal(state.Deployment, &deployment)).To(Succeed())
+			Expect(deployment.Resources).To(HaveLen(1))
+			return deployment.Resources[0]
+		}
+		retain := func(name string, export *infrastructurev1alpha1.StateExport) *enginetest.Stack {
+			key := create(name, false)
+			reconcile(key)
+			instanceStack := get(key)
+			instanceStack.Spec.DeletionPolicy = infrastructurev1alpha1.DeletionPolicyRetain
+			instanceStack.Spec.StateExport = export
+			Expect(c.Update(ctx, instanceStack)).To(Succeed())
+			stack := fakeEngine.Stack("default-" + name)
+			Expect(c.Delete(ctx, get(key))).To(Succeed())
+			reconcile(key)
+			Expect(fakeEngine.Stack("default-" + name)).To(BeNil())
+			Expect(stack.Resources()).To(HaveLen(1))
+			Expect(apierrors.IsNotFound(c.Get(ctx, key, &infrastructurev1alpha1.InstanceStack{}))).To(BeTrue())
+			return stack
+		}
+
+		By("writing the checkpoint to a Secret by default")
+		stack := retain("in-memory-retain", nil)
+		secret := &corev1.Secret{}
+		Expect(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "in-memory-retain-pulumi-state"}, secret)).To(Succeed())
+		Expect(secret.Labels).To(HaveKeyWithValue(stateExportLabel, "in-memory-retain"))
+		server := checkpointServer(secret.Data[infrastructurev1alpha1.StateExportKey])
+		Expect(string(server.ID)).To(Equal(stack.Resource(serverType).ID))
+		Expect(string(server.Type)).To(Equal(serverType))
+		Expect(server.Inputs).To(HaveKeyWithValue("flavorName", "m1.small"))
+
+		By("writing the checkpoint to a named ConfigMap")
+		stack = retain("in-memory-retain-cm", &infrastructurev1alpha1.StateExport{Kind: "ConfigMap", Name: "retained"})
+		configMap := &corev1.ConfigMap{}
+		Expect(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "retained"}, configMap)).To(Succeed())
+		server = checkpointServer([]byte(configMap.Data[infrastructurev1alpha1.StateExportKey]))
+		Expect(string(server.ID)).To(Equal(stack.Resource(serverType).ID))
+	})
+
+	It("Should report an output Secret it does not control", func() {
+		Expect(c.Create(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "taken"}})).To(Succeed())
+		key := create("in-memory-outputs", false)
+		instanceStack := get(key)
+		instanceStack.Spec.WriteOutputsToSecret = "taken"
+		Expect(c.Update(ctx, instanceStack)).To(Succeed())
+		reconcile(key)
+
+		condition := meta.FindStatusCondition(get(key).Status.Conditions, infrastructurev1alpha1.ConditionReady)
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal(infrastructurev1alpha1.ReasonOutputTargetConflict))
+	})
+
+	It("Should keep a protected server until protection is turned off", func() {
+		key := create("in-memory-protected", true)
+		reconcile(key)
+		stack := fakeEngine.Stack("default-in-memory-protected")
+		Expect(stack.Resource(serverType).Protect).To(BeTrue())
+
+		Expect(c.Delete(ctx, get(key))).To(Succeed())
+		reconcile(key)
+		instanceStack := get(key)
+		Expect(meta.IsStatusConditionTrue(instanceStack.Status.Conditions, infrastructurev1alpha1.ConditionDeletionBlocked)).To(BeTrue())
+		Expect(stack.Resources()).To(HaveLen(1))
+
+		By("destroying the server once deletion protection is off")
+		instanceStack.Spec.DeletionProtection = false
+		Expect(c.Update(ctx, instanceStack)).To(Succeed())
+		reconcile(key)
+		Expect(stack.Resources()).To(BeEmpty())
+		Expect(apierrors.IsNotFound(c.Get(ctx, key, &infrastructurev1alpha1.InstanceStack{}))).To(BeTrue())
+	})
+
+	It("Should destroy a protected server whose deletion was confirmed", func() {
+		key := create("confirmed", true)
+		reconcile(key)
+		stack := fakeEngine.Stack("default-confirmed")
+		Expect(stack.Resource(serverType).Protect).To(BeTrue())
+
+		instanceStack := get(key)
+		instanceStack.Annotations = map[string]string{infrastructurev1alpha1.ConfirmDeletionAnnotation: "confirmed"}
+		Expect(c.Update(ctx, instanceStack)).To(Succeed())
+		Expect(c.Delete(ctx, get(key))).To(Succeed())
+		reconcile(key)
+		Expect(stack.Resources()).To(BeEmpty())
+		Expect(fakeEngine.Stack("default-confirmed")).To(BeNil())
+		Expect(apierrors.IsNotFound(c.Get(ctx, key, &infrastructurev1alpha1.InstanceStack{}))).To(BeTrue())
+	})
+})
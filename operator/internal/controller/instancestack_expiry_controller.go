@@ -0,0 +1,191 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+)
+
+// expiryWarnings are how long before expiry ExpiringSoon events are emitted.
+var expiryWarnings = []time.Duration{24 * time.Hour, time.Hour}
+
+// InstanceStackExpiryReconciler deletes InstanceStacks once their ttl or
+// expiresAt has passed. The finalizer then destroys the resources as usual.
+type InstanceStackExpiryReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+func (r *InstanceStackExpiryReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	instanceStack := &infrastructurev1alpha1.InstanceStack{}
+	if err := r.Get(ctx, req.NamespacedName, instanceStack); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !instanceStack.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	now := time.Now()
+	if r.Now != nil {
+		now = r.Now()
+	}
+	original := instanceStack.Status.DeepCopy()
+	status := &instanceStack.Status
+
+	// 같은 연장을 두 번 적용하지 않도록 어노테이션을 먼저 제거한 뒤 status에 누적
+	if value, ok := instanceStack.Annotations[infrastructurev1alpha1.ExtendExpiryAnnotation]; ok {
+		delete(instanceStack.Annotations, infrastructurev1alpha1.ExtendExpiryAnnotation)
+		if err := r.Update(ctx, instanceStack); err != nil {
+			log.Error(err, "failed to remove the extend-expiry annotation")
+			return ctrl.Result{}, err
+		}
+		extension, err := time.ParseDuration(value)
+		if err != nil || extension <= 0 {
+			r.Recorder.Eventf(instanceStack, corev1.EventTypeWarning, "InvalidExtension",
+				"ignoring %s=%q: must be a positive duration such as 24h", infrastructurev1alpha1.ExtendExpiryAnnotation, value)
+		} else if expiry := baseExpiry(instanceStack); expiry != nil {
+			total := extension
+			if status.ExpiryExtension != nil {
+				total += status.ExpiryExtension.Duration
+			}
+			status.ExpiryExtension = &metav1.Duration{Duration: total}
+			if err := r.Status().Update(ctx, instanceStack); err != nil {
+				log.Error(err, "failed to update InstanceStack status")
+				r.Recorder.Eventf(instanceStack, corev1.EventTypeWarning, "ExtensionFailed",
+					"could not record the extension by %s, set %s again: %v", extension, infrastructurev1alpha1.ExtendExpiryAnnotation, err)
+				return ctrl.Result{}, err
+			}
+			original = instanceStack.Status.DeepCopy()
+			r.Recorder.Eventf(instanceStack, corev1.EventTypeNormal, "ExpiryExtended",
+				"expiry extended by %s to %s", extension, expiry.Add(total).Format(time.RFC3339))
+		}
+	}
+
+	expiresAt := effectiveExpiry(instanceStack)
+	if expiresAt == nil {
+		status.ExpiresAt, status.ExpiryExtension, status.LastExpiryWarning = nil, nil, nil
+		return ctrl.Result{}, r.updateStatusIfChanged(ctx, instanceStack, original)
+	}
+	status.ExpiresAt = &metav1.Time{Time: *expiresAt}
+
+	remaining := expiresAt.Sub(now)
+	if remaining <= 0 {
+		if err := r.updateStatusIfChanged(ctx, instanceStack, original); err != nil {
+			return ctrl.Result{}, err
+		}
+		log.Info("InstanceStack expired, deleting", "expiresAt", expiresAt)
+		r.Recorder.Eventf(instanceStack, corev1.EventTypeNormal, "Expired", "expired at %s, deleting", expiresAt.Format(time.RFC3339))
+		err := r.Delete(ctx, instanceStack)
+		switch {
+		case apierrors.IsNotFound(err):
+			return ctrl.Result{}, nil
+		case apierrors.IsForbidden(err):
+			// 삭제 보호나 참조 중인 스택은 webhook이 삭제를 거부함
+			r.Recorder.Eventf(instanceStack, corev1.EventTypeWarning, "ExpiryBlocked", "cannot delete expired InstanceStack: %v", err)
+			return ctrl.Result{RequeueAfter: time.Hour}, nil
+		case err != nil:
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if warning, ok := dueExpiryWarning(status.LastExpiryWarning, *expiresAt, now); ok {
+		r.Recorder.Eventf(instanceStack, corev1.EventTypeWarning, "ExpiringSoon",
+			"will be deleted at %s (in %s); annotate with %s=<duration> to extend",
+			expiresAt.Format(time.RFC3339), remaining.Round(time.Minute), infrastructurev1alpha1.ExtendExpiryAnnotation)
+		log.Info("InstanceStack expires soon", "expiresAt", expiresAt, "warning", warning)
+		status.LastExpiryWarning = &metav1.Time{Time: now}
+	}
+	if err := r.updateStatusIfChanged(ctx, instanceStack, original); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: nextExpiryCheck(*expiresAt, now)}, nil
+}
+
+func (r *InstanceStackExpiryReconciler) updateStatusIfChanged(ctx context.Context, instanceStack *infrastructurev1alpha1.InstanceStack, original *infrastructurev1alpha1.InstanceStackStatus) error {
+	if equality.Semantic.DeepEqual(original, &instanceStack.Status) {
+		return nil
+	}
+	if err := r.Status().Update(ctx, instanceStack); err != nil {
+		log.FromContext(ctx).Error(err, "failed to update InstanceStack status")
+		return err
+	}
+	return nil
+}
+
+// baseExpiry is the expiry from the spec alone, or nil.
+func baseExpiry(instanceStack *infrastructurev1alpha1.InstanceStack) *time.Time {
+	var expiry time.Time
+	switch {
+	case instanceStack.Spec.ExpiresAt != nil:
+		expiry = instanceStack.Spec.ExpiresAt.Time
+	case instanceStack.Spec.TTL != nil:
+		expiry = instanceStack.CreationTimestamp.Add(instanceStack.Spec.TTL.Duration)
+	default:
+		return nil
+	}
+	return &expiry
+}
+
+// effectiveExpiry is the spec expiry plus any extensions, or nil.
+func effectiveExpiry(instanceStack *infrastructurev1alpha1.InstanceStack) *time.Time {
+	expiry := baseExpiry(instanceStack)
+	if expiry != nil && instanceStack.Status.ExpiryExtension != nil {
+		*expiry = expiry.Add(instanceStack.Status.ExpiryExtension.Duration)
+	}
+	return expiry
+}
+
+// dueExpiryWarning returns the warning window now falls in if no warning was
+// emitted since it opened.
+func dueExpiryWarning(lastWarning *metav1.Time, expiresAt, now time.Time) (time.Duration, bool) {
+	for i := len(expiryWarnings) - 1; i >= 0; i-- {
+		opened := expiresAt.Add(-expiryWarnings[i])
+		if now.Before(opened) {
+			continue
+		}
+		return expiryWarnings[i], lastWarning == nil || lastWarning.Time.Before(opened)
+	}
+	return 0, false
+}
+
+// nextExpiryCheck is how long until the next warning window opens or the
+// InstanceStack expires.
+func nextExpiryCheck(expiresAt, now time.Time) time.Duration {
+	next := expiresAt
+	for _, warning := range expiryWarnings {
+		if opened := expiresAt.Add(-warning); opened.After(now) && opened.Before(next) {
+			next = opened
+		}
+	}
+	return next.Sub(now) + time.Second
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *InstanceStackExpiryReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.Recorder == nil {
+		r.Recorder = mgr.GetEventRecorderFor("instancestack-expiry")
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&infrastructurev1alpha1.InstanceStack{}).
+		Named("instancestack-expiry").
+		Complete(r)
+}
@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+)
+
+var _ = Describe("InstanceStack expiry", func() {
+	var (
+		ctx       context.Context
+		k8sClient client.Client
+		recorder  *record.FakeRecorder
+		now       time.Time
+		r         *InstanceStackExpiryReconciler
+	)
+	created := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+
+	setup := func(instanceStack *infrastructurev1alpha1.InstanceStack) {
+		ctx = context.Background()
+		scheme := runtime.NewScheme()
+		Expect(infrastructurev1alpha1.AddToScheme(scheme)).To(Succeed())
+		k8sClient = fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(instanceStack).WithStatusSubresource(instanceStack).Build()
+		recorder = record.NewFakeRecorder(10)
+		r = &InstanceStackExpiryReconciler{Client: k8sClient, Recorder: recorder, Now: func() time.Time { return now }}
+	}
+	ciStack := func(annotations map[string]string) *infrastructurev1alpha1.InstanceStack {
+		return &infrastructurev1alpha1.InstanceStack{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "ci", Name: "build-42", CreationTimestamp: metav1.NewTime(created),
+				Finalizers: []string{"instancestack.finalizers.cloudprovider.io"}, Annotations: annotations,
+			},
+			Spec: infrastructurev1alpha1.InstanceStackSpec{TTL: &metav1.Duration{Duration: 48 * time.Hour}},
+		}
+	}
+	reconcileAndGet := func() (reconcile.Result, *infrastructurev1alpha1.InstanceStack) {
+		key := client.ObjectKey{Namespace: "ci", Name: "build-42"}
+		result, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		instanceStack := &infrastructurev1alpha1.InstanceStack{}
+		Expect(k8sClient.Get(ctx, key, instanceStack)).To(Succeed())
+		return result, instanceStack
+	}
+
+	It("Should warn before expiry and requeue at the next warning window", func() {
+		setup(ciStack(nil))
+		now = created.Add(time.Hour)
+		result, instanceStack := reconcileAndGet()
+		Expect(instanceStack.Status.ExpiresAt.Time).To(BeTemporally("==", created.Add(48*time.Hour)))
+		Expect(result.RequeueAfter).To(Equal(23*time.Hour + time.Second))
+		Expect(recorder.Events).To(BeEmpty())
+
+		now = created.Add(25 * time.Hour)
+		_, instanceStack = reconcileAndGet()
+		Expect(recorder.Events).To(Receive(ContainSubstring("ExpiringSoon")))
+		Expect(instanceStack.Status.LastExpiryWarning).NotTo(BeNil())
+
+		By("not repeating the warning inside the same window")
+		now = created.Add(30 * time.Hour)
+		reconcileAndGet()
+		Expect(recorder.Events).To(BeEmpty())
+
+		now = created.Add(47*time.Hour + 30*time.Minute)
+		reconcileAndGet()
+		Expect(recorder.Events).To(Receive(ContainSubstring("ExpiringSoon")))
+	})
+
+	It("Should extend the expiry through the annotation", func() {
+		setup(ciStack(map[string]string{infrastructurev1alpha1.ExtendExpiryAnnotation: "24h"}))
+		now = created.Add(47 * time.Hour)
+		_, instanceStack := reconcileAndGet()
+		Expect(instanceStack.Annotations).NotTo(HaveKey(infrastructurev1alpha1.ExtendExpiryAnnotation))
+		Expect(instanceStack.Status.ExpiryExtension.Duration).To(Equal(24 * time.Hour))
+		Expect(instanceStack.Status.ExpiresAt.Time).To(BeTemporally("==", created.Add(72*time.Hour)))
+		Expect(recorder.Events).To(Receive(ContainSubstring("ExpiryExtended")))
+	})
+
+	It("Should apply an extension once when removing the annotation conflicts", func() {
+		instanceStack := ciStack(map[string]string{infrastructurev1alpha1.ExtendExpiryAnnotation: "24h"})
+		setup(instanceStack)
+		conflicts := 1
+		k8sClient = fake.NewClientBuilder().WithScheme(k8sClient.Scheme()).
+			WithObjects(instanceStack).WithStatusSubresource(instanceStack).
+			WithInterceptorFuncs(interceptor.Funcs{
+				Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+					if conflicts > 0 {
+						conflicts--
+						return apierrors.NewConflict(infrastructurev1alpha1.GroupVersion.WithResource("instancestacks").GroupResource(),
+							obj.GetName(), errors.New("the object has been modified"))
+					}
+					return c.Update(ctx, obj, opts...)
+				},
+			}).Build()
+		r.Client = k8sClient
+		now = created.Add(47 * time.Hour)
+
+		key := client.ObjectKey{Namespace: "ci", Name: "build-42"}
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(apierrors.IsConflict(err)).To(BeTrue())
+		_, instanceStack = reconcileAndGet()
+		Expect(instanceStack.Annotations).NotTo(HaveKey(infrastructurev1alpha1.ExtendExpiryAnnotation))
+		Expect(instanceStack.Status.ExpiryExtension.Duration).To(Equal(24 * time.Hour))
+	})
+
+	It("Should delete the InstanceStack once it has expired", func() {
+		setup(ciStack(nil))
+		now = created.Add(49 * time.Hour)
+		_, instanceStack := reconcileAndGet()
+		Expect(instanceStack.DeletionTimestamp).NotTo(BeNil())
+		Expect(recorder.Events).To(Receive(ContainSubstring("Expired")))
+	})
+})
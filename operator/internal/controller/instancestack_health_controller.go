@@ -0,0 +1,335 @@
+package controller
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+	"github.com/gunniLee/cloud-provider-operator/internal/openstack"
+)
+
+// consoleLogLines is how much of the console log the cloud-init probe reads.
+const consoleLogLines = 200
+
+// ConsoleOutputFunc returns the console log of the server of an InstanceStack.
+type ConsoleOutputFunc func(ctx context.Context, instanceStack *infrastructurev1alpha1.InstanceStack, serverID string) (string, error)
+
+// InstanceStackHealthReconciler probes provisioned InstanceStacks and records
+// the result in the HealthCheckPassed condition. It never runs Pulumi.
+type InstanceStackHealthReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// ConsoleOutput reads the console log for cloud-init probes. Defaults to
+	// the Nova API.
+	ConsoleOutput ConsoleOutputFunc
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+
+	// lastProbes records when each InstanceStack was last probed, so that
+	// events in between, such as status writes of this or other controllers,
+	// do not probe before spec.healthCheck.periodSeconds has passed.
+	mu         sync.Mutex
+	lastProbes map[types.NamespacedName]lastProbe
+}
+
+// lastProbe is the time and the generation of a probe round.
+type lastProbe struct {
+	time       time.Time
+	generation int64
+}
+
+func (r *InstanceStackHealthReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	instanceStack := &infrastructurev1alpha1.InstanceStack{}
+	if err := r.Get(ctx, req.NamespacedName, instanceStack); err != nil {
+		if apierrors.IsNotFound(err) {
+			r.forgetProbe(req.NamespacedName)
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	healthCheck := instanceStack.Spec.HealthCheck
+	if healthCheck == nil || !instanceStack.DeletionTimestamp.IsZero() {
+		r.forgetProbe(req.NamespacedName)
+		return ctrl.Result{}, nil
+	}
+	period := time.Duration(healthCheck.PeriodSeconds) * time.Second
+	if period <= 0 {
+		period = time.Minute
+	}
+
+	// Pulumi up이 끝나 주소가 출력되기 전에는 probe하지 않음
+	address := healthAddress(instanceStack)
+	if address == "" {
+		return ctrl.Result{}, nil
+	}
+	// spec가 바뀌지 않았다면 주기가 지나기 전에는 다시 probe하지 않음
+	if wait := r.untilNextProbe(req.NamespacedName, instanceStack.Generation, period); wait > 0 {
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+
+	failures := r.probe(ctx, instanceStack, address)
+	r.recordProbe(req.NamespacedName, instanceStack.Generation)
+	if len(failures) > 0 {
+		log.V(1).Info("health check failed", "address", address, "failures", failures)
+	}
+	if updateHealthStatus(instanceStack, failures) {
+		if err := r.Status().Update(ctx, instanceStack); err != nil {
+			log.Error(err, "failed to update InstanceStack status")
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{RequeueAfter: period}, nil
+}
+
+func (r *InstanceStackHealthReconciler) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
+
+// untilNextProbe returns how long to wait before the next probe round of an
+// InstanceStack, or zero if it is due. A new generation is probed at once.
+func (r *InstanceStackHealthReconciler) untilNextProbe(key types.NamespacedName, generation int64, period time.Duration) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	last, ok := r.lastProbes[key]
+	if !ok || last.generation != generation {
+		return 0
+	}
+	return last.time.Add(period).Sub(r.now())
+}
+
+func (r *InstanceStackHealthReconciler) recordProbe(key types.NamespacedName, generation int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.lastProbes == nil {
+		r.lastProbes = map[types.NamespacedName]lastProbe{}
+	}
+	r.lastProbes[key] = lastProbe{time: r.now(), generation: generation}
+}
+
+func (r *InstanceStackHealthReconciler) forgetProbe(key types.NamespacedName) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.lastProbes, key)
+}
+
+// healthAddress returns the address to probe from the stack outputs.
+func healthAddress(instanceStack *infrastructurev1alpha1.InstanceStack) string {
+	names := []string{"floatingIP", "instanceIP"}
+	if name := instanceStack.Spec.HealthCheck.AddressOutput; name != "" {
+		names = []string{name}
+	}
+	for _, name := range names {
+		if value := statusOutputString(instanceStack, name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// statusOutputString returns a string output recorded in status, or "".
+func statusOutputString(instanceStack *infrastructurev1alpha1.InstanceStack, name string) string {
+	raw, ok := instanceStack.Status.Outputs[name]
+	if !ok {
+		return ""
+	}
+	var value string
+	if json.Unmarshal(raw.Raw, &value) != nil || value == infrastructurev1alpha1.MaskedOutputValue {
+		return ""
+	}
+	return value
+}
+
+// probe runs every probe once and describes the ones that failed.
+func (r *InstanceStackHealthReconciler) probe(ctx context.Context, instanceStack *infrastructurev1alpha1.InstanceStack, address string) []string {
+	healthCheck := instanceStack.Spec.HealthCheck
+	timeout := time.Duration(healthCheck.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	var failures []string
+	for _, probe := range healthCheck.Probes {
+		probeCtx, cancel := context.WithTimeout(ctx, timeout)
+		var err error
+		switch {
+		case probe.TCPSocket != nil:
+			err = probeTCP(probeCtx, address, probe.TCPSocket)
+		case probe.HTTPGet != nil:
+			err = probeHTTP(probeCtx, address, probe.HTTPGet)
+		case probe.CloudInit != nil:
+			err = r.probeCloudInit(probeCtx, instanceStack, probe.CloudInit)
+		default:
+			err = fmt.Errorf("no probe action")
+		}
+		cancel()
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", probe.Name, err))
+		}
+	}
+	return failures
+}
+
+func probeTCP(ctx context.Context, address string, probe *infrastructurev1alpha1.TCPSocketProbe) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(address, strconv.Itoa(int(probe.Port))))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func probeHTTP(ctx context.Context, address string, probe *infrastructurev1alpha1.HTTPGetProbe) error {
+	scheme := strings.ToLower(probe.Scheme)
+	if scheme == "" {
+		scheme = "http"
+	}
+	path := probe.Path
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	url := fmt.Sprintf("%s://%s%s", scheme, net.JoinHostPort(address, strconv.Itoa(int(probe.Port))), path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	// 인스턴스는 보통 자체 서명 인증서를 사용하므로 검증하지 않음 (kubelet probe와 동일)
+	transport := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}} //nolint:gosec
+	defer transport.CloseIdleConnections()
+	httpClient := &http.Client{
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("HTTP status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (r *InstanceStackHealthReconciler) probeCloudInit(ctx context.Context, instanceStack *infrastructurev1alpha1.InstanceStack, probe *infrastructurev1alpha1.CloudInitProbe) error {
+	serverID := statusOutputString(instanceStack, "serverID")
+	if serverID == "" {
+		return fmt.Errorf("stack has no serverID output")
+	}
+	marker := probe.Marker
+	if marker == "" {
+		marker = infrastructurev1alpha1.DefaultCloudInitMarker
+	}
+	re, err := regexp.Compile(marker)
+	if err != nil {
+		return fmt.Errorf("invalid marker: %w", err)
+	}
+	consoleOutput := r.ConsoleOutput
+	if consoleOutput == nil {
+		consoleOutput = novaConsoleOutput(r.Client)
+	}
+	output, err := consoleOutput(ctx, instanceStack, serverID)
+	if err != nil {
+		return err
+	}
+	if !re.MatchString(output) {
+		return fmt.Errorf("console log does not match %q yet", marker)
+	}
+	return nil
+}
+
+// novaConsoleOutput reads console logs through the Nova API.
+func novaConsoleOutput(c client.Reader) ConsoleOutputFunc {
+	return func(ctx context.Context, instanceStack *infrastructurev1alpha1.InstanceStack, serverID string) (string, error) {
+		providerType, err := providerTypeOf(ctx, c, instanceStack)
+		if err != nil {
+			return "", err
+		}
+		if providerType != infrastructurev1alpha1.ProviderOpenStack {
+			return "", fmt.Errorf("console logs are only read from OpenStack servers, not %s", providerType)
+		}
+		creds, err := instanceStackCredentials(ctx, c, instanceStack)
+		if err != nil {
+			return "", err
+		}
+		osClient, err := openstack.NewClient(ctx, creds)
+		if err != nil {
+			return "", err
+		}
+		return osClient.ConsoleOutput(ctx, serverID, consoleLogLines)
+	}
+}
+
+// updateHealthStatus records a probe round and reports whether the status
+// changed. HealthCheckPassed only turns False after FailureThreshold
+// consecutive failed rounds. The failure count stops at the threshold so a
+// server that stays down does not rewrite the status every period.
+func updateHealthStatus(instanceStack *infrastructurev1alpha1.InstanceStack, failures []string) bool {
+	status := &instanceStack.Status
+	threshold := instanceStack.Spec.HealthCheck.FailureThreshold
+	if threshold <= 0 {
+		threshold = 3
+	}
+
+	previousFailures := status.HealthCheckFailures
+	condition := metav1.Condition{
+		Type:               infrastructurev1alpha1.ConditionHealthCheckPassed,
+		Status:             metav1.ConditionTrue,
+		Reason:             infrastructurev1alpha1.ReasonProbesSucceeded,
+		Message:            "all probes succeeded",
+		ObservedGeneration: instanceStack.Generation,
+	}
+	if len(failures) == 0 {
+		status.HealthCheckFailures = 0
+	} else {
+		if status.HealthCheckFailures < threshold {
+			status.HealthCheckFailures++
+		}
+		condition.Message = strings.Join(failures, "; ")
+		switch {
+		case status.HealthCheckFailures >= threshold:
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = infrastructurev1alpha1.ReasonProbesFailed
+		case meta.FindStatusCondition(status.Conditions, condition.Type) == nil:
+			condition.Status = metav1.ConditionUnknown
+			condition.Reason = infrastructurev1alpha1.ReasonProbing
+		default:
+			// 임계값에 도달하기 전에는 이전 결과를 유지
+			return status.HealthCheckFailures != previousFailures
+		}
+	}
+	changed := meta.SetStatusCondition(&status.Conditions, condition)
+	return changed || status.HealthCheckFailures != previousFailures
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *InstanceStackHealthReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&infrastructurev1alpha1.InstanceStack{}).
+		Named("instancestack-health").
+		Complete(r)
+}
@@ -0,0 +1,157 @@
+package controller
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+)
+
+var _ = Describe("InstanceStack health checks", func() {
+	raw := func(v string) apiextensionsv1.JSON { return apiextensionsv1.JSON{Raw: []byte(v)} }
+
+	newInstanceStack := func(probes ...infrastructurev1alpha1.Probe) *infrastructurev1alpha1.InstanceStack {
+		return &infrastructurev1alpha1.InstanceStack{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+			Spec: infrastructurev1alpha1.InstanceStackSpec{
+				HealthCheck: &infrastructurev1alpha1.HealthCheck{Probes: probes, TimeoutSeconds: 1, FailureThreshold: 2},
+			},
+			Status: infrastructurev1alpha1.InstanceStackStatus{
+				Outputs: map[string]apiextensionsv1.JSON{"instanceIP": raw(`"127.0.0.1"`), "serverID": raw(`"srv-1"`)},
+			},
+		}
+	}
+
+	It("Should probe TCP ports and HTTP endpoints on the instance address", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/healthz" {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		DeferCleanup(server.Close)
+		u, err := url.Parse(server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		port, err := strconv.Atoi(u.Port())
+		Expect(err).NotTo(HaveOccurred())
+
+		closed, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		closedPort := closed.Addr().(*net.TCPAddr).Port
+		Expect(closed.Close()).To(Succeed())
+
+		instanceStack := newInstanceStack(
+			infrastructurev1alpha1.Probe{Name: "tcp", TCPSocket: &infrastructurev1alpha1.TCPSocketProbe{Port: int32(port)}},
+			infrastructurev1alpha1.Probe{Name: "http", HTTPGet: &infrastructurev1alpha1.HTTPGetProbe{Port: int32(port), Path: "/healthz"}},
+			infrastructurev1alpha1.Probe{Name: "missing", HTTPGet: &infrastructurev1alpha1.HTTPGetProbe{Port: int32(port), Path: "/missing"}},
+			infrastructurev1alpha1.Probe{Name: "closed", TCPSocket: &infrastructurev1alpha1.TCPSocketProbe{Port: int32(closedPort)}},
+		)
+		r := &InstanceStackHealthReconciler{}
+		failures := r.probe(context.Background(), instanceStack, healthAddress(instanceStack))
+		Expect(failures).To(HaveLen(2))
+		Expect(failures[0]).To(HavePrefix("missing: HTTP status 404"))
+		Expect(failures[1]).To(HavePrefix("closed: "))
+	})
+
+	It("Should wait for the cloud-init marker in the console log", func() {
+		console := "[  OK  ] Started cloud-init.service\n"
+		r := &InstanceStackHealthReconciler{
+			ConsoleOutput: func(_ context.Context, _ *infrastructurev1alpha1.InstanceStack, serverID string) (string, error) {
+				Expect(serverID).To(Equal("srv-1"))
+				return console, nil
+			},
+		}
+		instanceStack := newInstanceStack(infrastructurev1alpha1.Probe{Name: "boot", CloudInit: &infrastructurev1alpha1.CloudInitProbe{}})
+		Expect(r.probe(context.Background(), instanceStack, "127.0.0.1")).To(HaveLen(1))
+
+		console += "Cloud-init v. 23.4.4 finished at Mon, 19 Oct 2026 01:02:03 +0000. Datasource DataSourceOpenStack.\n"
+		Expect(r.probe(context.Background(), instanceStack, "127.0.0.1")).To(BeEmpty())
+	})
+
+	It("Should only report failure after the failure threshold", func() {
+		instanceStack := newInstanceStack(infrastructurev1alpha1.Probe{Name: "ssh", TCPSocket: &infrastructurev1alpha1.TCPSocketProbe{Port: 22}})
+		passed := func() *metav1.Condition {
+			return meta.FindStatusCondition(instanceStack.Status.Conditions, infrastructurev1alpha1.ConditionHealthCheckPassed)
+		}
+
+		Expect(updateHealthStatus(instanceStack, nil)).To(BeTrue())
+		Expect(passed().Status).To(Equal(metav1.ConditionTrue))
+
+		Expect(updateHealthStatus(instanceStack, []string{"ssh: refused"})).To(BeTrue())
+		Expect(passed().Status).To(Equal(metav1.ConditionTrue))
+		Expect(instanceStack.Status.HealthCheckFailures).To(BeEquivalentTo(1))
+
+		Expect(updateHealthStatus(instanceStack, []string{"ssh: refused"})).To(BeTrue())
+		Expect(passed().Status).To(Equal(metav1.ConditionFalse))
+		Expect(passed().Reason).To(Equal(infrastructurev1alpha1.ReasonProbesFailed))
+
+		By("not rewriting the status while the server stays down")
+		Expect(updateHealthStatus(instanceStack, []string{"ssh: refused"})).To(BeFalse())
+		Expect(instanceStack.Status.HealthCheckFailures).To(BeEquivalentTo(2))
+
+		Expect(updateHealthStatus(instanceStack, nil)).To(BeTrue())
+		Expect(passed().Status).To(Equal(metav1.ConditionTrue))
+		Expect(instanceStack.Status.HealthCheckFailures).To(BeZero())
+	})
+
+	It("Should not probe again before the period has passed", func() {
+		closed, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		closedPort := closed.Addr().(*net.TCPAddr).Port
+		Expect(closed.Close()).To(Succeed())
+
+		instanceStack := newInstanceStack(infrastructurev1alpha1.Probe{Name: "closed", TCPSocket: &infrastructurev1alpha1.TCPSocketProbe{Port: int32(closedPort)}})
+		instanceStack.Spec.HealthCheck.PeriodSeconds = 60
+		instanceStack.Spec.HealthCheck.FailureThreshold = 5
+		scheme := runtime.NewScheme()
+		Expect(infrastructurev1alpha1.AddToScheme(scheme)).To(Succeed())
+		c := fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(instanceStack).
+			WithStatusSubresource(&infrastructurev1alpha1.InstanceStack{}).
+			Build()
+		now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+		r := &InstanceStackHealthReconciler{Client: c, Scheme: scheme, Now: func() time.Time { return now }}
+		key := client.ObjectKeyFromObject(instanceStack)
+		reconcile := func() (int32, time.Duration) {
+			result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.Get(context.Background(), key, instanceStack)).To(Succeed())
+			return instanceStack.Status.HealthCheckFailures, result.RequeueAfter
+		}
+
+		failures, requeueAfter := reconcile()
+		Expect(failures).To(BeEquivalentTo(1))
+		Expect(requeueAfter).To(Equal(time.Minute))
+
+		By("waiting out the period after the status write")
+		now = now.Add(20 * time.Second)
+		failures, requeueAfter = reconcile()
+		Expect(failures).To(BeEquivalentTo(1))
+		Expect(requeueAfter).To(Equal(40 * time.Second))
+
+		now = now.Add(40 * time.Second)
+		failures, requeueAfter = reconcile()
+		Expect(failures).To(BeEquivalentTo(2))
+		Expect(requeueAfter).To(Equal(time.Minute))
+
+		By("probing a new generation at once")
+		instanceStack.Generation++
+		Expect(c.Update(context.Background(), instanceStack)).To(Succeed())
+		failures, _ = reconcile()
+		Expect(failures).To(BeEquivalentTo(3))
+	})
+})
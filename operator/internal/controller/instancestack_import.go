@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/events"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optpreview"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+	"github.com/gunniLee/cloud-provider-operator/internal/engine"
+)
+
+// previewImport runs a preview of the import and returns the properties that
+// would change. An empty result means adopting the resource is a no-op.
+func previewImport(ctx context.Context, stack engine.Stack) ([]infrastructurev1alpha1.PropertyMismatch, error) {
+	engineEvents := make(chan events.EngineEvent)
+	collected := make(chan []infrastructurev1alpha1.PropertyMismatch)
+	go func() {
+		var mismatches []infrastructurev1alpha1.PropertyMismatch
+		for event := range engineEvents {
+			if event.ResourcePreEvent == nil || !isImportStep(event.ResourcePreEvent.Metadata.Op) {
+				continue
+			}
+			mismatches = append(mismatches, stepMismatches(event.ResourcePreEvent.Metadata)...)
+		}
+		collected <- mismatches
+	}()
+
+	_, err := stack.Preview(ctx, optpreview.Diff(), optpreview.EventStreams(engineEvents))
+	mismatches := <-collected
+	if err != nil {
+		return nil, fmt.Errorf("failed to preview import: %w", err)
+	}
+	return mismatches, nil
+}
+
+func isImportStep(op apitype.OpType) bool {
+	return op == apitype.OpImport || op == apitype.OpImportReplacement
+}
+
+// stepMismatches turns the diff of a planned import into mismatches. An
+// import that replaces the resource always counts as a mismatch.
+func stepMismatches(step apitype.StepEventMetadata) []infrastructurev1alpha1.PropertyMismatch {
+	var mismatches []infrastructurev1alpha1.PropertyMismatch
+	if len(step.DetailedDiff) > 0 {
+		for property, diff := range step.DetailedDiff {
+			mismatches = append(mismatches, infrastructurev1alpha1.PropertyMismatch{Property: property, Kind: string(diff.Kind)})
+		}
+	} else {
+		for _, key := range step.Diffs {
+			mismatches = append(mismatches, infrastructurev1alpha1.PropertyMismatch{Property: key, Kind: string(apitype.DiffUpdate)})
+		}
+	}
+	if len(mismatches) == 0 && step.Op != apitype.OpImport {
+		mismatches = append(mismatches, infrastructurev1alpha1.PropertyMismatch{Property: "*", Kind: string(step.Op)})
+	}
+	sort.Slice(mismatches, func(i, j int) bool { return mismatches[i].Property < mismatches[j].Property })
+	return mismatches
+}
+
+// setImportStatus records the outcome of an import on the status.
+func (r *InstanceStackReconciler) setImportStatus(ctx context.Context, instanceStack *infrastructurev1alpha1.InstanceStack, importID string, mismatches []infrastructurev1alpha1.PropertyMismatch) error {
+	condition := metav1.Condition{
+		Type:               infrastructurev1alpha1.ConditionImported,
+		Status:             metav1.ConditionTrue,
+		Reason:             infrastructurev1alpha1.ReasonImportSucceeded,
+		Message:            fmt.Sprintf("server %s is managed by this InstanceStack", importID),
+		ObservedGeneration: instanceStack.Generation,
+	}
+	if len(mismatches) > 0 {
+		properties := make([]string, 0, len(mismatches))
+		for _, m := range mismatches {
+			properties = append(properties, m.Property)
+		}
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = infrastructurev1alpha1.ReasonImportMismatch
+		condition.Message = fmt.Sprintf("server %s differs from the spec in %s; update the spec to match the server",
+			importID, strings.Join(properties, ", "))
+	} else {
+		instanceStack.Status.ImportedID = importID
+	}
+	instanceStack.Status.ImportMismatches = mismatches
+	meta.SetStatusCondition(&instanceStack.Status.Conditions, condition)
+	return r.Status().Update(ctx, instanceStack)
+}
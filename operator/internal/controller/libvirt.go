@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+	"github.com/gunniLee/cloud-provider-operator/internal/openstack"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+)
+
+// defaultLibvirtURI is the daemon of Libvirt ProviderConfigs that do not set
+// spec.libvirt.uri.
+const defaultLibvirtURI = "qemu:///system"
+
+// libvirtProvider runs programs against the libvirt daemon of the
+// ProviderConfig, e.g. on a laptop or a CI runner.
+type libvirtProvider struct{}
+
+func (libvirtProvider) Target(ctx context.Context, c client.Reader, instanceStack *infrastructurev1alpha1.InstanceStack) (*Target, error) {
+	name := providerConfigOrDefault(instanceStack.Spec.ProviderConfigName)
+	providerConfig := &infrastructurev1alpha1.ProviderConfig{}
+	if err := c.Get(ctx, types.NamespacedName{Name: name}, providerConfig); err != nil {
+		return nil, fmt.Errorf("failed to get ProviderConfig %q: %w", name, err)
+	}
+
+	binding, err := openstack.FindTenantBinding(ctx, c, instanceStack.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	if err := openstack.CheckTenantProviderConfig(binding, name); err != nil {
+		return nil, err
+	}
+
+	// libvirt에는 리전이 없으므로 ProviderConfig의 리전은 배치용 이름으로만 사용
+	region := instanceStack.Spec.Region
+	if region == "" {
+		region = providerConfig.Spec.Region
+	} else if err := openstack.CheckRegion(ctx, c, name, region); err != nil {
+		return nil, err
+	}
+
+	uri := defaultLibvirtURI
+	if settings := providerConfig.Spec.Libvirt; settings != nil && settings.URI != "" {
+		uri = settings.URI
+	}
+	return &Target{Region: region, Config: auto.ConfigMap{"libvirt:uri": {Value: uri}}}, nil
+}
@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+	"github.com/gunniLee/cloud-provider-operator/internal/machineclass"
+)
+
+// machineClassIndex indexes InstanceStacks by spec.machineClassName.
+const machineClassIndex = "spec.machineClassName"
+
+func machineClassKeys(obj client.Object) []string {
+	instanceStack := obj.(*infrastructurev1alpha1.InstanceStack)
+	if instanceStack.Spec.MachineClassName == "" {
+		return nil
+	}
+	return []string{instanceStack.Spec.MachineClassName}
+}
+
+// requestsForMachineClass maps a changed MachineClass to the InstanceStacks
+// that use it.
+func requestsForMachineClass(c client.Reader) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		instanceStacks := &infrastructurev1alpha1.InstanceStackList{}
+		if err := c.List(ctx, instanceStacks, client.MatchingFields{machineClassIndex: obj.GetName()}); err != nil {
+			log.FromContext(ctx).Error(err, "failed to list InstanceStacks of MachineClass", "name", obj.GetName())
+			return nil
+		}
+		requests := make([]reconcile.Request, 0, len(instanceStacks.Items))
+		for _, item := range instanceStacks.Items {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: item.Namespace, Name: item.Name}})
+		}
+		return requests
+	}
+}
+
+// resolveMachineClass applies the MachineClass of an InstanceStack to
+// resolved and records the settings used in status.machine. ok is false
+// while the class cannot be used; the MachineClassResolved condition says
+// why. A provisioned server is not replaced because its class changed unless
+// the InstanceStack allows replacement.
+func (r *InstanceStackReconciler) resolveMachineClass(ctx context.Context, instanceStack, resolved *infrastructurev1alpha1.InstanceStack) (*infrastructurev1alpha1.InstanceStack, bool, error) {
+	name := instanceStack.Spec.MachineClassName
+	if name == "" {
+		if instanceStack.Status.Machine == nil &&
+			meta.FindStatusCondition(instanceStack.Status.Conditions, infrastructurev1alpha1.ConditionMachineClassResolved) == nil {
+			return resolved, true, nil
+		}
+		instanceStack.Status.Machine = nil
+		meta.RemoveStatusCondition(&instanceStack.Status.Conditions, infrastructurev1alpha1.ConditionMachineClassResolved)
+		return resolved, true, r.Status().Update(ctx, instanceStack)
+	}
+
+	withClass, machine, err := machineclass.Resolve(ctx, r.Client, resolved)
+	condition := metav1.Condition{
+		Type:               infrastructurev1alpha1.ConditionMachineClassResolved,
+		Status:             metav1.ConditionTrue,
+		Reason:             infrastructurev1alpha1.ReasonMachineClassResolved,
+		Message:            fmt.Sprintf("MachineClass %q is mapped for the ProviderConfig", name),
+		ObservedGeneration: instanceStack.Generation,
+	}
+	changed := false
+	switch {
+	case apierrors.IsNotFound(err):
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = infrastructurev1alpha1.ReasonMachineClassNotFound
+		condition.Message = fmt.Sprintf("MachineClass %q does not exist", name)
+	case errors.Is(err, machineclass.ErrNoMapping):
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = infrastructurev1alpha1.ReasonNoMachineMapping
+		condition.Message = err.Error()
+	case err != nil:
+		return nil, false, err
+	default:
+		replaced := machineclass.ReplacedFields(instanceStack.Status.Machine, machine)
+		if len(replaced) > 0 && provisioned(instanceStack) &&
+			instanceStack.Annotations[infrastructurev1alpha1.AllowReplacementAnnotation] != "true" {
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = infrastructurev1alpha1.ReasonReplacementBlocked
+			condition.Message = fmt.Sprintf("MachineClass %q changed %s, which replaces the server; set the %s=true annotation to allow it",
+				name, strings.Join(replaced, ", "), infrastructurev1alpha1.AllowReplacementAnnotation)
+			break
+		}
+		if !equality.Semantic.DeepEqual(instanceStack.Status.Machine, machine) {
+			instanceStack.Status.Machine = machine
+			changed = true
+		}
+	}
+	if meta.SetStatusCondition(&instanceStack.Status.Conditions, condition) {
+		changed = true
+	}
+	if changed {
+		if err := r.Status().Update(ctx, instanceStack); err != nil {
+			return nil, false, err
+		}
+	}
+	return withClass, condition.Status == metav1.ConditionTrue, nil
+}
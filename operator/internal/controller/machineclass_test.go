@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+)
+
+var _ = Describe("MachineClass", func() {
+	ctx := context.Background()
+
+	medium := func(imageName string) *infrastructurev1alpha1.MachineClass {
+		return &infrastructurev1alpha1.MachineClass{
+			ObjectMeta: metav1.ObjectMeta{Name: "ubuntu-medium"},
+			Spec: infrastructurev1alpha1.MachineClassSpec{
+				Size:  "medium",
+				Image: "ubuntu-22.04",
+				Mappings: []infrastructurev1alpha1.MachineMapping{
+					{ProviderConfigName: "default", FlavorName: "m1.medium", ImageName: imageName, NetworkUUID: "3f7c1e2a-1b2c-4d5e-8f90-123456789abc"},
+					{ProviderConfigName: "aws", AWS: &infrastructurev1alpha1.AWSInstanceSpec{InstanceType: "t3.medium", AMI: "ami-1"}},
+				},
+			},
+		}
+	}
+
+	setup := func(objs ...client.Object) *InstanceStackReconciler {
+		scheme := runtime.NewScheme()
+		Expect(infrastructurev1alpha1.AddToScheme(scheme)).To(Succeed())
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).
+			WithStatusSubresource(&infrastructurev1alpha1.InstanceStack{}).Build()
+		return &InstanceStackReconciler{Client: c, Scheme: scheme}
+	}
+
+	newInstanceStack := func(providerConfigName string) *infrastructurev1alpha1.InstanceStack {
+		return &infrastructurev1alpha1.InstanceStack{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+			Spec: infrastructurev1alpha1.InstanceStackSpec{
+				ProviderConfigName: providerConfigName,
+				MachineClassName:   "ubuntu-medium",
+				FlavorName:         "m1.large",
+			},
+		}
+	}
+
+	condition := func(instanceStack *infrastructurev1alpha1.InstanceStack) *metav1.Condition {
+		return meta.FindStatusCondition(instanceStack.Status.Conditions, infrastructurev1alpha1.ConditionMachineClassResolved)
+	}
+
+	It("Should fill the fields the InstanceStack leaves empty and record them", func() {
+		instanceStack := newInstanceStack("")
+		r := setup(medium("ubuntu-22.04"), instanceStack)
+
+		resolved, ok, err := r.resolveMachineClass(ctx, instanceStack, instanceStack)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(resolved.Spec.FlavorName).To(Equal("m1.large"))
+		Expect(resolved.Spec.ImageName).To(Equal("ubuntu-22.04"))
+		Expect(instanceStack.Spec.ImageName).To(BeEmpty())
+		Expect(instanceStack.Status.Machine).To(Equal(&infrastructurev1alpha1.MachineStatus{
+			ClassName:   "ubuntu-medium",
+			FlavorName:  "m1.large",
+			ImageName:   "ubuntu-22.04",
+			NetworkUUID: "3f7c1e2a-1b2c-4d5e-8f90-123456789abc",
+		}))
+		Expect(condition(instanceStack).Status).To(Equal(metav1.ConditionTrue))
+		Expect(instanceStack.EffectiveFlavorName()).To(Equal("m1.large"))
+	})
+
+	It("Should map AWS ProviderConfigs to spec.aws", func() {
+		instanceStack := newInstanceStack("aws")
+		instanceStack.Spec.FlavorName = ""
+		r := setup(medium("ubuntu-22.04"), instanceStack)
+
+		resolved, ok, err := r.resolveMachineClass(ctx, instanceStack, instanceStack)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(resolved.Spec.AWS.InstanceType).To(Equal("t3.medium"))
+		Expect(resolved.Spec.FlavorName).To(BeEmpty())
+		Expect(instanceStack.Status.Machine.AWS.AMI).To(Equal("ami-1"))
+	})
+
+	It("Should report missing classes and mappings", func() {
+		instanceStack := newInstanceStack("")
+		r := setup(instanceStack)
+		_, ok, err := r.resolveMachineClass(ctx, instanceStack, instanceStack)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeFalse())
+		Expect(condition(instanceStack).Reason).To(Equal(infrastructurev1alpha1.ReasonMachineClassNotFound))
+
+		instanceStack = newInstanceStack("gcp")
+		r = setup(medium("ubuntu-22.04"), instanceStack)
+		_, ok, err = r.resolveMachineClass(ctx, instanceStack, instanceStack)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeFalse())
+		Expect(condition(instanceStack).Reason).To(Equal(infrastructurev1alpha1.ReasonNoMachineMapping))
+		Expect(condition(instanceStack).Message).To(ContainSubstring(`does not map ProviderConfig "gcp"`))
+	})
+
+	It("Should not replace a provisioned server when its class changes the image", func() {
+		instanceStack := newInstanceStack("")
+		instanceStack.Status.Outputs = map[string]apiextensionsv1.JSON{"instanceIP": {Raw: []byte(`"10.0.0.5"`)}}
+		instanceStack.Status.Machine = &infrastructurev1alpha1.MachineStatus{
+			ClassName:   "ubuntu-medium",
+			FlavorName:  "m1.large",
+			ImageName:   "ubuntu-22.04",
+			NetworkUUID: "3f7c1e2a-1b2c-4d5e-8f90-123456789abc",
+		}
+		r := setup(medium("ubuntu-24.04"), instanceStack)
+
+		_, ok, err := r.resolveMachineClass(ctx, instanceStack, instanceStack)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeFalse())
+		Expect(condition(instanceStack).Reason).To(Equal(infrastructurev1alpha1.ReasonReplacementBlocked))
+		Expect(condition(instanceStack).Message).To(ContainSubstring("changed imageName"))
+		Expect(instanceStack.Status.Machine.ImageName).To(Equal("ubuntu-22.04"))
+
+		instanceStack.Annotations = map[string]string{infrastructurev1alpha1.AllowReplacementAnnotation: "true"}
+		resolved, ok, err := r.resolveMachineClass(ctx, instanceStack, instanceStack)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(resolved.Spec.ImageName).To(Equal("ubuntu-24.04"))
+		Expect(instanceStack.Status.Machine.ImageName).To(Equal("ubuntu-24.04"))
+	})
+
+	It("Should index InstanceStacks by MachineClass", func() {
+		Expect(machineClassKeys(newInstanceStack(""))).To(Equal([]string{"ubuntu-medium"}))
+		Expect(machineClassKeys(&infrastructurev1alpha1.InstanceStack{})).To(BeEmpty())
+	})
+})
@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+	"github.com/gunniLee/cloud-provider-operator/internal/engine"
+)
+
+// errOutputTargetConflict means the Secret or ConfigMap the outputs are
+// written to already exists and is not controlled by the InstanceStack.
+var errOutputTargetConflict = errors.New("output target is not controlled by this InstanceStack")
+
+// publishOutputs writes the stack outputs to the named Secret and ConfigMap
+// in the owner's namespace. The Secret receives every output, the ConfigMap
+// only the outputs that are not secret. Empty names are skipped. Both objects
+// are owned by owner and their data is replaced on every call. Existing
+// objects that owner does not control are left alone and reported with
+// errOutputTargetConflict.
+func (r *InstanceStackReconciler) publishOutputs(ctx context.Context, owner client.Object, secretName, configMapName string, outputs auto.OutputMap) error {
+	plain := map[string]string{}
+	all := map[string][]byte{}
+	for key, output := range outputs {
+		value, err := outputString(output.Value)
+		if err != nil {
+			return fmt.Errorf("failed to encode output %q: %w", key, err)
+		}
+		all[key] = []byte(value)
+		if !output.Secret {
+			plain[key] = value
+		}
+	}
+
+	if secretName != "" {
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: owner.GetNamespace()}}
+		if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, secret, func() error {
+			if err := checkOutputTarget(owner, secret); err != nil {
+				return err
+			}
+			secret.Data = all
+			return controllerutil.SetControllerReference(owner, secret, r.Scheme)
+		}); err != nil {
+			return fmt.Errorf("failed to write outputs to Secret %q: %w", secretName, err)
+		}
+	}
+	if configMapName != "" {
+		configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: configMapName, Namespace: owner.GetNamespace()}}
+		if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, configMap, func() error {
+			if err := checkOutputTarget(owner, configMap); err != nil {
+				return err
+			}
+			configMap.Data = plain
+			return controllerutil.SetControllerReference(owner, configMap, r.Scheme)
+		}); err != nil {
+			return fmt.Errorf("failed to write outputs to ConfigMap %q: %w", configMapName, err)
+		}
+	}
+	return nil
+}
+
+// checkOutputTarget refuses an existing object that owner does not control,
+// so that outputs never overwrite a Secret or ConfigMap created by someone
+// else.
+func checkOutputTarget(owner, obj client.Object) error {
+	if obj.GetResourceVersion() == "" || metav1.IsControlledBy(obj, owner) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s already exists", errOutputTargetConflict, obj.GetName())
+}
+
+// setOutputTargetCondition marks an InstanceStack whose outputs could not be
+// written because the target object belongs to someone else.
+func (r *InstanceStackReconciler) setOutputTargetCondition(ctx context.Context, instanceStack *infrastructurev1alpha1.InstanceStack, err error) error {
+	condition := metav1.Condition{
+		Type:               infrastructurev1alpha1.ConditionReady,
+		Status:             metav1.ConditionFalse,
+		Reason:             infrastructurev1alpha1.ReasonOutputTargetConflict,
+		Message:            err.Error(),
+		ObservedGeneration: instanceStack.Generation,
+	}
+	if !meta.SetStatusCondition(&instanceStack.Status.Conditions, condition) {
+		return nil
+	}
+	return r.Status().Update(ctx, instanceStack)
+}
+
+// outputString renders an output value as a Secret or ConfigMap value:
+// strings as they are, anything else as JSON.
+func outputString(value any) (string, error) {
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	data, err := json.Marshal(value)
+	return string(data), err
+}
+
+// ensureGeneratedSecrets stores a random secret under every key that is not
+// set in the stack config yet, so that it is generated once per stack.
+func ensureGeneratedSecrets(ctx context.Context, stack engine.Stack, keys []string) error {
+	for _, key := range keys {
+		if _, err := stack.GetConfig(ctx, key); err == nil {
+			continue
+		}
+		buf := make([]byte, 18)
+		if _, err := rand.Read(buf); err != nil {
+			return err
+		}
+		value := base64.RawURLEncoding.EncodeToString(buf)
+		if err := stack.SetConfig(ctx, key, auto.ConfigValue{Value: value, Secret: true}); err != nil {
+			return fmt.Errorf("failed to store generated %q: %w", key, err)
+		}
+	}
+	return nil
+}
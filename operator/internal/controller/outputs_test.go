@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+)
+
+var _ = Describe("InstanceStack outputs", func() {
+	ctx := context.Background()
+	outputs := auto.OutputMap{
+		"instanceIP": {Value: "10.0.0.5"},
+		"ports":      {Value: []any{22, 443}},
+		"password":   {Value: "hunter2", Secret: true},
+	}
+
+	var (
+		c     client.Client
+		r     *InstanceStackReconciler
+		owner *infrastructurev1alpha1.InstanceStack
+	)
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(infrastructurev1alpha1.AddToScheme(scheme)).To(Succeed())
+		owner = &infrastructurev1alpha1.InstanceStack{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", UID: "uid-web"},
+		}
+		c = fake.NewClientBuilder().WithScheme(scheme).WithObjects(owner).Build()
+		r = &InstanceStackReconciler{Client: c, Scheme: scheme}
+	})
+
+	It("Should keep secret outputs out of the ConfigMap", func() {
+		Expect(r.publishOutputs(ctx, owner, "web-outputs", "web-outputs", outputs)).To(Succeed())
+
+		secret := &corev1.Secret{}
+		Expect(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "web-outputs"}, secret)).To(Succeed())
+		Expect(secret.Data).To(Equal(map[string][]byte{
+			"instanceIP": []byte("10.0.0.5"),
+			"ports":      []byte("[22,443]"),
+			"password":   []byte("hunter2"),
+		}))
+		Expect(metav1.IsControlledBy(secret, owner)).To(BeTrue())
+
+		configMap := &corev1.ConfigMap{}
+		Expect(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "web-outputs"}, configMap)).To(Succeed())
+		Expect(configMap.Data).To(Equal(map[string]string{"instanceIP": "10.0.0.5", "ports": "[22,443]"}))
+		Expect(metav1.IsControlledBy(configMap, owner)).To(BeTrue())
+
+		By("replacing the data on the next update")
+		Expect(r.publishOutputs(ctx, owner, "web-outputs", "", auto.OutputMap{"instanceIP": {Value: "10.0.0.6"}})).To(Succeed())
+		Expect(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "web-outputs"}, secret)).To(Succeed())
+		Expect(secret.Data).To(Equal(map[string][]byte{"instanceIP": []byte("10.0.0.6")}))
+	})
+
+	It("Should refuse to overwrite objects it does not control", func() {
+		foreign := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "db-credentials"},
+			Data:       map[string][]byte{"password": []byte("keep-me")},
+		}
+		Expect(c.Create(ctx, foreign)).To(Succeed())
+		err := r.publishOutputs(ctx, owner, "db-credentials", "", outputs)
+		Expect(err).To(MatchError(errOutputTargetConflict))
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(foreign), foreign)).To(Succeed())
+		Expect(foreign.Data).To(Equal(map[string][]byte{"password": []byte("keep-me")}))
+		Expect(foreign.OwnerReferences).To(BeEmpty())
+
+		By("refusing a ConfigMap controlled by another InstanceStack")
+		other := &infrastructurev1alpha1.InstanceStack{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "other", UID: "uid-other"},
+		}
+		Expect(r.publishOutputs(ctx, other, "", "shared", outputs)).To(Succeed())
+		Expect(r.publishOutputs(ctx, owner, "", "shared", outputs)).To(MatchError(errOutputTargetConflict))
+	})
+})
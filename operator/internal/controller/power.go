@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+	"github.com/gunniLee/cloud-provider-operator/internal/openstack"
+)
+
+// rebootRetryInterval is how often a reboot requested while the server is
+// not running is retried while the server is expected to start.
+const rebootRetryInterval = time.Minute
+
+// observedPowerState maps a Nova server status to status.powerState.
+func observedPowerState(novaStatus string) string {
+	switch novaStatus {
+	case "ACTIVE":
+		return string(infrastructurev1alpha1.PowerStateRunning)
+	case "SHUTOFF":
+		return string(infrastructurev1alpha1.PowerStateStopped)
+	case "SUSPENDED":
+		return string(infrastructurev1alpha1.PowerStateSuspended)
+	case "SHELVED", "SHELVED_OFFLOADED":
+		return string(infrastructurev1alpha1.PowerStateShelved)
+	case "REBOOT", "HARD_REBOOT":
+		return "Rebooting"
+	case "PAUSED":
+		return "Paused"
+	}
+	return novaStatus
+}
+
+// reconcilePowerState suspends or reboots the server as requested, removes
+// the reboot annotation once handled and records the observed power state.
+// An invalid reboot annotation is removed with a Warning event; a valid one
+// is kept until the server runs. It reports whether a reboot is still
+// pending on a server that should be running.
+func (r *InstanceStackReconciler) reconcilePowerState(ctx context.Context, instanceStack *infrastructurev1alpha1.InstanceStack, creds openstack.Credentials) (bool, error) {
+	if statusOutputString(instanceStack, "serverID") == "" {
+		return false, nil
+	}
+	if value, ok := instanceStack.Annotations[infrastructurev1alpha1.RebootAnnotation]; ok {
+		if _, err := parseRebootType(value); err != nil {
+			r.Recorder.Event(instanceStack, corev1.EventTypeWarning, "InvalidReboot", err.Error())
+			delete(instanceStack.Annotations, infrastructurev1alpha1.RebootAnnotation)
+			if err := r.Update(ctx, instanceStack); err != nil {
+				return false, err
+			}
+		}
+	}
+	novaClient, err := openstack.NewClient(ctx, creds)
+	if err != nil {
+		return false, err
+	}
+	powerState, rebooted, err := reconcilePower(ctx, novaClient, instanceStack)
+	if err != nil {
+		return false, err
+	}
+	if rebooted {
+		delete(instanceStack.Annotations, infrastructurev1alpha1.RebootAnnotation)
+		if err := r.Update(ctx, instanceStack); err != nil {
+			return false, err
+		}
+	}
+	_, pending := instanceStack.Annotations[infrastructurev1alpha1.RebootAnnotation]
+	pending = pending && (instanceStack.Spec.PowerState == "" || instanceStack.Spec.PowerState == infrastructurev1alpha1.PowerStateRunning)
+	if instanceStack.Status.PowerState == powerState {
+		return pending, nil
+	}
+	instanceStack.Status.PowerState = powerState
+	return pending, r.Status().Update(ctx, instanceStack)
+}
+
+// resumeBeforeUpdate resumes a suspended server that should no longer be
+// suspended. Pulumi cannot see the difference between active and suspended,
+// so this has to happen before the update.
+func resumeBeforeUpdate(ctx context.Context, creds openstack.Credentials, instanceStack *infrastructurev1alpha1.InstanceStack) error {
+	serverID := statusOutputString(instanceStack, "serverID")
+	if serverID == "" || instanceStack.Status.PowerState != string(infrastructurev1alpha1.PowerStateSuspended) ||
+		instanceStack.Spec.PowerState == infrastructurev1alpha1.PowerStateSuspended {
+		return nil
+	}
+	novaClient, err := openstack.NewClient(ctx, creds)
+	if err != nil {
+		return err
+	}
+	server, err := novaClient.GetServer(ctx, serverID)
+	if err != nil || server.Status != "SUSPENDED" {
+		return err
+	}
+	log.FromContext(ctx).Info("resuming suspended server", "serverID", serverID)
+	return novaClient.Resume(ctx, serverID)
+}
+
+// reconcilePower applies the power changes Pulumi cannot express: suspending
+// and one-shot reboots. It returns the observed power state, or "" when the
+// stack has no server, and whether the requested reboot was issued.
+func reconcilePower(ctx context.Context, novaClient *openstack.Client, instanceStack *infrastructurev1alpha1.InstanceStack) (string, bool, error) {
+	log := log.FromContext(ctx)
+	serverID := statusOutputString(instanceStack, "serverID")
+	if serverID == "" {
+		return "", false, nil
+	}
+	server, err := novaClient.GetServer(ctx, serverID)
+	if err != nil {
+		return "", false, err
+	}
+
+	if instanceStack.Spec.PowerState == infrastructurev1alpha1.PowerStateSuspended && server.Status == "ACTIVE" {
+		log.Info("suspending server", "serverID", serverID)
+		if err := novaClient.Suspend(ctx, serverID); err != nil {
+			return "", false, err
+		}
+		server.Status = "SUSPENDED"
+	}
+
+	rebooted := false
+	if value, ok := instanceStack.Annotations[infrastructurev1alpha1.RebootAnnotation]; ok {
+		hard, err := parseRebootType(value)
+		switch {
+		case err != nil:
+			log.Info("ignoring reboot request", "reason", err.Error())
+		case server.Status != "ACTIVE":
+			log.Info("postponing reboot request until the server runs", "serverID", serverID, "status", server.Status)
+		default:
+			log.Info("rebooting server", "serverID", serverID, "hard", hard)
+			if err := novaClient.Reboot(ctx, serverID, hard); err != nil {
+				return "", false, err
+			}
+			rebooted = true
+			server.Status = "REBOOT"
+			if hard {
+				server.Status = "HARD_REBOOT"
+			}
+		}
+	}
+	return observedPowerState(server.Status), rebooted, nil
+}
+
+// parseRebootType parses the reboot annotation, reporting whether a hard
+// reboot was requested.
+func parseRebootType(value string) (bool, error) {
+	switch value {
+	case "soft":
+		return false, nil
+	case "hard":
+		return true, nil
+	}
+	return false, fmt.Errorf("%s must be \"soft\" or \"hard\", not %q", infrastructurev1alpha1.RebootAnnotation, value)
+}
@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+	"github.com/gunniLee/cloud-provider-operator/internal/openstack"
+	"github.com/gunniLee/cloud-provider-operator/internal/openstack/openstacktest"
+)
+
+var _ = Describe("Power state", func() {
+	var (
+		cloud      *openstacktest.Server
+		novaClient *openstack.Client
+	)
+
+	BeforeEach(func() {
+		cloud = openstacktest.NewServer()
+		cloud.Instances = map[string]*openstacktest.Instance{"srv-1": {Status: "ACTIVE"}}
+		DeferCleanup(cloud.Close)
+		var err error
+		novaClient, err = openstack.NewClient(context.Background(), cloud.Credentials())
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	newInstanceStack := func(powerState infrastructurev1alpha1.PowerState, annotations map[string]string) *infrastructurev1alpha1.InstanceStack {
+		return &infrastructurev1alpha1.InstanceStack{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", Annotations: annotations},
+			Spec:       infrastructurev1alpha1.InstanceStackSpec{PowerState: powerState},
+			Status: infrastructurev1alpha1.InstanceStackStatus{
+				Outputs: map[string]apiextensionsv1.JSON{"serverID": {Raw: []byte(`"srv-1"`)}},
+			},
+		}
+	}
+
+	It("Should suspend a running server through Nova", func() {
+		observed, _, err := reconcilePower(context.Background(), novaClient, newInstanceStack(infrastructurev1alpha1.PowerStateSuspended, nil))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(observed).To(Equal("Suspended"))
+		Expect(cloud.Instances["srv-1"].Actions).To(Equal([]string{"suspend"}))
+
+		By("resuming it before the next update once it should run again")
+		instanceStack := newInstanceStack(infrastructurev1alpha1.PowerStateRunning, nil)
+		instanceStack.Status.PowerState = observed
+		Expect(resumeBeforeUpdate(context.Background(), cloud.Credentials(), instanceStack)).To(Succeed())
+		Expect(cloud.Instances["srv-1"].Status).To(Equal("ACTIVE"))
+	})
+
+	It("Should issue the reboot requested by the annotation", func() {
+		instanceStack := newInstanceStack("", map[string]string{infrastructurev1alpha1.RebootAnnotation: "hard"})
+		observed, rebooted, err := reconcilePower(context.Background(), novaClient, instanceStack)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rebooted).To(BeTrue())
+		Expect(observed).To(Equal("Rebooting"))
+		Expect(cloud.Instances["srv-1"].Actions).To(Equal([]string{"reboot:HARD"}))
+	})
+
+	It("Should not reboot a server that is not running", func() {
+		cloud.Instances["srv-1"].Status = "SHUTOFF"
+		instanceStack := newInstanceStack(infrastructurev1alpha1.PowerStateStopped, map[string]string{infrastructurev1alpha1.RebootAnnotation: "soft"})
+		observed, rebooted, err := reconcilePower(context.Background(), novaClient, instanceStack)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rebooted).To(BeFalse())
+		Expect(observed).To(Equal("Stopped"))
+		Expect(cloud.Instances["srv-1"].Actions).To(BeEmpty())
+	})
+
+	Context("with the reboot annotation", func() {
+		ctx := context.Background()
+		var (
+			c        client.Client
+			recorder *record.FakeRecorder
+			r        *InstanceStackReconciler
+		)
+
+		reconcileAnnotated := func(powerState infrastructurev1alpha1.PowerState, value string) (*infrastructurev1alpha1.InstanceStack, bool) {
+			scheme := runtime.NewScheme()
+			Expect(infrastructurev1alpha1.AddToScheme(scheme)).To(Succeed())
+			instanceStack := newInstanceStack(powerState, map[string]string{infrastructurev1alpha1.RebootAnnotation: value})
+			c = fake.NewClientBuilder().WithScheme(scheme).WithObjects(instanceStack).
+				WithStatusSubresource(instanceStack).Build()
+			recorder = record.NewFakeRecorder(10)
+			r = &InstanceStackReconciler{Client: c, Scheme: scheme, Recorder: recorder}
+			pending, err := r.reconcilePowerState(ctx, instanceStack, cloud.Credentials())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.Get(ctx, client.ObjectKeyFromObject(instanceStack), instanceStack)).To(Succeed())
+			return instanceStack, pending
+		}
+
+		It("Should drop an invalid value with a Warning event", func() {
+			instanceStack, pending := reconcileAnnotated("", "sideways")
+			Expect(pending).To(BeFalse())
+			Expect(instanceStack.Annotations).NotTo(HaveKey(infrastructurev1alpha1.RebootAnnotation))
+			Expect(recorder.Events).To(Receive(ContainSubstring("Warning InvalidReboot")))
+			Expect(cloud.Instances["srv-1"].Actions).To(BeEmpty())
+		})
+
+		It("Should keep the request until the server runs", func() {
+			cloud.Instances["srv-1"].Status = "BUILD"
+			instanceStack, pending := reconcileAnnotated(infrastructurev1alpha1.PowerStateRunning, "soft")
+			Expect(pending).To(BeTrue())
+			Expect(instanceStack.Annotations).To(HaveKeyWithValue(infrastructurev1alpha1.RebootAnnotation, "soft"))
+			Expect(cloud.Instances["srv-1"].Actions).To(BeEmpty())
+
+			By("rebooting once the server is active")
+			cloud.Instances["srv-1"].Status = "ACTIVE"
+			pending, err := r.reconcilePowerState(ctx, instanceStack, cloud.Credentials())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pending).To(BeFalse())
+			Expect(c.Get(ctx, client.ObjectKeyFromObject(instanceStack), instanceStack)).To(Succeed())
+			Expect(instanceStack.Annotations).NotTo(HaveKey(infrastructurev1alpha1.RebootAnnotation))
+			Expect(cloud.Instances["srv-1"].Actions).To(Equal([]string{"reboot:SOFT"}))
+		})
+
+		It("Should not poll for a server that should stay stopped", func() {
+			cloud.Instances["srv-1"].Status = "SHUTOFF"
+			instanceStack, pending := reconcileAnnotated(infrastructurev1alpha1.PowerStateStopped, "soft")
+			Expect(pending).To(BeFalse())
+			Expect(instanceStack.Annotations).To(HaveKey(infrastructurev1alpha1.RebootAnnotation))
+		})
+	})
+})
@@ -0,0 +1,218 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+)
+
+// maxMissedTransitions bounds how many missed transitions are walked through
+// to find the latest one, e.g. after the operator was down for a while.
+const maxMissedTransitions = 10000
+
+// PowerScheduleReconciler reconciles a PowerSchedule object
+type PowerScheduleReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// +kubebuilder:rbac:groups=infrastructure.cloudprovider.io,resources=powerschedules,verbs=get;list;watch
+// +kubebuilder:rbac:groups=infrastructure.cloudprovider.io,resources=powerschedules/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+func (r *PowerScheduleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	powerSchedule := &infrastructurev1alpha1.PowerSchedule{}
+	if err := r.Get(ctx, req.NamespacedName, powerSchedule); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	now := time.Now()
+	if r.Now != nil {
+		now = r.Now()
+	}
+	original := powerSchedule.Status.DeepCopy()
+	status := &powerSchedule.Status
+
+	condition := metav1.Condition{
+		Type:               infrastructurev1alpha1.ConditionReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             infrastructurev1alpha1.ReasonScheduled,
+		ObservedGeneration: powerSchedule.Generation,
+	}
+	schedule, err := parsePowerSchedule(powerSchedule.Spec)
+	switch {
+	case err != nil:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = infrastructurev1alpha1.ReasonInvalidSchedule
+		condition.Message = err.Error()
+		status.NextStartTime, status.NextStopTime = nil, nil
+	case powerSchedule.Spec.Suspend:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = infrastructurev1alpha1.ReasonSuspended
+		condition.Message = "schedule is suspended"
+		status.NextStartTime, status.NextStopTime = nil, nil
+	default:
+		// 마지막으로 적용한 시점 이후에 지난 전환 중 가장 최근 것만 적용
+		since := powerSchedule.CreationTimestamp.Time
+		if status.LastScheduleTime != nil {
+			since = status.LastScheduleTime.Time
+		}
+		if action, at := schedule.due(since, now); action != "" {
+			names, failed, err := r.apply(ctx, powerSchedule, action)
+			if err != nil {
+				log.Error(err, "failed to apply scheduled power state", "action", action)
+				return ctrl.Result{}, err
+			}
+			log.Info("applied scheduled power state", "action", action, "scheduledAt", at, "instanceStacks", names, "failed", failed)
+			status.LastAction = action
+			status.LastScheduleTime = &metav1.Time{Time: at}
+			status.InstanceStacks = names
+			status.FailedInstanceStacks = failed
+		}
+		nextStart := metav1.NewTime(schedule.start.Next(now.In(schedule.location)))
+		nextStop := metav1.NewTime(schedule.stop.Next(now.In(schedule.location)))
+		status.NextStartTime, status.NextStopTime = &nextStart, &nextStop
+		condition.Message = fmt.Sprintf("next start at %s, next stop at %s",
+			nextStart.In(schedule.location).Format(time.RFC3339), nextStop.In(schedule.location).Format(time.RFC3339))
+		if len(status.FailedInstanceStacks) > 0 {
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = infrastructurev1alpha1.ReasonTransitionFailed
+			condition.Message = fmt.Sprintf("%s failed for %s; %s",
+				status.LastAction, strings.Join(status.FailedInstanceStacks, ", "), condition.Message)
+		}
+	}
+	meta.SetStatusCondition(&status.Conditions, condition)
+
+	if !equality.Semantic.DeepEqual(original, status) {
+		if err := r.Status().Update(ctx, powerSchedule); err != nil {
+			log.Error(err, "failed to update PowerSchedule status")
+			return ctrl.Result{}, err
+		}
+	}
+	if status.NextStartTime == nil {
+		return ctrl.Result{}, nil
+	}
+	next := status.NextStartTime.Time
+	if status.NextStopTime.Before(status.NextStartTime) {
+		next = status.NextStopTime.Time
+	}
+	return ctrl.Result{RequeueAfter: next.Sub(now) + time.Second}, nil
+}
+
+// powerSchedule is a parsed PowerScheduleSpec.
+type powerSchedule struct {
+	start    cron.Schedule
+	stop     cron.Schedule
+	location *time.Location
+}
+
+func parsePowerSchedule(spec infrastructurev1alpha1.PowerScheduleSpec) (*powerSchedule, error) {
+	location := time.UTC
+	if spec.TimeZone != "" {
+		var err error
+		if location, err = time.LoadLocation(spec.TimeZone); err != nil {
+			return nil, fmt.Errorf("invalid timeZone %q: %w", spec.TimeZone, err)
+		}
+	}
+	start, err := cron.ParseStandard(spec.Start)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start schedule %q: %w", spec.Start, err)
+	}
+	stop, err := cron.ParseStandard(spec.Stop)
+	if err != nil {
+		return nil, fmt.Errorf("invalid stop schedule %q: %w", spec.Stop, err)
+	}
+	return &powerSchedule{start: start, stop: stop, location: location}, nil
+}
+
+// due returns the latest transition scheduled after since and not after
+// now, or "" if there is none. A start and stop at the same minute resolve
+// to stop.
+func (s *powerSchedule) due(since, now time.Time) (infrastructurev1alpha1.PowerScheduleAction, time.Time) {
+	var action infrastructurev1alpha1.PowerScheduleAction
+	var at time.Time
+	t := since.In(s.location)
+	for i := 0; i < maxMissedTransitions; i++ {
+		next, nextAction := s.stop.Next(t), infrastructurev1alpha1.PowerScheduleActionStop
+		if start := s.start.Next(t); start.Before(next) {
+			next, nextAction = start, infrastructurev1alpha1.PowerScheduleActionStart
+		}
+		if next.IsZero() || next.After(now) {
+			break
+		}
+		action, at, t = nextAction, next, next
+	}
+	return action, at
+}
+
+// apply sets the power state of the selected InstanceStacks and returns the
+// names of the ones it was applied to and of the ones it failed for.
+// InstanceStacks with the skip annotation are left alone. A failed update is
+// reported as an event and does not stop the others from being updated.
+func (r *PowerScheduleReconciler) apply(ctx context.Context, powerSchedule *infrastructurev1alpha1.PowerSchedule, action infrastructurev1alpha1.PowerScheduleAction) ([]string, []string, error) {
+	selector, err := metav1.LabelSelectorAsSelector(&powerSchedule.Spec.Selector)
+	if err != nil {
+		return nil, nil, err
+	}
+	instanceStacks := &infrastructurev1alpha1.InstanceStackList{}
+	if err := r.List(ctx, instanceStacks, client.InNamespace(powerSchedule.Namespace),
+		client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, nil, err
+	}
+
+	powerState := infrastructurev1alpha1.PowerStateRunning
+	if action == infrastructurev1alpha1.PowerScheduleActionStop {
+		powerState = powerSchedule.Spec.OffState
+		if powerState == "" {
+			powerState = infrastructurev1alpha1.PowerStateStopped
+		}
+	}
+
+	var names, failed []string
+	for i := range instanceStacks.Items {
+		instanceStack := &instanceStacks.Items[i]
+		if instanceStack.Annotations[infrastructurev1alpha1.SkipPowerScheduleAnnotation] == "true" ||
+			!instanceStack.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if instanceStack.Spec.PowerState != powerState {
+			instanceStack.Spec.PowerState = powerState
+			if err := r.Update(ctx, instanceStack); err != nil {
+				log.FromContext(ctx).Error(err, "failed to set power state", "instanceStack", instanceStack.Name)
+				r.Recorder.Eventf(powerSchedule, corev1.EventTypeWarning, "TransitionFailed",
+					"failed to set the power state of %s to %s: %v", instanceStack.Name, powerState, err)
+				failed = append(failed, instanceStack.Name)
+				continue
+			}
+		}
+		names = append(names, instanceStack.Name)
+	}
+	return names, failed, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PowerScheduleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&infrastructurev1alpha1.PowerSchedule{}).
+		Named("powerschedule").
+		Complete(r)
+}
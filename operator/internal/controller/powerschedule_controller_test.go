@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+)
+
+var _ = Describe("PowerSchedule controller", func() {
+	seoul, _ := time.LoadLocation("Asia/Seoul")
+	officeHours := infrastructurev1alpha1.PowerScheduleSpec{
+		Selector: metav1.LabelSelector{MatchLabels: map[string]string{"environment": "dev"}},
+		Start:    "0 8 * * 1-5",
+		Stop:     "0 20 * * 1-5",
+		TimeZone: "Asia/Seoul",
+		OffState: infrastructurev1alpha1.PowerStateShelved,
+	}
+
+	It("Should find the latest transition in the schedule's time zone", func() {
+		schedule, err := parsePowerSchedule(officeHours)
+		Expect(err).NotTo(HaveOccurred())
+
+		// 2026-10-16 is a Friday.
+		friday := time.Date(2026, 10, 16, 7, 0, 0, 0, seoul)
+		action, _ := schedule.due(friday, friday.Add(30*time.Minute))
+		Expect(action).To(BeEmpty())
+
+		action, at := schedule.due(friday, time.Date(2026, 10, 18, 12, 0, 0, 0, seoul))
+		Expect(action).To(Equal(infrastructurev1alpha1.PowerScheduleActionStop))
+		Expect(at).To(BeTemporally("==", time.Date(2026, 10, 16, 20, 0, 0, 0, seoul)))
+	})
+
+	It("Should reject invalid cron expressions and time zones", func() {
+		spec := officeHours
+		spec.Start = "every morning"
+		_, err := parsePowerSchedule(spec)
+		Expect(err).To(MatchError(ContainSubstring("invalid start schedule")))
+
+		spec = officeHours
+		spec.TimeZone = "Mars/Olympus"
+		_, err = parsePowerSchedule(spec)
+		Expect(err).To(MatchError(ContainSubstring("invalid timeZone")))
+	})
+
+	It("Should power off the selected InstanceStacks unless they opt out", func() {
+		ctx := context.Background()
+		scheme := runtime.NewScheme()
+		Expect(infrastructurev1alpha1.AddToScheme(scheme)).To(Succeed())
+
+		created := metav1.NewTime(time.Date(2026, 10, 16, 19, 0, 0, 0, seoul))
+		powerSchedule := &infrastructurev1alpha1.PowerSchedule{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "office-hours", CreationTimestamp: created},
+			Spec:       officeHours,
+		}
+		dev := func(name string, annotations map[string]string) *infrastructurev1alpha1.InstanceStack {
+			return &infrastructurev1alpha1.InstanceStack{ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default", Name: name, Labels: map[string]string{"environment": "dev"}, Annotations: annotations,
+			}}
+		}
+		prod := &infrastructurev1alpha1.InstanceStack{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "prod"}}
+		k8sClient := fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(powerSchedule, prod, dev("dev-1", nil),
+				dev("dev-2", map[string]string{infrastructurev1alpha1.SkipPowerScheduleAnnotation: "true"})).
+			WithStatusSubresource(powerSchedule).
+			Build()
+
+		r := &PowerScheduleReconciler{Client: k8sClient, Recorder: record.NewFakeRecorder(10), Now: func() time.Time {
+			return time.Date(2026, 10, 16, 20, 30, 0, 0, seoul)
+		}}
+		result, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(powerSchedule)})
+		Expect(err).NotTo(HaveOccurred())
+		// Next transition is Monday 08:00.
+		Expect(result.RequeueAfter).To(Equal(59*time.Hour + 30*time.Minute + time.Second))
+
+		powerState := func(name string) infrastructurev1alpha1.PowerState {
+			instanceStack := &infrastructurev1alpha1.InstanceStack{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, instanceStack)).To(Succeed())
+			return instanceStack.Spec.PowerState
+		}
+		Expect(powerState("dev-1")).To(Equal(infrastructurev1alpha1.PowerStateShelved))
+		Expect(powerState("dev-2")).To(BeEmpty())
+		Expect(powerState("prod")).To(BeEmpty())
+
+		updated := &infrastructurev1alpha1.PowerSchedule{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(powerSchedule), updated)).To(Succeed())
+		Expect(updated.Status.LastAction).To(Equal(infrastructurev1alpha1.PowerScheduleActionStop))
+		Expect(updated.Status.InstanceStacks).To(Equal([]string{"dev-1"}))
+		Expect(updated.Status.NextStartTime.Time).To(BeTemporally("==", time.Date(2026, 10, 19, 8, 0, 0, 0, seoul)))
+		Expect(meta.IsStatusConditionTrue(updated.Status.Conditions, infrastructurev1alpha1.ConditionReady)).To(BeTrue())
+	})
+
+	It("Should apply the transition to the other InstanceStacks when one update fails", func() {
+		ctx := context.Background()
+		scheme := runtime.NewScheme()
+		Expect(infrastructurev1alpha1.AddToScheme(scheme)).To(Succeed())
+
+		created := metav1.NewTime(time.Date(2026, 10, 16, 19, 0, 0, 0, seoul))
+		powerSchedule := &infrastructurev1alpha1.PowerSchedule{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "office-hours", CreationTimestamp: created},
+			Spec:       officeHours,
+		}
+		dev := func(name string) *infrastructurev1alpha1.InstanceStack {
+			return &infrastructurev1alpha1.InstanceStack{ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default", Name: name, Labels: map[string]string{"environment": "dev"},
+			}}
+		}
+		k8sClient := fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(powerSchedule, dev("dev-1"), dev("dev-2")).
+			WithStatusSubresource(powerSchedule).
+			WithInterceptorFuncs(interceptor.Funcs{
+				Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+					if obj.GetName() == "dev-1" {
+						return errors.New("conflict")
+					}
+					return c.Update(ctx, obj, opts...)
+				},
+			}).
+			Build()
+
+		recorder := record.NewFakeRecorder(10)
+		r := &PowerScheduleReconciler{Client: k8sClient, Recorder: recorder, Now: func() time.Time {
+			return time.Date(2026, 10, 16, 20, 30, 0, 0, seoul)
+		}}
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(powerSchedule)})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(recorder.Events).To(Receive(ContainSubstring("failed to set the power state of dev-1")))
+
+		instanceStack := &infrastructurev1alpha1.InstanceStack{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: "dev-2"}, instanceStack)).To(Succeed())
+		Expect(instanceStack.Spec.PowerState).To(Equal(infrastructurev1alpha1.PowerStateShelved))
+
+		updated := &infrastructurev1alpha1.PowerSchedule{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(powerSchedule), updated)).To(Succeed())
+		Expect(updated.Status.LastScheduleTime.Time).To(BeTemporally("==", time.Date(2026, 10, 16, 20, 0, 0, 0, seoul)))
+		Expect(updated.Status.InstanceStacks).To(Equal([]string{"dev-2"}))
+		Expect(updated.Status.FailedInstanceStacks).To(Equal([]string{"dev-1"}))
+		condition := meta.FindStatusCondition(updated.Status.Conditions, infrastructurev1alpha1.ConditionReady)
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal(infrastructurev1alpha1.ReasonTransitionFailed))
+	})
+})
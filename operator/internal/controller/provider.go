@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+	"github.com/gunniLee/cloud-provider-operator/internal/openstack"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+)
+
+// Provider resolves where the program of an InstanceStack runs for one kind
+// of ProviderConfig. The resources themselves come from the program, which
+// is written for a single ProviderType.
+type Provider interface {
+	// Target resolves the account and region of the InstanceStack.
+	Target(ctx context.Context, c client.Reader, instanceStack *infrastructurev1alpha1.InstanceStack) (*Target, error)
+}
+
+// Target is the cloud account and region an InstanceStack is provisioned in.
+type Target struct {
+	Region string
+
+	// Config is the Pulumi provider config of the stack. Stale lists keys of
+	// settings Config leaves out, which must be removed from the stack.
+	Config auto.ConfigMap
+	Stale  []string
+
+	// OpenStack is set for OpenStack targets. Quota checks and the power
+	// actions Pulumi cannot do use the OpenStack APIs directly.
+	OpenStack *openstack.Credentials
+}
+
+// providers are the Providers of each ProviderType.
+var providers = map[infrastructurev1alpha1.ProviderType]Provider{
+	infrastructurev1alpha1.ProviderOpenStack: openStackProvider{},
+	infrastructurev1alpha1.ProviderAWS:       awsProvider{},
+	infrastructurev1alpha1.ProviderLibvirt:   libvirtProvider{},
+}
+
+// providerTypeOf returns the ProviderType of the ProviderConfig of an
+// InstanceStack. A missing ProviderConfig is treated as OpenStack, whose
+// credentials fall back to the environment or report the missing object.
+func providerTypeOf(ctx context.Context, c client.Reader, instanceStack *infrastructurev1alpha1.InstanceStack) (infrastructurev1alpha1.ProviderType, error) {
+	providerConfig := &infrastructurev1alpha1.ProviderConfig{}
+	if err := c.Get(ctx, types.NamespacedName{Name: providerConfigOrDefault(instanceStack.Spec.ProviderConfigName)}, providerConfig); err != nil {
+		if apierrors.IsNotFound(err) {
+			return infrastructurev1alpha1.ProviderOpenStack, nil
+		}
+		return "", fmt.Errorf("failed to get ProviderConfig %q: %w", providerConfigOrDefault(instanceStack.Spec.ProviderConfigName), err)
+	}
+	return providerConfig.ProviderType(), nil
+}
+
+// providerFor returns the Provider of a ProviderType.
+func providerFor(providerType infrastructurev1alpha1.ProviderType) (Provider, error) {
+	provider, ok := providers[providerType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported provider %q", providerType)
+	}
+	return provider, nil
+}
+
+// openStackProvider runs programs against the OpenStack project of the
+// ProviderConfig or of the TenantBinding of the namespace.
+type openStackProvider struct{}
+
+func (openStackProvider) Target(ctx context.Context, c client.Reader, instanceStack *infrastructurev1alpha1.InstanceStack) (*Target, error) {
+	creds, err := instanceStackCredentials(ctx, c, instanceStack)
+	if err != nil {
+		return nil, err
+	}
+	config, stale := openStackConfig(creds)
+	return &Target{Region: creds.Region, Config: config, Stale: stale, OpenStack: &creds}, nil
+}
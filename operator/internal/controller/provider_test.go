@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+	"github.com/gunniLee/cloud-provider-operator/internal/openstack"
+)
+
+var _ = Describe("Provider", func() {
+	ctx := context.Background()
+
+	var c client.Client
+
+	awsProviderConfig := func(settings *infrastructurev1alpha1.AWSProviderConfig) *infrastructurev1alpha1.ProviderConfig {
+		return &infrastructurev1alpha1.ProviderConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: "aws"},
+			Spec: infrastructurev1alpha1.ProviderConfigSpec{
+				Provider: infrastructurev1alpha1.ProviderAWS,
+				Region:   "eu-west-1",
+				Regions:  []string{"us-east-1"},
+				AWS:      settings,
+			},
+		}
+	}
+
+	setup := func(objs ...client.Object) {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(infrastructurev1alpha1.AddToScheme(scheme)).To(Succeed())
+		c = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+	}
+
+	newInstanceStack := func(providerConfigName, region string) *infrastructurev1alpha1.InstanceStack {
+		return &infrastructurev1alpha1.InstanceStack{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "ec2"},
+			Spec:       infrastructurev1alpha1.InstanceStackSpec{ProviderConfigName: providerConfigName, Region: region},
+		}
+	}
+
+	It("Should select the provider of the ProviderConfig", func() {
+		setup(awsProviderConfig(nil), &infrastructurev1alpha1.ProviderConfig{ObjectMeta: metav1.ObjectMeta{Name: "openstack"}})
+		for name, want := range map[string]infrastructurev1alpha1.ProviderType{
+			"aws":       infrastructurev1alpha1.ProviderAWS,
+			"openstack": infrastructurev1alpha1.ProviderOpenStack,
+			"":          infrastructurev1alpha1.ProviderOpenStack,
+		} {
+			providerType, err := providerTypeOf(ctx, c, newInstanceStack(name, ""))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(providerType).To(Equal(want), "ProviderConfig %q", name)
+		}
+		_, err := providerFor("GCP")
+		Expect(err).To(MatchError(ContainSubstring(`unsupported provider "GCP"`)))
+	})
+
+	It("Should target a local EC2 emulator with the Secret credentials", func() {
+		setup(
+			awsProviderConfig(&infrastructurev1alpha1.AWSProviderConfig{
+				CredentialsSecretRef: &infrastructurev1alpha1.SecretReference{Namespace: "system", Name: "aws"},
+				Endpoint:             "http://localhost:4566",
+			}),
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "system", Name: "aws"},
+				Data:       map[string][]byte{"accessKeyID": []byte("test"), "secretAccessKey": []byte("test")},
+			},
+		)
+		target, err := awsProvider{}.Target(ctx, c, newInstanceStack("aws", "us-east-1"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(target.Region).To(Equal("us-east-1"))
+		Expect(target.OpenStack).To(BeNil())
+		Expect(target.Config["aws:region"].Value).To(Equal("us-east-1"))
+		Expect(target.Config["aws:accessKey"].Value).To(Equal("test"))
+		Expect(target.Config["aws:secretKey"].Secret).To(BeTrue())
+		Expect(target.Config["aws:endpoints"].Value).To(MatchJSON(`[{"ec2": "http://localhost:4566"}]`))
+		Expect(target.Config["aws:skipRequestingAccountId"].Value).To(Equal("true"))
+		Expect(target.Stale).To(Equal([]string{"aws:token"}))
+
+		_, err = awsProvider{}.Target(ctx, c, newInstanceStack("aws", "ap-south-1"))
+		Expect(err).To(MatchError(openstack.ErrRegionNotAllowed))
+	})
+
+	It("Should fall back to the default credential chain of the AWS SDK", func() {
+		setup(awsProviderConfig(nil))
+		target, err := awsProvider{}.Target(ctx, c, newInstanceStack("aws", ""))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(target.Region).To(Equal("eu-west-1"))
+		Expect(target.Config).To(HaveLen(1))
+		Expect(target.Stale).To(ContainElements("aws:accessKey", "aws:secretKey", "aws:endpoints"))
+	})
+
+	It("Should target the libvirt daemon of the ProviderConfig", func() {
+		libvirtProviderConfig := func(name string, settings *infrastructurev1alpha1.LibvirtProviderConfig) *infrastructurev1alpha1.ProviderConfig {
+			return &infrastructurev1alpha1.ProviderConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: name},
+				Spec: infrastructurev1alpha1.ProviderConfigSpec{
+					Provider: infrastructurev1alpha1.ProviderLibvirt,
+					Libvirt:  settings,
+				},
+			}
+		}
+		setup(
+			libvirtProviderConfig("laptop", nil),
+			libvirtProviderConfig("ci", &infrastructurev1alpha1.LibvirtProviderConfig{URI: "qemu+ssh://runner@ci/system"}),
+		)
+		provider, err := providerFor(infrastructurev1alpha1.ProviderLibvirt)
+		Expect(err).NotTo(HaveOccurred())
+
+		target, err := provider.Target(ctx, c, newInstanceStack("laptop", ""))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(target.OpenStack).To(BeNil())
+		Expect(target.Config).To(Equal(auto.ConfigMap{"libvirt:uri": {Value: "qemu:///system"}}))
+
+		target, err = provider.Target(ctx, c, newInstanceStack("ci", ""))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(target.Config["libvirt:uri"].Value).To(Equal("qemu+ssh://runner@ci/system"))
+
+		_, err = provider.Target(ctx, c, newInstanceStack("ci", "us-east-1"))
+		Expect(err).To(MatchError(openstack.ErrRegionNotAllowed))
+	})
+})
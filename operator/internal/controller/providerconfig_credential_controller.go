@@ -0,0 +1,343 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+	"github.com/gunniLee/cloud-provider-operator/internal/openstack"
+)
+
+const (
+	// credentialRetryInterval is how soon a failed or blocked rotation is
+	// tried again.
+	credentialRetryInterval = 5 * time.Minute
+
+	defaultRotationPeriod = 30 * 24 * time.Hour
+	defaultOverlap        = time.Hour
+)
+
+// ApplicationCredentialReconciler manages the Keystone application credential
+// of ProviderConfigs that set spec.applicationCredential. The credential is
+// created with the password credentials, written to a Secret that every
+// other controller reads through openstack.ResolveCredentials, and replaced
+// every rotation period. The replaced credential stays valid for the overlap
+// so that Pulumi runs started with it can finish.
+type ApplicationCredentialReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// NewClient authenticates against OpenStack. Defaults to
+	// openstack.NewClient.
+	NewClient func(ctx context.Context, creds openstack.Credentials) (*openstack.Client, error)
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// +kubebuilder:rbac:groups=infrastructure.cloudprovider.io,resources=providerconfigs,verbs=get;list;watch
+// +kubebuilder:rbac:groups=infrastructure.cloudprovider.io,resources=providerconfigs/status,verbs=get;update;patch
+
+func (r *ApplicationCredentialReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	providerConfig := &infrastructurev1alpha1.ProviderConfig{}
+	if err := r.Get(ctx, req.NamespacedName, providerConfig); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	policy := providerConfig.Spec.ApplicationCredential
+	if policy == nil {
+		return ctrl.Result{}, nil
+	}
+	now := time.Now()
+	if r.Now != nil {
+		now = r.Now()
+	}
+	original := providerConfig.Status.DeepCopy()
+	status := &providerConfig.Status
+
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: policy.SecretRef.Namespace, Name: policy.SecretRef.Name}, secret); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		secret = nil
+	}
+	adoptWrittenCredential(status, secret, now, policy)
+
+	condition := metav1.Condition{
+		Type:               infrastructurev1alpha1.ConditionApplicationCredentialReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             infrastructurev1alpha1.ReasonApplicationCredentialCurrent,
+		ObservedGeneration: providerConfig.Generation,
+	}
+	result := ctrl.Result{}
+	due := rotationDue(status.ApplicationCredential, secret, now, policy)
+	if due || previousDue(status.ApplicationCredential, now) {
+		if reason, err := r.rotate(ctx, providerConfig, due, now); err != nil {
+			log.Error(err, "failed to manage application credential", "reason", reason)
+			condition.Reason = reason
+			condition.Message = err.Error()
+			// 아직 유효한 credential이 있으면 Ready는 유지
+			if status.ApplicationCredential == nil || secret == nil || credentialExpired(status.ApplicationCredential, now) {
+				condition.Status = metav1.ConditionFalse
+			}
+			result.RequeueAfter = credentialRetryInterval
+		}
+	}
+	if current := status.ApplicationCredential; current != nil && condition.Message == "" {
+		condition.Message = fmt.Sprintf("application credential %s is rotated at %s",
+			current.ID, current.CreatedAt.Add(rotationPeriod(policy)).UTC().Format(time.RFC3339))
+	}
+	meta.SetStatusCondition(&status.Conditions, condition)
+
+	if !equality.Semantic.DeepEqual(original, status) {
+		if err := r.Status().Update(ctx, providerConfig); err != nil {
+			log.Error(err, "failed to update ProviderConfig status")
+			return ctrl.Result{}, err
+		}
+	}
+	if result.RequeueAfter == 0 {
+		result.RequeueAfter = nextCredentialEvent(status.ApplicationCredential, now, policy)
+	}
+	return result, nil
+}
+
+// rotate creates a new credential if due and deletes the previous one once
+// its overlap has passed. It returns the condition reason of a failure.
+func (r *ApplicationCredentialReconciler) rotate(ctx context.Context, providerConfig *infrastructurev1alpha1.ProviderConfig, due bool, now time.Time) (string, error) {
+	policy := providerConfig.Spec.ApplicationCredential
+	status := &providerConfig.Status
+	failure := infrastructurev1alpha1.ReasonRotationFailed
+	if status.ApplicationCredential == nil {
+		failure = infrastructurev1alpha1.ReasonBootstrapFailed
+	}
+
+	osClient, err := r.rotationClient(ctx, providerConfig)
+	if err != nil && !due {
+		// 이전 credential은 expires_at이 지나면 Keystone이 거부하므로 추적만 중단
+		log.FromContext(ctx).Info("cannot delete previous application credential, leaving it to expire",
+			"id", status.ApplicationCredential.PreviousID, "error", err.Error())
+		status.ApplicationCredential.PreviousID = ""
+		status.ApplicationCredential.PreviousRetireAt = nil
+		return "", nil
+	}
+	if err != nil {
+		if status.ApplicationCredential != nil {
+			failure = infrastructurev1alpha1.ReasonRotationBlocked
+		}
+		return failure, err
+	}
+
+	current := status.ApplicationCredential
+	if current != nil && current.PreviousID != "" && (due || !now.Before(current.PreviousRetireAt.Time)) {
+		if err := osClient.DeleteApplicationCredential(ctx, current.PreviousID); err != nil {
+			return failure, fmt.Errorf("failed to delete application credential %s: %w", current.PreviousID, err)
+		}
+		current.PreviousID = ""
+		current.PreviousRetireAt = nil
+	}
+	if !due {
+		return "", nil
+	}
+
+	expiresAt := now.Add(rotationPeriod(policy) + overlap(policy))
+	created, err := osClient.CreateApplicationCredential(ctx, openstack.ApplicationCredentialRequest{
+		Name:         fmt.Sprintf("cloud-provider-operator-%s-%d", providerConfig.Name, now.Unix()),
+		Description:  fmt.Sprintf("Managed by cloud-provider-operator for ProviderConfig %s", providerConfig.Name),
+		Roles:        policy.Roles,
+		ExpiresAt:    expiresAt,
+		Unrestricted: policy.Unrestricted,
+	})
+	if err != nil {
+		return failure, fmt.Errorf("failed to create application credential: %w", err)
+	}
+
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: policy.SecretRef.Namespace, Name: policy.SecretRef.Name}}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, secret, func() error {
+		secret.Data = map[string][]byte{
+			infrastructurev1alpha1.ApplicationCredentialIDKey:     []byte(created.ID),
+			infrastructurev1alpha1.ApplicationCredentialSecretKey: []byte(created.Secret),
+		}
+		return controllerutil.SetControllerReference(providerConfig, secret, r.Scheme)
+	}); err != nil {
+		// 저장하지 못한 credential은 다시 쓸 수 없으므로 바로 삭제
+		_ = osClient.DeleteApplicationCredential(ctx, created.ID)
+		return failure, fmt.Errorf("failed to write application credential Secret: %w", err)
+	}
+
+	next := &infrastructurev1alpha1.ApplicationCredentialStatus{
+		ID:        created.ID,
+		CreatedAt: metav1.NewTime(now),
+		ExpiresAt: &metav1.Time{Time: expiresAt},
+	}
+	if current != nil && current.ID != "" {
+		next.PreviousID = current.ID
+		next.PreviousRetireAt = &metav1.Time{Time: now.Add(overlap(policy))}
+	}
+	status.ApplicationCredential = next
+	log.FromContext(ctx).Info("created application credential", "id", created.ID, "previous", next.PreviousID)
+	return "", nil
+}
+
+// rotationClient authenticates with the password credentials of the
+// ProviderConfig, or with the current credential if it is unrestricted and
+// no password is configured any more.
+func (r *ApplicationCredentialReconciler) rotationClient(ctx context.Context, providerConfig *infrastructurev1alpha1.ProviderConfig) (*openstack.Client, error) {
+	newClient := r.NewClient
+	if newClient == nil {
+		newClient = openstack.NewClient
+	}
+	creds, err := openstack.ResolvePasswordCredentials(ctx, r.Client, providerConfig)
+	if err == nil {
+		return newClient(ctx, creds)
+	}
+	if !providerConfig.Spec.ApplicationCredential.Unrestricted || providerConfig.Status.ApplicationCredential == nil {
+		return nil, fmt.Errorf("no password credentials to manage the application credential with: %w", err)
+	}
+	creds, err = openstack.ResolveProviderConfigCredentials(ctx, r.Client, providerConfig)
+	if err != nil {
+		return nil, err
+	}
+	return newClient(ctx, creds)
+}
+
+// adoptWrittenCredential records a credential found in the Secret but not in
+// the status, which happens when the status update after a rotation failed.
+func adoptWrittenCredential(status *infrastructurev1alpha1.ProviderConfigStatus, secret *corev1.Secret, now time.Time, policy *infrastructurev1alpha1.ApplicationCredentialPolicy) {
+	if secret == nil {
+		return
+	}
+	id := string(secret.Data[infrastructurev1alpha1.ApplicationCredentialIDKey])
+	current := status.ApplicationCredential
+	if id == "" || (current != nil && current.ID == id) {
+		return
+	}
+	adopted := &infrastructurev1alpha1.ApplicationCredentialStatus{ID: id, CreatedAt: metav1.NewTime(now)}
+	if current != nil {
+		adopted.PreviousID = current.ID
+		adopted.PreviousRetireAt = &metav1.Time{Time: now.Add(overlap(policy))}
+	}
+	status.ApplicationCredential = adopted
+}
+
+// rotationDue reports whether a new credential is needed: none was created
+// yet, its Secret is gone or the rotation period has passed.
+func rotationDue(current *infrastructurev1alpha1.ApplicationCredentialStatus, secret *corev1.Secret, now time.Time, policy *infrastructurev1alpha1.ApplicationCredentialPolicy) bool {
+	if current == nil || secret == nil {
+		return true
+	}
+	return !now.Before(current.CreatedAt.Add(rotationPeriod(policy)))
+}
+
+func previousDue(current *infrastructurev1alpha1.ApplicationCredentialStatus, now time.Time) bool {
+	return current != nil && current.PreviousID != "" && current.PreviousRetireAt != nil && !now.Before(current.PreviousRetireAt.Time)
+}
+
+func credentialExpired(current *infrastructurev1alpha1.ApplicationCredentialStatus, now time.Time) bool {
+	return current.ExpiresAt != nil && !now.Before(current.ExpiresAt.Time)
+}
+
+// nextCredentialEvent is how long until the credential is rotated or the
+// previous one retired, whichever comes first.
+func nextCredentialEvent(current *infrastructurev1alpha1.ApplicationCredentialStatus, now time.Time, policy *infrastructurev1alpha1.ApplicationCredentialPolicy) time.Duration {
+	if current == nil {
+		return credentialRetryInterval
+	}
+	next := current.CreatedAt.Add(rotationPeriod(policy))
+	if current.PreviousRetireAt != nil && current.PreviousRetireAt.Before(&metav1.Time{Time: next}) {
+		next = current.PreviousRetireAt.Time
+	}
+	if wait := next.Sub(now); wait > 0 {
+		return wait
+	}
+	return time.Second
+}
+
+func rotationPeriod(policy *infrastructurev1alpha1.ApplicationCredentialPolicy) time.Duration {
+	if policy.RotationPeriod.Duration <= 0 {
+		return defaultRotationPeriod
+	}
+	return policy.RotationPeriod.Duration
+}
+
+func overlap(policy *infrastructurev1alpha1.ApplicationCredentialPolicy) time.Duration {
+	if policy.Overlap.Duration < 0 {
+		return defaultOverlap
+	}
+	return policy.Overlap.Duration
+}
+
+// requestsForCredentialSecret maps a changed application credential Secret
+// to the objects, listed with newList, using its ProviderConfig, so that
+// their stacks pick up the new credential before the previous one is
+// deleted. Namespaces bound by a TenantBinding use the credential of the
+// binding instead.
+func requestsForCredentialSecret(c client.Reader, newList func() client.ObjectList) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		providerConfigs := &infrastructurev1alpha1.ProviderConfigList{}
+		if err := c.List(ctx, providerConfigs); err != nil {
+			log.FromContext(ctx).Error(err, "failed to list ProviderConfigs")
+			return nil
+		}
+		names := map[string]bool{}
+		for _, providerConfig := range providerConfigs.Items {
+			if policy := providerConfig.Spec.ApplicationCredential; policy != nil &&
+				policy.SecretRef.Namespace == obj.GetNamespace() && policy.SecretRef.Name == obj.GetName() {
+				names[providerConfig.Name] = true
+			}
+		}
+		if len(names) == 0 {
+			return nil
+		}
+
+		list := newList()
+		if err := c.List(ctx, list); err != nil {
+			log.FromContext(ctx).Error(err, "failed to list objects using the application credential", "secret", obj.GetName())
+			return nil
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return nil
+		}
+		var requests []reconcile.Request
+		for _, item := range items {
+			var providerConfigName string
+			switch o := item.(type) {
+			case *infrastructurev1alpha1.InstanceStack:
+				providerConfigName = o.Spec.ProviderConfigName
+			case *infrastructurev1alpha1.Stack:
+				providerConfigName = o.Spec.ProviderConfigName
+			default:
+				continue
+			}
+			if names[providerConfigOrDefault(providerConfigName)] {
+				requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(item.(client.Object))})
+			}
+		}
+		return requests
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ApplicationCredentialReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&infrastructurev1alpha1.ProviderConfig{}).
+		Owns(&corev1.Secret{}).
+		Named("providerconfig-credential").
+		Complete(r)
+}
@@ -0,0 +1,189 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+	"github.com/gunniLee/cloud-provider-operator/internal/openstack"
+	"github.com/gunniLee/cloud-provider-operator/internal/openstack/openstacktest"
+)
+
+var _ = Describe("ProviderConfig application credential", func() {
+	ctx := context.Background()
+	start := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	var (
+		cloud *openstacktest.Server
+		c     client.Client
+		now   time.Time
+	)
+
+	passwordSecret := types.NamespacedName{Namespace: "system", Name: "bootstrap"}
+	managedSecret := types.NamespacedName{Namespace: "system", Name: "default-appcred"}
+
+	reconcile := func() (*infrastructurev1alpha1.ProviderConfig, ctrl.Result) {
+		r := &ApplicationCredentialReconciler{Client: c, Scheme: c.Scheme(), Now: func() time.Time { return now }}
+		result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: infrastructurev1alpha1.DefaultProviderConfigName}})
+		Expect(err).NotTo(HaveOccurred())
+		providerConfig := &infrastructurev1alpha1.ProviderConfig{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: infrastructurev1alpha1.DefaultProviderConfigName}, providerConfig)).To(Succeed())
+		return providerConfig, result
+	}
+
+	setup := func(unrestricted bool) {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(infrastructurev1alpha1.AddToScheme(scheme)).To(Succeed())
+		providerConfig := &infrastructurev1alpha1.ProviderConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: infrastructurev1alpha1.DefaultProviderConfigName},
+			Spec: infrastructurev1alpha1.ProviderConfigSpec{
+				AuthURL: cloud.Credentials().AuthURL, Region: openstacktest.Region, TenantName: "admin",
+				CredentialsSecretRef: &infrastructurev1alpha1.SecretReference{Namespace: passwordSecret.Namespace, Name: passwordSecret.Name},
+				ApplicationCredential: &infrastructurev1alpha1.ApplicationCredentialPolicy{
+					SecretRef:      infrastructurev1alpha1.SecretReference{Namespace: managedSecret.Namespace, Name: managedSecret.Name},
+					Roles:          []string{"member"},
+					RotationPeriod: metav1.Duration{Duration: 24 * time.Hour},
+					Overlap:        metav1.Duration{Duration: time.Hour},
+					Unrestricted:   unrestricted,
+				},
+			},
+		}
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: passwordSecret.Namespace, Name: passwordSecret.Name},
+			Data:       map[string][]byte{"username": []byte("admin"), "password": []byte("secret")},
+		}
+		c = fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(providerConfig, secret).
+			WithStatusSubresource(&infrastructurev1alpha1.ProviderConfig{}).
+			Build()
+	}
+
+	deletePasswordSecret := func() {
+		Expect(c.Delete(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: passwordSecret.Namespace, Name: passwordSecret.Name}})).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		cloud = openstacktest.NewServer()
+		DeferCleanup(cloud.Close)
+		now = start
+	})
+
+	It("Should bootstrap a restricted credential and use it instead of the password", func() {
+		setup(false)
+		providerConfig, result := reconcile()
+
+		status := providerConfig.Status.ApplicationCredential
+		Expect(status).NotTo(BeNil())
+		Expect(status.PreviousID).To(BeEmpty())
+		Expect(status.ExpiresAt.Time).To(BeTemporally("==", start.Add(25*time.Hour)))
+		Expect(meta.IsStatusConditionTrue(providerConfig.Status.Conditions, infrastructurev1alpha1.ConditionApplicationCredentialReady)).To(BeTrue())
+		Expect(result.RequeueAfter).To(Equal(24 * time.Hour))
+
+		created := cloud.ApplicationCredentials[status.ID]
+		Expect(created.ProjectName).To(Equal("admin"))
+		Expect(created.Roles).To(Equal([]string{"member"}))
+		Expect(created.Unrestricted).To(BeFalse())
+
+		By("resolving credentials from the managed Secret once the password is gone")
+		deletePasswordSecret()
+		creds, err := openstack.ResolveCredentials(ctx, c, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(creds.Username).To(BeEmpty())
+		Expect(creds.Password).To(BeEmpty())
+		Expect(creds.ApplicationCredentialID).To(Equal(status.ID))
+		_, err = openstack.NewClient(ctx, creds)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("blocking rotation without a password while keeping the credential usable")
+		now = start.Add(24 * time.Hour)
+		providerConfig, result = reconcile()
+		condition := meta.FindStatusCondition(providerConfig.Status.Conditions, infrastructurev1alpha1.ConditionApplicationCredentialReady)
+		Expect(condition.Reason).To(Equal(infrastructurev1alpha1.ReasonRotationBlocked))
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(providerConfig.Status.ApplicationCredential.ID).To(Equal(status.ID))
+		Expect(result.RequeueAfter).To(Equal(credentialRetryInterval))
+	})
+
+	It("Should rotate with overlap and retire the previous credential afterwards", func() {
+		setup(true)
+		providerConfig, _ := reconcile()
+		first := providerConfig.Status.ApplicationCredential.ID
+		deletePasswordSecret()
+
+		By("keeping the credential until the rotation period passes")
+		now = start.Add(12 * time.Hour)
+		providerConfig, result := reconcile()
+		Expect(providerConfig.Status.ApplicationCredential.ID).To(Equal(first))
+		Expect(result.RequeueAfter).To(Equal(12 * time.Hour))
+
+		By("rotating with the unrestricted credential itself")
+		now = start.Add(24 * time.Hour)
+		providerConfig, result = reconcile()
+		status := providerConfig.Status.ApplicationCredential
+		Expect(status.ID).NotTo(Equal(first))
+		Expect(status.PreviousID).To(Equal(first))
+		Expect(result.RequeueAfter).To(Equal(time.Hour))
+		Expect(cloud.ApplicationCredentials).To(HaveKey(first))
+
+		secret := &corev1.Secret{}
+		Expect(c.Get(ctx, managedSecret, secret)).To(Succeed())
+		Expect(string(secret.Data[infrastructurev1alpha1.ApplicationCredentialIDKey])).To(Equal(status.ID))
+
+		By("deleting the previous credential after the overlap")
+		now = start.Add(25 * time.Hour)
+		providerConfig, _ = reconcile()
+		Expect(providerConfig.Status.ApplicationCredential.PreviousID).To(BeEmpty())
+		Expect(cloud.ApplicationCredentials).NotTo(HaveKey(first))
+		Expect(cloud.ApplicationCredentials).To(HaveKey(status.ID))
+	})
+
+	It("Should report a failed bootstrap", func() {
+		setup(false)
+		deletePasswordSecret()
+		providerConfig, _ := reconcile()
+		Expect(providerConfig.Status.ApplicationCredential).To(BeNil())
+		condition := meta.FindStatusCondition(providerConfig.Status.Conditions, infrastructurev1alpha1.ConditionApplicationCredentialReady)
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal(infrastructurev1alpha1.ReasonBootstrapFailed))
+
+		_, err := openstack.ResolveCredentials(ctx, c, "")
+		Expect(err).To(MatchError(ContainSubstring("has not been created yet")))
+	})
+
+	It("Should requeue the stacks using a rotated credential", func() {
+		setup(false)
+		Expect(c.Create(ctx, &infrastructurev1alpha1.InstanceStack{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "uses-default"},
+		})).To(Succeed())
+		Expect(c.Create(ctx, &infrastructurev1alpha1.InstanceStack{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "uses-other"},
+			Spec:       infrastructurev1alpha1.InstanceStackSpec{ProviderConfigName: "other"},
+		})).To(Succeed())
+		Expect(c.Create(ctx, &infrastructurev1alpha1.Stack{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "uses-default"},
+		})).To(Succeed())
+
+		managed := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: managedSecret.Namespace, Name: managedSecret.Name}}
+		requests := requestsForCredentialSecret(c, func() client.ObjectList { return &infrastructurev1alpha1.InstanceStackList{} })(ctx, managed)
+		Expect(requests).To(ConsistOf(ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "uses-default"}}))
+		requests = requestsForCredentialSecret(c, func() client.ObjectList { return &infrastructurev1alpha1.StackList{} })(ctx, managed)
+		Expect(requests).To(ConsistOf(ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "uses-default"}}))
+
+		By("ignoring other Secrets")
+		other := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: passwordSecret.Namespace, Name: passwordSecret.Name}}
+		Expect(requestsForCredentialSecret(c, func() client.ObjectList { return &infrastructurev1alpha1.InstanceStackList{} })(ctx, other)).To(BeEmpty())
+	})
+})
@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+	"github.com/gunniLee/cloud-provider-operator/internal/openstack"
+)
+
+// ProviderConfigTLSReconciler reports the InsecureTLS condition of
+// ProviderConfigs, so that disabled certificate verification stays visible,
+// and checks that the CA bundle and client certificate can be loaded.
+type ProviderConfigTLSReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+func (r *ProviderConfigTLSReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	providerConfig := &infrastructurev1alpha1.ProviderConfig{}
+	if err := r.Get(ctx, req.NamespacedName, providerConfig); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if providerConfig.ProviderType() != infrastructurev1alpha1.ProviderOpenStack {
+		return ctrl.Result{}, nil
+	}
+	original := providerConfig.Status.DeepCopy()
+
+	condition := metav1.Condition{
+		Type:               infrastructurev1alpha1.ConditionInsecureTLS,
+		Status:             metav1.ConditionFalse,
+		Reason:             infrastructurev1alpha1.ReasonCertificatesVerified,
+		Message:            "certificates of the OpenStack APIs are verified",
+		ObservedGeneration: providerConfig.Generation,
+	}
+	creds, err := openstack.ResolveConnection(ctx, r.Client, providerConfig)
+	switch {
+	case err != nil:
+		condition.Status = metav1.ConditionUnknown
+		condition.Reason = infrastructurev1alpha1.ReasonInvalidTLSConfig
+		condition.Message = err.Error()
+	case creds.Insecure:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = infrastructurev1alpha1.ReasonInsecureSkipVerify
+		condition.Message = "certificate verification of the OpenStack APIs is disabled; configure spec.tls.caBundle instead"
+	}
+	changed := meta.SetStatusCondition(&providerConfig.Status.Conditions, condition)
+	if changed && condition.Status != metav1.ConditionFalse {
+		r.Recorder.Event(providerConfig, corev1.EventTypeWarning, condition.Reason, condition.Message)
+	}
+
+	if !equality.Semantic.DeepEqual(original, &providerConfig.Status) {
+		if err := r.Status().Update(ctx, providerConfig); err != nil {
+			log.Error(err, "failed to update ProviderConfig status")
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// requestsForTLSSource maps a Secret or ConfigMap to the ProviderConfigs
+// taking certificates from it.
+func (r *ProviderConfigTLSReconciler) requestsForTLSSource(ctx context.Context, obj client.Object) []reconcile.Request {
+	providerConfigs := &infrastructurev1alpha1.ProviderConfigList{}
+	if err := r.List(ctx, providerConfigs); err != nil {
+		log.FromContext(ctx).Error(err, "failed to list ProviderConfigs")
+		return nil
+	}
+	_, isConfigMap := obj.(*corev1.ConfigMap)
+	matches := func(name, namespace string) bool {
+		return name == obj.GetName() && namespace == obj.GetNamespace()
+	}
+	var requests []reconcile.Request
+	for _, providerConfig := range providerConfigs.Items {
+		tls := providerConfig.Spec.TLS
+		if tls == nil {
+			continue
+		}
+		referenced := false
+		if bundle := tls.CABundle; bundle != nil {
+			if isConfigMap && bundle.ConfigMapRef != nil {
+				referenced = matches(bundle.ConfigMapRef.Name, bundle.ConfigMapRef.Namespace)
+			}
+			if !isConfigMap && bundle.SecretRef != nil {
+				referenced = matches(bundle.SecretRef.Name, bundle.SecretRef.Namespace)
+			}
+		}
+		if ref := tls.ClientCertificateSecretRef; !isConfigMap && ref != nil && matches(ref.Name, ref.Namespace) {
+			referenced = true
+		}
+		if referenced {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: providerConfig.Name}})
+		}
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ProviderConfigTLSReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&infrastructurev1alpha1.ProviderConfig{}).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.requestsForTLSSource)).
+		Watches(&corev1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(r.requestsForTLSSource)).
+		Named("providerconfig-tls").
+		Complete(r)
+}
@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+	"github.com/gunniLee/cloud-provider-operator/internal/openstack"
+	"github.com/gunniLee/cloud-provider-operator/internal/openstack/openstacktest"
+)
+
+var _ = Describe("ProviderConfig TLS", func() {
+	ctx := context.Background()
+
+	var (
+		cloud    *openstacktest.Server
+		c        client.Client
+		recorder *record.FakeRecorder
+	)
+
+	setup := func(spec infrastructurev1alpha1.ProviderConfigSpec) {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(infrastructurev1alpha1.AddToScheme(scheme)).To(Succeed())
+		spec.AuthURL = cloud.Credentials().AuthURL
+		spec.Region = openstacktest.Region
+		spec.TenantName = "admin"
+		spec.CredentialsSecretRef = &infrastructurev1alpha1.SecretReference{Namespace: "system", Name: "openstack"}
+		c = fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(
+				&infrastructurev1alpha1.ProviderConfig{ObjectMeta: metav1.ObjectMeta{Name: infrastructurev1alpha1.DefaultProviderConfigName}, Spec: spec},
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "system", Name: "openstack"},
+					Data:       map[string][]byte{"username": []byte("admin"), "password": []byte("secret")},
+				},
+				&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "system", Name: "openstack-ca"},
+					Data:       map[string]string{"ca.crt": cloud.CACert(), "broken.crt": "not a certificate"},
+				},
+			).
+			WithStatusSubresource(&infrastructurev1alpha1.ProviderConfig{}).
+			Build()
+	}
+
+	reconcile := func() *metav1.Condition {
+		r := &ProviderConfigTLSReconciler{Client: c, Scheme: c.Scheme(), Recorder: recorder}
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: infrastructurev1alpha1.DefaultProviderConfigName}})
+		Expect(err).NotTo(HaveOccurred())
+		providerConfig := &infrastructurev1alpha1.ProviderConfig{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: infrastructurev1alpha1.DefaultProviderConfigName}, providerConfig)).To(Succeed())
+		return meta.FindStatusCondition(providerConfig.Status.Conditions, infrastructurev1alpha1.ConditionInsecureTLS)
+	}
+
+	caBundle := func(key string) *infrastructurev1alpha1.TLSConfig {
+		return &infrastructurev1alpha1.TLSConfig{CABundle: &infrastructurev1alpha1.CABundleSource{
+			ConfigMapRef: &infrastructurev1alpha1.ConfigMapReference{Namespace: "system", Name: "openstack-ca"},
+			Key:          key,
+		}}
+	}
+
+	BeforeEach(func() {
+		cloud = openstacktest.NewTLSServer()
+		DeferCleanup(cloud.Close)
+		recorder = record.NewFakeRecorder(10)
+	})
+
+	It("Should verify the APIs with the CA bundle", func() {
+		setup(infrastructurev1alpha1.ProviderConfigSpec{TLS: caBundle("ca.crt")})
+		condition := reconcile()
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal(infrastructurev1alpha1.ReasonCertificatesVerified))
+
+		creds, err := openstack.ResolveCredentials(ctx, c, "")
+		Expect(err).NotTo(HaveOccurred())
+		_, err = openstack.NewClient(ctx, creds)
+		Expect(err).NotTo(HaveOccurred())
+
+		config, stale := openStackConfig(creds)
+		Expect(config["openstack:cacertFile"].Value).To(Equal(cloud.CACert()))
+		Expect(config["openstack:insecure"].Value).To(Equal("false"))
+		Expect(stale).To(ContainElements("openstack:cert", "openstack:key"))
+
+		By("refusing the self-signed certificate without the bundle")
+		creds.CACert = ""
+		_, err = openstack.NewClient(ctx, creds)
+		Expect(err).To(MatchError(ContainSubstring("certificate")))
+	})
+
+	It("Should warn when certificate verification is disabled", func() {
+		insecure := true
+		setup(infrastructurev1alpha1.ProviderConfigSpec{Insecure: &insecure})
+		condition := reconcile()
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Reason).To(Equal(infrastructurev1alpha1.ReasonInsecureSkipVerify))
+		Expect(recorder.Events).To(Receive(ContainSubstring("Warning InsecureSkipVerify")))
+
+		By("warning only once")
+		reconcile()
+		Expect(recorder.Events).NotTo(Receive())
+	})
+
+	It("Should report a CA bundle without certificates", func() {
+		setup(infrastructurev1alpha1.ProviderConfigSpec{TLS: caBundle("broken.crt")})
+		condition := reconcile()
+		Expect(condition.Status).To(Equal(metav1.ConditionUnknown))
+		Expect(condition.Reason).To(Equal(infrastructurev1alpha1.ReasonInvalidTLSConfig))
+
+		_, err := openstack.ResolveCredentials(ctx, c, "")
+		Expect(err).To(MatchError(ContainSubstring("no PEM certificates")))
+	})
+})
@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+	"github.com/gunniLee/cloud-provider-operator/internal/catalog"
+	"github.com/gunniLee/cloud-provider-operator/internal/openstack"
+	"github.com/gunniLee/cloud-provider-operator/internal/program"
+)
+
+// quotaRetryInterval is how often an InstanceStack blocked by quota checks
+// again.
+const quotaRetryInterval = 5 * time.Minute
+
+// quotaRequest is what creating a server consumes.
+type quotaRequest struct {
+	Instances   int64
+	Cores       int64
+	RAM         int64
+	FloatingIPs int64
+}
+
+// serverQuotaRequest returns what the server of instanceStack would consume.
+// It returns false when the server already exists, is being imported or its
+// flavor is unknown, in which case quotas are not checked.
+func serverQuotaRequest(snapshot *catalog.Snapshot, instanceStack *infrastructurev1alpha1.InstanceStack, params program.Values) (quotaRequest, bool) {
+	if snapshot == nil || statusOutputString(instanceStack, "serverID") != "" || instanceStack.PendingImportID() != "" {
+		return quotaRequest{}, false
+	}
+	for _, flavor := range snapshot.Flavors {
+		if flavor.Name != instanceStack.Spec.FlavorName {
+			continue
+		}
+		request := quotaRequest{Instances: 1, Cores: int64(flavor.VCPUs), RAM: int64(flavor.RAM)}
+		if params.String("floatingIPPool") != "" {
+			request.FloatingIPs = 1
+		}
+		return request, true
+	}
+	return quotaRequest{}, false
+}
+
+// quotaShortfalls describes every limit the request does not fit in.
+func quotaShortfalls(quotas *openstack.Quotas, request quotaRequest) []string {
+	var shortfalls []string
+	check := func(name string, usage openstack.QuotaUsage, need int64) {
+		if available := usage.Available(); need > 0 && available >= 0 && need > available {
+			shortfalls = append(shortfalls, fmt.Sprintf("%s: requires %d, %d of %d available", name, need, available, usage.Limit))
+		}
+	}
+	check("instances", quotas.Instances, request.Instances)
+	check("cores", quotas.Cores, request.Cores)
+	check("ram", quotas.RAM, request.RAM)
+	check("floatingips", quotas.FloatingIPs, request.FloatingIPs)
+	return shortfalls
+}
+
+// checkQuota queries the live quotas of the project and returns the limits
+// the new server would exceed.
+func checkQuota(ctx context.Context, creds openstack.Credentials, request quotaRequest) ([]string, error) {
+	osClient, err := openstack.NewClient(ctx, creds)
+	if err != nil {
+		return nil, err
+	}
+	quotas, err := osClient.GetQuotas(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return quotaShortfalls(quotas, request), nil
+}
+
+// setQuotaCondition records the result of the quota pre-flight check.
+func (r *InstanceStackReconciler) setQuotaCondition(ctx context.Context, instanceStack *infrastructurev1alpha1.InstanceStack, shortfalls []string) error {
+	condition := metav1.Condition{
+		Type:               infrastructurev1alpha1.ConditionQuotaExceeded,
+		Status:             metav1.ConditionFalse,
+		Reason:             infrastructurev1alpha1.ReasonWithinQuota,
+		Message:            "the server fits in the project quota",
+		ObservedGeneration: instanceStack.Generation,
+	}
+	if len(shortfalls) > 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = infrastructurev1alpha1.ReasonInsufficientQuota
+		condition.Message = strings.Join(shortfalls, "; ")
+	}
+	if !meta.SetStatusCondition(&instanceStack.Status.Conditions, condition) {
+		return nil
+	}
+	return r.Status().Update(ctx, instanceStack)
+}
@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+	"github.com/gunniLee/cloud-provider-operator/internal/catalog"
+	"github.com/gunniLee/cloud-provider-operator/internal/openstack"
+	"github.com/gunniLee/cloud-provider-operator/internal/openstack/openstacktest"
+	"github.com/gunniLee/cloud-provider-operator/internal/program"
+)
+
+var _ = Describe("Quota pre-flight check", func() {
+	snapshot := &catalog.Snapshot{Flavors: []openstack.Flavor{{ID: "2", Name: "m1.large", VCPUs: 4, RAM: 8192}}}
+	instanceStack := &infrastructurev1alpha1.InstanceStack{
+		Spec: infrastructurev1alpha1.InstanceStackSpec{FlavorName: "m1.large"},
+	}
+
+	It("Should size a new server from its flavor", func() {
+		request, ok := serverQuotaRequest(snapshot, instanceStack, program.Values{"floatingIPPool": "public"})
+		Expect(ok).To(BeTrue())
+		Expect(request).To(Equal(quotaRequest{Instances: 1, Cores: 4, RAM: 8192, FloatingIPs: 1}))
+
+		By("skipping servers that already exist")
+		existing := instanceStack.DeepCopy()
+		existing.Status.Outputs = map[string]apiextensionsv1.JSON{"serverID": {Raw: []byte(`"srv-1"`)}}
+		_, ok = serverQuotaRequest(snapshot, existing, program.Values{})
+		Expect(ok).To(BeFalse())
+	})
+
+	It("Should list every limit the server does not fit in", func() {
+		cloud := openstacktest.NewServer()
+		DeferCleanup(cloud.Close)
+		cloud.Quotas = openstack.Quotas{
+			Instances: openstack.QuotaUsage{Limit: 10, InUse: 3},
+			Cores:     openstack.QuotaUsage{Limit: 20, InUse: 18},
+			RAM:       openstack.QuotaUsage{Limit: 51200, InUse: 40960, Reserved: 4096},
+		}
+
+		shortfalls, err := checkQuota(context.Background(), cloud.Credentials(), quotaRequest{Instances: 1, Cores: 4, RAM: 8192, FloatingIPs: 1})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(shortfalls).To(Equal([]string{
+			"cores: requires 4, 2 of 20 available",
+			"ram: requires 8192, 6144 of 51200 available",
+		}))
+	})
+})
@@ -0,0 +1,211 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+	"github.com/gunniLee/cloud-provider-operator/internal/openstack"
+)
+
+// outputRefIndex indexes InstanceStacks by the stacks whose outputs they
+// reference, as "<Kind>/<name>".
+const outputRefIndex = "spec.outputRefs"
+
+func outputRefKey(kind, name string) string {
+	if kind == "" {
+		kind = "InstanceStack"
+	}
+	return kind + "/" + name
+}
+
+// outputRefKeys lists the stacks an InstanceStack references.
+func outputRefKeys(obj client.Object) []string {
+	instanceStack := obj.(*infrastructurev1alpha1.InstanceStack)
+	keys := make([]string, 0, len(instanceStack.Spec.OutputRefs))
+	for _, ref := range instanceStack.Spec.OutputRefs {
+		keys = append(keys, outputRefKey(ref.Kind, ref.StackName))
+	}
+	return keys
+}
+
+// dependents returns the names of the InstanceStacks referencing the outputs
+// of the given stack.
+func dependents(ctx context.Context, c client.Reader, kind, namespace, name string) ([]string, error) {
+	instanceStacks := &infrastructurev1alpha1.InstanceStackList{}
+	if err := c.List(ctx, instanceStacks, client.InNamespace(namespace),
+		client.MatchingFields{outputRefIndex: outputRefKey(kind, name)}); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(instanceStacks.Items))
+	for _, item := range instanceStacks.Items {
+		names = append(names, item.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// errWaitingForOutputs is returned while a referenced output does not exist yet.
+type errWaitingForOutputs struct {
+	missing []string
+}
+
+func (e *errWaitingForOutputs) Error() string {
+	return "waiting for " + strings.Join(e.missing, ", ")
+}
+
+// resolveOutputRefs returns a copy of instanceStack whose parameters carry
+// the referenced outputs. It returns *errWaitingForOutputs while an output
+// is missing.
+func resolveOutputRefs(ctx context.Context, c client.Reader, instanceStack *infrastructurev1alpha1.InstanceStack) (*infrastructurev1alpha1.InstanceStack, error) {
+	if len(instanceStack.Spec.OutputRefs) == 0 {
+		return instanceStack, nil
+	}
+
+	values := map[string]apiextensionsv1.JSON{}
+	var missing []string
+	for _, ref := range instanceStack.Spec.OutputRefs {
+		outputs, err := referencedOutputs(ctx, c, instanceStack, ref)
+		if err != nil {
+			return nil, err
+		}
+		value, ok := outputs[ref.Output]
+		if !ok {
+			missing = append(missing, fmt.Sprintf("output %q of %s", ref.Output, outputRefKey(ref.Kind, ref.StackName)))
+			continue
+		}
+		var masked string
+		if json.Unmarshal(value.Raw, &masked) == nil && masked == infrastructurev1alpha1.MaskedOutputValue {
+			return nil, fmt.Errorf("output %q of %s is secret and cannot be referenced", ref.Output, outputRefKey(ref.Kind, ref.StackName))
+		}
+		values[ref.Name] = value
+	}
+	if len(missing) > 0 {
+		return nil, &errWaitingForOutputs{missing: missing}
+	}
+
+	resolved := instanceStack.DeepCopy()
+	if resolved.Spec.Parameters == nil {
+		resolved.Spec.Parameters = map[string]apiextensionsv1.JSON{}
+	}
+	for name, param := range resolved.Spec.Parameters {
+		resolved.Spec.Parameters[name] = expandReferences(param, values)
+	}
+	for name, value := range values {
+		if _, ok := resolved.Spec.Parameters[name]; !ok {
+			resolved.Spec.Parameters[name] = value
+		}
+	}
+	return resolved, nil
+}
+
+// referencedOutputs returns the status outputs of the referenced stack, or
+// nil if the stack does not exist yet. Stacks of another ProviderConfig run
+// in another project, so their outputs such as network IDs are refused.
+func referencedOutputs(ctx context.Context, c client.Reader, instanceStack *infrastructurev1alpha1.InstanceStack, ref infrastructurev1alpha1.OutputReference) (map[string]apiextensionsv1.JSON, error) {
+	key := types.NamespacedName{Namespace: instanceStack.Namespace, Name: ref.StackName}
+	var err error
+	var outputs map[string]apiextensionsv1.JSON
+	var providerConfigName string
+	if ref.Kind == "Stack" {
+		stack := &infrastructurev1alpha1.Stack{}
+		err = c.Get(ctx, key, stack)
+		outputs, providerConfigName = stack.Status.Outputs, stack.Spec.ProviderConfigName
+	} else {
+		referenced := &infrastructurev1alpha1.InstanceStack{}
+		err = c.Get(ctx, key, referenced)
+		outputs, providerConfigName = referenced.Status.Outputs, referenced.Spec.ProviderConfigName
+	}
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !sameProviderConfig(providerConfigName, instanceStack.Spec.ProviderConfigName) {
+		return nil, fmt.Errorf("%w: %s uses ProviderConfig %q", openstack.ErrCrossTenant,
+			outputRefKey(ref.Kind, ref.StackName), providerConfigOrDefault(providerConfigName))
+	}
+	return outputs, nil
+}
+
+func providerConfigOrDefault(name string) string {
+	if name == "" {
+		return infrastructurev1alpha1.DefaultProviderConfigName
+	}
+	return name
+}
+
+// sameProviderConfig compares ProviderConfig names, treating empty as
+// "default".
+func sameProviderConfig(a, b string) bool {
+	return providerConfigOrDefault(a) == providerConfigOrDefault(b)
+}
+
+// expandReferences replaces "$(name)" in a string parameter with the
+// referenced values. Other parameters are returned unchanged.
+func expandReferences(param apiextensionsv1.JSON, values map[string]apiextensionsv1.JSON) apiextensionsv1.JSON {
+	var s string
+	if err := json.Unmarshal(param.Raw, &s); err != nil || !strings.Contains(s, "$(") {
+		return param
+	}
+	for name, value := range values {
+		replacement := string(value.Raw)
+		var str string
+		if json.Unmarshal(value.Raw, &str) == nil {
+			replacement = str
+		}
+		s = strings.ReplaceAll(s, "$("+name+")", replacement)
+	}
+	raw, _ := json.Marshal(s)
+	return apiextensionsv1.JSON{Raw: raw}
+}
+
+// requestsForOutputRefs maps a changed stack to the InstanceStacks that
+// reference it and, for InstanceStacks, to the stacks it references so they
+// can re-check whether they may be deleted.
+func requestsForOutputRefs(c client.Reader, kind string) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		var requests []reconcile.Request
+		names, err := dependents(ctx, c, kind, obj.GetNamespace(), obj.GetName())
+		if err != nil {
+			log.FromContext(ctx).Error(err, "failed to list dependent InstanceStacks", "kind", kind, "name", obj.GetName())
+		}
+		for _, name := range names {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: obj.GetNamespace(), Name: name}})
+		}
+		if instanceStack, ok := obj.(*infrastructurev1alpha1.InstanceStack); ok {
+			for _, ref := range instanceStack.Spec.OutputRefs {
+				if ref.Kind == "" || ref.Kind == "InstanceStack" {
+					requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: obj.GetNamespace(), Name: ref.StackName}})
+				}
+			}
+		}
+		return requests
+	}
+}
+
+// requestsForReferencedStacks maps an InstanceStack to the Stacks it
+// references, so a Stack waiting to be deleted notices its last dependent
+// going away.
+func requestsForReferencedStacks(ctx context.Context, obj client.Object) []reconcile.Request {
+	instanceStack := obj.(*infrastructurev1alpha1.InstanceStack)
+	var requests []reconcile.Request
+	for _, ref := range instanceStack.Spec.OutputRefs {
+		if ref.Kind == "Stack" {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: obj.GetNamespace(), Name: ref.StackName}})
+		}
+	}
+	return requests
+}
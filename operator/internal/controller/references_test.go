@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+	"github.com/gunniLee/cloud-provider-operator/internal/openstack"
+)
+
+var _ = Describe("Output references", func() {
+	raw := func(v string) apiextensionsv1.JSON { return apiextensionsv1.JSON{Raw: []byte(v)} }
+
+	newClient := func(objs ...*infrastructurev1alpha1.InstanceStack) *fake.ClientBuilder {
+		scheme := runtime.NewScheme()
+		Expect(infrastructurev1alpha1.AddToScheme(scheme)).To(Succeed())
+		builder := fake.NewClientBuilder().WithScheme(scheme)
+		for _, obj := range objs {
+			builder = builder.WithObjects(obj)
+		}
+		return builder
+	}
+
+	app := func() *infrastructurev1alpha1.InstanceStack {
+		return &infrastructurev1alpha1.InstanceStack{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"},
+			Spec: infrastructurev1alpha1.InstanceStackSpec{
+				Parameters: map[string]apiextensionsv1.JSON{"userData": raw(`"#!/bin/sh\necho $(bastionIP) > /etc/bastion"`)},
+				OutputRefs: []infrastructurev1alpha1.OutputReference{
+					{Name: "bastionIP", StackName: "bastion", Output: "instanceIP"},
+				},
+			},
+		}
+	}
+
+	It("Should wait until the referenced output exists", func() {
+		c := newClient().Build()
+		_, err := resolveOutputRefs(context.Background(), c, app())
+		var waiting *errWaitingForOutputs
+		Expect(err).To(BeAssignableToTypeOf(waiting))
+		Expect(err.Error()).To(ContainSubstring(`output "instanceIP" of InstanceStack/bastion`))
+	})
+
+	It("Should pass the output as a parameter and expand it in strings", func() {
+		bastion := &infrastructurev1alpha1.InstanceStack{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "bastion"}}
+		bastion.Status.Outputs = map[string]apiextensionsv1.JSON{"instanceIP": raw(`"10.0.0.5"`)}
+		c := newClient(bastion).WithStatusSubresource(bastion).Build()
+
+		resolved, err := resolveOutputRefs(context.Background(), c, app())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(resolved.Spec.Parameters["bastionIP"].Raw)).To(Equal(`"10.0.0.5"`))
+		var userData string
+		Expect(json.Unmarshal(resolved.Spec.Parameters["userData"].Raw, &userData)).To(Succeed())
+		Expect(userData).To(Equal("#!/bin/sh\necho 10.0.0.5 > /etc/bastion"))
+	})
+
+	It("Should refuse outputs of stacks using another ProviderConfig", func() {
+		bastion := &infrastructurev1alpha1.InstanceStack{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "bastion"}}
+		bastion.Spec.ProviderConfigName = "other-cloud"
+		bastion.Status.Outputs = map[string]apiextensionsv1.JSON{"instanceIP": raw(`"10.0.0.5"`)}
+		c := newClient(bastion).Build()
+
+		_, err := resolveOutputRefs(context.Background(), c, app())
+		Expect(err).To(MatchError(openstack.ErrCrossTenant))
+		Expect(err.Error()).To(ContainSubstring(`InstanceStack/bastion uses ProviderConfig "other-cloud"`))
+	})
+
+	It("Should refuse secret outputs", func() {
+		bastion := &infrastructurev1alpha1.InstanceStack{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "bastion"}}
+		bastion.Status.Outputs = map[string]apiextensionsv1.JSON{"instanceIP": raw(`"[secret]"`)}
+		c := newClient(bastion).Build()
+
+		_, err := resolveOutputRefs(context.Background(), c, app())
+		Expect(err).To(MatchError(ContainSubstring("is secret")))
+	})
+})
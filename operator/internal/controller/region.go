@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+	"github.com/gunniLee/cloud-provider-operator/internal/openstack"
+)
+
+// instanceStackCredentials resolves the credentials of an InstanceStack in
+// the region it selects. Everything talking to its server must use them, as
+// the server only exists in that region.
+func instanceStackCredentials(ctx context.Context, c client.Reader, instanceStack *infrastructurev1alpha1.InstanceStack) (openstack.Credentials, error) {
+	creds, err := openstack.ResolveNamespaceCredentials(ctx, c, instanceStack.Spec.ProviderConfigName, instanceStack.Namespace)
+	if err != nil {
+		return creds, err
+	}
+	return openstack.SelectRegion(ctx, c, instanceStack.Spec.ProviderConfigName, creds, instanceStack.Spec.Region)
+}
+
+// setRegionCondition marks an InstanceStack whose spec.region is not a
+// region of its ProviderConfig.
+func (r *InstanceStackReconciler) setRegionCondition(ctx context.Context, instanceStack *infrastructurev1alpha1.InstanceStack, err error) error {
+	condition := metav1.Condition{
+		Type:               infrastructurev1alpha1.ConditionReady,
+		Status:             metav1.ConditionFalse,
+		Reason:             infrastructurev1alpha1.ReasonRegionNotAllowed,
+		Message:            err.Error(),
+		ObservedGeneration: instanceStack.Generation,
+	}
+	if !meta.SetStatusCondition(&instanceStack.Status.Conditions, condition) {
+		return nil
+	}
+	return r.Status().Update(ctx, instanceStack)
+}
@@ -0,0 +1,558 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+	"github.com/gunniLee/cloud-provider-operator/internal/engine"
+	"github.com/gunniLee/cloud-provider-operator/internal/openstack"
+)
+
+const (
+	stackFinalizer = "stack.finalizers.cloudprovider.io"
+
+	// yamlProjectName is the Pulumi project of inline and ConfigMap programs.
+	yamlProjectName = "cloud-provider-operator-yaml"
+
+	// stackReferenceIndex indexes Stacks by the ConfigMaps and Secrets they
+	// read, as "configmap/<name>" and "secret/<name>".
+	stackReferenceIndex = "spec.references"
+)
+
+var (
+	// errSourceNotFound marks program sources that do not exist (yet).
+	errSourceNotFound = errors.New("program source not found")
+	// errSourceNotAllowed marks Git sources outside of GitRoot.
+	errSourceNotAllowed = errors.New("program source not allowed")
+)
+
+// StackReconciler reconciles a Stack object
+type StackReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// WorkDir is where the Pulumi projects of Stacks are written or copied
+	// to, one directory per Stack.
+	WorkDir string
+
+	// GitRoot is the directory Git sources must be inside, e.g. the volume
+	// of a git-sync sidecar. Git sources are refused when it is empty.
+	GitRoot string
+}
+
+// +kubebuilder:rbac:groups=infrastructure.cloudprovider.io,resources=stacks,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=infrastructure.cloudprovider.io,resources=stacks/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=infrastructure.cloudprovider.io,resources=stacks/finalizers,verbs=update
+// +kubebuilder:rbac:groups="",resources=secrets;configmaps,verbs=get;list;watch
+
+func (r *StackReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	stack := &infrastructurev1alpha1.Stack{}
+	if err := r.Get(ctx, req.NamespacedName, stack); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !stack.DeletionTimestamp.IsZero() {
+		if containsString(stack.Finalizers, stackFinalizer) {
+			// InstanceStack이 출력을 참조하는 동안에는 삭제하지 않음
+			names, err := dependents(ctx, r.Client, "Stack", stack.Namespace, stack.Name)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			if len(names) > 0 {
+				log.Info("Stack is referenced by InstanceStacks, not deleting", "dependents", names)
+				meta.SetStatusCondition(&stack.Status.Conditions, metav1.Condition{
+					Type:               infrastructurev1alpha1.ConditionDeletionBlocked,
+					Status:             metav1.ConditionTrue,
+					Reason:             infrastructurev1alpha1.ReasonHasDependents,
+					Message:            fmt.Sprintf("outputs are referenced by %s", strings.Join(names, ", ")),
+					ObservedGeneration: stack.Generation,
+				})
+				return ctrl.Result{}, r.Status().Update(ctx, stack)
+			}
+			if err := r.destroy(ctx, stack); err != nil {
+				log.Error(err, "failed to destroy Pulumi stack")
+				return ctrl.Result{}, err
+			}
+			stack.Finalizers = removeString(stack.Finalizers, stackFinalizer)
+			if err := r.Update(ctx, stack); err != nil {
+				log.Error(err, "failed to remove finalizer from Stack")
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if !containsString(stack.Finalizers, stackFinalizer) {
+		stack.Finalizers = append(stack.Finalizers, stackFinalizer)
+		if err := r.Update(ctx, stack); err != nil {
+			log.Error(err, "failed to add finalizer to Stack")
+			return ctrl.Result{}, err
+		}
+	}
+
+	// 프로그램 소스를 작업 디렉토리에 준비
+	workDir, revision, err := r.prepareWorkDir(ctx, stack)
+	if err != nil {
+		if errors.Is(err, errSourceNotFound) {
+			// ConfigMap이 생기면 watch로 다시 reconcile됨
+			log.Info("Pulumi program source not found", "reason", err.Error())
+			return ctrl.Result{}, r.setReadyCondition(ctx, stack, infrastructurev1alpha1.ReasonSourceNotFound, err)
+		}
+		if errors.Is(err, errSourceNotAllowed) {
+			return ctrl.Result{}, r.setReadyCondition(ctx, stack, infrastructurev1alpha1.ReasonSourceNotAllowed, err)
+		}
+		return ctrl.Result{}, err
+	}
+
+	creds, err := openstack.ResolveNamespaceCredentials(ctx, r.Client, stack.Spec.ProviderConfigName, stack.Namespace)
+	if errors.Is(err, openstack.ErrCrossTenant) {
+		return ctrl.Result{}, r.setReadyCondition(ctx, stack, infrastructurev1alpha1.ReasonCrossTenantReference, err)
+	}
+	if err != nil {
+		log.Error(err, "failed to resolve OpenStack credentials")
+		return ctrl.Result{}, err
+	}
+	config, err := r.stackConfig(ctx, stack)
+	if err != nil {
+		return ctrl.Result{}, r.setReadyCondition(ctx, stack, infrastructurev1alpha1.ReasonUpdateFailed, err)
+	}
+	// 사용자 엔드포인트에 오퍼레이터 인증 정보가 전달되지 않도록 OpenStack 설정은 덮어쓸 수 없음
+	if err := checkReservedConfig(config); err != nil {
+		return ctrl.Result{}, r.setReadyCondition(ctx, stack, infrastructurev1alpha1.ReasonReservedConfig, err)
+	}
+	openStack, stale := openStackConfig(creds)
+	for key, value := range openStack {
+		config[key] = value
+	}
+
+	pulumiStack, err := engine.UpsertLocalStack(ctx, stackName(stack), workDir)
+	if err != nil {
+		log.Error(err, "failed to create or select Pulumi stack")
+		return ctrl.Result{}, err
+	}
+	if err := pulumiStack.SetAllConfig(ctx, config); err != nil {
+		log.Error(err, "failed to set Pulumi stack config")
+		return ctrl.Result{}, err
+	}
+	for _, key := range stale {
+		if err := pulumiStack.RemoveConfig(ctx, key); err != nil {
+			log.Error(err, "failed to remove stale Pulumi stack config", "key", key)
+			return ctrl.Result{}, err
+		}
+	}
+
+	upRes, err := pulumiStack.Up(ctx)
+	if err != nil {
+		log.Error(err, "failed to apply Pulumi stack")
+		if statusErr := r.setReadyCondition(ctx, stack, infrastructurev1alpha1.ReasonUpdateFailed, err); statusErr != nil {
+			log.Error(statusErr, "failed to update Stack status")
+		}
+		return ctrl.Result{}, err
+	}
+
+	outputs, err := statusOutputs(upRes.Outputs)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	now := metav1.Now()
+	stack.Status.Outputs = outputs
+	stack.Status.SourceRevision = revision
+	stack.Status.LastUpdateTime = &now
+	log.Info("Successfully applied Pulumi stack", "outputs", len(outputs))
+	return ctrl.Result{}, r.setReadyCondition(ctx, stack, infrastructurev1alpha1.ReasonUpdateSucceeded, nil)
+}
+
+func stackName(stack *infrastructurev1alpha1.Stack) string {
+	return fmt.Sprintf("%s-%s", stack.Namespace, stack.Name)
+}
+
+// destroy destroys the resources of the stack and removes it. Inline and
+// ConfigMap projects whose source is gone are destroyed from a bare project
+// file, which is enough because YAML programs are not run on destroy.
+func (r *StackReconciler) destroy(ctx context.Context, stack *infrastructurev1alpha1.Stack) error {
+	workDir, _, err := r.prepareWorkDir(ctx, stack)
+	if err != nil {
+		if !errors.Is(err, errSourceNotFound) && !errors.Is(err, errSourceNotAllowed) {
+			return err
+		}
+		// Git 프로젝트는 이름이 다르므로 마지막으로 복사한 프로젝트를 사용
+		workDir = r.projectDir(stack)
+		if stack.Spec.Source.Git == nil {
+			err = writeProject(workDir, []byte("{}"))
+		} else if _, statErr := os.Stat(filepath.Join(workDir, "Pulumi.yaml")); statErr != nil {
+			err = fmt.Errorf("%w: no copy of the Git project is left", err)
+		} else {
+			err = nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	pulumiStack, err := engine.UpsertLocalStack(ctx, stackName(stack), workDir)
+	if err != nil {
+		return fmt.Errorf("failed to select Pulumi stack: %w", err)
+	}
+	// 마지막 업데이트 이후 교체된 자격 증명으로 삭제하도록 현재 값을 다시 설정
+	if creds, err := openstack.ResolveNamespaceCredentials(ctx, r.Client, stack.Spec.ProviderConfigName, stack.Namespace); err != nil {
+		log.FromContext(ctx).Info("keeping the stored OpenStack config for destroy", "reason", err.Error())
+	} else {
+		config, stale := openStackConfig(creds)
+		if err := pulumiStack.SetAllConfig(ctx, config); err != nil {
+			return fmt.Errorf("failed to set Pulumi stack config: %w", err)
+		}
+		if err := pulumiStack.RemoveAllConfig(ctx, stale); err != nil {
+			return fmt.Errorf("failed to remove stale Pulumi stack config: %w", err)
+		}
+	}
+	if _, err := pulumiStack.Destroy(ctx); err != nil {
+		return fmt.Errorf("failed to destroy Pulumi stack: %w", err)
+	}
+	if err := pulumiStack.Workspace().RemoveStack(ctx, stackName(stack)); err != nil {
+		return fmt.Errorf("failed to remove Pulumi stack: %w", err)
+	}
+	return os.RemoveAll(r.projectDir(stack))
+}
+
+func (r *StackReconciler) projectDir(stack *infrastructurev1alpha1.Stack) string {
+	return filepath.Join(r.WorkDir, stack.Namespace, stack.Name)
+}
+
+// prepareWorkDir returns the Pulumi project directory of the stack and, for
+// Git sources, the checked out revision. Git projects are copied out of the
+// checkout, which may be read-only and must not receive stack settings.
+func (r *StackReconciler) prepareWorkDir(ctx context.Context, stack *infrastructurev1alpha1.Stack) (string, string, error) {
+	source := stack.Spec.Source
+	switch {
+	case source.Git != nil:
+		checkout, err := r.gitCheckout(source.Git.Path)
+		if err != nil {
+			return "", "", err
+		}
+		dir := filepath.Join(checkout, filepath.Clean("/"+source.Git.Dir))
+		if _, err := os.Stat(filepath.Join(dir, "Pulumi.yaml")); err != nil {
+			return "", "", fmt.Errorf("%w: %s has no Pulumi.yaml", errSourceNotFound, dir)
+		}
+		revision, err := gitRevision(checkout)
+		if err != nil {
+			return "", "", err
+		}
+		workDir := r.projectDir(stack)
+		return workDir, revision, copyProject(dir, workDir, stackName(stack))
+
+	case source.ConfigMapRef != nil:
+		key := source.ConfigMapRef.Key
+		if key == "" {
+			key = "Pulumi.yaml"
+		}
+		configMap := &corev1.ConfigMap{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: stack.Namespace, Name: source.ConfigMapRef.Name}, configMap); err != nil {
+			if apierrors.IsNotFound(err) {
+				return "", "", fmt.Errorf("%w: ConfigMap %q", errSourceNotFound, source.ConfigMapRef.Name)
+			}
+			return "", "", err
+		}
+		program, ok := configMap.Data[key]
+		if !ok {
+			return "", "", fmt.Errorf("%w: key %q in ConfigMap %q", errSourceNotFound, key, source.ConfigMapRef.Name)
+		}
+		dir := r.projectDir(stack)
+		return dir, "", writeProject(dir, []byte(program))
+
+	default:
+		dir := r.projectDir(stack)
+		return dir, "", writeProject(dir, []byte(source.Inline))
+	}
+}
+
+// gitCheckout resolves the path of a Git source, which must be inside
+// GitRoot once symbolic links such as the one git-sync maintains are
+// followed.
+func (r *StackReconciler) gitCheckout(path string) (string, error) {
+	if r.GitRoot == "" {
+		return "", fmt.Errorf("%w: Git sources are disabled because the operator has no Git root", errSourceNotAllowed)
+	}
+	root, err := filepath.EvalSymlinks(r.GitRoot)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve Git root: %w", err)
+	}
+	checkout, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("%w: %s", errSourceNotFound, path)
+		}
+		return "", err
+	}
+	if rel, err := filepath.Rel(root, checkout); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s is not inside %s", errSourceNotAllowed, path, r.GitRoot)
+	}
+	return checkout, nil
+}
+
+// copyProject replaces the files of dst with those of the project in src,
+// keeping the settings file of the stack, which holds its encryption salt.
+// Symbolic links and the .git directory are not copied, so that the program
+// cannot read files outside of the project.
+func copyProject(src, dst, stackName string) error {
+	if err := os.MkdirAll(dst, 0o700); err != nil {
+		return err
+	}
+	settings := "Pulumi." + stackName + ".yaml"
+	entries, err := os.ReadDir(dst)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Name() == settings {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(dst, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return filepath.WalkDir(src, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		switch {
+		case entry.IsDir() && entry.Name() == ".git":
+			return filepath.SkipDir
+		case entry.IsDir():
+			return os.MkdirAll(filepath.Join(dst, rel), 0o700)
+		case !entry.Type().IsRegular() || rel == settings:
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dst, rel), data, 0o600)
+	})
+}
+
+// writeProject writes program as the Pulumi.yaml of dir, forcing the YAML
+// runtime and the operator's project name.
+func writeProject(dir string, program []byte) error {
+	project := map[string]any{}
+	if err := yaml.Unmarshal(program, &project); err != nil {
+		return fmt.Errorf("invalid Pulumi YAML program: %w", err)
+	}
+	if project == nil {
+		project = map[string]any{}
+	}
+	project["name"] = yamlProjectName
+	project["runtime"] = "yaml"
+	data, err := yaml.Marshal(project)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, "Pulumi.yaml")
+	if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, data) {
+		return nil
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// gitRevision returns the commit checked out at path, following symbolic
+// refs, packed refs and worktree ".git" files as written by git-sync. A
+// directory that is not a checkout has no revision.
+func gitRevision(path string) (string, error) {
+	gitDir := filepath.Join(path, ".git")
+	if info, err := os.Stat(gitDir); err != nil {
+		return "", nil
+	} else if !info.IsDir() {
+		data, err := os.ReadFile(gitDir)
+		if err != nil {
+			return "", err
+		}
+		gitDir = strings.TrimSpace(strings.TrimPrefix(string(data), "gitdir:"))
+		if !filepath.IsAbs(gitDir) {
+			gitDir = filepath.Join(path, gitDir)
+		}
+	}
+
+	head, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return "", err
+	}
+	ref, ok := strings.CutPrefix(strings.TrimSpace(string(head)), "ref: ")
+	if !ok {
+		return ref, nil
+	}
+	// Worktrees keep their refs in the common directory.
+	commonDir := gitDir
+	if data, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir = filepath.Join(gitDir, strings.TrimSpace(string(data)))
+	}
+	if data, err := os.ReadFile(filepath.Join(commonDir, ref)); err == nil {
+		return strings.TrimSpace(string(data)), nil
+	}
+	packed, err := os.ReadFile(filepath.Join(commonDir, "packed-refs"))
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve %s: %w", ref, err)
+	}
+	for _, line := range strings.Split(string(packed), "\n") {
+		if sha, name, ok := strings.Cut(line, " "); ok && name == ref {
+			return sha, nil
+		}
+	}
+	return "", fmt.Errorf("unable to resolve %s", ref)
+}
+
+// stackConfig collects the plain and Secret backed config of the stack.
+func (r *StackReconciler) stackConfig(ctx context.Context, stack *infrastructurev1alpha1.Stack) (auto.ConfigMap, error) {
+	config := auto.ConfigMap{}
+	for key, value := range stack.Spec.Config {
+		config[key] = auto.ConfigValue{Value: value}
+	}
+	for _, item := range stack.Spec.SecretConfig {
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: stack.Namespace, Name: item.SecretKeyRef.Name}, secret); err != nil {
+			return nil, fmt.Errorf("failed to get Secret %q for config %q: %w", item.SecretKeyRef.Name, item.Key, err)
+		}
+		value, ok := secret.Data[item.SecretKeyRef.Key]
+		if !ok {
+			return nil, fmt.Errorf("Secret %q has no key %q for config %q", item.SecretKeyRef.Name, item.SecretKeyRef.Key, item.Key)
+		}
+		config[item.Key] = auto.ConfigValue{Value: string(value), Secret: true}
+	}
+	return config, nil
+}
+
+// checkReservedConfig refuses "openstack:" config keys. The operator passes
+// the credentials of the ProviderConfig as openstack:* config, and a user
+// endpoint or project mixed with them would hand those credentials out.
+func checkReservedConfig(config auto.ConfigMap) error {
+	var keys []string
+	for key := range config {
+		if strings.HasPrefix(key, "openstack:") {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	sort.Strings(keys)
+	return fmt.Errorf("config %s is set from the ProviderConfig and may not be overridden", strings.Join(keys, ", "))
+}
+
+// statusOutputs converts stack outputs for the status, masking secrets.
+func statusOutputs(outputs auto.OutputMap) (map[string]apiextensionsv1.JSON, error) {
+	if len(outputs) == 0 {
+		return nil, nil
+	}
+	result := make(map[string]apiextensionsv1.JSON, len(outputs))
+	for name, output := range outputs {
+		value := output.Value
+		if output.Secret {
+			value = infrastructurev1alpha1.MaskedOutputValue
+		}
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode output %q: %w", name, err)
+		}
+		result[name] = apiextensionsv1.JSON{Raw: raw}
+	}
+	return result, nil
+}
+
+// setReadyCondition records the outcome of the last reconcile.
+func (r *StackReconciler) setReadyCondition(ctx context.Context, stack *infrastructurev1alpha1.Stack, reason string, err error) error {
+	condition := metav1.Condition{
+		Type:               infrastructurev1alpha1.ConditionReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             reason,
+		Message:            "stack is up to date",
+		ObservedGeneration: stack.Generation,
+	}
+	if err != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Message = err.Error()
+	}
+	meta.SetStatusCondition(&stack.Status.Conditions, condition)
+	return r.Status().Update(ctx, stack)
+}
+
+// stackReferences lists the ConfigMaps and Secrets a Stack reads.
+func stackReferences(obj client.Object) []string {
+	stack := obj.(*infrastructurev1alpha1.Stack)
+	var refs []string
+	if stack.Spec.Source.ConfigMapRef != nil {
+		refs = append(refs, "configmap/"+stack.Spec.Source.ConfigMapRef.Name)
+	}
+	for _, item := range stack.Spec.SecretConfig {
+		refs = append(refs, "secret/"+item.SecretKeyRef.Name)
+	}
+	return refs
+}
+
+// requestsForReferences maps a ConfigMap or Secret to the Stacks reading it.
+func (r *StackReconciler) requestsForReferences(kind string) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		stacks := &infrastructurev1alpha1.StackList{}
+		if err := r.List(ctx, stacks, client.InNamespace(obj.GetNamespace()),
+			client.MatchingFields{stackReferenceIndex: kind + "/" + obj.GetName()}); err != nil {
+			log.FromContext(ctx).Error(err, "failed to list Stacks referencing object", "kind", kind, "name", obj.GetName())
+			return nil
+		}
+		requests := make([]reconcile.Request, 0, len(stacks.Items))
+		for _, stack := range stacks.Items {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&stack)})
+		}
+		return requests
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *StackReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &infrastructurev1alpha1.Stack{},
+		stackReferenceIndex, stackReferences); err != nil {
+		return err
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&infrastructurev1alpha1.Stack{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&corev1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(r.requestsForReferences("configmap"))).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.requestsForReferences("secret"))).
+		Watches(&infrastructurev1alpha1.InstanceStack{}, handler.EnqueueRequestsFromMapFunc(requestsForReferencedStacks)).
+		Watches(&infrastructurev1alpha1.TenantBinding{}, handler.EnqueueRequestsFromMapFunc(requestsForTenantBinding(mgr.GetClient(),
+			func() client.ObjectList { return &infrastructurev1alpha1.StackList{} }))).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(requestsForCredentialSecret(mgr.GetClient(),
+			func() client.ObjectList { return &infrastructurev1alpha1.StackList{} }))).
+		Named("stack").
+		Complete(r)
+}
@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"sigs.k8s.io/yaml"
+)
+
+var _ = Describe("Stack Controller", func() {
+	Context("When writing an inline program", func() {
+		It("Should force the project name and YAML runtime", func() {
+			dir := GinkgoT().TempDir()
+			Expect(writeProject(dir, []byte("name: mine\nruntime: nodejs\nresources: {}\n"))).To(Succeed())
+
+			data, err := os.ReadFile(filepath.Join(dir, "Pulumi.yaml"))
+			Expect(err).NotTo(HaveOccurred())
+			project := map[string]any{}
+			Expect(yaml.Unmarshal(data, &project)).To(Succeed())
+			Expect(project).To(HaveKeyWithValue("name", yamlProjectName))
+			Expect(project).To(HaveKeyWithValue("runtime", "yaml"))
+			Expect(project).To(HaveKey("resources"))
+		})
+	})
+
+	Context("When reading a Git checkout", func() {
+		It("Should resolve symbolic and packed refs", func() {
+			checkout := GinkgoT().TempDir()
+			gitDir := filepath.Join(checkout, ".git")
+			Expect(os.MkdirAll(filepath.Join(gitDir, "refs", "heads"), 0o755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(gitDir, "HEAD"), []byte("ref: refs/heads/main\n"), 0o644)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(gitDir, "packed-refs"),
+				[]byte("# pack-refs with: peeled\n3f2a1b refs/heads/main\n"), 0o644)).To(Succeed())
+
+			revision, err := gitRevision(checkout)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(revision).To(Equal("3f2a1b"))
+
+			Expect(os.WriteFile(filepath.Join(gitDir, "refs", "heads", "main"), []byte("9c8d7e\n"), 0o644)).To(Succeed())
+			revision, err = gitRevision(checkout)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(revision).To(Equal("9c8d7e"))
+		})
+	})
+
+	Context("When running a Git project", func() {
+		It("Should only accept checkouts inside the Git root", func() {
+			root := GinkgoT().TempDir()
+			checkout := filepath.Join(root, "repo")
+			Expect(os.MkdirAll(filepath.Join(root, ".worktrees", "3f2a1b"), 0o755)).To(Succeed())
+			Expect(os.Symlink(filepath.Join(root, ".worktrees", "3f2a1b"), checkout)).To(Succeed())
+
+			r := &StackReconciler{}
+			_, err := r.gitCheckout(checkout)
+			Expect(err).To(MatchError(errSourceNotAllowed))
+
+			r.GitRoot = root
+			resolved, err := r.gitCheckout(checkout)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resolved).To(HaveSuffix(filepath.Join(".worktrees", "3f2a1b")))
+
+			_, err = r.gitCheckout(GinkgoT().TempDir())
+			Expect(err).To(MatchError(errSourceNotAllowed))
+			_, err = r.gitCheckout(filepath.Join(root, "missing"))
+			Expect(err).To(MatchError(errSourceNotFound))
+		})
+
+		It("Should copy the project without links, Git data or stale files", func() {
+			src, dst := GinkgoT().TempDir(), GinkgoT().TempDir()
+			Expect(os.MkdirAll(filepath.Join(src, ".git"), 0o755)).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(src, "files"), 0o755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(src, "Pulumi.yaml"), []byte("name: net\nruntime: yaml\n"), 0o644)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(src, "files", "cloud-init.yaml"), []byte("#cloud-config\n"), 0o644)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(src, ".git", "HEAD"), []byte("ref: refs/heads/main\n"), 0o644)).To(Succeed())
+			Expect(os.Symlink("/etc/hostname", filepath.Join(src, "hostname"))).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(dst, "Pulumi.default-net.yaml"), []byte("encryptionsalt: abc\n"), 0o600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(dst, "removed.yaml"), []byte("{}"), 0o600)).To(Succeed())
+
+			Expect(copyProject(src, dst, "default-net")).To(Succeed())
+			Expect(filepath.Join(dst, "Pulumi.yaml")).To(BeARegularFile())
+			Expect(filepath.Join(dst, "files", "cloud-init.yaml")).To(BeARegularFile())
+			Expect(filepath.Join(dst, "Pulumi.default-net.yaml")).To(BeARegularFile())
+			Expect(filepath.Join(dst, "removed.yaml")).NotTo(BeAnExistingFile())
+			Expect(filepath.Join(dst, "hostname")).NotTo(BeAnExistingFile())
+			Expect(filepath.Join(dst, ".git")).NotTo(BeAnExistingFile())
+		})
+	})
+
+	Context("When collecting config", func() {
+		It("Should refuse openstack config keys", func() {
+			Expect(checkReservedConfig(auto.ConfigMap{"flavor": {Value: "m1.small"}})).To(Succeed())
+			Expect(checkReservedConfig(auto.ConfigMap{
+				"openstack:authUrl":  {Value: "https://keystone.example.com/v3"},
+				"openstack:password": {Value: "secret", Secret: true},
+			})).To(MatchError(ContainSubstring("config openstack:authUrl, openstack:password is set from the ProviderConfig")))
+		})
+	})
+
+	Context("When recording outputs", func() {
+		It("Should mask secret outputs", func() {
+			outputs, err := statusOutputs(auto.OutputMap{
+				"networkID": {Value: "net-1"},
+				"password":  {Value: "hunter2", Secret: true},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(outputs["networkID"].Raw)).To(Equal(`"net-1"`))
+			Expect(string(outputs["password"].Raw)).To(Equal(`"[secret]"`))
+		})
+	})
+})
@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+)
+
+// setCrossTenantCondition marks an InstanceStack whose ProviderConfig is not
+// the one its namespace is bound to.
+func (r *InstanceStackReconciler) setCrossTenantCondition(ctx context.Context, instanceStack *infrastructurev1alpha1.InstanceStack, err error) error {
+	condition := metav1.Condition{
+		Type:               infrastructurev1alpha1.ConditionReady,
+		Status:             metav1.ConditionFalse,
+		Reason:             infrastructurev1alpha1.ReasonCrossTenantReference,
+		Message:            err.Error(),
+		ObservedGeneration: instanceStack.Generation,
+	}
+	if !meta.SetStatusCondition(&instanceStack.Status.Conditions, condition) {
+		return nil
+	}
+	return r.Status().Update(ctx, instanceStack)
+}
+
+// requestsForTenantBinding maps a changed TenantBinding to the objects of its
+// namespaces, listed with newList, so they pick up the new credentials or
+// stop being refused.
+func requestsForTenantBinding(c client.Reader, newList func() client.ObjectList) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		binding := obj.(*infrastructurev1alpha1.TenantBinding)
+		var requests []reconcile.Request
+		for _, namespace := range binding.Spec.Namespaces {
+			list := newList()
+			if err := c.List(ctx, list, client.InNamespace(namespace)); err != nil {
+				log.FromContext(ctx).Error(err, "failed to list objects of a bound namespace", "namespace", namespace)
+				continue
+			}
+			items, err := meta.ExtractList(list)
+			if err != nil {
+				continue
+			}
+			for _, item := range items {
+				if o, ok := item.(client.Object); ok {
+					requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: o.GetNamespace(), Name: o.GetName()}})
+				}
+			}
+		}
+		return requests
+	}
+}
@@ -0,0 +1,168 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+	"github.com/gunniLee/cloud-provider-operator/internal/openstack"
+)
+
+// tenantBindingVerifyInterval is how often the application credential of a
+// TenantBinding is verified again, e.g. in case it was revoked.
+const tenantBindingVerifyInterval = 10 * time.Minute
+
+// TenantBindingReconciler verifies that the application credential of a
+// TenantBinding is scoped to its project. Only Ready bindings are used for
+// provisioning, so a credential of another project is never used.
+type TenantBindingReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// NewClient authenticates against OpenStack. Defaults to
+	// openstack.NewClient.
+	NewClient func(ctx context.Context, creds openstack.Credentials) (*openstack.Client, error)
+}
+
+// +kubebuilder:rbac:groups=infrastructure.cloudprovider.io,resources=tenantbindings,verbs=get;list;watch
+// +kubebuilder:rbac:groups=infrastructure.cloudprovider.io,resources=tenantbindings/status,verbs=get;update;patch
+
+func (r *TenantBindingReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	binding := &infrastructurev1alpha1.TenantBinding{}
+	if err := r.Get(ctx, req.NamespacedName, binding); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	original := binding.Status.DeepCopy()
+
+	projectID, reason, verifyErr := r.verify(ctx, binding)
+	condition := metav1.Condition{
+		Type:               infrastructurev1alpha1.ConditionReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             infrastructurev1alpha1.ReasonCredentialVerified,
+		Message:            fmt.Sprintf("application credential is scoped to project %s", binding.Spec.ProjectName),
+		ObservedGeneration: binding.Generation,
+	}
+	if verifyErr != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = reason
+		condition.Message = verifyErr.Error()
+		log.Info("TenantBinding is not usable", "reason", reason, "message", verifyErr.Error())
+	}
+	binding.Status.ProjectID = projectID
+	meta.SetStatusCondition(&binding.Status.Conditions, condition)
+	if !equality.Semantic.DeepEqual(original, &binding.Status) {
+		if err := r.Status().Update(ctx, binding); err != nil {
+			log.Error(err, "failed to update TenantBinding status")
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{RequeueAfter: tenantBindingVerifyInterval}, nil
+}
+
+// verify checks that no other binding claims the namespaces and that the
+// application credential authenticates into the bound project. It returns
+// the project ID, or the Ready reason and the error.
+func (r *TenantBindingReconciler) verify(ctx context.Context, binding *infrastructurev1alpha1.TenantBinding) (string, string, error) {
+	for _, namespace := range binding.Spec.Namespaces {
+		owner, err := openstack.FindTenantBinding(ctx, r.Client, namespace)
+		if err != nil {
+			return "", infrastructurev1alpha1.ReasonInvalidCredential, err
+		}
+		if owner != nil && owner.Name != binding.Name {
+			return "", infrastructurev1alpha1.ReasonNamespaceConflict,
+				fmt.Errorf("namespace %s is already bound by TenantBinding %s", namespace, owner.Name)
+		}
+	}
+
+	creds, err := openstack.TenantCredentials(ctx, r.Client, binding)
+	if err != nil {
+		return "", infrastructurev1alpha1.ReasonInvalidCredential, err
+	}
+	newClient := r.NewClient
+	if newClient == nil {
+		newClient = openstack.NewClient
+	}
+	osClient, err := newClient(ctx, creds)
+	if err != nil {
+		return "", infrastructurev1alpha1.ReasonInvalidCredential, err
+	}
+
+	// 다른 프로젝트의 application credential은 거부
+	domain := binding.Spec.ProjectDomainName
+	if domain == "" {
+		domain = openstack.DefaultDomain
+	}
+	if osClient.ProjectName() != binding.Spec.ProjectName || (osClient.ProjectDomainName() != "" && osClient.ProjectDomainName() != domain) {
+		return "", infrastructurev1alpha1.ReasonProjectMismatch,
+			fmt.Errorf("%w: application credential is scoped to project %s in domain %s, not %s in domain %s",
+				openstack.ErrCrossTenant, osClient.ProjectName(), osClient.ProjectDomainName(), binding.Spec.ProjectName, domain)
+	}
+	return osClient.ProjectID(), "", nil
+}
+
+// requestsForCredentialSecret maps a Secret to the TenantBindings using it.
+func (r *TenantBindingReconciler) requestsForCredentialSecret(ctx context.Context, obj client.Object) []reconcile.Request {
+	bindings := &infrastructurev1alpha1.TenantBindingList{}
+	if err := r.List(ctx, bindings); err != nil {
+		log.FromContext(ctx).Error(err, "failed to list TenantBindings")
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, binding := range bindings.Items {
+		ref := binding.Spec.ApplicationCredentialSecretRef
+		if ref.Namespace == obj.GetNamespace() && ref.Name == obj.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: binding.Name}})
+		}
+	}
+	return requests
+}
+
+// requestsForOverlappingBindings maps a TenantBinding to the others sharing
+// one of its namespaces, so a conflict clears once either side changes.
+func (r *TenantBindingReconciler) requestsForOverlappingBindings(ctx context.Context, obj client.Object) []reconcile.Request {
+	changed := obj.(*infrastructurev1alpha1.TenantBinding)
+	bindings := &infrastructurev1alpha1.TenantBindingList{}
+	if err := r.List(ctx, bindings); err != nil {
+		log.FromContext(ctx).Error(err, "failed to list TenantBindings")
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, binding := range bindings.Items {
+		if binding.Name == changed.Name {
+			continue
+		}
+		for _, namespace := range binding.Spec.Namespaces {
+			if slices.Contains(changed.Spec.Namespaces, namespace) {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: binding.Name}})
+				break
+			}
+		}
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *TenantBindingReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&infrastructurev1alpha1.TenantBinding{}).
+		Watches(&infrastructurev1alpha1.TenantBinding{}, handler.EnqueueRequestsFromMapFunc(r.requestsForOverlappingBindings)).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.requestsForCredentialSecret)).
+		Named("tenantbinding").
+		Complete(r)
+}
@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+	"github.com/gunniLee/cloud-provider-operator/internal/openstack"
+	"github.com/gunniLee/cloud-provider-operator/internal/openstack/openstacktest"
+)
+
+var _ = Describe("TenantBinding", func() {
+	ctx := context.Background()
+
+	var (
+		cloud *openstacktest.Server
+		c     client.Client
+	)
+
+	newBinding := func(name, project string, namespaces ...string) *infrastructurev1alpha1.TenantBinding {
+		return &infrastructurev1alpha1.TenantBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: infrastructurev1alpha1.TenantBindingSpec{
+				Namespaces:                     namespaces,
+				ProjectName:                    project,
+				ProjectDomainName:              "Default",
+				ApplicationCredentialSecretRef: infrastructurev1alpha1.SecretReference{Namespace: "system", Name: "team-a"},
+			},
+		}
+	}
+
+	reconcile := func(name string) *infrastructurev1alpha1.TenantBinding {
+		r := &TenantBindingReconciler{Client: c, Scheme: c.Scheme()}
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: name}})
+		Expect(err).NotTo(HaveOccurred())
+		binding := &infrastructurev1alpha1.TenantBinding{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: name}, binding)).To(Succeed())
+		return binding
+	}
+
+	BeforeEach(func() {
+		cloud = openstacktest.NewServer()
+		DeferCleanup(cloud.Close)
+		cloud.ApplicationCredentials = map[string]openstacktest.ApplicationCredential{
+			"cred-a": {Secret: "s3cret", ProjectName: "team-a"},
+		}
+
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(infrastructurev1alpha1.AddToScheme(scheme)).To(Succeed())
+		providerConfig := &infrastructurev1alpha1.ProviderConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: infrastructurev1alpha1.DefaultProviderConfigName},
+			Spec: infrastructurev1alpha1.ProviderConfigSpec{
+				AuthURL: cloud.Credentials().AuthURL, Region: openstacktest.Region, TenantName: "admin",
+			},
+		}
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "system", Name: "team-a"},
+			Data: map[string][]byte{
+				infrastructurev1alpha1.ApplicationCredentialIDKey:     []byte("cred-a"),
+				infrastructurev1alpha1.ApplicationCredentialSecretKey: []byte("s3cret"),
+			},
+		}
+		c = fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(providerConfig, secret).
+			WithStatusSubresource(&infrastructurev1alpha1.TenantBinding{}).
+			Build()
+	})
+
+	It("Should verify an application credential of the bound project", func() {
+		Expect(c.Create(ctx, newBinding("team-a", "team-a", "team-a"))).To(Succeed())
+
+		binding := reconcile("team-a")
+		Expect(meta.IsStatusConditionTrue(binding.Status.Conditions, infrastructurev1alpha1.ConditionReady)).To(BeTrue())
+		Expect(binding.Status.ProjectID).To(Equal(openstacktest.ProjectID))
+
+		By("resolving the application credential for the bound namespace")
+		creds, err := openstack.ResolveNamespaceCredentials(ctx, c, "", "team-a")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(creds.ApplicationCredentialID).To(Equal("cred-a"))
+		Expect(creds.Username).To(BeEmpty())
+		Expect(creds.TenantName).To(Equal("team-a"))
+
+		By("refusing another ProviderConfig in the bound namespace")
+		_, err = openstack.ResolveNamespaceCredentials(ctx, c, "other", "team-a")
+		Expect(err).To(MatchError(openstack.ErrCrossTenant))
+	})
+
+	It("Should refuse an application credential of another project", func() {
+		Expect(c.Create(ctx, newBinding("team-b", "team-b", "team-b"))).To(Succeed())
+
+		binding := reconcile("team-b")
+		ready := meta.FindStatusCondition(binding.Status.Conditions, infrastructurev1alpha1.ConditionReady)
+		Expect(ready.Status).To(Equal(metav1.ConditionFalse))
+		Expect(ready.Reason).To(Equal(infrastructurev1alpha1.ReasonProjectMismatch))
+		Expect(ready.Message).To(ContainSubstring("scoped to project team-a"))
+
+		_, err := openstack.ResolveNamespaceCredentials(ctx, c, "", "team-b")
+		Expect(err).To(MatchError(ContainSubstring("TenantBinding team-b is not ready")))
+	})
+
+	It("Should report a namespace bound twice", func() {
+		Expect(c.Create(ctx, newBinding("a-first", "team-a", "shared"))).To(Succeed())
+		Expect(c.Create(ctx, newBinding("b-second", "team-a", "shared"))).To(Succeed())
+
+		Expect(meta.IsStatusConditionTrue(reconcile("a-first").Status.Conditions, infrastructurev1alpha1.ConditionReady)).To(BeTrue())
+		ready := meta.FindStatusCondition(reconcile("b-second").Status.Conditions, infrastructurev1alpha1.ConditionReady)
+		Expect(ready.Reason).To(Equal(infrastructurev1alpha1.ReasonNamespaceConflict))
+	})
+
+	It("Should keep using the ProviderConfig credentials in unbound namespaces", func() {
+		creds, err := openstack.ResolveNamespaceCredentials(ctx, c, "", "unbound")
+		Expect(err).To(MatchError(ContainSubstring("username or password")))
+		Expect(creds.UsesApplicationCredential()).To(BeFalse())
+	})
+})
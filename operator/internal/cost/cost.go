@@ -0,0 +1,106 @@
+// Package cost estimates what InstanceStacks cost from the PriceList of
+// their ProviderConfig and exports the per-namespace totals as metrics.
+package cost
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+	"github.com/gunniLee/cloud-provider-operator/internal/program"
+)
+
+// FindPriceList returns the PriceList of a ProviderConfig, the first by
+// name when several match, or nil when there is none.
+func FindPriceList(ctx context.Context, c client.Reader, providerConfigName string) (*infrastructurev1alpha1.PriceList, error) {
+	if providerConfigName == "" {
+		providerConfigName = infrastructurev1alpha1.DefaultProviderConfigName
+	}
+	priceLists := &infrastructurev1alpha1.PriceListList{}
+	if err := c.List(ctx, priceLists); err != nil {
+		return nil, err
+	}
+	sort.Slice(priceLists.Items, func(i, j int) bool { return priceLists.Items[i].Name < priceLists.Items[j].Name })
+	for i := range priceLists.Items {
+		name := priceLists.Items[i].Spec.ProviderConfigName
+		if name == "" {
+			name = infrastructurev1alpha1.DefaultProviderConfigName
+		}
+		if name == providerConfigName {
+			return &priceLists.Items[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// Hourly estimates the hourly cost of an InstanceStack's spec. Resources
+// without a rate are left out and returned as unpriced.
+func Hourly(prices infrastructurev1alpha1.PriceListSpec, instanceStack *infrastructurev1alpha1.InstanceStack) (float64, []string) {
+	prog, params, errs := program.Resolve(instanceStack)
+	if prog == nil || len(errs) > 0 {
+		return 0, nil
+	}
+	var hourly float64
+	var unpriced []string
+	if machineType := prog.MachineType(instanceStack); machineType != "" {
+		if rate, ok := prices.Flavors[machineType]; ok {
+			hourly += rate.AsApproximateFloat64()
+		} else if prog.Provider == infrastructurev1alpha1.ProviderAWS {
+			unpriced = append(unpriced, "instance type "+machineType)
+		} else {
+			unpriced = append(unpriced, "flavor "+machineType)
+		}
+	}
+	if prog.FloatingIPParameter != "" && params.String(prog.FloatingIPParameter) != "" {
+		if prices.FloatingIP != nil {
+			hourly += prices.FloatingIP.AsApproximateFloat64()
+		} else {
+			unpriced = append(unpriced, "floating IP")
+		}
+	}
+	if prog.VolumeSizeParameter != "" {
+		var volumeType string
+		if prog.VolumeTypeParameter != "" {
+			volumeType = params.String(prog.VolumeTypeParameter)
+		}
+		if rate := volumeRate(prices, volumeType); rate != nil {
+			hourly += rate.AsApproximateFloat64() * float64(params.Int(prog.VolumeSizeParameter))
+		} else if volumeType != "" {
+			unpriced = append(unpriced, "volume type "+volumeType)
+		} else {
+			unpriced = append(unpriced, "volume")
+		}
+	}
+	return hourly, unpriced
+}
+
+// volumeRate returns the per-GiB rate of a volume type, falling back to the
+// generic volume rate.
+func volumeRate(prices infrastructurev1alpha1.PriceListSpec, volumeType string) *resource.Quantity {
+	if rate, ok := prices.VolumeTypes[volumeType]; ok && volumeType != "" {
+		return &rate
+	}
+	return prices.VolumeGiB
+}
+
+// Format renders an amount the way it is stored in status.
+func Format(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 4, 64)
+}
+
+// FormatDelta renders a signed difference, e.g. "+0.1200".
+func FormatDelta(delta float64) string {
+	return fmt.Sprintf("%+.4f", delta)
+}
+
+// Parse reads back an amount written by Format. Empty or malformed amounts
+// are zero.
+func Parse(amount string) float64 {
+	v, _ := strconv.ParseFloat(amount, 64)
+	return v
+}
@@ -0,0 +1,127 @@
+package cost
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+)
+
+var _ = Describe("Cost", func() {
+	raw := func(v string) apiextensionsv1.JSON { return apiextensionsv1.JSON{Raw: []byte(v)} }
+	rate := func(v string) *resource.Quantity { q := resource.MustParse(v); return &q }
+
+	prices := infrastructurev1alpha1.PriceListSpec{
+		Currency:    "USD",
+		Flavors:     map[string]resource.Quantity{"m1.small": resource.MustParse("0.023"), "t3.micro": resource.MustParse("0.0104")},
+		VolumeGiB:   rate("0.0001"),
+		VolumeTypes: map[string]resource.Quantity{"ssd": resource.MustParse("0.0002")},
+		FloatingIP:  rate("0.005"),
+	}
+
+	server := func(flavor string, params map[string]apiextensionsv1.JSON) *infrastructurev1alpha1.InstanceStack {
+		return &infrastructurev1alpha1.InstanceStack{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+			Spec:       infrastructurev1alpha1.InstanceStackSpec{FlavorName: flavor, Parameters: params},
+		}
+	}
+
+	volume := func(params map[string]apiextensionsv1.JSON) *infrastructurev1alpha1.InstanceStack {
+		return &infrastructurev1alpha1.InstanceStack{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "data"},
+			Spec:       infrastructurev1alpha1.InstanceStackSpec{Program: "volume", Parameters: params},
+		}
+	}
+
+	It("Should price servers by flavor and floating IP", func() {
+		hourly, unpriced := Hourly(prices, server("m1.small", nil))
+		Expect(Format(hourly)).To(Equal("0.0230"))
+		Expect(unpriced).To(BeEmpty())
+
+		hourly, _ = Hourly(prices, server("m1.small", map[string]apiextensionsv1.JSON{"floatingIPPool": raw(`"public"`)}))
+		Expect(Format(hourly)).To(Equal("0.0280"))
+	})
+
+	It("Should price EC2 instances by instance type and leave libvirt domains unpriced", func() {
+		ec2 := func(instanceType string) *infrastructurev1alpha1.InstanceStack {
+			return &infrastructurev1alpha1.InstanceStack{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+				Spec: infrastructurev1alpha1.InstanceStackSpec{
+					Program: "ec2-instance",
+					AWS:     &infrastructurev1alpha1.AWSInstanceSpec{InstanceType: instanceType, AMI: "ami-0123456789abcdef0"},
+				},
+			}
+		}
+		hourly, unpriced := Hourly(prices, ec2("t3.micro"))
+		Expect(Format(hourly)).To(Equal("0.0104"))
+		Expect(unpriced).To(BeEmpty())
+
+		_, unpriced = Hourly(prices, ec2("m7g.large"))
+		Expect(unpriced).To(ConsistOf("instance type m7g.large"))
+
+		hourly, unpriced = Hourly(prices, &infrastructurev1alpha1.InstanceStack{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "dev"},
+			Spec: infrastructurev1alpha1.InstanceStackSpec{
+				Program: "libvirt-domain",
+				Libvirt: &infrastructurev1alpha1.LibvirtInstanceSpec{Image: "https://example.com/jammy.qcow2"},
+			},
+		})
+		Expect(hourly).To(BeZero())
+		Expect(unpriced).To(BeEmpty())
+	})
+
+	It("Should price volumes per GiB by type", func() {
+		hourly, _ := Hourly(prices, volume(map[string]apiextensionsv1.JSON{"sizeGiB": raw(`100`)}))
+		Expect(Format(hourly)).To(Equal("0.0100"))
+
+		hourly, _ = Hourly(prices, volume(map[string]apiextensionsv1.JSON{"sizeGiB": raw(`100`), "volumeType": raw(`"ssd"`)}))
+		Expect(Format(hourly)).To(Equal("0.0200"))
+	})
+
+	It("Should report resources without a rate", func() {
+		hourly, unpriced := Hourly(infrastructurev1alpha1.PriceListSpec{}, server("m1.huge", map[string]apiextensionsv1.JSON{"floatingIPPool": raw(`"public"`)}))
+		Expect(hourly).To(BeZero())
+		Expect(unpriced).To(ConsistOf("flavor m1.huge", "floating IP"))
+
+		_, unpriced = Hourly(infrastructurev1alpha1.PriceListSpec{}, volume(map[string]apiextensionsv1.JSON{"sizeGiB": raw(`10`), "volumeType": raw(`"ssd"`)}))
+		Expect(unpriced).To(ConsistOf("volume type ssd"))
+	})
+
+	It("Should find the PriceList of a ProviderConfig", func() {
+		scheme := runtime.NewScheme()
+		Expect(infrastructurev1alpha1.AddToScheme(scheme)).To(Succeed())
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			&infrastructurev1alpha1.PriceList{ObjectMeta: metav1.ObjectMeta{Name: "b-default"}},
+			&infrastructurev1alpha1.PriceList{ObjectMeta: metav1.ObjectMeta{Name: "a-default"}},
+			&infrastructurev1alpha1.PriceList{
+				ObjectMeta: metav1.ObjectMeta{Name: "other"},
+				Spec:       infrastructurev1alpha1.PriceListSpec{ProviderConfigName: "other"},
+			},
+		).Build()
+
+		priceList, err := FindPriceList(context.Background(), c, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(priceList.Name).To(Equal("a-default"))
+
+		priceList, err = FindPriceList(context.Background(), c, "other")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(priceList.Name).To(Equal("other"))
+
+		priceList, err = FindPriceList(context.Background(), c, "missing")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(priceList).To(BeNil())
+	})
+
+	It("Should format deltas with a sign", func() {
+		Expect(FormatDelta(0.12)).To(Equal("+0.1200"))
+		Expect(FormatDelta(-0.5)).To(Equal("-0.5000"))
+		Expect(Parse("")).To(BeZero())
+	})
+})
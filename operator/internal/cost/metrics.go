@@ -0,0 +1,41 @@
+package cost
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+)
+
+var (
+	namespaceHourly = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cloudprovider_namespace_estimated_hourly_cost",
+		Help: "Estimated hourly cost of the InstanceStacks of a namespace.",
+	}, []string{"namespace", "currency"})
+	namespaceMonthly = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cloudprovider_namespace_estimated_monthly_cost",
+		Help: "Estimated monthly cost of the InstanceStacks of a namespace.",
+	}, []string{"namespace", "currency"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(namespaceHourly, namespaceMonthly)
+}
+
+// RecordNamespace sets the cost metrics of a namespace to the sum of the
+// estimates in the status of its InstanceStacks, one series per currency.
+func RecordNamespace(namespace string, instanceStacks []infrastructurev1alpha1.InstanceStack) {
+	totals := map[string]float64{}
+	for _, instanceStack := range instanceStacks {
+		if instanceStack.Status.Cost == nil || !instanceStack.DeletionTimestamp.IsZero() {
+			continue
+		}
+		totals[instanceStack.Status.Cost.Currency] += Parse(instanceStack.Status.Cost.Hourly)
+	}
+	namespaceHourly.DeletePartialMatch(prometheus.Labels{"namespace": namespace})
+	namespaceMonthly.DeletePartialMatch(prometheus.Labels{"namespace": namespace})
+	for currency, hourly := range totals {
+		namespaceHourly.WithLabelValues(namespace, currency).Set(hourly)
+		namespaceMonthly.WithLabelValues(namespace, currency).Set(hourly * infrastructurev1alpha1.HoursPerMonth)
+	}
+}
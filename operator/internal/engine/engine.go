@@ -0,0 +1,104 @@
+// Package engine runs the Pulumi programs of InstanceStacks. The operator
+// uses the Pulumi Automation API; package enginetest provides an in-memory
+// engine for tests that must not reach a cloud or the Pulumi CLI.
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optdestroy"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optpreview"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optremove"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optup"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// ProjectName is the Pulumi project of every InstanceStack stack.
+const ProjectName = "cloud-provider-operator"
+
+// passphrase encrypts the secrets of every stack, InstanceStacks and Stacks
+// alike.
+const passphrase = "cloud1234"
+
+// ErrStackNotFound is wrapped by SelectStack errors about a stack that does
+// not exist.
+var ErrStackNotFound = errors.New("stack not found")
+
+// Engine opens the Pulumi stacks of InstanceStacks.
+type Engine interface {
+	// UpsertStack creates the stack or selects the existing one.
+	UpsertStack(ctx context.Context, stackName string, program pulumi.RunFunc) (Stack, error)
+	// SelectStack selects an existing stack. A missing stack is reported
+	// with an error wrapping ErrStackNotFound.
+	SelectStack(ctx context.Context, stackName string, program pulumi.RunFunc) (Stack, error)
+}
+
+// Stack is the part of a Pulumi stack the controllers use.
+type Stack interface {
+	GetConfig(ctx context.Context, key string) (auto.ConfigValue, error)
+	SetConfig(ctx context.Context, key string, val auto.ConfigValue) error
+	SetAllConfig(ctx context.Context, config auto.ConfigMap) error
+	RemoveAllConfig(ctx context.Context, keys []string) error
+
+	Preview(ctx context.Context, opts ...optpreview.Option) (auto.PreviewResult, error)
+	Up(ctx context.Context, opts ...optup.Option) (auto.UpResult, error)
+	Destroy(ctx context.Context, opts ...optdestroy.Option) (auto.DestroyResult, error)
+
+	Export(ctx context.Context) (apitype.UntypedDeployment, error)
+	Import(ctx context.Context, state apitype.UntypedDeployment) error
+
+	// Remove deletes the stack, even when it still has resources.
+	Remove(ctx context.Context) error
+}
+
+// Pulumi runs stacks with the Pulumi Automation API and the passphrase
+// secrets provider.
+type Pulumi struct{}
+
+var _ Engine = Pulumi{}
+
+func (Pulumi) UpsertStack(ctx context.Context, stackName string, program pulumi.RunFunc) (Stack, error) {
+	usePassphrase()
+
+	stack, err := auto.UpsertStackInlineSource(ctx, stackName, ProjectName, program, auto.SecretsProvider("passphrase"))
+	if err != nil {
+		return nil, err
+	}
+	return pulumiStack{&stack}, nil
+}
+
+// UpsertLocalStack creates or selects the stack of the Pulumi project in
+// workDir with the same secrets provider as the InstanceStack stacks.
+func UpsertLocalStack(ctx context.Context, stackName, workDir string) (auto.Stack, error) {
+	usePassphrase()
+	return auto.UpsertStackLocalSource(ctx, stackName, workDir, auto.SecretsProvider("passphrase"))
+}
+
+// usePassphrase makes the passphrase secrets provider use passphrase.
+func usePassphrase() {
+	os.Setenv("PULUMI_CONFIG_PASSPHRASE", passphrase)
+}
+
+func (Pulumi) SelectStack(ctx context.Context, stackName string, program pulumi.RunFunc) (Stack, error) {
+	stack, err := auto.SelectStackInlineSource(ctx, stackName, ProjectName, program)
+	if auto.IsSelectStack404Error(err) {
+		return nil, fmt.Errorf("%w: %w", ErrStackNotFound, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pulumiStack{&stack}, nil
+}
+
+type pulumiStack struct {
+	*auto.Stack
+}
+
+func (s pulumiStack) Remove(ctx context.Context) error {
+	return s.Workspace().RemoveStack(ctx, s.Name(), optremove.Force())
+}
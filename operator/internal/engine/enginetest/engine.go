@@ -0,0 +1,314 @@
+// Package enginetest provides an in-memory fake of the Pulumi engine, so
+// that controller tests can create, update and delete InstanceStacks without
+// a cloud or the Pulumi CLI.
+package enginetest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optdestroy"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optpreview"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optup"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/tokens"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+
+	"github.com/gunniLee/cloud-provider-operator/internal/engine"
+)
+
+// Engine is a fake engine keeping its stacks in memory. Programs are run
+// against Pulumi mocks, which echo the inputs of every resource as its
+// outputs, so a program that fails to register its resources fails Up.
+type Engine struct {
+	mu     sync.Mutex
+	stacks map[string]*Stack
+	hosts  int
+}
+
+var _ engine.Engine = &Engine{}
+
+// NewEngine returns an Engine without stacks.
+func NewEngine() *Engine {
+	return &Engine{stacks: map[string]*Stack{}}
+}
+
+func (e *Engine) UpsertStack(_ context.Context, stackName string, program pulumi.RunFunc) (engine.Stack, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	stack, ok := e.stacks[stackName]
+	if !ok {
+		e.hosts++
+		stack = &Stack{
+			engine:  e,
+			name:    stackName,
+			address: fmt.Sprintf("10.0.%d.%d", e.hosts/250, e.hosts%250+2),
+			config:  auto.ConfigMap{},
+		}
+		e.stacks[stackName] = stack
+	}
+	stack.program = program
+	return stack, nil
+}
+
+func (e *Engine) SelectStack(_ context.Context, stackName string, program pulumi.RunFunc) (engine.Stack, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	stack, ok := e.stacks[stackName]
+	if !ok {
+		return nil, fmt.Errorf("%w: no stack named '%s' found", engine.ErrStackNotFound, stackName)
+	}
+	stack.program = program
+	return stack, nil
+}
+
+// Stack returns the stack named stackName, or nil.
+func (e *Engine) Stack(stackName string) *Stack {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.stacks[stackName]
+}
+
+// Resource is a resource of a fake stack.
+type Resource struct {
+	Type    string
+	Name    string
+	ID      string
+	Inputs  resource.PropertyMap
+	Protect bool
+}
+
+// Stack is a stack of the fake engine.
+type Stack struct {
+	engine  *Engine
+	name    string
+	address string
+	program pulumi.RunFunc
+
+	config    auto.ConfigMap
+	resources []Resource
+	outputs   auto.OutputMap
+	updates   int
+}
+
+var _ engine.Stack = &Stack{}
+
+// Config returns the config of the stack.
+func (s *Stack) Config() auto.ConfigMap {
+	s.engine.mu.Lock()
+	defer s.engine.mu.Unlock()
+	config := auto.ConfigMap{}
+	for key, value := range s.config {
+		config[key] = value
+	}
+	return config
+}
+
+// Resources returns the resources created by the last Up.
+func (s *Stack) Resources() []Resource {
+	s.engine.mu.Lock()
+	defer s.engine.mu.Unlock()
+	return append([]Resource(nil), s.resources...)
+}
+
+// Resource returns the resource of the given type, or nil.
+func (s *Stack) Resource(typeToken string) *Resource {
+	for _, res := range s.Resources() {
+		if res.Type == typeToken {
+			return &res
+		}
+	}
+	return nil
+}
+
+// Updates returns how many times Up succeeded.
+func (s *Stack) Updates() int {
+	s.engine.mu.Lock()
+	defer s.engine.mu.Unlock()
+	return s.updates
+}
+
+func (s *Stack) GetConfig(_ context.Context, key string) (auto.ConfigValue, error) {
+	s.engine.mu.Lock()
+	defer s.engine.mu.Unlock()
+	value, ok := s.config[key]
+	if !ok {
+		return auto.ConfigValue{}, fmt.Errorf("configuration key '%s' not found for stack '%s'", key, s.name)
+	}
+	return value, nil
+}
+
+func (s *Stack) SetConfig(_ context.Context, key string, val auto.ConfigValue) error {
+	s.engine.mu.Lock()
+	defer s.engine.mu.Unlock()
+	s.config[key] = val
+	return nil
+}
+
+func (s *Stack) SetAllConfig(_ context.Context, config auto.ConfigMap) error {
+	s.engine.mu.Lock()
+	defer s.engine.mu.Unlock()
+	for key, value := range config {
+		s.config[key] = value
+	}
+	return nil
+}
+
+func (s *Stack) RemoveAllConfig(_ context.Context, keys []string) error {
+	s.engine.mu.Lock()
+	defer s.engine.mu.Unlock()
+	for _, key := range keys {
+		delete(s.config, key)
+	}
+	return nil
+}
+
+// Preview reports no changes. The event streams are closed like the real
+// engine does once the preview is over.
+func (s *Stack) Preview(_ context.Context, opts ...optpreview.Option) (auto.PreviewResult, error) {
+	options := &optpreview.Options{}
+	for _, opt := range opts {
+		opt.ApplyOption(options)
+	}
+	for _, events := range options.EventStreams {
+		close(events)
+	}
+	return auto.PreviewResult{ChangeSummary: map[apitype.OpType]int{}}, nil
+}
+
+// Up runs the program against mocks and replaces the resources of the
+// stack. Resources keep their IDs across updates. The outputs are those of a
+// server: instanceIP, an address of the stack, and serverID, the ID of the
+// first resource.
+func (s *Stack) Up(_ context.Context, _ ...optup.Option) (auto.UpResult, error) {
+	s.engine.mu.Lock()
+	program, previous := s.program, map[string]string{}
+	for _, res := range s.resources {
+		previous[res.Type+"::"+res.Name] = res.ID
+	}
+	s.engine.mu.Unlock()
+
+	mocks := &mocks{previous: previous}
+	if err := pulumi.RunErr(program, pulumi.WithMocks(engine.ProjectName, s.name, mocks)); err != nil {
+		return auto.UpResult{}, fmt.Errorf("update failed: %w", err)
+	}
+
+	s.engine.mu.Lock()
+	defer s.engine.mu.Unlock()
+	s.resources = mocks.resources
+	s.outputs = auto.OutputMap{}
+	if len(s.resources) > 0 {
+		s.outputs["instanceIP"] = auto.OutputValue{Value: s.address}
+		s.outputs["serverID"] = auto.OutputValue{Value: s.resources[0].ID}
+	}
+	s.updates++
+	return auto.UpResult{Outputs: s.outputs, Summary: auto.UpdateSummary{Result: "succeeded"}}, nil
+}
+
+// Destroy deletes the resources of the stack. Like Pulumi, it refuses to
+// delete protected resources.
+func (s *Stack) Destroy(_ context.Context, _ ...optdestroy.Option) (auto.DestroyResult, error) {
+	s.engine.mu.Lock()
+	defer s.engine.mu.Unlock()
+	for _, res := range s.resources {
+		if res.Protect {
+			return auto.DestroyResult{}, fmt.Errorf("resource %s is protected", res.Name)
+		}
+	}
+	s.resources, s.outputs = nil, nil
+	return auto.DestroyResult{Summary: auto.UpdateSummary{Result: "succeeded"}}, nil
+}
+
+// Export returns a checkpoint of the resources of the stack.
+func (s *Stack) Export(_ context.Context) (apitype.UntypedDeployment, error) {
+	s.engine.mu.Lock()
+	defer s.engine.mu.Unlock()
+	deployment := apitype.DeploymentV3{}
+	for _, res := range s.resources {
+		deployment.Resources = append(deployment.Resources, apitype.ResourceV3{
+			URN:     s.urn(res),
+			Custom:  true,
+			ID:      resource.ID(res.ID),
+			Type:    tokens.Type(res.Type),
+			Inputs:  res.Inputs.Mappable(),
+			Outputs: res.Inputs.Mappable(),
+			Protect: res.Protect,
+		})
+	}
+	raw, err := json.Marshal(deployment)
+	if err != nil {
+		return apitype.UntypedDeployment{}, err
+	}
+	return apitype.UntypedDeployment{Version: 3, Deployment: raw}, nil
+}
+
+// Import applies the protect flags of a checkpoint to the stack.
+func (s *Stack) Import(_ context.Context, state apitype.UntypedDeployment) error {
+	var deployment apitype.DeploymentV3
+	if err := json.Unmarshal(state.Deployment, &deployment); err != nil {
+		return err
+	}
+	s.engine.mu.Lock()
+	defer s.engine.mu.Unlock()
+	protect := map[resource.URN]bool{}
+	for _, res := range deployment.Resources {
+		protect[res.URN] = res.Protect
+	}
+	for i := range s.resources {
+		s.resources[i].Protect = protect[s.urn(s.resources[i])]
+	}
+	return nil
+}
+
+// Remove forgets the stack, keeping its resources like a forced removal.
+func (s *Stack) Remove(_ context.Context) error {
+	s.engine.mu.Lock()
+	defer s.engine.mu.Unlock()
+	delete(s.engine.stacks, s.name)
+	return nil
+}
+
+func (s *Stack) urn(res Resource) resource.URN {
+	return resource.URN(fmt.Sprintf("urn:pulumi:%s::%s::%s::%s", s.name, engine.ProjectName, res.Type, res.Name))
+}
+
+// mocks records the resources a program registers.
+type mocks struct {
+	mu        sync.Mutex
+	previous  map[string]string
+	resources []Resource
+}
+
+func (m *mocks) NewResource(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !args.Custom || strings.HasPrefix(args.TypeToken, "pulumi:providers:") {
+		return args.Name + "-id", args.Inputs, nil
+	}
+	id := args.ID
+	if id == "" && args.RegisterRPC != nil {
+		id = args.RegisterRPC.GetImportId()
+	}
+	if id == "" {
+		id = m.previous[args.TypeToken+"::"+args.Name]
+	}
+	if id == "" {
+		id = uuid.NewString()
+	}
+	res := Resource{Type: args.TypeToken, Name: args.Name, ID: id, Inputs: args.Inputs}
+	if args.RegisterRPC != nil {
+		res.Protect = args.RegisterRPC.GetProtect()
+	}
+	m.resources = append(m.resources, res)
+	return id, args.Inputs, nil
+}
+
+func (m *mocks) Call(args pulumi.MockCallArgs) (resource.PropertyMap, error) {
+	return args.Args, nil
+}
@@ -0,0 +1,125 @@
+// Package machineclass resolves the MachineClass of an InstanceStack to the
+// concrete settings of its ProviderConfig. It is shared by the admission
+// webhook, which rejects classes that cannot be resolved, and the controller.
+package machineclass
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+)
+
+// ErrNoMapping is wrapped by errors about a MachineClass without a mapping
+// for the ProviderConfig of an InstanceStack.
+var ErrNoMapping = errors.New("no mapping for ProviderConfig")
+
+// Resolve returns a copy of instanceStack with the empty flavor, image,
+// network, spec.aws and spec.libvirt fields filled from its MachineClass,
+// and the settings used. InstanceStacks without spec.machineClassName are
+// returned as they are. A missing MachineClass is reported as a NotFound
+// error.
+func Resolve(ctx context.Context, c client.Reader, instanceStack *infrastructurev1alpha1.InstanceStack) (*infrastructurev1alpha1.InstanceStack, *infrastructurev1alpha1.MachineStatus, error) {
+	name := instanceStack.Spec.MachineClassName
+	if name == "" {
+		return instanceStack, nil, nil
+	}
+	machineClass := &infrastructurev1alpha1.MachineClass{}
+	if err := c.Get(ctx, types.NamespacedName{Name: name}, machineClass); err != nil {
+		return nil, nil, fmt.Errorf("failed to get MachineClass %q: %w", name, err)
+	}
+	mapping := Mapping(machineClass, instanceStack.Spec.ProviderConfigName)
+	if mapping == nil {
+		return nil, nil, fmt.Errorf("%w: MachineClass %q does not map ProviderConfig %q",
+			ErrNoMapping, name, providerConfigOrDefault(instanceStack.Spec.ProviderConfigName))
+	}
+
+	resolved := instanceStack.DeepCopy()
+	spec := &resolved.Spec
+	if spec.FlavorName == "" {
+		spec.FlavorName = mapping.FlavorName
+	}
+	if spec.ImageName == "" {
+		spec.ImageName = mapping.ImageName
+	}
+	if spec.NetworkUUID == "" {
+		spec.NetworkUUID = mapping.NetworkUUID
+	}
+	if spec.AWS == nil && mapping.AWS != nil {
+		spec.AWS = mapping.AWS.DeepCopy()
+	}
+	if spec.Libvirt == nil && mapping.Libvirt != nil {
+		spec.Libvirt = mapping.Libvirt.DeepCopy()
+	}
+	machine := &infrastructurev1alpha1.MachineStatus{
+		ClassName:   name,
+		FlavorName:  spec.FlavorName,
+		ImageName:   spec.ImageName,
+		NetworkUUID: spec.NetworkUUID,
+	}
+	if spec.AWS != nil {
+		machine.AWS = spec.AWS.DeepCopy()
+	}
+	if spec.Libvirt != nil {
+		machine.Libvirt = spec.Libvirt.DeepCopy()
+	}
+	return resolved, machine, nil
+}
+
+// Mapping returns the mapping of a MachineClass for a ProviderConfig, or nil.
+func Mapping(machineClass *infrastructurev1alpha1.MachineClass, providerConfigName string) *infrastructurev1alpha1.MachineMapping {
+	providerConfigName = providerConfigOrDefault(providerConfigName)
+	for i := range machineClass.Spec.Mappings {
+		if machineClass.Spec.Mappings[i].ProviderConfigName == providerConfigName {
+			return &machineClass.Spec.Mappings[i]
+		}
+	}
+	return nil
+}
+
+// ReplacedFields returns the settings that differ between two resolutions of
+// the same MachineClass and would replace the server, like the fields the
+// webhook keeps immutable without the allow-replacement annotation.
+func ReplacedFields(previous, next *infrastructurev1alpha1.MachineStatus) []string {
+	if previous == nil || next == nil || previous.ClassName != next.ClassName {
+		return nil
+	}
+	var fields []string
+	if previous.ImageName != next.ImageName {
+		fields = append(fields, "imageName")
+	}
+	if previous.NetworkUUID != next.NetworkUUID {
+		fields = append(fields, "networkUUID")
+	}
+	if oldAWS, newAWS := previous.AWS, next.AWS; oldAWS != nil && newAWS != nil {
+		if oldAWS.AMI != newAWS.AMI {
+			fields = append(fields, "aws.ami")
+		}
+		if oldAWS.SubnetID != newAWS.SubnetID {
+			fields = append(fields, "aws.subnetID")
+		}
+		if oldAWS.KeyName != newAWS.KeyName {
+			fields = append(fields, "aws.keyName")
+		}
+	}
+	if oldLibvirt, newLibvirt := previous.Libvirt, next.Libvirt; oldLibvirt != nil && newLibvirt != nil {
+		if oldLibvirt.Image != newLibvirt.Image {
+			fields = append(fields, "libvirt.image")
+		}
+		if oldLibvirt.Pool != newLibvirt.Pool {
+			fields = append(fields, "libvirt.pool")
+		}
+	}
+	return fields
+}
+
+func providerConfigOrDefault(name string) string {
+	if name == "" {
+		return infrastructurev1alpha1.DefaultProviderConfigName
+	}
+	return name
+}
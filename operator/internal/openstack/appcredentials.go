@@ -0,0 +1,74 @@
+package openstack
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// ApplicationCredential is a Keystone application credential. Secret is
+// only returned when the credential is created.
+type ApplicationCredential struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Secret string `json:"secret,omitempty"`
+}
+
+// ApplicationCredentialRequest describes a credential to create for the
+// authenticated user. Roles restrict it to a subset of the user's roles on
+// the project; empty means all of them.
+type ApplicationCredentialRequest struct {
+	Name        string
+	Description string
+	Roles       []string
+	ExpiresAt   time.Time
+	// Unrestricted allows the credential to create and delete other
+	// application credentials, which rotation with it requires.
+	Unrestricted bool
+}
+
+// CreateApplicationCredential creates an application credential for the
+// authenticated user, scoped to the project of the token.
+func (c *Client) CreateApplicationCredential(ctx context.Context, req ApplicationCredentialRequest) (*ApplicationCredential, error) {
+	base, err := c.endpoint(ServiceIdentity, "/v3")
+	if err != nil {
+		return nil, err
+	}
+	body := map[string]any{
+		"name":         req.Name,
+		"description":  req.Description,
+		"unrestricted": req.Unrestricted,
+	}
+	if !req.ExpiresAt.IsZero() {
+		body["expires_at"] = req.ExpiresAt.UTC().Format("2006-01-02T15:04:05")
+	}
+	if len(req.Roles) > 0 {
+		roles := make([]map[string]string, 0, len(req.Roles))
+		for _, role := range req.Roles {
+			roles = append(roles, map[string]string{"name": role})
+		}
+		body["roles"] = roles
+	}
+	var out struct {
+		ApplicationCredential ApplicationCredential `json:"application_credential"`
+	}
+	if err := c.do(ctx, http.MethodPost, base+"/users/"+c.userID+"/application_credentials",
+		map[string]any{"application_credential": body}, &out); err != nil {
+		return nil, err
+	}
+	return &out.ApplicationCredential, nil
+}
+
+// DeleteApplicationCredential deletes an application credential of the
+// authenticated user. Deleting one that no longer exists is not an error.
+func (c *Client) DeleteApplicationCredential(ctx context.Context, id string) error {
+	base, err := c.endpoint(ServiceIdentity, "/v3")
+	if err != nil {
+		return err
+	}
+	err = c.do(ctx, http.MethodDelete, base+"/users/"+c.userID+"/application_credentials/"+id, nil, nil)
+	if IsNotFound(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,162 @@
+package openstack
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// Flavor is a Nova flavor.
+type Flavor struct {
+	ID       string            `json:"id"`
+	Name     string            `json:"name"`
+	VCPUs    int               `json:"vcpus"`
+	RAM      int               `json:"ram"`
+	Disk     int               `json:"disk"`
+	IsPublic bool              `json:"os-flavor-access:is_public"`
+	Extra    map[string]string `json:"extra_specs,omitempty"`
+}
+
+// Image is a Glance v2 image. Properties holds the custom image properties,
+// which Glance returns as top-level keys.
+type Image struct {
+	ID         string            `json:"id"`
+	Name       string            `json:"name"`
+	Status     string            `json:"status"`
+	Visibility string            `json:"visibility"`
+	MinDisk    int               `json:"min_disk"`
+	MinRAM     int               `json:"min_ram"`
+	Size       int64             `json:"size"`
+	Properties map[string]string `json:"-"`
+}
+
+// Network is a Neutron network.
+type Network struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Shared   bool   `json:"shared"`
+	External bool   `json:"router:external"`
+}
+
+// AvailabilityZone is a Nova availability zone.
+type AvailabilityZone struct {
+	Name      string
+	Available bool
+}
+
+// ListFlavors lists all flavors visible to the project.
+func (c *Client) ListFlavors(ctx context.Context) ([]Flavor, error) {
+	base, err := c.endpoint(ServiceCompute, "")
+	if err != nil {
+		return nil, err
+	}
+	var out struct {
+		Flavors []Flavor `json:"flavors"`
+	}
+	if err := c.do(ctx, http.MethodGet, base+"/flavors/detail?is_public=None", nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Flavors, nil
+}
+
+// imageFields are the Glance keys decoded into Image; everything else with a
+// string value is treated as a custom property.
+var imageFields = map[string]bool{
+	"id": true, "name": true, "status": true, "visibility": true, "min_disk": true, "min_ram": true,
+	"size": true, "checksum": true, "created_at": true, "updated_at": true, "file": true, "schema": true,
+	"self": true, "owner": true, "protected": true, "tags": true, "virtual_size": true, "direct_url": true,
+	"locations": true, "os_hash_algo": true, "os_hash_value": true, "os_hidden": true,
+}
+
+// ListImages lists all images visible to the project, following pagination.
+func (c *Client) ListImages(ctx context.Context) ([]Image, error) {
+	base, err := c.endpoint(ServiceImage, "")
+	if err != nil {
+		return nil, err
+	}
+	root := strings.TrimSuffix(base, "/v2")
+
+	var images []Image
+	next := "/v2/images?limit=500"
+	for next != "" {
+		var page struct {
+			Images []map[string]any `json:"images"`
+			Next   string           `json:"next"`
+		}
+		if err := c.do(ctx, http.MethodGet, root+next, nil, &page); err != nil {
+			return nil, err
+		}
+		for _, raw := range page.Images {
+			images = append(images, decodeImage(raw))
+		}
+		next = page.Next
+	}
+	return images, nil
+}
+
+func decodeImage(raw map[string]any) Image {
+	str := func(key string) string {
+		s, _ := raw[key].(string)
+		return s
+	}
+	num := func(key string) float64 {
+		n, _ := raw[key].(float64)
+		return n
+	}
+	image := Image{
+		ID:         str("id"),
+		Name:       str("name"),
+		Status:     str("status"),
+		Visibility: str("visibility"),
+		MinDisk:    int(num("min_disk")),
+		MinRAM:     int(num("min_ram")),
+		Size:       int64(num("size")),
+		Properties: map[string]string{},
+	}
+	for key, value := range raw {
+		if s, ok := value.(string); ok && !imageFields[key] {
+			image.Properties[key] = s
+		}
+	}
+	return image
+}
+
+// ListNetworks lists all networks visible to the project.
+func (c *Client) ListNetworks(ctx context.Context) ([]Network, error) {
+	base, err := c.endpoint(ServiceNetwork, "/v2.0")
+	if err != nil {
+		return nil, err
+	}
+	var out struct {
+		Networks []Network `json:"networks"`
+	}
+	if err := c.do(ctx, http.MethodGet, base+"/networks", nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Networks, nil
+}
+
+// ListAvailabilityZones lists the compute availability zones.
+func (c *Client) ListAvailabilityZones(ctx context.Context) ([]AvailabilityZone, error) {
+	base, err := c.endpoint(ServiceCompute, "")
+	if err != nil {
+		return nil, err
+	}
+	var out struct {
+		Zones []struct {
+			ZoneName  string `json:"zoneName"`
+			ZoneState struct {
+				Available bool `json:"available"`
+			} `json:"zoneState"`
+		} `json:"availabilityZoneInfo"`
+	}
+	if err := c.do(ctx, http.MethodGet, base+"/os-availability-zone", nil, &out); err != nil {
+		return nil, err
+	}
+	zones := make([]AvailabilityZone, 0, len(out.Zones))
+	for _, z := range out.Zones {
+		zones = append(zones, AvailabilityZone{Name: z.ZoneName, Available: z.ZoneState.Available})
+	}
+	return zones, nil
+}
@@ -0,0 +1,282 @@
+// Package openstack is a minimal OpenStack REST client covering the calls the
+// operator makes outside of Pulumi: catalog listing, quotas and server actions.
+package openstack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Service types as they appear in the Keystone service catalog.
+const (
+	ServiceCompute  = "compute"
+	ServiceImage    = "image"
+	ServiceNetwork  = "network"
+	ServiceIdentity = "identity"
+)
+
+// Client is an authenticated session against one project and region.
+type Client struct {
+	httpClient *http.Client
+	creds      Credentials
+
+	token             string
+	userID            string
+	projectID         string
+	projectName       string
+	projectDomainName string
+	endpoints         map[string]string
+}
+
+// NewClient authenticates with Keystone using password auth scoped to the
+// tenant and resolves the public endpoints for the region.
+func NewClient(ctx context.Context, creds Credentials) (*Client, error) {
+	if err := creds.Validate(); err != nil {
+		return nil, err
+	}
+	tlsConfig, err := creds.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	c := &Client{
+		httpClient: &http.Client{Transport: transport, Timeout: 30 * time.Second},
+		creds:      creds,
+	}
+	if err := c.authenticate(ctx); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ProjectID is the ID of the project the token is scoped to.
+func (c *Client) ProjectID() string {
+	return c.projectID
+}
+
+// ProjectName is the name of the project the token is scoped to.
+func (c *Client) ProjectName() string {
+	return c.projectName
+}
+
+// ProjectDomainName is the Keystone domain of the project the token is
+// scoped to.
+func (c *Client) ProjectDomainName() string {
+	return c.projectDomainName
+}
+
+type authRequest struct {
+	Auth struct {
+		Identity struct {
+			Methods               []string               `json:"methods"`
+			Password              *passwordAuth          `json:"password,omitempty"`
+			ApplicationCredential *applicationCredential `json:"application_credential,omitempty"`
+		} `json:"identity"`
+		// Scope is left out for application credentials, which are bound to
+		// their project.
+		Scope *projectScope `json:"scope,omitempty"`
+	} `json:"auth"`
+}
+
+type passwordAuth struct {
+	User struct {
+		Name     string `json:"name"`
+		Password string `json:"password"`
+		Domain   struct {
+			Name string `json:"name"`
+		} `json:"domain"`
+	} `json:"user"`
+}
+
+type applicationCredential struct {
+	ID     string `json:"id"`
+	Secret string `json:"secret"`
+}
+
+type projectScope struct {
+	Project struct {
+		Name   string `json:"name"`
+		Domain struct {
+			Name string `json:"name"`
+		} `json:"domain"`
+	} `json:"project"`
+}
+
+type authResponse struct {
+	Token struct {
+		User struct {
+			ID string `json:"id"`
+		} `json:"user"`
+		Project struct {
+			ID     string `json:"id"`
+			Name   string `json:"name"`
+			Domain struct {
+				Name string `json:"name"`
+			} `json:"domain"`
+		} `json:"project"`
+		Catalog []struct {
+			Type      string `json:"type"`
+			Endpoints []struct {
+				Interface string `json:"interface"`
+				Region    string `json:"region"`
+				RegionID  string `json:"region_id"`
+				URL       string `json:"url"`
+			} `json:"endpoints"`
+		} `json:"catalog"`
+	} `json:"token"`
+}
+
+func (c *Client) authenticate(ctx context.Context) error {
+	var req authRequest
+	if c.creds.UsesApplicationCredential() {
+		req.Auth.Identity.Methods = []string{"application_credential"}
+		req.Auth.Identity.ApplicationCredential = &applicationCredential{
+			ID:     c.creds.ApplicationCredentialID,
+			Secret: c.creds.ApplicationCredentialSecret,
+		}
+	} else {
+		// The user is looked up in the domain of the project.
+		domain := c.creds.ProjectDomainName
+		if domain == "" {
+			domain = DefaultDomain
+		}
+		req.Auth.Identity.Methods = []string{"password"}
+		req.Auth.Identity.Password = &passwordAuth{}
+		req.Auth.Identity.Password.User.Name = c.creds.Username
+		req.Auth.Identity.Password.User.Password = c.creds.Password
+		req.Auth.Identity.Password.User.Domain.Name = domain
+		req.Auth.Scope = &projectScope{}
+		req.Auth.Scope.Project.Name = c.creds.TenantName
+		req.Auth.Scope.Project.Domain.Name = domain
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	url := strings.TrimSuffix(c.creds.AuthURL, "/") + "/auth/tokens"
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to authenticate with Keystone: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return statusError(resp)
+	}
+
+	var authResp authResponse
+	if err := json.NewDecoder(resp.Body).Decode(&authResp); err != nil {
+		return fmt.Errorf("failed to decode Keystone token: %w", err)
+	}
+	c.token = resp.Header.Get("X-Subject-Token")
+	c.userID = authResp.Token.User.ID
+	c.projectID = authResp.Token.Project.ID
+	c.projectName = authResp.Token.Project.Name
+	c.projectDomainName = authResp.Token.Project.Domain.Name
+	c.endpoints = map[string]string{}
+	for _, service := range authResp.Token.Catalog {
+		for _, ep := range service.Endpoints {
+			if ep.Interface != "public" {
+				continue
+			}
+			if ep.Region != c.creds.Region && ep.RegionID != c.creds.Region {
+				continue
+			}
+			c.endpoints[service.Type] = strings.TrimSuffix(ep.URL, "/")
+		}
+	}
+	return nil
+}
+
+// endpoint returns the base URL of a service, with versionPrefix appended
+// unless the catalog entry already ends with it.
+func (c *Client) endpoint(serviceType, versionPrefix string) (string, error) {
+	base, ok := c.endpoints[serviceType]
+	if !ok {
+		return "", fmt.Errorf("no public %s endpoint in region %s", serviceType, c.creds.Region)
+	}
+	if versionPrefix != "" && !strings.HasSuffix(base, versionPrefix) {
+		base += versionPrefix
+	}
+	return base, nil
+}
+
+// do sends a request to a service and decodes the JSON response into out,
+// which may be nil.
+func (c *Client) do(ctx context.Context, method, url string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Auth-Token", c.token)
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return statusError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response of %s %s: %w", method, url, err)
+	}
+	return nil
+}
+
+// StatusError is returned for non-2xx responses.
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %s: unexpected status %d: %s", e.Method, e.URL, e.StatusCode, e.Body)
+}
+
+// IsNotFound reports whether err is a 404 response.
+func IsNotFound(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound
+}
+
+func statusError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return &StatusError{
+		Method:     resp.Request.Method,
+		URL:        resp.Request.URL.String(),
+		StatusCode: resp.StatusCode,
+		Body:       strings.TrimSpace(string(data)),
+	}
+}
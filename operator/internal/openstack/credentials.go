@@ -0,0 +1,274 @@
+package openstack
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+)
+
+// DefaultDomain is the Keystone domain used when no domain is configured.
+const DefaultDomain = "Default"
+
+// Credentials are the settings needed to talk to an OpenStack cloud, both
+// for the REST client in this package and for the Pulumi provider config.
+type Credentials struct {
+	AuthURL    string
+	Username   string
+	Password   string
+	TenantName string
+	Region     string
+	Insecure   bool
+
+	// ProjectDomainName is the Keystone domain of TenantName and, with
+	// password authentication, of Username. Defaults to DefaultDomain.
+	ProjectDomainName string
+
+	// ApplicationCredentialID and ApplicationCredentialSecret replace the
+	// username and password when set. The credential is bound to its
+	// project, so TenantName is informational.
+	ApplicationCredentialID     string
+	ApplicationCredentialSecret string
+
+	// CACert is a PEM bundle trusted in addition to the system roots.
+	// ClientCert and ClientKey are a PEM client certificate and its key.
+	CACert     string
+	ClientCert string
+	ClientKey  string
+}
+
+// TLSConfig is the TLS configuration for connecting to the OpenStack APIs.
+func (c Credentials) TLSConfig() (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.Insecure {
+		config.InsecureSkipVerify = true //nolint:gosec
+	}
+	if c.CACert != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM([]byte(c.CACert)) {
+			return nil, fmt.Errorf("CA bundle contains no PEM certificates")
+		}
+		config.RootCAs = pool
+	}
+	if c.ClientCert != "" || c.ClientKey != "" {
+		cert, err := tls.X509KeyPair([]byte(c.ClientCert), []byte(c.ClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// UsesApplicationCredential reports whether the credentials authenticate
+// with an application credential instead of a password.
+func (c Credentials) UsesApplicationCredential() bool {
+	return c.ApplicationCredentialID != ""
+}
+
+// CredentialsFromEnv reads the OPENSTACK_* variables set on the manager.
+func CredentialsFromEnv() Credentials {
+	insecure, _ := strconv.ParseBool(os.Getenv("OPENSTACK_INSECURE"))
+	return Credentials{
+		AuthURL:    os.Getenv("OPENSTACK_AUTH_URL"),
+		Username:   os.Getenv("OPENSTACK_USERNAME"),
+		Password:   os.Getenv("OPENSTACK_PASSWORD"),
+		TenantName: os.Getenv("OPENSTACK_TENANT_NAME"),
+		Region:     os.Getenv("OPENSTACK_REGION"),
+		Insecure:   insecure,
+	}
+}
+
+// Validate reports the first required setting that is missing.
+func (c Credentials) Validate() error {
+	switch {
+	case c.AuthURL == "":
+		return fmt.Errorf("missing OpenStack auth URL")
+	case c.UsesApplicationCredential() && c.ApplicationCredentialSecret == "":
+		return fmt.Errorf("missing OpenStack application credential secret")
+	case !c.UsesApplicationCredential() && (c.Username == "" || c.Password == ""):
+		return fmt.Errorf("missing OpenStack username or password")
+	case !c.UsesApplicationCredential() && c.TenantName == "":
+		return fmt.Errorf("missing OpenStack tenant name")
+	case c.Region == "":
+		return fmt.Errorf("missing OpenStack region")
+	}
+	_, err := c.TLSConfig()
+	return err
+}
+
+// ResolveCredentials builds the credentials for the named ProviderConfig.
+// Fields the ProviderConfig leaves empty are taken from the environment, and a
+// missing "default" ProviderConfig means the environment is used as is.
+func ResolveCredentials(ctx context.Context, c client.Reader, providerConfigName string) (Credentials, error) {
+	providerConfig, err := getProviderConfig(ctx, c, providerConfigName)
+	if err != nil {
+		return CredentialsFromEnv(), err
+	}
+	if providerConfig == nil {
+		creds := CredentialsFromEnv()
+		return creds, creds.Validate()
+	}
+	return ResolveProviderConfigCredentials(ctx, c, providerConfig)
+}
+
+// getProviderConfig returns the named ProviderConfig, or nil if the
+// "default" one does not exist.
+func getProviderConfig(ctx context.Context, c client.Reader, providerConfigName string) (*infrastructurev1alpha1.ProviderConfig, error) {
+	if providerConfigName == "" {
+		providerConfigName = infrastructurev1alpha1.DefaultProviderConfigName
+	}
+	providerConfig := &infrastructurev1alpha1.ProviderConfig{}
+	if err := c.Get(ctx, types.NamespacedName{Name: providerConfigName}, providerConfig); err != nil {
+		if apierrors.IsNotFound(err) && providerConfigName == infrastructurev1alpha1.DefaultProviderConfigName {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get ProviderConfig %q: %w", providerConfigName, err)
+	}
+	return providerConfig, nil
+}
+
+// ResolveProviderConfigCredentials is ResolveCredentials for an already
+// fetched ProviderConfig.
+func ResolveProviderConfigCredentials(ctx context.Context, c client.Reader, providerConfig *infrastructurev1alpha1.ProviderConfig) (Credentials, error) {
+	creds, err := specCredentials(ctx, c, providerConfig)
+	if err != nil {
+		return creds, err
+	}
+	return creds, creds.Validate()
+}
+
+// ResolvePasswordCredentials returns the username and password credentials
+// of a ProviderConfig, ignoring its managed application credential. They are
+// only used to create and rotate that credential.
+func ResolvePasswordCredentials(ctx context.Context, c client.Reader, providerConfig *infrastructurev1alpha1.ProviderConfig) (Credentials, error) {
+	creds, err := passwordCredentials(ctx, c, providerConfig)
+	if err != nil {
+		return creds, err
+	}
+	return creds, creds.Validate()
+}
+
+// ResolveConnection returns the connection settings of a ProviderConfig,
+// including its TLS configuration, without any user credentials.
+func ResolveConnection(ctx context.Context, c client.Reader, providerConfig *infrastructurev1alpha1.ProviderConfig) (Credentials, error) {
+	creds, err := connectionCredentials(ctx, c, providerConfig)
+	if err != nil {
+		return creds, err
+	}
+	_, err = creds.TLSConfig()
+	return creds, err
+}
+
+// specCredentials returns the credentials of a ProviderConfig. With a
+// managed application credential, the password is never handed out.
+func specCredentials(ctx context.Context, c client.Reader, providerConfig *infrastructurev1alpha1.ProviderConfig) (Credentials, error) {
+	policy := providerConfig.Spec.ApplicationCredential
+	if policy == nil {
+		return passwordCredentials(ctx, c, providerConfig)
+	}
+	creds, err := connectionCredentials(ctx, c, providerConfig)
+	if err != nil {
+		return creds, err
+	}
+	ref := policy.SecretRef
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return creds, fmt.Errorf("application credential of ProviderConfig %q has not been created yet", providerConfig.Name)
+		}
+		return creds, fmt.Errorf("failed to get application credential Secret %s/%s: %w", ref.Namespace, ref.Name, err)
+	}
+	creds.ApplicationCredentialID = string(secret.Data[infrastructurev1alpha1.ApplicationCredentialIDKey])
+	creds.ApplicationCredentialSecret = string(secret.Data[infrastructurev1alpha1.ApplicationCredentialSecretKey])
+	if !creds.UsesApplicationCredential() {
+		return creds, fmt.Errorf("application credential Secret %s/%s has no %s", ref.Namespace, ref.Name, infrastructurev1alpha1.ApplicationCredentialIDKey)
+	}
+	return creds, nil
+}
+
+func passwordCredentials(ctx context.Context, c client.Reader, providerConfig *infrastructurev1alpha1.ProviderConfig) (Credentials, error) {
+	creds, err := connectionCredentials(ctx, c, providerConfig)
+	if err != nil {
+		return creds, err
+	}
+	creds.Username = os.Getenv("OPENSTACK_USERNAME")
+	creds.Password = os.Getenv("OPENSTACK_PASSWORD")
+	if ref := providerConfig.Spec.CredentialsSecretRef; ref != nil {
+		secret := &corev1.Secret{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, secret); err != nil {
+			return creds, fmt.Errorf("failed to get credentials Secret %s/%s: %w", ref.Namespace, ref.Name, err)
+		}
+		creds.Username = string(secret.Data["username"])
+		creds.Password = string(secret.Data["password"])
+	}
+	return creds, nil
+}
+
+// connectionCredentials returns where and how to reach the cloud of a
+// ProviderConfig, without any user credentials.
+func connectionCredentials(ctx context.Context, c client.Reader, providerConfig *infrastructurev1alpha1.ProviderConfig) (Credentials, error) {
+	creds := CredentialsFromEnv()
+	creds.Username, creds.Password = "", ""
+	spec := providerConfig.Spec
+	if spec.AuthURL != "" {
+		creds.AuthURL = spec.AuthURL
+	}
+	if spec.Region != "" {
+		creds.Region = spec.Region
+	}
+	if spec.TenantName != "" {
+		creds.TenantName = spec.TenantName
+	}
+	if spec.Insecure != nil {
+		creds.Insecure = *spec.Insecure
+	}
+	if spec.TLS == nil {
+		return creds, nil
+	}
+
+	if bundle := spec.TLS.CABundle; bundle != nil {
+		key := bundle.Key
+		if key == "" {
+			key = "ca.crt"
+		}
+		switch {
+		case bundle.SecretRef != nil:
+			secret := &corev1.Secret{}
+			if err := c.Get(ctx, types.NamespacedName{Namespace: bundle.SecretRef.Namespace, Name: bundle.SecretRef.Name}, secret); err != nil {
+				return creds, fmt.Errorf("failed to get CA bundle Secret %s/%s: %w", bundle.SecretRef.Namespace, bundle.SecretRef.Name, err)
+			}
+			creds.CACert = string(secret.Data[key])
+		case bundle.ConfigMapRef != nil:
+			configMap := &corev1.ConfigMap{}
+			if err := c.Get(ctx, types.NamespacedName{Namespace: bundle.ConfigMapRef.Namespace, Name: bundle.ConfigMapRef.Name}, configMap); err != nil {
+				return creds, fmt.Errorf("failed to get CA bundle ConfigMap %s/%s: %w", bundle.ConfigMapRef.Namespace, bundle.ConfigMapRef.Name, err)
+			}
+			creds.CACert = configMap.Data[key]
+		}
+		if creds.CACert == "" {
+			return creds, fmt.Errorf("CA bundle of ProviderConfig %q has no key %q", providerConfig.Name, key)
+		}
+	}
+	if ref := spec.TLS.ClientCertificateSecretRef; ref != nil {
+		secret := &corev1.Secret{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, secret); err != nil {
+			return creds, fmt.Errorf("failed to get client certificate Secret %s/%s: %w", ref.Namespace, ref.Name, err)
+		}
+		creds.ClientCert = string(secret.Data[corev1.TLSCertKey])
+		creds.ClientKey = string(secret.Data[corev1.TLSPrivateKeyKey])
+	}
+	return creds, nil
+}
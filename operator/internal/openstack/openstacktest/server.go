@@ -0,0 +1,336 @@
+// Package openstacktest provides an in-memory fake of the OpenStack APIs used
+// by the operator, served over httptest for tests.
+package openstacktest
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+
+	"github.com/gunniLee/cloud-provider-operator/internal/openstack"
+)
+
+const (
+	// Region is the only region in the fake service catalog.
+	Region = "RegionOne"
+	// ProjectID is the ID of the project every token is scoped to.
+	ProjectID = "fake-project-id"
+	// Token is the token handed out by the fake Keystone.
+	Token = "fake-token"
+	// UserID is the ID of the user every token belongs to.
+	UserID = "fake-user-id"
+)
+
+// Server is a fake OpenStack cloud. Its fields may be modified between
+// requests while holding Lock.
+type Server struct {
+	sync.Mutex
+	*httptest.Server
+
+	Flavors           []openstack.Flavor
+	Images            []map[string]any
+	Networks          []openstack.Network
+	AvailabilityZones []openstack.AvailabilityZone
+
+	// Instances are the servers known to the fake, by ID.
+	Instances map[string]*Instance
+
+	// Quotas are returned by the compute and network quota APIs. Zero
+	// limits are reported as unlimited.
+	Quotas openstack.Quotas
+
+	// ApplicationCredentials are the Keystone application credentials
+	// accepted by the fake, by ID.
+	ApplicationCredentials map[string]ApplicationCredential
+
+	// lastAuth is the credential of the most recent authentication, which
+	// the fake treats as the owner of the token.
+	lastAuth ApplicationCredential
+	nextID   int
+}
+
+// ApplicationCredential is a fake Keystone application credential scoped to
+// ProjectName. The zero Unrestricted of a password login is ignored.
+type ApplicationCredential struct {
+	Secret       string
+	ProjectName  string
+	Roles        []string
+	Unrestricted bool
+	ExpiresAt    string
+
+	password bool
+}
+
+// Instance is a fake Nova server. Actions records the server actions
+// received, such as "reboot:SOFT" or "suspend".
+type Instance struct {
+	Status        string
+	ConsoleOutput string
+	Actions       []string
+}
+
+// NewServer starts a fake cloud. Close it when done.
+func NewServer() *Server {
+	return newServer(httptest.NewServer)
+}
+
+// NewTLSServer starts a fake cloud serving HTTPS with a self-signed
+// certificate, see CACert. Close it when done.
+func NewTLSServer() *Server {
+	return newServer(httptest.NewTLSServer)
+}
+
+func newServer(start func(http.Handler) *httptest.Server) *Server {
+	s := &Server{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /identity/v3/auth/tokens", s.handleAuth)
+	mux.HandleFunc("GET /compute/v2.1/flavors/detail", s.authorized(func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"flavors": s.Flavors})
+	}))
+	mux.HandleFunc("GET /compute/v2.1/os-availability-zone", s.authorized(func(w http.ResponseWriter, _ *http.Request) {
+		zones := []map[string]any{}
+		for _, z := range s.AvailabilityZones {
+			zones = append(zones, map[string]any{"zoneName": z.Name, "zoneState": map[string]bool{"available": z.Available}})
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"availabilityZoneInfo": zones})
+	}))
+	mux.HandleFunc("GET /image/v2/images", s.authorized(func(w http.ResponseWriter, _ *http.Request) {
+		images := s.Images
+		if images == nil {
+			images = []map[string]any{}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"images": images})
+	}))
+	mux.HandleFunc("GET /network/v2.0/networks", s.authorized(func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"networks": s.Networks})
+	}))
+	mux.HandleFunc("GET /compute/v2.1/servers/{id}", s.authorized(func(w http.ResponseWriter, r *http.Request) {
+		instance, ok := s.Instances[r.PathValue("id")]
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "server not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"server": map[string]string{"id": r.PathValue("id"), "status": instance.Status}})
+	}))
+	mux.HandleFunc("POST /compute/v2.1/servers/{id}/action", s.authorized(s.handleServerAction))
+	mux.HandleFunc("GET /compute/v2.1/os-quota-sets/{project}/detail", s.authorized(func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"quota_set": map[string]any{
+			"instances": unlimitedIfZero(s.Quotas.Instances),
+			"cores":     unlimitedIfZero(s.Quotas.Cores),
+			"ram":       unlimitedIfZero(s.Quotas.RAM),
+		}})
+	}))
+	mux.HandleFunc("GET /network/v2.0/quotas/{project}/details", s.authorized(func(w http.ResponseWriter, _ *http.Request) {
+		networkQuota := func(q openstack.QuotaUsage) map[string]int64 {
+			q = unlimitedIfZero(q)
+			return map[string]int64{"limit": q.Limit, "used": q.InUse, "reserved": q.Reserved}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"quota": map[string]any{
+			"floatingip": networkQuota(s.Quotas.FloatingIPs),
+			"port":       networkQuota(s.Quotas.Ports),
+		}})
+	}))
+	mux.HandleFunc("POST /identity/v3/users/{user}/application_credentials", s.authorized(s.handleCreateApplicationCredential))
+	mux.HandleFunc("DELETE /identity/v3/users/{user}/application_credentials/{id}", s.authorized(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := s.ApplicationCredentials[r.PathValue("id")]; !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "application credential not found"})
+			return
+		}
+		delete(s.ApplicationCredentials, r.PathValue("id"))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	s.Server = start(mux)
+	return s
+}
+
+// Credentials returns credentials that authenticate against the fake.
+func (s *Server) Credentials() openstack.Credentials {
+	return openstack.Credentials{
+		AuthURL:    s.URL + "/identity/v3",
+		Username:   "admin",
+		Password:   "secret",
+		TenantName: "admin",
+		Region:     Region,
+	}
+}
+
+// CACert returns the PEM certificate of a server started with NewTLSServer.
+func (s *Server) CACert() string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw}))
+}
+
+// AddImage registers an active public image with the given name and ID.
+func (s *Server) AddImage(id, name string) {
+	s.Lock()
+	defer s.Unlock()
+	s.Images = append(s.Images, map[string]any{
+		"id": id, "name": name, "status": "active", "visibility": "public",
+	})
+}
+
+func (s *Server) handleAuth(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Auth struct {
+			Identity struct {
+				ApplicationCredential *struct {
+					ID     string `json:"id"`
+					Secret string `json:"secret"`
+				} `json:"application_credential"`
+			} `json:"identity"`
+			Scope struct {
+				Project struct {
+					Name string `json:"name"`
+				} `json:"project"`
+			} `json:"scope"`
+		} `json:"auth"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	projectName := req.Auth.Scope.Project.Name
+	auth := ApplicationCredential{ProjectName: projectName, password: true}
+	if appCred := req.Auth.Identity.ApplicationCredential; appCred != nil {
+		s.Lock()
+		known, ok := s.ApplicationCredentials[appCred.ID]
+		s.Unlock()
+		if !ok || known.Secret != appCred.Secret {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid application credential"})
+			return
+		}
+		projectName = known.ProjectName
+		auth = known
+	}
+	s.Lock()
+	s.lastAuth = auth
+	s.Unlock()
+
+	endpoint := func(path string) []map[string]string {
+		return []map[string]string{{"interface": "public", "region": Region, "region_id": Region, "url": s.URL + path}}
+	}
+	w.Header().Set("X-Subject-Token", Token)
+	writeJSON(w, http.StatusCreated, map[string]any{
+		"token": map[string]any{
+			"user":    map[string]string{"id": UserID},
+			"project": map[string]any{"id": ProjectID, "name": projectName, "domain": map[string]string{"name": openstack.DefaultDomain}},
+			"catalog": []map[string]any{
+				{"type": openstack.ServiceIdentity, "endpoints": endpoint("/identity/v3")},
+				{"type": openstack.ServiceCompute, "endpoints": endpoint("/compute/v2.1")},
+				{"type": openstack.ServiceImage, "endpoints": endpoint("/image")},
+				{"type": openstack.ServiceNetwork, "endpoints": endpoint("/network")},
+			},
+		},
+	})
+}
+
+// handleCreateApplicationCredential creates a credential in the project of
+// the token. Like Keystone, a restricted application credential may not
+// create another one.
+func (s *Server) handleCreateApplicationCredential(w http.ResponseWriter, r *http.Request) {
+	if !s.lastAuth.password && !s.lastAuth.Unrestricted {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "restricted application credential cannot create application credentials"})
+		return
+	}
+	var req struct {
+		ApplicationCredential struct {
+			Name         string              `json:"name"`
+			Roles        []map[string]string `json:"roles"`
+			Unrestricted bool                `json:"unrestricted"`
+			ExpiresAt    string              `json:"expires_at"`
+		} `json:"application_credential"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	s.nextID++
+	id := "appcred-" + strconv.Itoa(s.nextID)
+	created := ApplicationCredential{
+		Secret:       "appcred-secret-" + strconv.Itoa(s.nextID),
+		ProjectName:  s.lastAuth.ProjectName,
+		Unrestricted: req.ApplicationCredential.Unrestricted,
+		ExpiresAt:    req.ApplicationCredential.ExpiresAt,
+	}
+	for _, role := range req.ApplicationCredential.Roles {
+		created.Roles = append(created.Roles, role["name"])
+	}
+	if s.ApplicationCredentials == nil {
+		s.ApplicationCredentials = map[string]ApplicationCredential{}
+	}
+	s.ApplicationCredentials[id] = created
+	writeJSON(w, http.StatusCreated, map[string]any{"application_credential": map[string]any{
+		"id": id, "name": req.ApplicationCredential.Name, "secret": created.Secret,
+	}})
+}
+
+// handleServerAction implements the server actions used by the operator.
+func (s *Server) handleServerAction(w http.ResponseWriter, r *http.Request) {
+	instance, ok := s.Instances[r.PathValue("id")]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "server not found"})
+		return
+	}
+	var action map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&action); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if _, ok := action["os-getConsoleOutput"]; ok {
+		writeJSON(w, http.StatusOK, map[string]string{"output": instance.ConsoleOutput})
+		return
+	}
+	if body, ok := action["reboot"]; ok {
+		var reboot struct {
+			Type string `json:"type"`
+		}
+		_ = json.Unmarshal(body, &reboot)
+		instance.Actions = append(instance.Actions, "reboot:"+reboot.Type)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	transitions := map[string][2]string{"suspend": {"ACTIVE", "SUSPENDED"}, "resume": {"SUSPENDED", "ACTIVE"}}
+	for name, transition := range transitions {
+		if _, ok := action[name]; !ok {
+			continue
+		}
+		if instance.Status != transition[0] {
+			writeJSON(w, http.StatusConflict, map[string]string{"error": "cannot " + name + " while " + instance.Status})
+			return
+		}
+		instance.Status = transition[1]
+		instance.Actions = append(instance.Actions, name)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported action"})
+}
+
+func unlimitedIfZero(q openstack.QuotaUsage) openstack.QuotaUsage {
+	if q.Limit == 0 {
+		q.Limit = -1
+	}
+	return q
+}
+
+// authorized rejects requests without the fake token and serializes access
+// to the server state.
+func (s *Server) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Auth-Token") != Token {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		s.Lock()
+		defer s.Unlock()
+		next(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,84 @@
+package openstack
+
+import (
+	"context"
+	"net/http"
+)
+
+// QuotaUsage is the limit and usage of one quota. A limit of -1 means
+// unlimited.
+type QuotaUsage struct {
+	Limit    int64 `json:"limit"`
+	InUse    int64 `json:"in_use"`
+	Reserved int64 `json:"reserved"`
+}
+
+// Available returns how much is left, or -1 when unlimited.
+func (q QuotaUsage) Available() int64 {
+	if q.Limit < 0 {
+		return -1
+	}
+	if left := q.Limit - q.InUse - q.Reserved; left > 0 {
+		return left
+	}
+	return 0
+}
+
+// Quotas are the compute and network quotas of the project.
+type Quotas struct {
+	Instances   QuotaUsage
+	Cores       QuotaUsage
+	RAM         QuotaUsage
+	FloatingIPs QuotaUsage
+	Ports       QuotaUsage
+}
+
+// GetQuotas returns the compute and network quotas and usage of the
+// project the client is scoped to.
+func (c *Client) GetQuotas(ctx context.Context) (*Quotas, error) {
+	compute, err := c.endpoint(ServiceCompute, "")
+	if err != nil {
+		return nil, err
+	}
+	var computeOut struct {
+		QuotaSet struct {
+			Instances QuotaUsage `json:"instances"`
+			Cores     QuotaUsage `json:"cores"`
+			RAM       QuotaUsage `json:"ram"`
+		} `json:"quota_set"`
+	}
+	if err := c.do(ctx, http.MethodGet, compute+"/os-quota-sets/"+c.projectID+"/detail", nil, &computeOut); err != nil {
+		return nil, err
+	}
+
+	network, err := c.endpoint(ServiceNetwork, "/v2.0")
+	if err != nil {
+		return nil, err
+	}
+	// Neutron reports usage as "used" instead of "in_use".
+	type networkUsage struct {
+		Limit    int64 `json:"limit"`
+		Used     int64 `json:"used"`
+		Reserved int64 `json:"reserved"`
+	}
+	var networkOut struct {
+		Quota struct {
+			FloatingIP networkUsage `json:"floatingip"`
+			Port       networkUsage `json:"port"`
+		} `json:"quota"`
+	}
+	if err := c.do(ctx, http.MethodGet, network+"/quotas/"+c.projectID+"/details", nil, &networkOut); err != nil {
+		return nil, err
+	}
+	toUsage := func(u networkUsage) QuotaUsage {
+		return QuotaUsage{Limit: u.Limit, InUse: u.Used, Reserved: u.Reserved}
+	}
+
+	return &Quotas{
+		Instances:   computeOut.QuotaSet.Instances,
+		Cores:       computeOut.QuotaSet.Cores,
+		RAM:         computeOut.QuotaSet.RAM,
+		FloatingIPs: toUsage(networkOut.Quota.FloatingIP),
+		Ports:       toUsage(networkOut.Quota.Port),
+	}, nil
+}
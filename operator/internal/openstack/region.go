@@ -0,0 +1,70 @@
+package openstack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+)
+
+// ErrRegionNotAllowed is wrapped by errors about a region the ProviderConfig
+// does not list.
+var ErrRegionNotAllowed = errors.New("region not allowed")
+
+// Regions returns the regions of a ProviderConfig, its primary region first.
+// A missing "default" ProviderConfig only has the environment region.
+func Regions(ctx context.Context, c client.Reader, providerConfigName string) ([]string, error) {
+	providerConfig, err := getProviderConfig(ctx, c, providerConfigName)
+	if err != nil {
+		return nil, err
+	}
+	primary := CredentialsFromEnv().Region
+	if providerConfig == nil {
+		return []string{primary}, nil
+	}
+	if providerConfig.Spec.Region != "" {
+		primary = providerConfig.Spec.Region
+	}
+	regions := []string{primary}
+	for _, region := range providerConfig.Spec.Regions {
+		if !slices.Contains(regions, region) {
+			regions = append(regions, region)
+		}
+	}
+	return regions, nil
+}
+
+// CheckRegion refuses a region the ProviderConfig does not list. An empty
+// region selects the primary one.
+func CheckRegion(ctx context.Context, c client.Reader, providerConfigName, region string) error {
+	if region == "" {
+		return nil
+	}
+	regions, err := Regions(ctx, c, providerConfigName)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(regions, region) {
+		if providerConfigName == "" {
+			providerConfigName = infrastructurev1alpha1.DefaultProviderConfigName
+		}
+		return fmt.Errorf("%w: ProviderConfig %q has regions %v, not %q", ErrRegionNotAllowed, providerConfigName, regions, region)
+	}
+	return nil
+}
+
+// SelectRegion returns creds for another region of the same cloud.
+func SelectRegion(ctx context.Context, c client.Reader, providerConfigName string, creds Credentials, region string) (Credentials, error) {
+	if region == "" || region == creds.Region {
+		return creds, nil
+	}
+	if err := CheckRegion(ctx, c, providerConfigName, region); err != nil {
+		return creds, err
+	}
+	creds.Region = region
+	return creds, nil
+}
@@ -0,0 +1,76 @@
+package openstack
+
+import (
+	"context"
+	"net/http"
+)
+
+// ConsoleOutput returns the last lines of the console log of a server. A
+// length of zero returns the whole log.
+func (c *Client) ConsoleOutput(ctx context.Context, serverID string, length int) (string, error) {
+	base, err := c.endpoint(ServiceCompute, "")
+	if err != nil {
+		return "", err
+	}
+	action := map[string]any{}
+	if length > 0 {
+		action["length"] = length
+	}
+	var out struct {
+		Output string `json:"output"`
+	}
+	if err := c.do(ctx, http.MethodPost, base+"/servers/"+serverID+"/action",
+		map[string]any{"os-getConsoleOutput": action}, &out); err != nil {
+		return "", err
+	}
+	return out.Output, nil
+}
+
+// Server is the part of a Nova server the operator looks at.
+type Server struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+// GetServer returns a server by ID.
+func (c *Client) GetServer(ctx context.Context, serverID string) (*Server, error) {
+	base, err := c.endpoint(ServiceCompute, "")
+	if err != nil {
+		return nil, err
+	}
+	var out struct {
+		Server Server `json:"server"`
+	}
+	if err := c.do(ctx, http.MethodGet, base+"/servers/"+serverID, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out.Server, nil
+}
+
+// Reboot reboots a server, forcing a power cycle when hard is set.
+func (c *Client) Reboot(ctx context.Context, serverID string, hard bool) error {
+	rebootType := "SOFT"
+	if hard {
+		rebootType = "HARD"
+	}
+	return c.serverAction(ctx, serverID, map[string]any{"reboot": map[string]string{"type": rebootType}})
+}
+
+// Suspend suspends a running server to disk.
+func (c *Client) Suspend(ctx context.Context, serverID string) error {
+	return c.serverAction(ctx, serverID, map[string]any{"suspend": nil})
+}
+
+// Resume resumes a suspended server.
+func (c *Client) Resume(ctx context.Context, serverID string) error {
+	return c.serverAction(ctx, serverID, map[string]any{"resume": nil})
+}
+
+func (c *Client) serverAction(ctx context.Context, serverID string, action map[string]any) error {
+	base, err := c.endpoint(ServiceCompute, "")
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, http.MethodPost, base+"/servers/"+serverID+"/action", action, nil)
+}
@@ -0,0 +1,112 @@
+package openstack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+)
+
+// ErrCrossTenant is wrapped by errors about objects reaching outside the
+// OpenStack project their namespace is bound to.
+var ErrCrossTenant = errors.New("cross-tenant reference")
+
+// FindTenantBinding returns the TenantBinding of a namespace, or nil if the
+// namespace is not bound. When several bindings list the namespace, the
+// first by name wins and the others report a conflict.
+func FindTenantBinding(ctx context.Context, c client.Reader, namespace string) (*infrastructurev1alpha1.TenantBinding, error) {
+	bindings := &infrastructurev1alpha1.TenantBindingList{}
+	if err := c.List(ctx, bindings); err != nil {
+		return nil, fmt.Errorf("failed to list TenantBindings: %w", err)
+	}
+	sort.Slice(bindings.Items, func(i, j int) bool { return bindings.Items[i].Name < bindings.Items[j].Name })
+	for i := range bindings.Items {
+		if slices.Contains(bindings.Items[i].Spec.Namespaces, namespace) {
+			return &bindings.Items[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// TenantProviderConfig is the ProviderConfig a TenantBinding applies to.
+func TenantProviderConfig(binding *infrastructurev1alpha1.TenantBinding) string {
+	if binding.Spec.ProviderConfigName == "" {
+		return infrastructurev1alpha1.DefaultProviderConfigName
+	}
+	return binding.Spec.ProviderConfigName
+}
+
+// CheckTenantProviderConfig refuses a ProviderConfig other than the one the
+// namespace is bound to, since it would provision outside the project.
+func CheckTenantProviderConfig(binding *infrastructurev1alpha1.TenantBinding, providerConfigName string) error {
+	if binding == nil {
+		return nil
+	}
+	if providerConfigName == "" {
+		providerConfigName = infrastructurev1alpha1.DefaultProviderConfigName
+	}
+	if want := TenantProviderConfig(binding); providerConfigName != want {
+		return fmt.Errorf("%w: namespace is bound to ProviderConfig %q by TenantBinding %s, not %q",
+			ErrCrossTenant, want, binding.Name, providerConfigName)
+	}
+	return nil
+}
+
+// ResolveNamespaceCredentials is ResolveCredentials for an object in
+// namespace. In a namespace bound by a TenantBinding, the application
+// credential of the binding replaces the ProviderConfig user.
+func ResolveNamespaceCredentials(ctx context.Context, c client.Reader, providerConfigName, namespace string) (Credentials, error) {
+	binding, err := FindTenantBinding(ctx, c, namespace)
+	if err != nil {
+		return Credentials{}, err
+	}
+	if binding == nil {
+		return ResolveCredentials(ctx, c, providerConfigName)
+	}
+	if err := CheckTenantProviderConfig(binding, providerConfigName); err != nil {
+		return Credentials{}, err
+	}
+	if !meta.IsStatusConditionTrue(binding.Status.Conditions, infrastructurev1alpha1.ConditionReady) {
+		return Credentials{}, fmt.Errorf("TenantBinding %s is not ready", binding.Name)
+	}
+	return TenantCredentials(ctx, c, binding)
+}
+
+// TenantCredentials returns the credentials of the ProviderConfig of a
+// TenantBinding, scoped to its project with its application credential.
+func TenantCredentials(ctx context.Context, c client.Reader, binding *infrastructurev1alpha1.TenantBinding) (Credentials, error) {
+	// The binding brings its own credential, so only the connection
+	// settings of the ProviderConfig are used.
+	creds := CredentialsFromEnv()
+	providerConfig, err := getProviderConfig(ctx, c, TenantProviderConfig(binding))
+	if err != nil {
+		return creds, err
+	}
+	if providerConfig != nil {
+		if creds, err = connectionCredentials(ctx, c, providerConfig); err != nil {
+			return creds, err
+		}
+	}
+	ref := binding.Spec.ApplicationCredentialSecretRef
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, secret); err != nil {
+		return creds, fmt.Errorf("failed to get application credential Secret %s/%s: %w", ref.Namespace, ref.Name, err)
+	}
+	creds.Username, creds.Password = "", ""
+	creds.TenantName = binding.Spec.ProjectName
+	creds.ProjectDomainName = binding.Spec.ProjectDomainName
+	creds.ApplicationCredentialID = string(secret.Data[infrastructurev1alpha1.ApplicationCredentialIDKey])
+	creds.ApplicationCredentialSecret = string(secret.Data[infrastructurev1alpha1.ApplicationCredentialSecretKey])
+	if !creds.UsesApplicationCredential() {
+		return creds, fmt.Errorf("application credential Secret %s/%s has no %s", ref.Namespace, ref.Name, infrastructurev1alpha1.ApplicationCredentialIDKey)
+	}
+	return creds, creds.Validate()
+}
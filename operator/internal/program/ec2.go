@@ -0,0 +1,122 @@
+package program
+
+import (
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+)
+
+// EC2InstanceName is the program run by InstanceStacks of AWS
+// ProviderConfigs that do not set spec.program.
+const EC2InstanceName = "ec2-instance"
+
+// awsProviderVersion is the version of the Pulumi AWS provider plugin the
+// EC2 resources are registered with.
+const awsProviderVersion = "6.83.0"
+
+func init() {
+	Register(Program{
+		Name:        EC2InstanceName,
+		Description: "A single EC2 instance built from spec.aws.",
+		Provider:    infrastructurev1alpha1.ProviderAWS,
+		Schema: Schema{
+			{Name: "userData", Type: TypeString, Description: "cloud-init user data"},
+			{Name: "associatePublicIPAddress", Type: TypeBoolean, Description: "give the instance a public IP; defaults to the subnet setting"},
+			{Name: "rootVolumeSizeGiB", Type: TypeInteger, Description: "size of the root volume in GiB"},
+		},
+		Instance:            true,
+		VolumeSizeParameter: "rootVolumeSizeGiB",
+		Run:                 runEC2Instance,
+	})
+}
+
+// ec2Instance holds the outputs of an aws:ec2/instance:Instance. The
+// resources are registered by type token instead of through the generated
+// pulumi-aws SDK, one of the largest Go modules, which would dominate the
+// build of the operator for the two resources used here. The provider plugin
+// is installed by the Pulumi engine from the pinned version.
+type ec2Instance struct {
+	pulumi.CustomResourceState
+
+	PublicIP  pulumi.StringOutput `pulumi:"publicIp"`
+	PrivateIP pulumi.StringOutput `pulumi:"privateIp"`
+}
+
+// ec2InstanceState holds the outputs of an aws:ec2/instanceState:InstanceState.
+type ec2InstanceState struct {
+	pulumi.CustomResourceState
+
+	State pulumi.StringOutput `pulumi:"state"`
+}
+
+func runEC2Instance(ctx *pulumi.Context, instanceStack *infrastructurev1alpha1.InstanceStack, params Values) error {
+	spec := instanceStack.Spec.AWS
+	if spec == nil {
+		spec = &infrastructurev1alpha1.AWSInstanceSpec{}
+	}
+	name := instanceStack.Spec.ServerName
+	if name == "" {
+		name = instanceStack.Name
+	}
+
+	// EC2 인스턴스 생성
+	args := pulumi.Map{
+		"instanceType": pulumi.String(spec.InstanceType),
+		"ami":          pulumi.String(spec.AMI),
+		"tags":         pulumi.StringMap{"Name": pulumi.String(name)},
+	}
+	if spec.SubnetID != "" {
+		args["subnetId"] = pulumi.String(spec.SubnetID)
+	}
+	if len(spec.SecurityGroupIDs) > 0 {
+		args["vpcSecurityGroupIds"] = pulumi.ToStringArray(spec.SecurityGroupIDs)
+	}
+	if spec.KeyName != "" {
+		args["keyName"] = pulumi.String(spec.KeyName)
+	}
+	if userData := params.String("userData"); userData != "" {
+		args["userData"] = pulumi.String(userData)
+	}
+	if _, ok := params["associatePublicIPAddress"]; ok {
+		args["associatePublicIpAddress"] = pulumi.Bool(params.Bool("associatePublicIPAddress"))
+	}
+	if size := params.Int("rootVolumeSizeGiB"); size > 0 {
+		args["rootBlockDevice"] = pulumi.Map{"volumeSize": pulumi.Int(size)}
+	}
+	opts := []pulumi.ResourceOption{pulumi.Version(awsProviderVersion), pulumi.Protect(instanceStack.Spec.DeletionProtection)}
+	if importID := instanceStack.PendingImportID(); importID != "" {
+		opts = append(opts, pulumi.Import(pulumi.ID(importID)))
+	}
+	instance := &ec2Instance{}
+	if err := ctx.RegisterResource("aws:ec2/instance:Instance", instanceStack.Name, args, instance, opts...); err != nil {
+		return err
+	}
+
+	ctx.Export("instanceIP", instance.PrivateIP)
+	ctx.Export("publicIP", instance.PublicIP)
+	ctx.Export("serverID", instance.ID())
+
+	// EC2는 Running과 Stopped만 지원하며 webhook이 나머지를 거부함
+	if state := ec2PowerState(instanceStack.Spec.PowerState); state != "" {
+		powerState := &ec2InstanceState{}
+		if err := ctx.RegisterResource("aws:ec2/instanceState:InstanceState", instanceStack.Name, pulumi.Map{
+			"instanceId": instance.ID(),
+			"state":      pulumi.String(state),
+		}, powerState, pulumi.Version(awsProviderVersion)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ec2PowerState maps spec.powerState to the state of an
+// aws:ec2/instanceState:InstanceState.
+func ec2PowerState(powerState infrastructurev1alpha1.PowerState) string {
+	switch powerState {
+	case infrastructurev1alpha1.PowerStateRunning:
+		return "running"
+	case infrastructurev1alpha1.PowerStateStopped:
+		return "stopped"
+	}
+	return ""
+}
@@ -0,0 +1,94 @@
+package program
+
+import (
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+)
+
+// recordingMocks records the inputs of the resources a program registers.
+type recordingMocks struct {
+	mu     sync.Mutex
+	inputs map[string]resource.PropertyMap
+}
+
+func (m *recordingMocks) NewResource(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inputs[args.TypeToken] = args.Inputs
+	return args.Name + "-id", args.Inputs, nil
+}
+
+func (m *recordingMocks) Call(args pulumi.MockCallArgs) (resource.PropertyMap, error) {
+	return args.Args, nil
+}
+
+var _ = Describe("EC2 instance program", func() {
+	run := func(instanceStack *infrastructurev1alpha1.InstanceStack) map[string]resource.PropertyMap {
+		mocks := &recordingMocks{inputs: map[string]resource.PropertyMap{}}
+		prog, params, errs := Resolve(instanceStack)
+		Expect(errs).To(BeEmpty())
+		Expect(pulumi.RunErr(func(ctx *pulumi.Context) error {
+			return prog.Run(ctx, instanceStack, params)
+		}, pulumi.WithMocks("cloud-provider-operator", "test", mocks))).To(Succeed())
+		return mocks.inputs
+	}
+
+	It("Should be the default program of AWS ProviderConfigs", func() {
+		Expect(DefaultFor(infrastructurev1alpha1.ProviderAWS)).To(Equal(EC2InstanceName))
+		Expect(DefaultFor(infrastructurev1alpha1.ProviderOpenStack)).To(Equal(DefaultName))
+
+		prog, _ := Lookup(EC2InstanceName)
+		Expect(prog.CheckProvider(infrastructurev1alpha1.ProviderAWS)).To(BeNil())
+		Expect(prog.CheckProvider(infrastructurev1alpha1.ProviderOpenStack).Error()).To(ContainSubstring("program is for AWS ProviderConfigs"))
+		prog, _ = Lookup("")
+		Expect(prog.CheckProvider(infrastructurev1alpha1.ProviderOpenStack)).To(BeNil())
+	})
+
+	It("Should launch the instance from spec.aws", func() {
+		inputs := run(&infrastructurev1alpha1.InstanceStack{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+			Spec: infrastructurev1alpha1.InstanceStackSpec{
+				Program: EC2InstanceName,
+				AWS: &infrastructurev1alpha1.AWSInstanceSpec{
+					InstanceType:     "t3.micro",
+					AMI:              "ami-0123456789abcdef0",
+					SubnetID:         "subnet-1",
+					SecurityGroupIDs: []string{"sg-1", "sg-2"},
+				},
+				Parameters: map[string]apiextensionsv1.JSON{"associatePublicIPAddress": {Raw: []byte(`false`)}},
+				PowerState: infrastructurev1alpha1.PowerStateStopped,
+			},
+		})
+		instance := inputs["aws:ec2/instance:Instance"]
+		Expect(instance["instanceType"].StringValue()).To(Equal("t3.micro"))
+		Expect(instance["ami"].StringValue()).To(Equal("ami-0123456789abcdef0"))
+		Expect(instance["subnetId"].StringValue()).To(Equal("subnet-1"))
+		Expect(instance["vpcSecurityGroupIds"].ArrayValue()).To(HaveLen(2))
+		Expect(instance["associatePublicIpAddress"].BoolValue()).To(BeFalse())
+		Expect(instance["tags"].ObjectValue()["Name"].StringValue()).To(Equal("web"))
+		Expect(instance).NotTo(HaveKey(resource.PropertyKey("keyName")))
+
+		Expect(inputs["aws:ec2/instanceState:InstanceState"]["state"].StringValue()).To(Equal("stopped"))
+	})
+
+	It("Should leave the power state alone when it is not set", func() {
+		inputs := run(&infrastructurev1alpha1.InstanceStack{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+			Spec: infrastructurev1alpha1.InstanceStackSpec{
+				Program: EC2InstanceName,
+				AWS:     &infrastructurev1alpha1.AWSInstanceSpec{InstanceType: "t3.micro", AMI: "ami-1"},
+			},
+		})
+		Expect(inputs).To(HaveKey("aws:ec2/instance:Instance"))
+		Expect(inputs).NotTo(HaveKey("aws:ec2/instanceState:InstanceState"))
+		Expect(inputs["aws:ec2/instance:Instance"]).NotTo(HaveKey(resource.PropertyKey("associatePublicIpAddress")))
+	})
+})
@@ -0,0 +1,160 @@
+package program
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+)
+
+// LibvirtDomainName is the program run by InstanceStacks of Libvirt
+// ProviderConfigs that do not set spec.program.
+const LibvirtDomainName = "libvirt-domain"
+
+// libvirtProviderVersion is the version of the Pulumi libvirt provider
+// plugin the domain resources are registered with.
+const libvirtProviderVersion = "0.5.4"
+
+func init() {
+	Register(Program{
+		Name:        LibvirtDomainName,
+		Description: "A libvirt domain booted from a copy of the cloud image of spec.libvirt.",
+		Provider:    infrastructurev1alpha1.ProviderLibvirt,
+		Schema: Schema{
+			{Name: "userData", Type: TypeString, Description: "cloud-init user data"},
+			{Name: "rootVolumeSizeGiB", Type: TypeInteger, Description: "size of the root volume in GiB; defaults to the size of the image"},
+		},
+		Instance:            true,
+		VolumeSizeParameter: "rootVolumeSizeGiB",
+		Run:                 runLibvirtDomain,
+	})
+}
+
+// libvirtVolume holds the outputs of a libvirt:index/volume:Volume. Like the
+// EC2 resources, the libvirt resources are registered by type token instead
+// of through the generated pulumi-libvirt SDK.
+type libvirtVolume struct {
+	pulumi.CustomResourceState
+}
+
+// libvirtCloudInitDisk holds the outputs of a
+// libvirt:index/cloudInitDisk:CloudInitDisk.
+type libvirtCloudInitDisk struct {
+	pulumi.CustomResourceState
+}
+
+// libvirtDomain holds the outputs of a libvirt:index/domain:Domain.
+type libvirtDomain struct {
+	pulumi.CustomResourceState
+
+	NetworkInterfaces pulumi.MapArrayOutput `pulumi:"networkInterfaces"`
+}
+
+func runLibvirtDomain(ctx *pulumi.Context, instanceStack *infrastructurev1alpha1.InstanceStack, params Values) error {
+	spec := instanceStack.Spec.Libvirt
+	if spec == nil {
+		spec = &infrastructurev1alpha1.LibvirtInstanceSpec{}
+	}
+	// 도메인 이름은 호스트 전체에서 고유해야 하므로 네임스페이스를 붙임
+	name := instanceStack.Spec.ServerName
+	if name == "" {
+		name = instanceStack.Namespace + "-" + instanceStack.Name
+	}
+	pool := stringOr(spec.Pool, "default")
+	vcpus, memoryMiB := LibvirtSize(spec)
+	opts := []pulumi.ResourceOption{pulumi.Version(libvirtProviderVersion), pulumi.Protect(instanceStack.Spec.DeletionProtection)}
+
+	// 클라우드 이미지를 내려받은 뒤 루트 디스크를 그 위에 복제
+	base := &libvirtVolume{}
+	if err := ctx.RegisterResource("libvirt:index/volume:Volume", instanceStack.Name+"-base", pulumi.Map{
+		"name":   pulumi.String(name + "-base.qcow2"),
+		"pool":   pulumi.String(pool),
+		"source": pulumi.String(spec.Image),
+		"format": pulumi.String("qcow2"),
+	}, base, opts...); err != nil {
+		return err
+	}
+	rootArgs := pulumi.Map{
+		"name":         pulumi.String(name + ".qcow2"),
+		"pool":         pulumi.String(pool),
+		"baseVolumeId": base.ID(),
+		"format":       pulumi.String("qcow2"),
+	}
+	if size := params.Int("rootVolumeSizeGiB"); size > 0 {
+		rootArgs["size"] = pulumi.Int(size << 30)
+	}
+	root := &libvirtVolume{}
+	if err := ctx.RegisterResource("libvirt:index/volume:Volume", instanceStack.Name, rootArgs, root, opts...); err != nil {
+		return err
+	}
+
+	args := pulumi.Map{
+		"name":   pulumi.String(name),
+		"vcpu":   pulumi.Int(int(vcpus)),
+		"memory": pulumi.Int(int(memoryMiB)),
+		"disks":  pulumi.MapArray{pulumi.Map{"volumeId": root.ID()}},
+		"networkInterfaces": pulumi.MapArray{pulumi.Map{
+			"networkName":  pulumi.String(stringOr(spec.NetworkName, "default")),
+			"waitForLease": pulumi.Bool(true),
+		}},
+		// libvirt 도메인은 Running과 Stopped만 지원하며 webhook이 나머지를 거부함
+		"running": pulumi.Bool(instanceStack.Spec.PowerState != infrastructurev1alpha1.PowerStateStopped),
+	}
+	if userData := params.String("userData"); userData != "" {
+		cloudInit := &libvirtCloudInitDisk{}
+		if err := ctx.RegisterResource("libvirt:index/cloudInitDisk:CloudInitDisk", instanceStack.Name, pulumi.Map{
+			"name":     pulumi.String(name + "-cloudinit.iso"),
+			"pool":     pulumi.String(pool),
+			"userData": pulumi.String(userData),
+		}, cloudInit, opts...); err != nil {
+			return err
+		}
+		args["cloudinit"] = cloudInit.ID()
+	}
+	domain := &libvirtDomain{}
+	if err := ctx.RegisterResource("libvirt:index/domain:Domain", instanceStack.Name, args, domain, opts...); err != nil {
+		return err
+	}
+
+	ctx.Export("instanceIP", domain.NetworkInterfaces.ApplyT(firstAddress).(pulumi.StringOutput))
+	ctx.Export("serverID", domain.ID())
+	return nil
+}
+
+// LibvirtSize returns the vCPUs and memory in MiB of the domain spec
+// describes, filling in the defaults of the CRD.
+func LibvirtSize(spec *infrastructurev1alpha1.LibvirtInstanceSpec) (vcpus, memoryMiB int32) {
+	if spec == nil {
+		spec = &infrastructurev1alpha1.LibvirtInstanceSpec{}
+	}
+	return int32Or(spec.VCPUs, 1), int32Or(spec.MemoryMiB, 1024)
+}
+
+// firstAddress returns the first address the network of a domain leased to
+// it, or an empty string.
+func firstAddress(networkInterfaces []map[string]interface{}) string {
+	for _, networkInterface := range networkInterfaces {
+		addresses, _ := networkInterface["addresses"].([]interface{})
+		for _, address := range addresses {
+			if address := fmt.Sprint(address); address != "" {
+				return address
+			}
+		}
+	}
+	return ""
+}
+
+func stringOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+func int32Or(value, fallback int32) int32 {
+	if value == 0 {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,69 @@
+package program
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+)
+
+var _ = Describe("Libvirt domain program", func() {
+	run := func(instanceStack *infrastructurev1alpha1.InstanceStack) *recordingMocks {
+		mocks := &recordingMocks{inputs: map[string]resource.PropertyMap{}}
+		prog, params, errs := Resolve(instanceStack)
+		Expect(errs).To(BeEmpty())
+		Expect(pulumi.RunErr(func(ctx *pulumi.Context) error {
+			return prog.Run(ctx, instanceStack, params)
+		}, pulumi.WithMocks("cloud-provider-operator", "test", mocks))).To(Succeed())
+		return mocks
+	}
+
+	It("Should be the default program of Libvirt ProviderConfigs", func() {
+		Expect(DefaultFor(infrastructurev1alpha1.ProviderLibvirt)).To(Equal(LibvirtDomainName))
+		prog, _ := Lookup(LibvirtDomainName)
+		Expect(prog.CheckProvider(infrastructurev1alpha1.ProviderLibvirt)).To(BeNil())
+	})
+
+	It("Should boot the domain from a copy of the image", func() {
+		inputs := run(&infrastructurev1alpha1.InstanceStack{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "dev"},
+			Spec: infrastructurev1alpha1.InstanceStackSpec{
+				Program: LibvirtDomainName,
+				Libvirt: &infrastructurev1alpha1.LibvirtInstanceSpec{
+					VCPUs:     2,
+					MemoryMiB: 2048,
+					Image:     "https://cloud-images.ubuntu.com/jammy/current/jammy-server-cloudimg-amd64.img",
+				},
+				Parameters: map[string]apiextensionsv1.JSON{
+					"userData":          {Raw: []byte(`"#cloud-config\n"`)},
+					"rootVolumeSizeGiB": {Raw: []byte(`20`)},
+				},
+				PowerState: infrastructurev1alpha1.PowerStateStopped,
+			},
+		}).inputs
+		Expect(inputs["libvirt:index/volume:Volume"]["size"].NumberValue()).To(Equal(float64(20 << 30)))
+		Expect(inputs["libvirt:index/volume:Volume"]["baseVolumeId"].StringValue()).To(Equal("dev-base-id"))
+		Expect(inputs["libvirt:index/cloudInitDisk:CloudInitDisk"]["userData"].StringValue()).To(Equal("#cloud-config\n"))
+
+		domain := inputs["libvirt:index/domain:Domain"]
+		Expect(domain["name"].StringValue()).To(Equal("default-dev"))
+		Expect(domain["vcpu"].NumberValue()).To(Equal(float64(2)))
+		Expect(domain["memory"].NumberValue()).To(Equal(float64(2048)))
+		Expect(domain["running"].BoolValue()).To(BeFalse())
+		Expect(domain["cloudinit"].StringValue()).To(Equal("dev-id"))
+		Expect(domain["disks"].ArrayValue()[0].ObjectValue()["volumeId"].StringValue()).To(Equal("dev-id"))
+		Expect(domain["networkInterfaces"].ArrayValue()[0].ObjectValue()["networkName"].StringValue()).To(Equal("default"))
+	})
+
+	It("Should report the first leased address", func() {
+		Expect(firstAddress([]map[string]interface{}{
+			{"networkName": "default"},
+			{"addresses": []interface{}{"192.168.122.10", "fe80::1"}},
+		})).To(Equal("192.168.122.10"))
+		Expect(firstAddress(nil)).To(BeEmpty())
+	})
+})
@@ -0,0 +1,313 @@
+// Package program is the registry of Pulumi programs an InstanceStack can
+// run. Programs register themselves from init with a parameter schema, and
+// InstanceStacks select one by name through spec.program.
+package program
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+)
+
+// DefaultName is the program run when spec.program is empty.
+const DefaultName = "server"
+
+// defaultNames are the programs run when spec.program is empty, per provider
+// of the ProviderConfig.
+var defaultNames = map[infrastructurev1alpha1.ProviderType]string{
+	infrastructurev1alpha1.ProviderOpenStack: DefaultName,
+	infrastructurev1alpha1.ProviderAWS:       EC2InstanceName,
+	infrastructurev1alpha1.ProviderLibvirt:   LibvirtDomainName,
+}
+
+// DefaultFor returns the program run when spec.program is empty for
+// InstanceStacks of a provider.
+func DefaultFor(provider infrastructurev1alpha1.ProviderType) string {
+	if name, ok := defaultNames[provider]; ok {
+		return name
+	}
+	return DefaultName
+}
+
+// Type is the type of a program parameter.
+type Type string
+
+const (
+	TypeString  Type = "string"
+	TypeInteger Type = "integer"
+	TypeBoolean Type = "boolean"
+)
+
+// Parameter describes one entry of spec.parameters.
+type Parameter struct {
+	Name        string
+	Type        Type
+	Required    bool
+	Description string
+	// Default is used when the parameter is not given. It must match Type.
+	Default any
+}
+
+// Schema is the list of parameters a program accepts. Parameters that are
+// not in the schema are rejected.
+type Schema []Parameter
+
+// Values are validated parameters. Strings are string, integers int64 and
+// booleans bool.
+type Values map[string]any
+
+// String returns a string parameter, or "" when it is unset.
+func (v Values) String(name string) string {
+	s, _ := v[name].(string)
+	return s
+}
+
+// Int returns an integer parameter, or 0 when it is unset.
+func (v Values) Int(name string) int {
+	i, _ := v[name].(int64)
+	return int(i)
+}
+
+// Bool returns a boolean parameter, or false when it is unset.
+func (v Values) Bool(name string) bool {
+	b, _ := v[name].(bool)
+	return b
+}
+
+// RunFunc is the body of a program. It creates the resources of one
+// InstanceStack.
+type RunFunc func(ctx *pulumi.Context, instanceStack *infrastructurev1alpha1.InstanceStack, params Values) error
+
+// Program is a named Pulumi program.
+type Program struct {
+	Name        string
+	Description string
+
+	// Provider is the kind of ProviderConfig the program's resources belong
+	// to. Empty means OpenStack.
+	Provider infrastructurev1alpha1.ProviderType
+
+	// ServerSpec is set for programs that use flavorName, imageName,
+	// networkUUID and availabilityZone. Only those are defaulted, required
+	// and checked against the catalog.
+	ServerSpec bool
+
+	// Instance is set for programs that create one server. It is counted
+	// against the instances of InstanceBudgets and priced by its
+	// MachineType.
+	Instance bool
+
+	Schema Schema
+
+	// GeneratedSecrets are stack config keys the controller fills once with
+	// a random secret, e.g. an admin password. Programs read them with
+	// config.RequireSecret, and the value stays stable across updates.
+	GeneratedSecrets []string
+
+	// VolumeSizeParameter names the integer parameter holding the size in
+	// GiB of the volume the program creates, counted against budgets.
+	VolumeSizeParameter string
+	// VolumeTypeParameter names the string parameter holding the Cinder
+	// volume type, used to price the volume.
+	VolumeTypeParameter string
+	// FloatingIPParameter names the parameter that, when set, makes the
+	// program allocate a floating IP, used to price it.
+	FloatingIPParameter string
+
+	Run RunFunc
+}
+
+var (
+	mu       sync.RWMutex
+	registry = map[string]*Program{}
+)
+
+// Register adds a program to the registry. It panics if the name is empty or
+// already taken, or if a default does not match its parameter type.
+func Register(p Program) {
+	if p.Name == "" || p.Run == nil {
+		panic("program: Register requires a name and a Run function")
+	}
+	for _, param := range p.Schema {
+		if param.Default != nil {
+			if _, err := convert(param.Type, param.Default); err != nil {
+				panic(fmt.Sprintf("program: default of %s.%s: %v", p.Name, param.Name, err))
+			}
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if _, dup := registry[p.Name]; dup {
+		panic(fmt.Sprintf("program: Register called twice for %q", p.Name))
+	}
+	registry[p.Name] = &p
+}
+
+// ProviderType returns the provider the program is written for.
+func (p *Program) ProviderType() infrastructurev1alpha1.ProviderType {
+	if p.Provider == "" {
+		return infrastructurev1alpha1.ProviderOpenStack
+	}
+	return p.Provider
+}
+
+// CheckProvider reports an error against spec.program when the program is
+// written for another provider than the ProviderConfig.
+func (p *Program) CheckProvider(provider infrastructurev1alpha1.ProviderType) *field.Error {
+	if p.ProviderType() == provider {
+		return nil
+	}
+	return field.Invalid(field.NewPath("spec", "program"), p.Name,
+		fmt.Sprintf("program is for %s ProviderConfigs, not %s", p.ProviderType(), provider))
+}
+
+// Lookup returns the program registered under name. An empty name selects
+// DefaultName.
+func Lookup(name string) (*Program, bool) {
+	if name == "" {
+		name = DefaultName
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	p, ok := registry[name]
+	return p, ok
+}
+
+// MachineType returns the name the server of an InstanceStack is sized and
+// priced by: the flavor on OpenStack and the instance type on AWS. It is
+// empty for programs that create no server and for libvirt domains, which
+// are sized by spec.libvirt.
+func (p *Program) MachineType(instanceStack *infrastructurev1alpha1.InstanceStack) string {
+	if !p.Instance {
+		return ""
+	}
+	switch p.Provider {
+	case infrastructurev1alpha1.ProviderAWS:
+		if spec := instanceStack.EffectiveAWS(); spec != nil {
+			return spec.InstanceType
+		}
+		return ""
+	case infrastructurev1alpha1.ProviderLibvirt:
+		return ""
+	default:
+		return instanceStack.EffectiveFlavorName()
+	}
+}
+
+// Names returns the registered program names in order.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Resolve looks up the program of an InstanceStack and validates its
+// parameters. The errors are reported against spec.program and
+// spec.parameters.
+func Resolve(instanceStack *infrastructurev1alpha1.InstanceStack) (*Program, Values, field.ErrorList) {
+	specPath := field.NewPath("spec")
+	p, ok := Lookup(instanceStack.Spec.Program)
+	if !ok {
+		return nil, nil, field.ErrorList{field.NotSupported(specPath.Child("program"), instanceStack.Spec.Program, Names())}
+	}
+	values, errs := p.Schema.Validate(specPath.Child("parameters"), instanceStack.Spec.Parameters)
+	if len(errs) > 0 {
+		return p, nil, errs
+	}
+	return p, values, nil
+}
+
+// Validate checks raw parameters against the schema and converts them to
+// Values, filling in defaults.
+func (s Schema) Validate(path *field.Path, raw map[string]apiextensionsv1.JSON) (Values, field.ErrorList) {
+	var allErrs field.ErrorList
+	values := Values{}
+	known := make([]string, 0, len(s))
+
+	for _, param := range s {
+		known = append(known, param.Name)
+		value, ok := raw[param.Name]
+		if !ok {
+			switch {
+			case param.Default != nil:
+				values[param.Name], _ = convert(param.Type, param.Default)
+			case param.Required:
+				allErrs = append(allErrs, field.Required(path.Key(param.Name), param.Description))
+			}
+			continue
+		}
+		var decoded any
+		if err := json.Unmarshal(value.Raw, &decoded); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Key(param.Name), string(value.Raw), err.Error()))
+			continue
+		}
+		converted, err := convert(param.Type, decoded)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Key(param.Name), decoded, err.Error()))
+			continue
+		}
+		values[param.Name] = converted
+	}
+
+	names := make([]string, 0, len(raw))
+	for name := range raw {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !contains(known, name) {
+			allErrs = append(allErrs, field.NotSupported(path.Key(name), name, known))
+		}
+	}
+	return values, allErrs
+}
+
+// convert checks that a decoded JSON value has the given type.
+func convert(t Type, v any) (any, error) {
+	switch t {
+	case TypeString:
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case TypeBoolean:
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	case TypeInteger:
+		switch n := v.(type) {
+		case int:
+			return int64(n), nil
+		case int64:
+			return n, nil
+		case float64:
+			if n == math.Trunc(n) && math.Abs(n) <= 1<<53 {
+				return int64(n), nil
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported parameter type %q", t)
+	}
+	return nil, fmt.Errorf("must be of type %s", t)
+}
+
+func contains(slice []string, s string) bool {
+	for _, item := range slice {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,14 @@
+package program
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestProgram(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Program Suite")
+}
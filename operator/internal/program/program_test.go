@@ -0,0 +1,69 @@
+package program
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+)
+
+var _ = Describe("Program registry", func() {
+	raw := func(v string) apiextensionsv1.JSON { return apiextensionsv1.JSON{Raw: []byte(v)} }
+
+	It("Should register the built-in programs", func() {
+		Expect(Names()).To(ContainElements(DefaultName, "volume"))
+
+		prog, ok := Lookup("")
+		Expect(ok).To(BeTrue())
+		Expect(prog.Name).To(Equal(DefaultName))
+		Expect(prog.ServerSpec).To(BeTrue())
+	})
+
+	It("Should panic when a name is registered twice", func() {
+		Expect(func() { Register(Program{Name: "volume", Run: runVolume}) }).To(Panic())
+	})
+
+	It("Should convert parameters and fill in defaults", func() {
+		prog, _ := Lookup("volume")
+		values, errs := prog.Schema.Validate(field.NewPath("spec", "parameters"), map[string]apiextensionsv1.JSON{
+			"sizeGiB":    raw(`20`),
+			"volumeType": raw(`"ssd"`),
+		})
+		Expect(errs).To(BeEmpty())
+		Expect(values.Int("sizeGiB")).To(Equal(20))
+		Expect(values.String("volumeType")).To(Equal("ssd"))
+		Expect(values.Bool("onlineResize")).To(BeTrue())
+	})
+
+	It("Should reject missing, mistyped and unknown parameters", func() {
+		prog, _ := Lookup("volume")
+		_, errs := prog.Schema.Validate(field.NewPath("spec", "parameters"), map[string]apiextensionsv1.JSON{
+			"volumeType":   raw(`3`),
+			"onlineResize": raw(`"yes"`),
+			"size":         raw(`20`),
+		})
+		Expect(errs).To(HaveLen(4))
+		Expect(errs.ToAggregate().Error()).To(And(
+			ContainSubstring("spec.parameters[sizeGiB]: Required value"),
+			ContainSubstring("spec.parameters[volumeType]: Invalid value: 3: must be of type string"),
+			ContainSubstring("spec.parameters[onlineResize]"),
+			ContainSubstring(`spec.parameters[size]: Unsupported value: "size"`),
+		))
+	})
+
+	It("Should reject fractional integers", func() {
+		prog, _ := Lookup("volume")
+		_, errs := prog.Schema.Validate(field.NewPath("spec", "parameters"), map[string]apiextensionsv1.JSON{"sizeGiB": raw(`1.5`)})
+		Expect(errs).To(HaveLen(1))
+	})
+
+	It("Should report unknown programs against spec.program", func() {
+		instanceStack := &infrastructurev1alpha1.InstanceStack{}
+		instanceStack.Spec.Program = "database"
+		prog, _, errs := Resolve(instanceStack)
+		Expect(prog).To(BeNil())
+		Expect(errs.ToAggregate().Error()).To(ContainSubstring(`spec.program: Unsupported value: "database"`))
+	})
+})
@@ -0,0 +1,119 @@
+package program
+
+import (
+	"github.com/pulumi/pulumi-openstack/sdk/v4/go/openstack/compute"
+	"github.com/pulumi/pulumi-openstack/sdk/v4/go/openstack/networking"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+)
+
+func init() {
+	Register(Program{
+		Name:        DefaultName,
+		Description: "A single compute instance built from flavorName, imageName and networkUUID.",
+		ServerSpec:  true,
+		Instance:    true,
+		Schema: Schema{
+			{Name: "floatingIPPool", Type: TypeString, Description: "external network to allocate a floating IP from"},
+			{Name: "generateKeypair", Type: TypeBoolean, Default: false, Description: "create a keypair and export its private key"},
+			{Name: "generateAdminPassword", Type: TypeBoolean, Default: false, Description: "set a generated admin password"},
+			{Name: "userData", Type: TypeString, Description: "cloud-init user data"},
+			{Name: "networkID", Type: TypeString, Description: "network to attach to instead of networkUUID, e.g. one created by another stack"},
+		},
+		GeneratedSecrets:    []string{adminPasswordConfig},
+		FloatingIPParameter: "floatingIPPool",
+		Run:                 runServer,
+	})
+}
+
+// adminPasswordConfig is the stack config key of the generated admin password.
+const adminPasswordConfig = "adminPassword"
+
+func runServer(ctx *pulumi.Context, instanceStack *infrastructurev1alpha1.InstanceStack, params Values) error {
+	networkID := instanceStack.Spec.NetworkUUID
+	if id := params.String("networkID"); id != "" {
+		networkID = id
+	}
+
+	// OpenStack 인스턴스 생성
+	args := &compute.InstanceArgs{
+		FlavorName: pulumi.String(instanceStack.Spec.FlavorName),
+		ImageName:  pulumi.String(instanceStack.Spec.ImageName),
+		Networks: compute.InstanceNetworkArray{
+			&compute.InstanceNetworkArgs{
+				Uuid: pulumi.String(networkID),
+			},
+		},
+	}
+	if userData := params.String("userData"); userData != "" {
+		args.UserData = pulumi.String(userData)
+	}
+	if instanceStack.Spec.AvailabilityZone != "" {
+		args.AvailabilityZone = pulumi.String(instanceStack.Spec.AvailabilityZone)
+	}
+	if instanceStack.Spec.ServerName != "" {
+		args.Name = pulumi.String(instanceStack.Spec.ServerName)
+	}
+	if params.Bool("generateKeypair") {
+		keypair, err := compute.NewKeypair(ctx, instanceStack.Name, &compute.KeypairArgs{})
+		if err != nil {
+			return err
+		}
+		args.KeyPair = keypair.Name
+		ctx.Export("privateKey", keypair.PrivateKey)
+	}
+	if params.Bool("generateAdminPassword") {
+		adminPass := config.RequireSecret(ctx, adminPasswordConfig)
+		args.AdminPass = adminPass
+		ctx.Export("adminPassword", adminPass)
+	}
+	if powerState := pulumiPowerState(instanceStack.Spec.PowerState); powerState != "" {
+		args.PowerState = pulumi.String(powerState)
+	}
+	opts := []pulumi.ResourceOption{pulumi.Protect(instanceStack.Spec.DeletionProtection)}
+	if importID := instanceStack.PendingImportID(); importID != "" {
+		opts = append(opts, pulumi.Import(pulumi.ID(importID)))
+	}
+	newInstance, err := compute.NewInstance(ctx, instanceStack.Name, args, opts...)
+	if err != nil {
+		return err
+	}
+
+	// 생성된 인스턴스의 IP를 Export
+	ctx.Export("instanceIP", newInstance.AccessIpV4)
+	ctx.Export("serverID", newInstance.ID())
+
+	if pool := params.String("floatingIPPool"); pool != "" {
+		floatingIP, err := networking.NewFloatingIp(ctx, instanceStack.Name, &networking.FloatingIpArgs{
+			Pool: pulumi.String(pool),
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := compute.NewFloatingIpAssociate(ctx, instanceStack.Name, &compute.FloatingIpAssociateArgs{
+			FloatingIp: floatingIP.Address,
+			InstanceId: newInstance.ID(),
+		}); err != nil {
+			return err
+		}
+		ctx.Export("floatingIP", floatingIP.Address)
+	}
+	return nil
+}
+
+// pulumiPowerState maps spec.powerState to the provider's powerState. The
+// provider cannot suspend, so Suspended servers are kept active in Pulumi and
+// suspended through Nova by the controller.
+func pulumiPowerState(powerState infrastructurev1alpha1.PowerState) string {
+	switch powerState {
+	case infrastructurev1alpha1.PowerStateRunning, infrastructurev1alpha1.PowerStateSuspended:
+		return "active"
+	case infrastructurev1alpha1.PowerStateStopped:
+		return "shutoff"
+	case infrastructurev1alpha1.PowerStateShelved:
+		return "shelved_offloaded"
+	}
+	return ""
+}
@@ -0,0 +1,47 @@
+package program
+
+import (
+	"github.com/pulumi/pulumi-openstack/sdk/v4/go/openstack/blockstorage"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+)
+
+func init() {
+	Register(Program{
+		Name:        "volume",
+		Description: "A Cinder block storage volume.",
+		Schema: Schema{
+			{Name: "sizeGiB", Type: TypeInteger, Required: true, Description: "size of the volume in GiB"},
+			{Name: "volumeType", Type: TypeString, Description: "Cinder volume type"},
+			{Name: "onlineResize", Type: TypeBoolean, Default: true, Description: "allow resizing while attached"},
+		},
+		VolumeSizeParameter: "sizeGiB",
+		VolumeTypeParameter: "volumeType",
+		Run:                 runVolume,
+	})
+}
+
+func runVolume(ctx *pulumi.Context, instanceStack *infrastructurev1alpha1.InstanceStack, params Values) error {
+	args := &blockstorage.VolumeArgs{
+		Size:               pulumi.Int(params.Int("sizeGiB")),
+		EnableOnlineResize: pulumi.Bool(params.Bool("onlineResize")),
+	}
+	if volumeType := params.String("volumeType"); volumeType != "" {
+		args.VolumeType = pulumi.String(volumeType)
+	}
+	if instanceStack.Spec.AvailabilityZone != "" {
+		args.AvailabilityZone = pulumi.String(instanceStack.Spec.AvailabilityZone)
+	}
+	opts := []pulumi.ResourceOption{pulumi.Protect(instanceStack.Spec.DeletionProtection)}
+	if importID := instanceStack.PendingImportID(); importID != "" {
+		opts = append(opts, pulumi.Import(pulumi.ID(importID)))
+	}
+	volume, err := blockstorage.NewVolume(ctx, instanceStack.Name, args, opts...)
+	if err != nil {
+		return err
+	}
+
+	ctx.Export("volumeID", volume.ID())
+	return nil
+}
@@ -0,0 +1,750 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+	"github.com/gunniLee/cloud-provider-operator/internal/budget"
+	"github.com/gunniLee/cloud-provider-operator/internal/catalog"
+	"github.com/gunniLee/cloud-provider-operator/internal/machineclass"
+	"github.com/gunniLee/cloud-provider-operator/internal/openstack"
+	"github.com/gunniLee/cloud-provider-operator/internal/program"
+)
+
+// log is for logging in this package.
+var instancestacklog = logf.Log.WithName("instancestack-resource")
+
+// SetupInstanceStackWebhookWithManager registers the webhook for InstanceStack in the manager.
+// cache may be nil, in which case names are not checked against the catalog.
+func SetupInstanceStackWebhookWithManager(mgr ctrl.Manager, cache *catalog.Cache) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&infrastructurev1alpha1.InstanceStack{}).
+		WithValidator(&InstanceStackCustomValidator{Client: mgr.GetClient(), Catalog: cache}).
+		WithDefaulter(&InstanceStackCustomDefaulter{Client: mgr.GetClient()}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-infrastructure-cloudprovider-io-v1alpha1-instancestack,mutating=true,failurePolicy=fail,sideEffects=None,groups=infrastructure.cloudprovider.io,resources=instancestacks,verbs=create;update,versions=v1alpha1,name=minstancestack-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups=infrastructure.cloudprovider.io,resources=providerconfigs,verbs=get;list;watch
+// +kubebuilder:rbac:groups=infrastructure.cloudprovider.io,resources=instancebudgets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=infrastructure.cloudprovider.io,resources=tenantbindings,verbs=get;list;watch
+// +kubebuilder:rbac:groups=infrastructure.cloudprovider.io,resources=machineclasses,verbs=get;list;watch
+
+// InstanceStackCustomDefaulter fills empty flavor, image and network fields
+// of server programs from namespace annotations or the referenced
+// ProviderConfig. InstanceStacks with a MachineClass take them from the class
+// when they are reconciled instead.
+type InstanceStackCustomDefaulter struct {
+	Client client.Reader
+}
+
+var _ webhook.CustomDefaulter = &InstanceStackCustomDefaulter{}
+
+// Default implements webhook.CustomDefaulter so a webhook will be registered for the Kind InstanceStack.
+func (d *InstanceStackCustomDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	instanceStack, ok := obj.(*infrastructurev1alpha1.InstanceStack)
+	if !ok {
+		return fmt.Errorf("expected an InstanceStack object but got %T", obj)
+	}
+	instancestacklog.Info("Defaulting for InstanceStack", "name", instanceStack.GetName())
+
+	namespace := instanceStack.Namespace
+	if namespace == "" {
+		if req, err := admission.RequestFromContext(ctx); err == nil {
+			namespace = req.Namespace
+		}
+	}
+
+	if instanceStack.Spec.ProviderConfigName == "" {
+		// 프로젝트에 묶인 네임스페이스는 바인딩의 ProviderConfig를 사용
+		binding, err := openstack.FindTenantBinding(ctx, d.Client, namespace)
+		if err != nil {
+			return err
+		}
+		instanceStack.Spec.ProviderConfigName = infrastructurev1alpha1.DefaultProviderConfigName
+		if binding != nil {
+			instanceStack.Spec.ProviderConfigName = openstack.TenantProviderConfig(binding)
+		}
+	}
+	if instanceStack.Spec.Program == "" {
+		providerType, err := providerTypeOf(ctx, d.Client, instanceStack.Spec.ProviderConfigName)
+		if err != nil {
+			return err
+		}
+		instanceStack.Spec.Program = program.DefaultFor(providerType)
+	}
+	if prog, ok := program.Lookup(instanceStack.Spec.Program); !ok || !prog.ServerSpec || instanceStack.Spec.MachineClassName != "" {
+		return nil
+	}
+
+	defaults, err := d.lookupDefaults(ctx, namespace, instanceStack.Spec.ProviderConfigName)
+	if err != nil {
+		return err
+	}
+
+	if instanceStack.Spec.FlavorName == "" {
+		instanceStack.Spec.FlavorName = defaults.FlavorName
+	}
+	if instanceStack.Spec.ImageName == "" {
+		instanceStack.Spec.ImageName = defaults.ImageName
+	}
+	if instanceStack.Spec.NetworkUUID == "" {
+		instanceStack.Spec.NetworkUUID = defaults.NetworkUUID
+	}
+	return nil
+}
+
+// lookupDefaults merges the ProviderConfig defaults with the namespace
+// annotations, the latter taking precedence. Missing objects yield no defaults.
+func (d *InstanceStackCustomDefaulter) lookupDefaults(ctx context.Context, namespace, providerConfigName string) (infrastructurev1alpha1.InstanceDefaults, error) {
+	var defaults infrastructurev1alpha1.InstanceDefaults
+
+	providerConfig := &infrastructurev1alpha1.ProviderConfig{}
+	err := d.Client.Get(ctx, types.NamespacedName{Name: providerConfigName}, providerConfig)
+	switch {
+	case err == nil:
+		defaults = providerConfig.Spec.Defaults
+	case !apierrors.IsNotFound(err):
+		return defaults, fmt.Errorf("failed to get ProviderConfig %q: %w", providerConfigName, err)
+	}
+
+	if namespace == "" {
+		return defaults, nil
+	}
+	ns := &corev1.Namespace{}
+	if err := d.Client.Get(ctx, types.NamespacedName{Name: namespace}, ns); err != nil {
+		if apierrors.IsNotFound(err) {
+			return defaults, nil
+		}
+		return defaults, fmt.Errorf("failed to get namespace %q: %w", namespace, err)
+	}
+	if v := ns.Annotations[infrastructurev1alpha1.DefaultFlavorNameAnnotation]; v != "" {
+		defaults.FlavorName = v
+	}
+	if v := ns.Annotations[infrastructurev1alpha1.DefaultImageNameAnnotation]; v != "" {
+		defaults.ImageName = v
+	}
+	if v := ns.Annotations[infrastructurev1alpha1.DefaultNetworkUUIDAnnotation]; v != "" {
+		defaults.NetworkUUID = v
+	}
+	return defaults, nil
+}
+
+// +kubebuilder:webhook:path=/validate-infrastructure-cloudprovider-io-v1alpha1-instancestack,mutating=false,failurePolicy=fail,sideEffects=None,groups=infrastructure.cloudprovider.io,resources=instancestacks,verbs=create;update;delete,versions=v1alpha1,name=vinstancestack-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// InstanceStackCustomValidator rejects InstanceStacks that would only fail
+// once the Pulumi program runs.
+type InstanceStackCustomValidator struct {
+	// Client is used to find InstanceStacks referencing one being deleted
+	// and the InstanceBudgets of the namespace.
+	Client client.Reader
+
+	// Catalog, when it holds a snapshot for the ProviderConfig, is used to
+	// reject unknown flavors, images, networks and availability zones.
+	Catalog *catalog.Cache
+}
+
+var _ webhook.CustomValidator = &InstanceStackCustomValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type InstanceStack.
+func (v *InstanceStackCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	instanceStack, ok := obj.(*infrastructurev1alpha1.InstanceStack)
+	if !ok {
+		return nil, fmt.Errorf("expected an InstanceStack object but got %T", obj)
+	}
+	instancestacklog.Info("Validation for InstanceStack upon creation", "name", instanceStack.GetName())
+
+	resolved, classErrs, err := v.resolveMachineClass(ctx, instanceStack)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve the MachineClass: %w", err)
+	}
+	allErrs := append(classErrs, validateInstanceStackSpec(resolved)...)
+	allErrs = append(allErrs, v.validateCatalog(ctx, resolved)...)
+	regionErrs, err := v.validateRegion(ctx, instanceStack)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check the region: %w", err)
+	}
+	allErrs = append(allErrs, regionErrs...)
+	providerErrs, err := v.validateProvider(ctx, instanceStack)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check the provider: %w", err)
+	}
+	allErrs = append(allErrs, providerErrs...)
+	tenantErrs, err := v.validateTenant(ctx, instanceStack)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check the TenantBinding: %w", err)
+	}
+	allErrs = append(allErrs, tenantErrs...)
+	if err := toInvalid(instanceStack, allErrs); err != nil {
+		return nil, err
+	}
+	return nil, v.validateBudget(ctx, nil, resolved)
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type InstanceStack.
+func (v *InstanceStackCustomValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	instanceStack, ok := newObj.(*infrastructurev1alpha1.InstanceStack)
+	if !ok {
+		return nil, fmt.Errorf("expected an InstanceStack object for the newObj but got %T", newObj)
+	}
+	oldInstanceStack, ok := oldObj.(*infrastructurev1alpha1.InstanceStack)
+	if !ok {
+		return nil, fmt.Errorf("expected an InstanceStack object for the oldObj but got %T", oldObj)
+	}
+	instancestacklog.Info("Validation for InstanceStack upon update", "name", instanceStack.GetName())
+
+	// 이 webhook 이전에 저장된 스택도 삭제(finalizer 제거)는 가능해야 하므로 spec이 바뀔 때만 검사
+	if !instanceStack.DeletionTimestamp.IsZero() || equality.Semantic.DeepEqual(oldInstanceStack.Spec, instanceStack.Spec) {
+		return nil, nil
+	}
+
+	// 삭제된 MachineClass를 쓰는 스택도 삭제(finalizer 제거)는 가능해야 하므로 바뀐 참조만 검사
+	resolved, classErrs, err := v.resolveMachineClass(ctx, instanceStack)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve the MachineClass: %w", err)
+	}
+	var allErrs field.ErrorList
+	if oldInstanceStack.Spec.MachineClassName != instanceStack.Spec.MachineClassName ||
+		providerConfigNameOf(oldInstanceStack) != providerConfigNameOf(instanceStack) {
+		allErrs = classErrs
+	}
+	allErrs = append(allErrs, validateInstanceStackSpec(resolved)...)
+	allErrs = append(allErrs, validateImmutableFields(oldInstanceStack, instanceStack)...)
+	// 카탈로그에서 사라진 flavor나 이미지를 쓰는 스택도 삭제(finalizer 제거)는 가능해야 하므로 바뀐 값만 검사
+	if catalogFieldsChanged(oldInstanceStack, instanceStack) {
+		allErrs = append(allErrs, v.validateCatalog(ctx, resolved)...)
+	}
+	if oldInstanceStack.Spec.Region != instanceStack.Spec.Region ||
+		providerConfigNameOf(oldInstanceStack) != providerConfigNameOf(instanceStack) {
+		regionErrs, err := v.validateRegion(ctx, instanceStack)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check the region: %w", err)
+		}
+		allErrs = append(allErrs, regionErrs...)
+	}
+	if oldInstanceStack.Spec.Program != instanceStack.Spec.Program ||
+		providerConfigNameOf(oldInstanceStack) != providerConfigNameOf(instanceStack) {
+		providerErrs, err := v.validateProvider(ctx, instanceStack)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check the provider: %w", err)
+		}
+		allErrs = append(allErrs, providerErrs...)
+	}
+	// 바인딩 이전에 만든 스택도 삭제(finalizer 제거)는 가능해야 하므로 바뀐 참조만 검사
+	if oldInstanceStack.Spec.ProviderConfigName != instanceStack.Spec.ProviderConfigName ||
+		!equality.Semantic.DeepEqual(oldInstanceStack.Spec.OutputRefs, instanceStack.Spec.OutputRefs) {
+		tenantErrs, err := v.validateTenant(ctx, instanceStack)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check the TenantBinding: %w", err)
+		}
+		allErrs = append(allErrs, tenantErrs...)
+	}
+	if err := toInvalid(instanceStack, allErrs); err != nil {
+		return nil, err
+	}
+	return nil, v.validateBudget(ctx, oldInstanceStack, resolved)
+}
+
+// catalogFieldsChanged reports whether an update changes a field checked
+// against the catalog, directly or through the MachineClass, ProviderConfig
+// or region it is resolved in.
+func catalogFieldsChanged(oldObj, newObj *infrastructurev1alpha1.InstanceStack) bool {
+	return oldObj.Spec.FlavorName != newObj.Spec.FlavorName ||
+		oldObj.Spec.ImageName != newObj.Spec.ImageName ||
+		oldObj.Spec.NetworkUUID != newObj.Spec.NetworkUUID ||
+		oldObj.Spec.AvailabilityZone != newObj.Spec.AvailabilityZone ||
+		oldObj.Spec.MachineClassName != newObj.Spec.MachineClassName ||
+		oldObj.Spec.Region != newObj.Spec.Region ||
+		providerConfigNameOf(oldObj) != providerConfigNameOf(newObj)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type InstanceStack.
+// InstanceStacks whose outputs are referenced cannot be deleted, and
+// protected ones only once the confirm-deletion annotation names them.
+func (v *InstanceStackCustomValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	instanceStack, ok := obj.(*infrastructurev1alpha1.InstanceStack)
+	if !ok {
+		return nil, fmt.Errorf("expected an InstanceStack object but got %T", obj)
+	}
+	instancestacklog.Info("Validation for InstanceStack upon deletion", "name", instanceStack.GetName())
+
+	if names, err := v.dependents(ctx, instanceStack); err != nil {
+		return nil, err
+	} else if len(names) > 0 {
+		return nil, apierrors.NewForbidden(infrastructurev1alpha1.GroupVersion.WithResource("instancestacks").GroupResource(),
+			instanceStack.Name, fmt.Errorf("outputs are referenced by %s", strings.Join(names, ", ")))
+	}
+
+	if !instanceStack.Spec.DeletionProtection || instanceStack.DeletionConfirmed() {
+		return nil, nil
+	}
+	return nil, apierrors.NewForbidden(infrastructurev1alpha1.GroupVersion.WithResource("instancestacks").GroupResource(),
+		instanceStack.Name, fmt.Errorf("deletion protection is enabled; set the %s=%s annotation to confirm",
+			infrastructurev1alpha1.ConfirmDeletionAnnotation, instanceStack.Name))
+}
+
+// resolveMachineClass returns instanceStack with its MachineClass applied, so
+// the other checks see the concrete settings, and the errors of a class that
+// does not exist or does not map the ProviderConfig. In that case
+// instanceStack is returned as it is.
+func (v *InstanceStackCustomValidator) resolveMachineClass(ctx context.Context, instanceStack *infrastructurev1alpha1.InstanceStack) (*infrastructurev1alpha1.InstanceStack, field.ErrorList, error) {
+	if v.Client == nil || instanceStack.Spec.MachineClassName == "" {
+		return instanceStack, nil, nil
+	}
+	classPath := field.NewPath("spec", "machineClassName")
+	resolved, _, err := machineclass.Resolve(ctx, v.Client, instanceStack)
+	switch {
+	case apierrors.IsNotFound(err):
+		return instanceStack, field.ErrorList{field.NotFound(classPath, instanceStack.Spec.MachineClassName)}, nil
+	case errors.Is(err, machineclass.ErrNoMapping):
+		return instanceStack, field.ErrorList{field.Invalid(classPath, instanceStack.Spec.MachineClassName, err.Error())}, nil
+	case err != nil:
+		return nil, nil, err
+	}
+	return resolved, nil, nil
+}
+
+// validateTenant rejects InstanceStacks that would reach outside the
+// OpenStack project of their namespace: another ProviderConfig than the one
+// of the TenantBinding, or outputs of stacks using another ProviderConfig.
+func (v *InstanceStackCustomValidator) validateTenant(ctx context.Context, instanceStack *infrastructurev1alpha1.InstanceStack) (field.ErrorList, error) {
+	if v.Client == nil {
+		return nil, nil
+	}
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+	binding, err := openstack.FindTenantBinding(ctx, v.Client, instanceStack.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	if err := openstack.CheckTenantProviderConfig(binding, instanceStack.Spec.ProviderConfigName); err != nil {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("providerConfigName"), err.Error()))
+	}
+
+	providerConfigName := func(name string) string {
+		if name == "" {
+			return infrastructurev1alpha1.DefaultProviderConfigName
+		}
+		return name
+	}
+	for i, ref := range instanceStack.Spec.OutputRefs {
+		key := types.NamespacedName{Namespace: instanceStack.Namespace, Name: ref.StackName}
+		var referenced string
+		if ref.Kind == "Stack" {
+			stack := &infrastructurev1alpha1.Stack{}
+			err = v.Client.Get(ctx, key, stack)
+			referenced = stack.Spec.ProviderConfigName
+		} else {
+			other := &infrastructurev1alpha1.InstanceStack{}
+			err = v.Client.Get(ctx, key, other)
+			referenced = other.Spec.ProviderConfigName
+		}
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if providerConfigName(referenced) != providerConfigName(instanceStack.Spec.ProviderConfigName) {
+			kind := ref.Kind
+			if kind == "" {
+				kind = "InstanceStack"
+			}
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("outputRefs").Index(i),
+				fmt.Sprintf("%s %s uses ProviderConfig %q and runs in another project", kind, ref.StackName, providerConfigName(referenced))))
+		}
+	}
+	return allErrs, nil
+}
+
+// validateBudget rejects an InstanceStack that would push its namespace over
+// an InstanceBudget. Updates are only checked when they consume more than
+// before, so lowering a budget does not block unrelated changes.
+func (v *InstanceStackCustomValidator) validateBudget(ctx context.Context, oldObj, newObj *infrastructurev1alpha1.InstanceStack) error {
+	if v.Client == nil {
+		return nil
+	}
+	if oldObj != nil {
+		previous, _ := budget.Usage(ctx, v.Catalog, oldObj)
+		next, _ := budget.Usage(ctx, v.Catalog, newObj)
+		if !budget.Increases(previous, next) {
+			return nil
+		}
+	}
+	exceeded, err := budget.Check(ctx, v.Client, v.Catalog, newObj, nil)
+	if err != nil {
+		return fmt.Errorf("failed to check InstanceBudgets: %w", err)
+	}
+	if len(exceeded) == 0 {
+		return nil
+	}
+	return apierrors.NewForbidden(infrastructurev1alpha1.GroupVersion.WithResource("instancestacks").GroupResource(),
+		newObj.Name, fmt.Errorf("exceeds budget: %s", strings.Join(exceeded, "; ")))
+}
+
+// dependents returns the InstanceStacks in the same namespace that reference
+// the outputs of instanceStack.
+func (v *InstanceStackCustomValidator) dependents(ctx context.Context, instanceStack *infrastructurev1alpha1.InstanceStack) ([]string, error) {
+	if v.Client == nil {
+		return nil, nil
+	}
+	instanceStacks := &infrastructurev1alpha1.InstanceStackList{}
+	if err := v.Client.List(ctx, instanceStacks, client.InNamespace(instanceStack.Namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list InstanceStacks: %w", err)
+	}
+	var names []string
+	for _, item := range instanceStacks.Items {
+		for _, ref := range item.Spec.OutputRefs {
+			if (ref.Kind == "" || ref.Kind == "InstanceStack") && ref.StackName == instanceStack.Name {
+				names = append(names, item.Name)
+				break
+			}
+		}
+	}
+	return names, nil
+}
+
+func validateInstanceStackSpec(instanceStack *infrastructurev1alpha1.InstanceStack) field.ErrorList {
+	prog, _, allErrs := program.Resolve(instanceStack)
+	allErrs = append(allErrs, validateDependencies(instanceStack)...)
+	if value, ok := instanceStack.Annotations[infrastructurev1alpha1.RebootAnnotation]; ok &&
+		value != "soft" && value != "hard" {
+		allErrs = append(allErrs, field.NotSupported(field.NewPath("metadata", "annotations").Key(infrastructurev1alpha1.RebootAnnotation),
+			value, []string{"soft", "hard"}))
+	}
+	if value, ok := instanceStack.Annotations[infrastructurev1alpha1.ExtendExpiryAnnotation]; ok {
+		if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("metadata", "annotations").Key(infrastructurev1alpha1.ExtendExpiryAnnotation),
+				value, "must be a positive duration such as 24h"))
+		}
+	}
+	specPath := field.NewPath("spec")
+	if ttl := instanceStack.Spec.TTL; ttl != nil && ttl.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("ttl"), ttl.Duration.String(), "must be positive"))
+	}
+	var providerType infrastructurev1alpha1.ProviderType
+	if prog != nil {
+		providerType = prog.ProviderType()
+	}
+	if instanceStack.Spec.AWS != nil && providerType != infrastructurev1alpha1.ProviderAWS {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("aws"), "only programs for AWS ProviderConfigs use spec.aws"))
+	}
+	if instanceStack.Spec.Libvirt != nil && providerType != infrastructurev1alpha1.ProviderLibvirt {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("libvirt"), "only programs for Libvirt ProviderConfigs use spec.libvirt"))
+	}
+	switch providerType {
+	case infrastructurev1alpha1.ProviderAWS:
+		return append(allErrs, validateAWSSpec(instanceStack)...)
+	case infrastructurev1alpha1.ProviderLibvirt:
+		return append(allErrs, validateLibvirtSpec(instanceStack)...)
+	}
+	if prog == nil || !prog.ServerSpec {
+		if prog != nil && instanceStack.Spec.PowerState != "" {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("powerState"),
+				fmt.Sprintf("program %q does not create a server", prog.Name)))
+		}
+		return allErrs
+	}
+
+	// MachineClass가 채우지 못한 값은 controller가 상태로 보고함
+	fromClass := instanceStack.Spec.MachineClassName != ""
+	if instanceStack.Spec.FlavorName == "" && !fromClass {
+		allErrs = append(allErrs, field.Required(specPath.Child("flavorName"), "flavorName must be set or defaulted"))
+	}
+	if instanceStack.Spec.ImageName == "" && !fromClass {
+		allErrs = append(allErrs, field.Required(specPath.Child("imageName"), "imageName must be set or defaulted"))
+	}
+	if instanceStack.Spec.NetworkUUID == "" {
+		if !setsParameter(instanceStack, "networkID") && !fromClass {
+			allErrs = append(allErrs, field.Required(specPath.Child("networkUUID"), "networkUUID must be set or defaulted"))
+		}
+	} else if _, err := uuid.Parse(instanceStack.Spec.NetworkUUID); err != nil || len(instanceStack.Spec.NetworkUUID) != 36 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("networkUUID"), instanceStack.Spec.NetworkUUID,
+			"must be a UUID in the form xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx"))
+	}
+	return allErrs
+}
+
+// validateAWSSpec checks the fields of an InstanceStack running a program
+// for AWS ProviderConfigs: spec.aws replaces the OpenStack server fields, and
+// EC2 instances can only be running or stopped.
+func validateAWSSpec(instanceStack *infrastructurev1alpha1.InstanceStack) field.ErrorList {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+	if instanceStack.Spec.AWS == nil && instanceStack.Spec.MachineClassName == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("aws"), "aws must be set for programs of AWS ProviderConfigs"))
+	}
+	allErrs = append(allErrs, validateOpenStackFieldsUnset(instanceStack, "aws", infrastructurev1alpha1.ProviderAWS)...)
+	return append(allErrs, validateRunningOrStopped(instanceStack)...)
+}
+
+// validateLibvirtSpec checks the fields of an InstanceStack running a program
+// for Libvirt ProviderConfigs like validateAWSSpec. Libvirt domains cannot
+// be imported, since the program also creates their volumes.
+func validateLibvirtSpec(instanceStack *infrastructurev1alpha1.InstanceStack) field.ErrorList {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+	if instanceStack.Spec.Libvirt == nil && instanceStack.Spec.MachineClassName == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("libvirt"), "libvirt must be set for programs of Libvirt ProviderConfigs"))
+	}
+	allErrs = append(allErrs, validateOpenStackFieldsUnset(instanceStack, "libvirt", infrastructurev1alpha1.ProviderLibvirt)...)
+	if instanceStack.EffectiveImportID() != "" {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("importID"), "libvirt domains cannot be imported"))
+	}
+	return append(allErrs, validateRunningOrStopped(instanceStack)...)
+}
+
+// validateOpenStackFieldsUnset rejects the OpenStack server fields on an
+// InstanceStack of another provider, whose settings live in spec.<block>.
+func validateOpenStackFieldsUnset(instanceStack *infrastructurev1alpha1.InstanceStack, block string, provider infrastructurev1alpha1.ProviderType) field.ErrorList {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+	for _, f := range []struct{ name, value string }{
+		{"flavorName", instanceStack.Spec.FlavorName},
+		{"imageName", instanceStack.Spec.ImageName},
+		{"networkUUID", instanceStack.Spec.NetworkUUID},
+		{"availabilityZone", instanceStack.Spec.AvailabilityZone},
+	} {
+		if f.value != "" {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child(f.name),
+				fmt.Sprintf("OpenStack field; use spec.%s for %s ProviderConfigs", block, provider)))
+		}
+	}
+	return allErrs
+}
+
+// validateRunningOrStopped rejects the power states only OpenStack servers
+// support.
+func validateRunningOrStopped(instanceStack *infrastructurev1alpha1.InstanceStack) field.ErrorList {
+	if powerState := instanceStack.Spec.PowerState; powerState != "" &&
+		powerState != infrastructurev1alpha1.PowerStateRunning && powerState != infrastructurev1alpha1.PowerStateStopped {
+		return field.ErrorList{field.NotSupported(field.NewPath("spec", "powerState"), powerState,
+			[]infrastructurev1alpha1.PowerState{infrastructurev1alpha1.PowerStateRunning, infrastructurev1alpha1.PowerStateStopped})}
+	}
+	return nil
+}
+
+// validateDependencies rejects an InstanceStack depending on itself and
+// readiness gates on the Ready condition the gates feed into.
+func validateDependencies(instanceStack *infrastructurev1alpha1.InstanceStack) field.ErrorList {
+	var allErrs field.ErrorList
+	dependsOnPath := field.NewPath("spec", "dependsOn")
+	for i, dep := range instanceStack.Spec.DependsOn {
+		if dep.Name == instanceStack.Name && (dep.Kind == "" || dep.Kind == "InstanceStack") &&
+			(dep.APIVersion == "" || dep.APIVersion == infrastructurev1alpha1.GroupVersion.String()) {
+			allErrs = append(allErrs, field.Invalid(dependsOnPath.Index(i), dep.Name, "an InstanceStack cannot depend on itself"))
+		}
+	}
+	gatesPath := field.NewPath("spec", "readinessGates")
+	for i, gate := range instanceStack.Spec.ReadinessGates {
+		if gate.ConditionType == infrastructurev1alpha1.ConditionReady {
+			allErrs = append(allErrs, field.Invalid(gatesPath.Index(i).Child("conditionType"), gate.ConditionType,
+				"the Ready condition is computed from the readiness gates"))
+		}
+	}
+	return allErrs
+}
+
+// setsParameter reports whether a parameter is given directly or through an
+// output reference.
+func setsParameter(instanceStack *infrastructurev1alpha1.InstanceStack, name string) bool {
+	if _, ok := instanceStack.Spec.Parameters[name]; ok {
+		return true
+	}
+	for _, ref := range instanceStack.Spec.OutputRefs {
+		if ref.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// validateCatalog reports references missing from the catalog, suggesting
+// close matches. Without a snapshot nothing is checked.
+func (v *InstanceStackCustomValidator) validateCatalog(ctx context.Context, instanceStack *infrastructurev1alpha1.InstanceStack) field.ErrorList {
+	if prog, ok := program.Lookup(instanceStack.Spec.Program); !ok || !prog.ServerSpec {
+		return nil
+	}
+	snapshot := v.Catalog.GetRegion(ctx, instanceStack.Spec.ProviderConfigName, instanceStack.Spec.Region)
+	if snapshot == nil {
+		return nil
+	}
+
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+	ref := catalog.Reference{
+		FlavorName:       instanceStack.Spec.FlavorName,
+		ImageName:        instanceStack.Spec.ImageName,
+		NetworkUUID:      instanceStack.Spec.NetworkUUID,
+		AvailabilityZone: instanceStack.Spec.AvailabilityZone,
+	}
+	if setsParameter(instanceStack, "networkID") {
+		ref.NetworkUUID = ""
+	}
+	for _, problem := range snapshot.Check(ref) {
+		allErrs = append(allErrs, &field.Error{
+			Type:     field.ErrorTypeNotFound,
+			Field:    specPath.Child(problem.Field).String(),
+			BadValue: problem.Value,
+			Detail:   problem.String(),
+		})
+	}
+	return allErrs
+}
+
+// validateRegion rejects a spec.region the ProviderConfig does not list.
+func (v *InstanceStackCustomValidator) validateRegion(ctx context.Context, instanceStack *infrastructurev1alpha1.InstanceStack) (field.ErrorList, error) {
+	err := openstack.CheckRegion(ctx, v.Client, instanceStack.Spec.ProviderConfigName, instanceStack.Spec.Region)
+	if errors.Is(err, openstack.ErrRegionNotAllowed) {
+		return field.ErrorList{field.Invalid(field.NewPath("spec", "region"), instanceStack.Spec.Region, err.Error())}, nil
+	}
+	return nil, err
+}
+
+// validateProvider rejects a program written for another provider than the
+// one of the ProviderConfig.
+func (v *InstanceStackCustomValidator) validateProvider(ctx context.Context, instanceStack *infrastructurev1alpha1.InstanceStack) (field.ErrorList, error) {
+	prog, ok := program.Lookup(instanceStack.Spec.Program)
+	if !ok {
+		return nil, nil
+	}
+	providerType, err := providerTypeOf(ctx, v.Client, instanceStack.Spec.ProviderConfigName)
+	if err != nil {
+		return nil, err
+	}
+	if err := prog.CheckProvider(providerType); err != nil {
+		return field.ErrorList{err}, nil
+	}
+	return nil, nil
+}
+
+// providerTypeOf returns the ProviderType of a ProviderConfig. A missing
+// ProviderConfig is treated as OpenStack, like the controller does.
+func providerTypeOf(ctx context.Context, c client.Reader, providerConfigName string) (infrastructurev1alpha1.ProviderType, error) {
+	if providerConfigName == "" {
+		providerConfigName = infrastructurev1alpha1.DefaultProviderConfigName
+	}
+	providerConfig := &infrastructurev1alpha1.ProviderConfig{}
+	if err := c.Get(ctx, types.NamespacedName{Name: providerConfigName}, providerConfig); err != nil {
+		if apierrors.IsNotFound(err) {
+			return infrastructurev1alpha1.ProviderOpenStack, nil
+		}
+		return "", fmt.Errorf("failed to get ProviderConfig %q: %w", providerConfigName, err)
+	}
+	return providerConfig.ProviderType(), nil
+}
+
+// validateImmutableFields rejects changes that would make Pulumi replace the
+// server unless the object carries the allow-replacement annotation.
+func validateImmutableFields(oldObj, newObj *infrastructurev1alpha1.InstanceStack) field.ErrorList {
+	allErrs := validateImportID(oldObj, newObj)
+	if newObj.Annotations[infrastructurev1alpha1.AllowReplacementAnnotation] == "true" {
+		return allErrs
+	}
+
+	specPath := field.NewPath("spec")
+	msg := fmt.Sprintf("field is immutable because changing it replaces the server; set the %s=true annotation to allow it",
+		infrastructurev1alpha1.AllowReplacementAnnotation)
+
+	if providerConfigNameOf(oldObj) != providerConfigNameOf(newObj) {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("providerConfigName"), msg))
+	}
+	if oldObj.Spec.MachineClassName != newObj.Spec.MachineClassName {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("machineClassName"), msg))
+	}
+	if oldObj.Spec.ImageName != newObj.Spec.ImageName {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("imageName"), msg))
+	}
+	if oldObj.Spec.NetworkUUID != newObj.Spec.NetworkUUID {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("networkUUID"), msg))
+	}
+	if oldObj.Spec.AvailabilityZone != newObj.Spec.AvailabilityZone {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("availabilityZone"), msg))
+	}
+	if oldObj.Spec.Region != newObj.Spec.Region {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("region"), msg))
+	}
+	if oldAWS, newAWS := oldObj.Spec.AWS, newObj.Spec.AWS; oldAWS != nil && newAWS != nil {
+		awsPath := specPath.Child("aws")
+		if oldAWS.AMI != newAWS.AMI {
+			allErrs = append(allErrs, field.Forbidden(awsPath.Child("ami"), msg))
+		}
+		if oldAWS.SubnetID != newAWS.SubnetID {
+			allErrs = append(allErrs, field.Forbidden(awsPath.Child("subnetID"), msg))
+		}
+		if oldAWS.KeyName != newAWS.KeyName {
+			allErrs = append(allErrs, field.Forbidden(awsPath.Child("keyName"), msg))
+		}
+	}
+	if oldLibvirt, newLibvirt := oldObj.Spec.Libvirt, newObj.Spec.Libvirt; oldLibvirt != nil && newLibvirt != nil {
+		libvirtPath := specPath.Child("libvirt")
+		if oldLibvirt.Image != newLibvirt.Image {
+			allErrs = append(allErrs, field.Forbidden(libvirtPath.Child("image"), msg))
+		}
+		if oldLibvirt.Pool != newLibvirt.Pool {
+			allErrs = append(allErrs, field.Forbidden(libvirtPath.Child("pool"), msg))
+		}
+	}
+	return allErrs
+}
+
+// validateImportID rejects changing the import ID once a server has been
+// adopted; the stack already owns that server.
+func validateImportID(oldObj, newObj *infrastructurev1alpha1.InstanceStack) field.ErrorList {
+	if oldObj.Status.ImportedID == "" || newObj.EffectiveImportID() == oldObj.EffectiveImportID() {
+		return nil
+	}
+	return field.ErrorList{field.Forbidden(field.NewPath("spec", "importID"),
+		fmt.Sprintf("server %s has already been imported", oldObj.Status.ImportedID))}
+}
+
+// providerConfigNameOf treats an unset providerConfigName as the default one,
+// so objects created before defaulting existed can still be updated.
+func providerConfigNameOf(instanceStack *infrastructurev1alpha1.InstanceStack) string {
+	if instanceStack.Spec.ProviderConfigName == "" {
+		return infrastructurev1alpha1.DefaultProviderConfigName
+	}
+	return instanceStack.Spec.ProviderConfigName
+}
+
+func toInvalid(instanceStack *infrastructurev1alpha1.InstanceStack, allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(infrastructurev1alpha1.GroupVersion.WithKind("InstanceStack").GroupKind(),
+		instanceStack.Name, allErrs)
+}
@@ -0,0 +1,495 @@
+package v1alpha1
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	infrastructurev1alpha1 "github.com/gunniLee/cloud-provider-operator/api/v1alpha1"
+	"github.com/gunniLee/cloud-provider-operator/internal/catalog"
+	"github.com/gunniLee/cloud-provider-operator/internal/openstack"
+	"github.com/gunniLee/cloud-provider-operator/internal/program"
+)
+
+var _ = Describe("InstanceStack Webhook", func() {
+	const (
+		networkUUID      = "0a7e0885-9deb-45c6-bfeb-d28821d8d3d3"
+		otherNetworkUUID = "5d1c8a8e-3c1f-4b9a-9b8e-2f0b1a6f7c44"
+	)
+
+	newInstanceStack := func(namespace, name string) *infrastructurev1alpha1.InstanceStack {
+		return &infrastructurev1alpha1.InstanceStack{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec: infrastructurev1alpha1.InstanceStackSpec{
+				FlavorName:  "m1.small",
+				ImageName:   "ubuntu-22.04",
+				NetworkUUID: networkUUID,
+			},
+		}
+	}
+
+	Context("When creating InstanceStack under Defaulting Webhook", func() {
+		It("Should fill empty fields from the ProviderConfig and namespace annotations", func() {
+			By("creating a default ProviderConfig")
+			providerConfig := &infrastructurev1alpha1.ProviderConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: infrastructurev1alpha1.DefaultProviderConfigName},
+				Spec: infrastructurev1alpha1.ProviderConfigSpec{
+					Defaults: infrastructurev1alpha1.InstanceDefaults{
+						FlavorName:  "pc-flavor",
+						ImageName:   "pc-image",
+						NetworkUUID: networkUUID,
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, providerConfig)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, providerConfig)).To(Succeed()) })
+
+			By("creating a namespace that overrides the flavor")
+			ns := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "defaulting",
+					Annotations: map[string]string{
+						infrastructurev1alpha1.DefaultFlavorNameAnnotation: "ns-flavor",
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, ns)).To(Succeed())
+
+			instanceStack := newInstanceStack(ns.Name, "defaulted")
+			instanceStack.Spec = infrastructurev1alpha1.InstanceStackSpec{}
+			Expect(k8sClient.Create(ctx, instanceStack)).To(Succeed())
+
+			created := &infrastructurev1alpha1.InstanceStack{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: ns.Name, Name: "defaulted"}, created)).To(Succeed())
+			Expect(created.Spec.ProviderConfigName).To(Equal(infrastructurev1alpha1.DefaultProviderConfigName))
+			Expect(created.Spec.FlavorName).To(Equal("ns-flavor"))
+			Expect(created.Spec.ImageName).To(Equal("pc-image"))
+			Expect(created.Spec.NetworkUUID).To(Equal(networkUUID))
+			Expect(k8sClient.Delete(ctx, created)).To(Succeed())
+		})
+	})
+
+	Context("When creating or updating InstanceStack under Validating Webhook", func() {
+		It("Should deny creation if required fields cannot be defaulted", func() {
+			instanceStack := newInstanceStack("default", "missing-flavor")
+			instanceStack.Spec.FlavorName = ""
+			err := k8sClient.Create(ctx, instanceStack)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.flavorName"))
+		})
+
+		It("Should deny creation if the network UUID is malformed", func() {
+			instanceStack := newInstanceStack("default", "bad-network")
+			instanceStack.Spec.NetworkUUID = "test-network-uuid"
+			err := k8sClient.Create(ctx, instanceStack)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.networkUUID"))
+		})
+
+		It("Should deny names missing from the catalog and suggest close matches", func() {
+			catalogCache.Set(infrastructurev1alpha1.DefaultProviderConfigName, &catalog.Snapshot{
+				Flavors:  []openstack.Flavor{{Name: "m1.small"}},
+				Images:   []openstack.Image{{Name: "ubuntu-22.04", Status: "active"}},
+				Networks: []openstack.Network{{ID: networkUUID, Name: "private"}},
+			})
+			DeferCleanup(func() { catalogCache.Set(infrastructurev1alpha1.DefaultProviderConfigName, nil) })
+
+			Expect(k8sClient.Create(ctx, newInstanceStack("default", "in-catalog"))).To(Succeed())
+			Expect(k8sClient.Delete(ctx, newInstanceStack("default", "in-catalog"))).To(Succeed())
+
+			instanceStack := newInstanceStack("default", "typo")
+			instanceStack.Spec.ImageName = "ubuntu-22.4"
+			err := k8sClient.Create(ctx, instanceStack)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring(`did you mean "ubuntu-22.04"?`))
+		})
+
+		It("Should only check the catalog when a checked field changes", func() {
+			catalogCache.Set(infrastructurev1alpha1.DefaultProviderConfigName, &catalog.Snapshot{
+				Flavors:  []openstack.Flavor{{Name: "m1.small"}},
+				Images:   []openstack.Image{{Name: "ubuntu-22.04", Status: "active"}},
+				Networks: []openstack.Network{{ID: networkUUID, Name: "private"}},
+			})
+			DeferCleanup(func() { catalogCache.Set(infrastructurev1alpha1.DefaultProviderConfigName, nil) })
+			instanceStack := newInstanceStack("default", "retired-image")
+			Expect(k8sClient.Create(ctx, instanceStack)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, instanceStack)).To(Succeed()) })
+
+			By("retiring the image")
+			catalogCache.Set(infrastructurev1alpha1.DefaultProviderConfigName, &catalog.Snapshot{
+				Flavors:  []openstack.Flavor{{Name: "m1.small"}, {Name: "m1.large"}},
+				Images:   []openstack.Image{{Name: "ubuntu-22.04", Status: "deactivated"}},
+				Networks: []openstack.Network{{ID: networkUUID, Name: "private"}},
+			})
+			instanceStack.Labels = map[string]string{"team": "web"}
+			Expect(k8sClient.Update(ctx, instanceStack)).To(Succeed())
+
+			instanceStack.Spec.FlavorName = "m1.large"
+			err := k8sClient.Update(ctx, instanceStack)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.imageName"))
+		})
+
+		It("Should deny replacing fields unless the allow-replacement annotation is set", func() {
+			instanceStack := newInstanceStack("default", "immutable")
+			Expect(k8sClient.Create(ctx, instanceStack)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, instanceStack)).To(Succeed()) })
+
+			By("resizing the flavor, which is allowed")
+			instanceStack.Spec.FlavorName = "m1.large"
+			Expect(k8sClient.Update(ctx, instanceStack)).To(Succeed())
+
+			By("changing the network without the annotation")
+			instanceStack.Spec.NetworkUUID = otherNetworkUUID
+			err := k8sClient.Update(ctx, instanceStack)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.networkUUID"))
+
+			By("changing the network with the annotation")
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: "immutable"}, instanceStack)).To(Succeed())
+			instanceStack.Annotations = map[string]string{infrastructurev1alpha1.AllowReplacementAnnotation: "true"}
+			instanceStack.Spec.NetworkUUID = otherNetworkUUID
+			Expect(k8sClient.Update(ctx, instanceStack)).To(Succeed())
+		})
+
+		It("Should let an InstanceStack stored with an invalid spec drop its finalizer", func() {
+			stored := newInstanceStack("default", "legacy")
+			stored.Spec.NetworkUUID = "test-network-uuid"
+			stored.Finalizers = []string{"instancestack.finalizers.cloudprovider.io"}
+			validator := &InstanceStackCustomValidator{Client: k8sClient}
+
+			By("updating only the metadata")
+			labeled := stored.DeepCopy()
+			labeled.Labels = map[string]string{"team": "web"}
+			_, err := validator.ValidateUpdate(ctx, stored, labeled)
+			Expect(err).NotTo(HaveOccurred())
+
+			By("removing the finalizer once deleted")
+			now := metav1.Now()
+			stored.DeletionTimestamp = &now
+			released := stored.DeepCopy()
+			released.Finalizers = nil
+			_, err = validator.ValidateUpdate(ctx, stored, released)
+			Expect(err).NotTo(HaveOccurred())
+
+			By("still validating a changed spec")
+			changed := labeled.DeepCopy()
+			changed.Spec.FlavorName = "m1.large"
+			_, err = validator.ValidateUpdate(ctx, labeled, changed)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.networkUUID"))
+		})
+
+		It("Should validate parameters against the program schema", func() {
+			instanceStack := newInstanceStack("default", "bad-volume")
+			instanceStack.Spec = infrastructurev1alpha1.InstanceStackSpec{
+				Program:    "volume",
+				Parameters: map[string]apiextensionsv1.JSON{"sizeGiB": {Raw: []byte(`"large"`)}},
+			}
+			err := k8sClient.Create(ctx, instanceStack)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.parameters[sizeGiB]"))
+
+			By("not requiring server fields for other programs")
+			instanceStack.Spec.Parameters["sizeGiB"] = apiextensionsv1.JSON{Raw: []byte(`20`)}
+			Expect(k8sClient.Create(ctx, instanceStack)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, instanceStack)).To(Succeed())
+		})
+
+		It("Should deny changing the import ID once the server has been imported", func() {
+			instanceStack := newInstanceStack("default", "imported")
+			instanceStack.Spec.ImportID = "8c2b5b8e-6f4e-4d6a-a7a1-0d5b1f3f6a10"
+			Expect(k8sClient.Create(ctx, instanceStack)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, instanceStack)).To(Succeed()) })
+
+			instanceStack.Status.ImportedID = instanceStack.Spec.ImportID
+			Expect(k8sClient.Status().Update(ctx, instanceStack)).To(Succeed())
+
+			instanceStack.Spec.ImportID = "2f6f0c1e-9b7d-4c55-8f0e-6c1d2a3b4c5d"
+			err := k8sClient.Update(ctx, instanceStack)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.importID"))
+		})
+		It("Should deny unknown reboot types", func() {
+			instanceStack := newInstanceStack("default", "reboot")
+			instanceStack.Annotations = map[string]string{infrastructurev1alpha1.RebootAnnotation: "now"}
+			err := k8sClient.Create(ctx, instanceStack)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring(infrastructurev1alpha1.RebootAnnotation))
+		})
+
+		It("Should deny an InstanceStack depending on itself", func() {
+			instanceStack := newInstanceStack("default", "loop")
+			instanceStack.Spec.DependsOn = []infrastructurev1alpha1.Dependency{{Name: "loop"}}
+			err := k8sClient.Create(ctx, instanceStack)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.dependsOn[0]"))
+		})
+	})
+
+	Context("When deleting InstanceStack under Validating Webhook", func() {
+		It("Should require confirmation to delete a protected InstanceStack", func() {
+			instanceStack := newInstanceStack("default", "protected")
+			instanceStack.Spec.DeletionProtection = true
+			Expect(k8sClient.Create(ctx, instanceStack)).To(Succeed())
+
+			err := k8sClient.Delete(ctx, instanceStack)
+			Expect(apierrors.IsForbidden(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring(infrastructurev1alpha1.ConfirmDeletionAnnotation))
+
+			instanceStack.Annotations = map[string]string{infrastructurev1alpha1.ConfirmDeletionAnnotation: "protected"}
+			Expect(k8sClient.Update(ctx, instanceStack)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, instanceStack)).To(Succeed())
+		})
+
+		It("Should deny deleting an InstanceStack whose outputs are referenced", func() {
+			bastion := newInstanceStack("default", "bastion")
+			Expect(k8sClient.Create(ctx, bastion)).To(Succeed())
+
+			app := newInstanceStack("default", "app")
+			app.Spec.Parameters = map[string]apiextensionsv1.JSON{"userData": {Raw: []byte(`"BASTION=$(bastionIP)"`)}}
+			app.Spec.OutputRefs = []infrastructurev1alpha1.OutputReference{
+				{Name: "bastionIP", StackName: "bastion", Output: "instanceIP"},
+			}
+			Expect(k8sClient.Create(ctx, app)).To(Succeed())
+
+			err := k8sClient.Delete(ctx, bastion)
+			Expect(apierrors.IsForbidden(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("referenced by app"))
+
+			Expect(k8sClient.Delete(ctx, app)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, bastion)).To(Succeed())
+		})
+
+		It("Should keep InstanceStacks of a bound namespace in its project", func() {
+			binding := &infrastructurev1alpha1.TenantBinding{
+				ObjectMeta: metav1.ObjectMeta{Name: "default-tenant"},
+				Spec: infrastructurev1alpha1.TenantBindingSpec{
+					Namespaces:                     []string{"default"},
+					ProviderConfigName:             "tenant-cloud",
+					ProjectName:                    "team-a",
+					ApplicationCredentialSecretRef: infrastructurev1alpha1.SecretReference{Namespace: "default", Name: "team-a"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, binding)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, binding)).To(Succeed()) })
+
+			By("defaulting to the ProviderConfig of the binding")
+			defaulted := newInstanceStack("default", "tenant-defaulted")
+			Expect(k8sClient.Create(ctx, defaulted)).To(Succeed())
+			Expect(defaulted.Spec.ProviderConfigName).To(Equal("tenant-cloud"))
+			Expect(k8sClient.Delete(ctx, defaulted)).To(Succeed())
+
+			By("refusing another ProviderConfig")
+			other := newInstanceStack("default", "tenant-other")
+			other.Spec.ProviderConfigName = infrastructurev1alpha1.DefaultProviderConfigName
+			err := k8sClient.Create(ctx, other)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring(`bound to ProviderConfig "tenant-cloud"`))
+		})
+
+		It("Should only allow the regions of the ProviderConfig", func() {
+			providerConfig := &infrastructurev1alpha1.ProviderConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: "multi-region"},
+				Spec: infrastructurev1alpha1.ProviderConfigSpec{
+					Region:  "RegionOne",
+					Regions: []string{"RegionTwo"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, providerConfig)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, providerConfig)).To(Succeed()) })
+
+			instanceStack := newInstanceStack("default", "regional")
+			instanceStack.Spec.ProviderConfigName = "multi-region"
+			instanceStack.Spec.Region = "RegionThree"
+			err := k8sClient.Create(ctx, instanceStack)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.region"))
+
+			instanceStack.Spec.Region = "RegionTwo"
+			Expect(k8sClient.Create(ctx, instanceStack)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, instanceStack)).To(Succeed()) })
+
+			By("moving it to another region without the allow-replacement annotation")
+			instanceStack.Spec.Region = "RegionOne"
+			err = k8sClient.Update(ctx, instanceStack)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("field is immutable"))
+		})
+
+		It("Should default and validate InstanceStacks of AWS ProviderConfigs", func() {
+			providerConfig := &infrastructurev1alpha1.ProviderConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: "aws"},
+				Spec: infrastructurev1alpha1.ProviderConfigSpec{
+					Provider: infrastructurev1alpha1.ProviderAWS,
+					Region:   "eu-west-1",
+					AWS:      &infrastructurev1alpha1.AWSProviderConfig{Endpoint: "http://localhost:4566"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, providerConfig)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, providerConfig)).To(Succeed()) })
+
+			instanceStack := &infrastructurev1alpha1.InstanceStack{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "ec2"},
+				Spec: infrastructurev1alpha1.InstanceStackSpec{
+					ProviderConfigName: "aws",
+					FlavorName:         "m1.small",
+					PowerState:         infrastructurev1alpha1.PowerStateSuspended,
+				},
+			}
+			err := k8sClient.Create(ctx, instanceStack)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(And(
+				ContainSubstring("spec.aws: Required value"),
+				ContainSubstring("spec.flavorName: Forbidden"),
+				ContainSubstring("spec.powerState: Unsupported value"),
+			))
+
+			instanceStack.Spec.FlavorName = ""
+			instanceStack.Spec.PowerState = infrastructurev1alpha1.PowerStateStopped
+			instanceStack.Spec.AWS = &infrastructurev1alpha1.AWSInstanceSpec{InstanceType: "t3.micro", AMI: "ami-1"}
+			Expect(k8sClient.Create(ctx, instanceStack)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, instanceStack)).To(Succeed()) })
+			Expect(instanceStack.Spec.Program).To(Equal(program.EC2InstanceName))
+
+			By("refusing OpenStack programs and a new AMI")
+			instanceStack.Spec.Program = program.DefaultName
+			err = k8sClient.Update(ctx, instanceStack)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("program is for OpenStack ProviderConfigs"))
+
+			instanceStack.Spec.Program = program.EC2InstanceName
+			instanceStack.Spec.AWS.AMI = "ami-2"
+			err = k8sClient.Update(ctx, instanceStack)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.aws.ami: Forbidden"))
+		})
+
+		It("Should default and validate InstanceStacks of Libvirt ProviderConfigs", func() {
+			providerConfig := &infrastructurev1alpha1.ProviderConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: "libvirt"},
+				Spec: infrastructurev1alpha1.ProviderConfigSpec{
+					Provider: infrastructurev1alpha1.ProviderLibvirt,
+					Libvirt:  &infrastructurev1alpha1.LibvirtProviderConfig{URI: "qemu:///session"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, providerConfig)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, providerConfig)).To(Succeed()) })
+
+			instanceStack := &infrastructurev1alpha1.InstanceStack{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "domain"},
+				Spec: infrastructurev1alpha1.InstanceStackSpec{
+					ProviderConfigName: "libvirt",
+					ImageName:          "ubuntu-22.04",
+					ImportID:           "4e1f6e2c-8d3a-4f0e-9d1b-2a7c5b6e8f90",
+					AWS:                &infrastructurev1alpha1.AWSInstanceSpec{InstanceType: "t3.micro", AMI: "ami-1"},
+				},
+			}
+			err := k8sClient.Create(ctx, instanceStack)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(And(
+				ContainSubstring("spec.libvirt: Required value"),
+				ContainSubstring("spec.imageName: Forbidden: OpenStack field; use spec.libvirt for Libvirt ProviderConfigs"),
+				ContainSubstring("spec.importID: Forbidden"),
+				ContainSubstring("spec.aws: Forbidden"),
+			))
+
+			instanceStack.Spec.ImageName = ""
+			instanceStack.Spec.ImportID = ""
+			instanceStack.Spec.AWS = nil
+			instanceStack.Spec.Libvirt = &infrastructurev1alpha1.LibvirtInstanceSpec{Image: "/var/lib/libvirt/images/jammy.qcow2"}
+			Expect(k8sClient.Create(ctx, instanceStack)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, instanceStack)).To(Succeed()) })
+			Expect(instanceStack.Spec.Program).To(Equal(program.LibvirtDomainName))
+			Expect(instanceStack.Spec.Libvirt.Pool).To(Equal("default"))
+
+			By("refusing a new image")
+			instanceStack.Spec.Libvirt.Image = "/var/lib/libvirt/images/noble.qcow2"
+			err = k8sClient.Update(ctx, instanceStack)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.libvirt.image: Forbidden"))
+		})
+
+		It("Should take the flavor, image and network from a MachineClass", func() {
+			instanceStack := &infrastructurev1alpha1.InstanceStack{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "classy"},
+				Spec:       infrastructurev1alpha1.InstanceStackSpec{MachineClassName: "ubuntu-medium"},
+			}
+			err := k8sClient.Create(ctx, instanceStack)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.machineClassName: Not found"))
+
+			machineClass := &infrastructurev1alpha1.MachineClass{
+				ObjectMeta: metav1.ObjectMeta{Name: "ubuntu-medium"},
+				Spec: infrastructurev1alpha1.MachineClassSpec{
+					Size:  "medium",
+					Image: "ubuntu-22.04",
+					Mappings: []infrastructurev1alpha1.MachineMapping{
+						{ProviderConfigName: "default", FlavorName: "m1.medium", ImageName: "ubuntu-22.04", NetworkUUID: "not-a-uuid"},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, machineClass)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, machineClass)).To(Succeed()) })
+
+			By("validating the mapped values")
+			err = k8sClient.Create(ctx, instanceStack)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.networkUUID: Invalid value"))
+
+			machineClass.Spec.Mappings[0].NetworkUUID = networkUUID
+			Expect(k8sClient.Update(ctx, machineClass)).To(Succeed())
+			Expect(k8sClient.Create(ctx, instanceStack)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, instanceStack)).To(Succeed()) })
+			Expect(instanceStack.Spec.FlavorName).To(BeEmpty(), "namespace and ProviderConfig defaults do not apply")
+
+			By("refusing another class without the allow-replacement annotation")
+			instanceStack.Spec.MachineClassName = "ubuntu-large"
+			err = k8sClient.Update(ctx, instanceStack)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(And(
+				ContainSubstring("spec.machineClassName: Forbidden"),
+				ContainSubstring("spec.machineClassName: Not found"),
+			))
+		})
+
+		It("Should deny InstanceStacks that exceed an InstanceBudget", func() {
+			catalogCache.Set(infrastructurev1alpha1.DefaultProviderConfigName, &catalog.Snapshot{
+				Flavors:  []openstack.Flavor{{Name: "m1.small", VCPUs: 2, RAM: 2048}, {Name: "m1.large", VCPUs: 8, RAM: 16384}},
+				Images:   []openstack.Image{{Name: "ubuntu-22.04", Status: "active"}},
+				Networks: []openstack.Network{{ID: networkUUID, Name: "private"}},
+			})
+			DeferCleanup(func() { catalogCache.Set(infrastructurev1alpha1.DefaultProviderConfigName, nil) })
+
+			vcpus := int64(4)
+			budget := &infrastructurev1alpha1.InstanceBudget{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "team"},
+				Spec:       infrastructurev1alpha1.InstanceBudgetSpec{VCPUs: &vcpus},
+			}
+			Expect(k8sClient.Create(ctx, budget)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, budget)).To(Succeed()) })
+
+			web := newInstanceStack("default", "budget-web")
+			Expect(k8sClient.Create(ctx, web)).To(Succeed())
+
+			large := newInstanceStack("default", "budget-large")
+			large.Spec.FlavorName = "m1.large"
+			err := k8sClient.Create(ctx, large)
+			Expect(apierrors.IsForbidden(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("InstanceBudget team: vcpus: 10 of 4"))
+
+			// 사용량이 늘지 않는 변경은 예산과 상관없이 허용
+			vcpus = 1
+			Expect(k8sClient.Update(ctx, budget)).To(Succeed())
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: "budget-web"}, web)).To(Succeed())
+			web.Labels = map[string]string{"team": "a"}
+			Expect(k8sClient.Update(ctx, web)).To(Succeed())
+
+			Expect(k8sClient.Delete(ctx, web)).To(Succeed())
+		})
+	})
+})